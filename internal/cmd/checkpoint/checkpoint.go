@@ -0,0 +1,114 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package checkpoint contains commands to inspect and change the
+// consistent points of replication loops while they are stopped.
+package checkpoint
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/checkpoint"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/spf13/cobra"
+)
+
+// Command returns the checkpoint subcommand.
+func Command() *cobra.Command {
+	cfg := &checkpoint.Config{}
+	var asJSON bool
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Short: "list the consistent points of replication loops",
+		Use:   "checkpoint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cfg.Preflight(); err != nil {
+				return err
+			}
+			// main.go provides a stopper.
+			ctx := stopper.From(cmd.Context())
+			pool, err := cfg.Open(ctx)
+			if err != nil {
+				return err
+			}
+			checkpoints, err := cfg.ReadCheckpoints(ctx, pool)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				return writeJSON(cmd.OutOrStdout(), checkpoints)
+			}
+			return checkpoint.WriteCheckpoints(cmd.OutOrStdout(), checkpoints)
+		},
+	}
+	cfg.Bind(cmd.PersistentFlags())
+	cmd.PersistentFlags().BoolVar(&asJSON, "json", false, "write the output as JSON")
+	cmd.AddCommand(setCommand(cfg, &asJSON))
+	return cmd
+}
+
+// setCommand returns a command to change a loop's consistent point.
+func setCommand(cfg *checkpoint.Config, asJSON *bool) *cobra.Command {
+	change := &checkpoint.Change{}
+	cmd := &cobra.Command{
+		Args: cobra.ExactArgs(2),
+		Long: `Change the consistent point of a stopped replication loop. The new
+consistent point uses the same format as the --stopAt flag of the
+loop's dialect. The change is only reported unless --apply is set.
+Moving a consistent point backwards requires --rewind.
+
+The loop must be stopped first, since a running loop will overwrite the
+change. A loop whose consistent point was updated within --idleFor is
+assumed to be running and will not be changed unless --force is set.
+This check is weak: it cannot detect a running loop that has had no
+new data to apply, so it is not a substitute for stopping the loop.`,
+		Short: "change the consistent point of a replication loop",
+		Use:   "set <loop> <consistent point>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			change.Loop, change.To = args[0], args[1]
+			if err := cfg.Preflight(); err != nil {
+				return err
+			}
+			if err := change.Preflight(); err != nil {
+				return err
+			}
+			// main.go provides a stopper.
+			ctx := stopper.From(cmd.Context())
+			pool, err := cfg.Open(ctx)
+			if err != nil {
+				return err
+			}
+			result, err := cfg.SetCheckpoint(ctx, pool, change)
+			if err != nil {
+				return err
+			}
+			if *asJSON {
+				return writeJSON(cmd.OutOrStdout(), result)
+			}
+			return result.WriteText(cmd.OutOrStdout())
+		},
+	}
+	change.Bind(cmd.Flags())
+	return cmd
+}
+
+// writeJSON writes an indented representation of the value.
+func writeJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,39 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package multilogical contains a command to run several logical
+// replication loops, described by a config file, in a single process.
+package multilogical
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/source/multilogical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/spf13/cobra"
+)
+
+// Command returns the multilogical subcommand.
+func Command() *cobra.Command {
+	cfg := &multilogical.Config{}
+	return stdlogical.New(&stdlogical.Template{
+		Bind:  cfg.Bind,
+		Short: "run the logical replication loops described by a config file",
+		Start: func(ctx *stopper.Context, cmd *cobra.Command) (any, error) {
+			return multilogical.Start(ctx, cfg)
+		},
+		Use: "multilogical",
+	})
+}
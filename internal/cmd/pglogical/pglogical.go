@@ -28,7 +28,7 @@ import (
 // Command returns the pglogical subcommand.
 func Command() *cobra.Command {
 	cfg := &pglogical.Config{}
-	return stdlogical.New(&stdlogical.Template{
+	ret := stdlogical.New(&stdlogical.Template{
 		Bind:  cfg.Bind,
 		Short: "start a pg logical replication feed",
 		Start: func(ctx *stopper.Context, cmd *cobra.Command) (any, error) {
@@ -36,4 +36,6 @@ func Command() *cobra.Command {
 		},
 		Use: "pglogical",
 	})
+	ret.AddCommand(teardownCommand())
+	return ret
 }
@@ -27,4 +27,5 @@ import (
 func TestCommand(t *testing.T) {
 	r := require.New(t)
 	r.NoError(Command().Help())
+	r.NoError(teardownCommand().Help())
 }
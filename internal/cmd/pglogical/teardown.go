@@ -0,0 +1,77 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/pglogical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// teardownCommand returns a command that drops the replication slot
+// and publication once a migration is complete.
+func teardownCommand() *cobra.Command {
+	var keepPublication bool
+	var publication, slot, sourceConn string
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Short: "drop the replication slot and publication from the source database",
+		Use:   "teardown --sourceConn <url> --slotName <slot> --publicationName <publication>",
+		Example: strings.TrimSpace(`
+# An unused replication slot causes WAL to accumulate in the source
+# database. Stop cdc-sink before running this command.
+cdc-sink pglogical teardown \
+  --sourceConn postgresql://user@source:5432/my_db \
+  --slotName cdc_sink \
+  --publicationName my_pub
+`),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if sourceConn == "" {
+				return errors.New("no sourceConn specified")
+			}
+			if keepPublication {
+				publication = ""
+			}
+			if slot == "" && publication == "" {
+				return errors.New("no slotName or publicationName specified")
+			}
+
+			// main.go provides a stopper.
+			ctx := stopper.From(cmd.Context())
+
+			source, err := stdpool.OpenPgxAsConn(ctx, sourceConn)
+			if err != nil {
+				return errors.Wrap(err, "could not connect to source database")
+			}
+			return pglogical.Teardown(ctx, source, slot, publication)
+		},
+	}
+	f := cmd.Flags()
+	f.BoolVar(&keepPublication, "keepPublication", false,
+		"drop only the replication slot")
+	f.StringVar(&publication, "publicationName", "",
+		"the publication to drop")
+	f.StringVar(&slot, "slotName", "cdc_sink",
+		"the replication slot to drop")
+	f.StringVar(&sourceConn, "sourceConn", "",
+		"the source database's connection string")
+	return cmd
+}
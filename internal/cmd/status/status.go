@@ -0,0 +1,66 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package status contains a command to report the replication state
+// that is stored in the staging database.
+package status
+
+import (
+	"encoding/json"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/checkpoint"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/spf13/cobra"
+)
+
+// Command returns the status subcommand.
+func Command() *cobra.Command {
+	cfg := &checkpoint.Config{}
+	var asJSON bool
+	cmd := &cobra.Command{
+		Args: cobra.NoArgs,
+		Long: `Report the consistent point of each replication loop, the progress of
+each target schema's resolved timestamps, and the number of unapplied
+mutations in each staging table. Only a connection to the staging
+cluster is required, so this may be used while cdc-sink is stopped.`,
+		Short: "report the replication state stored in the staging database",
+		Use:   "status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cfg.Preflight(); err != nil {
+				return err
+			}
+			// main.go provides a stopper.
+			ctx := stopper.From(cmd.Context())
+			pool, err := cfg.Open(ctx)
+			if err != nil {
+				return err
+			}
+			status, err := cfg.ReadStatus(ctx, pool)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(status)
+			}
+			return status.WriteText(cmd.OutOrStdout())
+		},
+	}
+	cfg.Bind(cmd.Flags())
+	cmd.Flags().BoolVar(&asJSON, "json", false, "write the status as JSON")
+	return cmd
+}
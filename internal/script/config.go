@@ -20,17 +20,23 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 )
 
 // Config drives UserScript behavior.
 type Config struct {
-	FS       fs.FS   // A filesystem to load resources fs.
-	MainPath string  // A path, relative to FS that holds the entrypoint.
-	Options  Options // The target for calls to api.setOptions().
+	CacheDir     string        // An optional cache for remote modules.
+	FetchTimeout time.Duration // Limits requests for remote modules.
+	FS           fs.FS         // A filesystem to load resources fs.
+	ImportMap    *ImportMap    // Optional module remapping and pinning.
+	MainPath     string        // A path, relative to FS that holds the entrypoint.
+	Options      Options       // The target for calls to api.setOptions().
 
-	userscript string // An external filesystem path.
+	importMapPath string // An external filesystem path.
+	userscript    string // An external filesystem path.
 }
 
 // Bind adds flags to the set.
@@ -40,6 +46,13 @@ func (c *Config) Bind(f *pflag.FlagSet) {
 	}
 	f.StringVar(&c.userscript, "userscript", "",
 		"the path to a configuration script, see userscript subcommand")
+	f.StringVar(&c.CacheDir, "userscriptCache", "",
+		"a directory in which to cache remote userscript modules for use when the remote "+
+			"host is unavailable")
+	f.DurationVar(&c.FetchTimeout, "userscriptFetchTimeout", defaultFetchTimeout,
+		"the maximum time to wait for a remote userscript module")
+	f.StringVar(&c.importMapPath, "userscriptImportMap", "",
+		"the path to a JSON import map that remaps, pins, or vendors userscript modules")
 }
 
 // Preflight validates the configuration.
@@ -56,5 +69,18 @@ func (c *Config) Preflight() error {
 		c.userscript = ""
 	}
 
+	if c.importMapPath != "" {
+		importMap, err := ReadImportMap(c.importMapPath)
+		if err != nil {
+			return err
+		}
+		c.ImportMap = importMap
+		c.importMapPath = ""
+	}
+
+	if c.FetchTimeout < 0 {
+		return errors.New("userscriptFetchTimeout must be non-negative")
+	}
+
 	return nil
 }
@@ -45,6 +45,24 @@ that produces a CommonJS-compatible output. Please note that, at the
 current time, the JavaScript runtime does not support all ES6+ features,
 especially those related to async behavior.
 
+The typings subcommand will connect to the target database and write
+a .d.ts file that describes the columns of each table in a schema.
+Including that file when compiling a TypeScript userscript allows the
+arguments to api.configureTable() to be checked against the schema.
+
+Modules may also be imported from http:// or https:// URLs. The
+vendor subcommand will download all remote modules into a directory
+next to the userscript and write an import map which pins each module
+to a sha256 digest. An import map may also be written by hand:
+
+  {
+    "imports":   { "lib": "https://some.cdn/lib@1.js" },
+    "integrity": { "https://some.cdn/lib@1.js": "sha256-<base64>" }
+  }
+
+Use the --userscriptCache flag to allow remote modules to be loaded
+from a local cache when the remote host is unavailable.
+
 Re-run this command with the --api flag to print only the .d.ts file.
 `
 
@@ -70,5 +88,6 @@ func HelpCommand() *cobra.Command {
 	}
 	ret.Flags().BoolVar(&justAPI, "api", false,
 		"write just the API .d.ts file to stdout.")
+	ret.AddCommand(typingsCommand(), vendorCommand())
 	return ret
 }
@@ -0,0 +1,264 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// integrityPrefix is the only Subresource Integrity algorithm that we
+// currently support.
+const integrityPrefix = "sha256-"
+
+// defaultFetchTimeout is used if Config.FetchTimeout is unset.
+const defaultFetchTimeout = 30 * time.Second
+
+// An ImportMap controls how modules that are imported by a userscript
+// are located and verified. It is loosely modeled on the HTML import
+// map specification.
+type ImportMap struct {
+	// Imports maps module specifiers onto replacement specifiers. A
+	// key that ends in a slash matches any specifier with that prefix.
+	Imports map[string]string `json:"imports,omitempty"`
+	// Integrity maps absolute module URLs onto a sha256 digest in
+	// Subresource Integrity format (e.g. "sha256-<base64>"). A module
+	// whose contents do not match will not be loaded.
+	Integrity map[string]string `json:"integrity,omitempty"`
+	// Vendored maps remote module URLs onto absolute paths within the
+	// userscript filesystem. A vendored module is never fetched from
+	// the network.
+	Vendored map[string]string `json:"vendored,omitempty"`
+}
+
+// ReadImportMap loads an ImportMap from a JSON file on disk.
+func ReadImportMap(path string) (*ImportMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ret := &ImportMap{}
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	if err := ret.validate(); err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	return ret, nil
+}
+
+// Integrity returns the Subresource Integrity string for the data.
+func Integrity(data []byte) string {
+	sum := sha256.Sum256(data)
+	return integrityPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// WriteFile stores the ImportMap as a JSON file on disk.
+func (m *ImportMap) WriteFile(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(path, append(data, '\n'), 0644))
+}
+
+// resolve applies the Imports mapping to a module specifier. An exact
+// match is preferred, followed by the longest matching prefix.
+func (m *ImportMap) resolve(module string) string {
+	if m == nil || len(m.Imports) == 0 {
+		return module
+	}
+	if found, ok := m.Imports[module]; ok {
+		return found
+	}
+	var bestKey string
+	for key := range m.Imports {
+		if strings.HasSuffix(key, "/") &&
+			strings.HasPrefix(module, key) &&
+			len(key) > len(bestKey) {
+			bestKey = key
+		}
+	}
+	if bestKey == "" {
+		return module
+	}
+	return m.Imports[bestKey] + module[len(bestKey):]
+}
+
+// validate checks the ImportMap for unsupported entries.
+func (m *ImportMap) validate() error {
+	for key, value := range m.Imports {
+		if strings.HasSuffix(key, "/") != strings.HasSuffix(value, "/") {
+			return errors.Errorf("import prefix %q must map to a prefix, got %q", key, value)
+		}
+	}
+	for key, value := range m.Integrity {
+		if !strings.HasPrefix(value, integrityPrefix) {
+			return errors.Errorf("integrity for %s must begin with %q", key, integrityPrefix)
+		}
+	}
+	for key, value := range m.Vendored {
+		if !strings.HasPrefix(value, "/") {
+			return errors.Errorf("vendored path for %s must be absolute, got %q", key, value)
+		}
+	}
+	return nil
+}
+
+// verify checks the data against any pinned digest for the module.
+func (m *ImportMap) verify(key string, data []byte) error {
+	if m == nil {
+		return nil
+	}
+	expected, ok := m.Integrity[key]
+	if !ok {
+		return nil
+	}
+	if actual := Integrity(data); actual != expected {
+		return errors.Errorf("integrity check failed for %s: expected %s, got %s",
+			key, expected, actual)
+	}
+	return nil
+}
+
+// A fetcher retrieves remote modules, consulting the vendored files
+// and the on-disk cache as necessary.
+type fetcher struct {
+	cacheDir  string       // Optional on-disk cache.
+	client    *http.Client // Has a timeout set.
+	fs        fs.FS        // Holds vendored modules.
+	importMap *ImportMap   // May be nil.
+}
+
+// fetch returns the contents of a remote module. Vendored modules are
+// loaded from the filesystem. Pinned modules are loaded from the cache
+// if a matching entry exists. Otherwise, the module is requested and
+// the cache is used as a fallback if the remote host is unavailable.
+func (f *fetcher) fetch(source *url.URL) ([]byte, error) {
+	key := source.String()
+
+	if f.importMap != nil {
+		if vendored, ok := f.importMap.Vendored[key]; ok {
+			data, err := fs.ReadFile(f.fs, strings.TrimPrefix(vendored, "/"))
+			return data, errors.Wrapf(err, "vendored module %s", key)
+		}
+
+		// A pinned module is immutable, so the cache is authoritative.
+		if _, pinned := f.importMap.Integrity[key]; pinned {
+			if data, ok := f.readCache(key); ok && f.importMap.verify(key, data) == nil {
+				log.Tracef("using cached module %s", key)
+				return data, nil
+			}
+		}
+	}
+
+	data, err := f.get(source)
+	if err != nil {
+		cached, ok := f.readCache(key)
+		if !ok {
+			return nil, err
+		}
+		log.WithError(err).Warnf("using cached copy of remote module %s", key)
+		return cached, nil
+	}
+
+	// Don't poison the cache with data that's going to be rejected.
+	if err := f.importMap.verify(key, data); err != nil {
+		return nil, err
+	}
+	f.writeCache(key, data)
+	return data, nil
+}
+
+// get makes a request for the module.
+func (f *fetcher) get(source *url.URL) ([]byte, error) {
+	resp, err := f.client.Get(source.String())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("could not fetch %s: %s", source, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, errors.Wrap(err, source.String())
+}
+
+// cachePath returns the on-disk location of a cached module.
+func (f *fetcher) cachePath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.cacheDir, hex.EncodeToString(sum[:]))
+}
+
+// readCache returns the cached module contents, if any.
+func (f *fetcher) readCache(key string) ([]byte, bool) {
+	if f.cacheDir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(f.cachePath(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// writeCache updates the on-disk cache. Errors are logged, since the
+// cache is only an optimization.
+func (f *fetcher) writeCache(key string, data []byte) {
+	if f.cacheDir == "" {
+		return
+	}
+	if err := os.MkdirAll(f.cacheDir, 0755); err != nil {
+		log.WithError(err).Warn("could not create userscript module cache")
+		return
+	}
+	// Write and rename to avoid leaving partial files behind.
+	dest := f.cachePath(key)
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.WithError(err).Warnf("could not cache module %s", key)
+		return
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		log.WithError(err).Warnf("could not cache module %s", key)
+	}
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys[V any](m map[string]V) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package script
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	remoteLib = `import {dep} from "./dep.js"; export const lib = dep + 1;`
+	remoteDep = `export const dep = 41;`
+)
+
+// remoteServer serves a module that imports a sibling module.
+func remoteServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pkg/lib.js", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(remoteLib))
+	})
+	mux.HandleFunc("/pkg/dep.js", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(remoteDep))
+	})
+	svr := httptest.NewServer(mux)
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+// writeMain creates a userscript that imports the remote module via
+// a bare specifier.
+func writeMain(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.ts"), []byte(`
+import * as api from "cdc-sink@v1";
+import {lib} from "remote";
+api.configureSource("src" + lib, {target: "dest"});
+`), 0644))
+	return dir
+}
+
+func TestImportMapResolve(t *testing.T) {
+	a := assert.New(t)
+
+	var nilMap *ImportMap
+	a.Equal("foo", nilMap.resolve("foo"))
+
+	m := &ImportMap{Imports: map[string]string{
+		"lib":                 "https://cdn/lib@1.js",
+		"https://cdn/":        "https://mirror/",
+		"https://cdn/pinned/": "/local/",
+	}}
+	a.NoError(m.validate())
+	a.Equal("https://cdn/lib@1.js", m.resolve("lib"))
+	a.Equal("https://mirror/other.js", m.resolve("https://cdn/other.js"))
+	a.Equal("/local/x.js", m.resolve("https://cdn/pinned/x.js"))
+	a.Equal("./relative", m.resolve("./relative"))
+
+	a.Error((&ImportMap{Imports: map[string]string{"a/": "b"}}).validate())
+	a.Error((&ImportMap{Integrity: map[string]string{"a": "md5-xyz"}}).validate())
+	a.Error((&ImportMap{Vendored: map[string]string{"a": "relative"}}).validate())
+}
+
+func TestRemoteModules(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	svr := remoteServer(t)
+	libURL := svr.URL + "/pkg/lib.js"
+	depURL := svr.URL + "/pkg/dep.js"
+	dir := writeMain(t)
+	cacheDir := t.TempDir()
+
+	newConfig := func(m *ImportMap) *Config {
+		m.Imports = map[string]string{"remote": libURL}
+		return &Config{
+			CacheDir:  cacheDir,
+			FS:        os.DirFS(dir),
+			ImportMap: m,
+			MainPath:  "/main.ts",
+		}
+	}
+
+	// Load once to populate the cache.
+	l, err := ProvideLoader(newConfig(&ImportMap{}))
+	r.NoError(err)
+	a.Contains(l.sources, "src42")
+	a.Equal(remoteLib, string(l.remotes[libURL]))
+	a.Equal(remoteDep, string(l.remotes[depURL]))
+
+	// A bad pin must prevent the script from loading.
+	_, err = ProvideLoader(newConfig(&ImportMap{
+		Integrity: map[string]string{depURL: Integrity([]byte("tampered"))},
+	}))
+	a.ErrorContains(err, "integrity check failed")
+
+	// Vendor the modules into the script directory.
+	r.NoError((&ImportMap{Imports: map[string]string{"remote": libURL}}).
+		WriteFile(filepath.Join(dir, "importmap.json")))
+	r.NoError(vendor(filepath.Join(dir, "main.ts"), "vendor",
+		filepath.Join(dir, "importmap.json")))
+	m, err := ReadImportMap(filepath.Join(dir, "importmap.json"))
+	r.NoError(err)
+	a.Equal(libURL, m.Imports["remote"])
+	a.Equal(Integrity([]byte(remoteLib)), m.Integrity[libURL])
+	a.Equal(Integrity([]byte(remoteDep)), m.Integrity[depURL])
+	if vendored := m.Vendored[depURL]; a.NotEmpty(vendored) {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(vendored)))
+		a.NoError(err)
+		a.Equal(remoteDep, string(data))
+	}
+
+	// With the remote host down, the cache and the vendored modules
+	// must still be usable.
+	svr.Close()
+
+	cfg := newConfig(&ImportMap{})
+	l, err = ProvideLoader(cfg)
+	r.NoError(err)
+	a.Contains(l.sources, "src42")
+
+	cfg.CacheDir = ""
+	_, err = ProvideLoader(cfg)
+	a.Error(err)
+
+	l, err = ProvideLoader(newConfig(m))
+	r.NoError(err)
+	a.Contains(l.sources, "src42")
+}
+
+func TestRemoteModuleStatus(t *testing.T) {
+	a := assert.New(t)
+
+	svr := httptest.NewServer(http.NotFoundHandler())
+	defer svr.Close()
+
+	dir := writeMain(t)
+	_, err := ProvideLoader(&Config{
+		FS:        os.DirFS(dir),
+		ImportMap: &ImportMap{Imports: map[string]string{"remote": svr.URL + "/missing.js"}},
+		MainPath:  "/main.ts",
+	})
+	a.ErrorContains(err, fmt.Sprintf("could not fetch %s/missing.js: 404", svr.URL))
+}
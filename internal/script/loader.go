@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"net/http"
 	"net/url"
 	"path"
 	"strings"
@@ -50,6 +49,27 @@ type mapJS func(
 	meta map[string]any,
 ) (map[string]any, error)
 
+// A transaction-begin hook.
+//
+//	{ meta } => void
+type txBeginJS func(meta map[string]any) error
+
+// A transaction-commit hook, which may return additional documents in
+// the same form as a dispatch function.
+//
+//	{ meta } => { "target" : [ { doc }, ... ], ... }
+type txCommitJS func(
+	meta map[string]any,
+) (map[string][]map[string]any, error)
+
+// A logical-decoding message hook, which may return documents in the
+// same form as a dispatch function.
+//
+//	{ msg } => { "target" : [ { doc }, ... ], ... }
+type messageJS func(
+	msg map[string]any,
+) (map[string][]map[string]any, error)
+
 // A mergeOp is the input to the user-provided merge function.
 type mergeOp struct {
 	Before   goja.Value     `goja:"before"`   // Backed by bagWrapper. Nil in 2-way case.
@@ -81,6 +101,9 @@ var (
 type sourceJS struct {
 	DeletesTo string     `goja:"deletesTo"`
 	Dispatch  dispatchJS `goja:"dispatch"`
+	OnBegin   txBeginJS  `goja:"onBegin"`
+	OnCommit  txCommitJS `goja:"onCommit"`
+	OnMessage messageJS  `goja:"onMessage"`
 	Recurse   bool       `goja:"recurse"`
 	Target    string     `goja:"target"`
 }
@@ -110,8 +133,11 @@ type targetJS struct {
 // script. It will load all required resources, parse, and execute the
 // top-level API calls.
 type Loader struct {
+	fetcher      *fetcher              // Retrieves remote modules.
 	fs           fs.FS                 // Used by require.
+	importMap    *ImportMap            // Optional module remapping.
 	options      Options               // Target of api.setOptions().
+	remotes      map[string][]byte     // Remote module contents, by URL.
 	requireStack []*url.URL            // Allows relative import paths.
 	requireCache map[string]goja.Value // Keys are URLs.
 	rt           *goja.Runtime         // JS Runtime.
@@ -122,7 +148,9 @@ type Loader struct {
 
 // configureSource is exported to the JS runtime.
 func (l *Loader) configureSource(sourceName string, bag *sourceJS) error {
-	if (bag.Dispatch != nil) == (bag.Target != "") {
+	// A source that only receives messages needs no destination.
+	if bag.Dispatch != nil && bag.Target != "" ||
+		bag.Dispatch == nil && bag.Target == "" && bag.OnMessage == nil {
 		return errors.Errorf("configureSource(%q): one of mapper or target must be set", sourceName)
 	}
 	l.sources[sourceName] = bag
@@ -146,6 +174,9 @@ func (l *Loader) require(module string) (goja.Value, error) {
 		return found, nil
 	}
 
+	// Apply any user-provided remapping of the module name.
+	module = l.importMap.resolve(module)
+
 	// The required path is parsed as a URL, relative to the top of the
 	// require stack.  This allows, for example, a script to be loaded
 	// from an external source which then refers to sibling paths.
@@ -197,19 +228,21 @@ func (l *Loader) require(module string) (goja.Value, error) {
 		}
 
 	case "http", "https":
-		resp, err := http.Get(source.String())
-		if err != nil {
-			return nil, err
-		}
-		data, err = io.ReadAll(resp.Body)
+		data, err = l.fetcher.fetch(source)
 		if err != nil {
 			return nil, err
 		}
+		l.remotes[key] = data
 
 	default:
 		return nil, errors.Errorf("unsupported scheme %s", source.Scheme)
 	}
 
+	// Verify the contents of pinned modules, regardless of origin.
+	if err := l.importMap.verify(key, data); err != nil {
+		return nil, err
+	}
+
 	// These options will create a self-executing closure that provides
 	// the expected ambient symbols for a CommonJS script. The header
 	// assigns a stub object to the global __require_cache map to defuse
@@ -21,6 +21,11 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 )
 
+// MetaLSN is the key used by dialects to record the position of a
+// mutation's transaction within the source's log. It will be copied
+// into the [TxMeta] passed to transaction hooks.
+const MetaLSN = "lsn"
+
 // AddMeta decorates the mutation with a standard set of properties.
 func AddMeta(source string, tbl ident.Table, mut *types.Mutation) {
 	meta := map[string]any{
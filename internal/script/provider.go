@@ -17,6 +17,7 @@
 package script
 
 import (
+	"net/http"
 	"net/url"
 	"sync"
 
@@ -60,9 +61,22 @@ func ProvideLoader(cfg *Config) (*Loader, error) {
 		options = NoOptions
 	}
 
+	timeout := cfg.FetchTimeout
+	if timeout == 0 {
+		timeout = defaultFetchTimeout
+	}
+
 	l := &Loader{
+		fetcher: &fetcher{
+			cacheDir:  cfg.CacheDir,
+			client:    &http.Client{Timeout: timeout},
+			fs:        cfg.FS,
+			importMap: cfg.ImportMap,
+		},
 		fs:           cfg.FS,
+		importMap:    cfg.ImportMap,
 		options:      options,
+		remotes:      make(map[string][]byte),
 		requireCache: make(map[string]goja.Value),
 		rt:           goja.New(),
 		rtMu:         &sync.Mutex{},
@@ -27,6 +27,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/merge"
 	"github.com/dop251/goja"
@@ -59,6 +60,100 @@ var identity Map = func(_ context.Context, mut types.Mutation) (types.Mutation,
 	return mut, true, nil
 }
 
+// A TxBegin function is invoked before the first mutation from a
+// source is dispatched within a source transaction. TxBegin functions
+// are internally synchronized to ensure single-threaded access to the
+// underlying JS VM.
+type TxBegin func(ctx context.Context, meta *TxMeta) error
+
+// A TxCommit function is invoked when a source transaction that
+// contained mutations from a source is about to be committed. It may
+// return additional mutations to be applied within the same target
+// transaction. TxCommit functions are internally synchronized to
+// ensure single-threaded access to the underlying JS VM.
+type TxCommit func(ctx context.Context, meta *TxMeta) (*ident.TableMap[[]types.Mutation], error)
+
+// An OnMessage function is invoked when the source emits an
+// application-defined message, such as one written by PostgreSQL's
+// pg_logical_emit_message(). It may return mutations to be applied
+// within the same target transaction. OnMessage functions are
+// internally synchronized to ensure single-threaded access to the
+// underlying JS VM.
+type OnMessage func(ctx context.Context, msg *Message) (*ident.TableMap[[]types.Mutation], error)
+
+// A Message is an application-defined message that was written into
+// the source's log.
+type Message struct {
+	// The message payload.
+	Content []byte
+	// An optional, dialect-specific log position of the message.
+	LSN string
+	// An application-defined prefix which identifies the kind of
+	// message.
+	Prefix string
+	// The name of the configured source.
+	Source ident.Ident
+	// The effective time of the message, if the source provides HLC
+	// timestamps.
+	Time hlc.Time
+	// True if the message was written as part of a source transaction.
+	Transactional bool
+}
+
+// asJS returns the representation of the Message that is passed to the
+// user-provided function.
+func (m *Message) asJS() map[string]any {
+	ret := map[string]any{
+		"content":       string(m.Content),
+		"logical":       m.Time.Logical(),
+		"nanos":         m.Time.Nanos(),
+		"prefix":        m.Prefix,
+		"source":        m.Source.Raw(),
+		"transactional": m.Transactional,
+	}
+	if m.LSN != "" {
+		ret["lsn"] = m.LSN
+	}
+	return ret
+}
+
+// TxMeta describes a source transaction to the TxBegin and TxCommit
+// functions.
+type TxMeta struct {
+	// An optional, dialect-specific log position of the transaction
+	// (e.g. a PostgreSQL LSN).
+	LSN string
+	// The name of the configured source.
+	Source ident.Ident
+	// The target tables that have received mutations from the source
+	// in this transaction, in the order in which they were first seen.
+	// This will be empty when passed to a TxBegin function.
+	Tables []ident.Table
+	// The latest effective time of the source's mutations in the
+	// transaction. This will be zero if the source does not provide
+	// HLC timestamps.
+	Time hlc.Time
+}
+
+// asJS returns the representation of the TxMeta that is passed to the
+// user-provided functions.
+func (m *TxMeta) asJS() map[string]any {
+	tables := make([]any, len(m.Tables))
+	for idx, tbl := range m.Tables {
+		tables[idx] = tbl.Raw()
+	}
+	ret := map[string]any{
+		"logical": m.Time.Logical(),
+		"nanos":   m.Time.Nanos(),
+		"source":  m.Source.Raw(),
+		"tables":  tables,
+	}
+	if m.LSN != "" {
+		ret["lsn"] = m.LSN
+	}
+	return ret
+}
+
 // A Source holds user-provided configuration options for a
 // generic data-source.
 type Source struct {
@@ -68,6 +163,15 @@ type Source struct {
 	// A user-provided function that routes mutations to zero or more
 	// tables.
 	Dispatch Dispatch `json:"-"`
+	// An optional, user-provided function to call when the source is
+	// first seen in a source transaction.
+	OnBegin TxBegin `json:"-"`
+	// An optional, user-provided function to call before a source
+	// transaction is committed.
+	OnCommit TxCommit `json:"-"`
+	// An optional, user-provided function to call when the source
+	// emits a message.
+	OnMessage OnMessage `json:"-"`
 	// Enable recursion in sources which support nested sources.
 	Recurse bool
 }
@@ -145,9 +249,24 @@ func (s *UserScript) bind(loader *Loader) error {
 			src.DeletesTo = dest
 			src.Dispatch = dispatchTo(dest)
 
+		case bag.OnMessage != nil:
+			// Data from the source will be passed through, but there
+			// is no table to send deletes to.
+
 		default:
 			return errors.Errorf("configureSource(%q): dispatch or target required", sourceName)
 		}
+
+		// Transaction hooks are optional.
+		if bag.OnBegin != nil {
+			src.OnBegin = s.bindTxBegin(bag.OnBegin)
+		}
+		if bag.OnCommit != nil {
+			src.OnCommit = s.bindTxCommit(sourceName, bag.OnCommit)
+		}
+		if bag.OnMessage != nil {
+			src.OnMessage = s.bindOnMessage(sourceName, bag.OnMessage)
+		}
 	}
 
 	// Evaluate calls to api.configureTarget(). As above, we implement a
@@ -219,69 +338,117 @@ func (s *UserScript) bindDispatch(fnName string, dispatch dispatchJS) Dispatch {
 			return nil, err
 		}
 
-		ret := &ident.TableMap[[]types.Mutation]{}
+		return s.toMutations("dispatch function "+fnName, dispatches, mut.Before, mut.Time)
+	}
+}
 
-		// If nothing returned, return an empty map.
-		if len(dispatches) == 0 {
-			return ret, nil
+// bindTxBegin exports a user-provided function as a TxBegin.
+func (s *UserScript) bindTxBegin(onBegin txBeginJS) TxBegin {
+	return func(_ context.Context, meta *TxMeta) error {
+		return s.execJS(func() error {
+			return onBegin(meta.asJS())
+		})
+	}
+}
+
+// bindTxCommit exports a user-provided function as a TxCommit.
+func (s *UserScript) bindTxCommit(sourceName string, onCommit txCommitJS) TxCommit {
+	return func(_ context.Context, meta *TxMeta) (*ident.TableMap[[]types.Mutation], error) {
+		var extra map[string][]map[string]any
+		if err := s.execJS(func() (err error) {
+			extra, err = onCommit(meta.asJS())
+			return err
+		}); err != nil {
+			return nil, err
 		}
 
-		// Serialize mutations back to JSON.
-		for tblName, jsDocs := range dispatches {
-			tbl, _, err := ident.ParseTableRelative(tblName, s.target)
-			if err != nil {
-				return nil, errors.Wrapf(err,
-					"dispatch function returned unparsable table name %q", tblName)
+		return s.toMutations("onCommit function "+sourceName, extra, nil, meta.Time)
+	}
+}
+
+// bindOnMessage exports a user-provided function as an OnMessage.
+func (s *UserScript) bindOnMessage(sourceName string, onMessage messageJS) OnMessage {
+	return func(_ context.Context, msg *Message) (*ident.TableMap[[]types.Mutation], error) {
+		var docs map[string][]map[string]any
+		if err := s.execJS(func() (err error) {
+			docs, err = onMessage(msg.asJS())
+			return err
+		}); err != nil {
+			return nil, err
+		}
+
+		return s.toMutations("onMessage function "+sourceName, docs, nil, msg.Time)
+	}
+}
+
+// toMutations converts the documents returned from a user-provided
+// function into per-table mutations. The description is used to
+// provide context in error messages.
+func (s *UserScript) toMutations(
+	desc string, docs map[string][]map[string]any, before json.RawMessage, ts hlc.Time,
+) (*ident.TableMap[[]types.Mutation], error) {
+	ret := &ident.TableMap[[]types.Mutation]{}
+
+	// If nothing returned, return an empty map.
+	if len(docs) == 0 {
+		return ret, nil
+	}
+
+	// Serialize mutations back to JSON.
+	for tblName, jsDocs := range docs {
+		tbl, _, err := ident.ParseTableRelative(tblName, s.target)
+		if err != nil {
+			return nil, errors.Wrapf(err,
+				"%s returned unparsable table name %q", desc, tblName)
+		}
+		tblMuts := make([]types.Mutation, len(jsDocs))
+		ret.Put(tbl, tblMuts)
+		for idx, rawJsDoc := range jsDocs {
+			// Use a case-insensitive map for lookups.
+			jsDoc := &ident.Map[any]{}
+			for k, v := range rawJsDoc {
+				jsDoc.Put(ident.New(k), v)
 			}
-			tblMuts := make([]types.Mutation, len(jsDocs))
-			ret.Put(tbl, tblMuts)
-			for idx, rawJsDoc := range jsDocs {
-				// Use a case-insensitive map for lookups.
-				jsDoc := &ident.Map[any]{}
-				for k, v := range rawJsDoc {
-					jsDoc.Put(ident.New(k), v)
-				}
 
-				colData, ok := s.watcher.Get().Columns.Get(tbl)
-				if !ok {
-					return nil, errors.Errorf(
-						"dispatch function %s returned unknown table %s", fnName, tbl)
-				}
+			colData, ok := s.watcher.Get().Columns.Get(tbl)
+			if !ok {
+				return nil, errors.Errorf(
+					"%s returned unknown table %s", desc, tbl)
+			}
 
-				// Extract the revised primary key components.
-				var jsKey []any
-				for _, col := range colData {
-					if col.Primary {
-						keyVal, ok := jsDoc.Get(col.Name)
-						if !ok {
-							return nil, errors.Errorf(
-								"dispatch funcion %s omitted value for PK %s", fnName, col.Name)
-						}
-						jsKey = append(jsKey, keyVal)
+			// Extract the revised primary key components.
+			var jsKey []any
+			for _, col := range colData {
+				if col.Primary {
+					keyVal, ok := jsDoc.Get(col.Name)
+					if !ok {
+						return nil, errors.Errorf(
+							"%s omitted value for PK %s", desc, col.Name)
 					}
+					jsKey = append(jsKey, keyVal)
 				}
+			}
 
-				dataBytes, err := json.Marshal(jsDoc)
-				if err != nil {
-					return nil, err
-				}
+			dataBytes, err := json.Marshal(jsDoc)
+			if err != nil {
+				return nil, err
+			}
 
-				keyBytes, err := json.Marshal(jsKey)
-				if err != nil {
-					return nil, err
-				}
+			keyBytes, err := json.Marshal(jsKey)
+			if err != nil {
+				return nil, err
+			}
 
-				tblMuts[idx] = types.Mutation{
-					Before: mut.Before,
-					Data:   dataBytes,
-					Key:    keyBytes,
-					Time:   mut.Time,
-				}
+			tblMuts[idx] = types.Mutation{
+				Before: before,
+				Data:   dataBytes,
+				Key:    keyBytes,
+				Time:   ts,
 			}
 		}
-
-		return ret, nil
 	}
+
+	return ret, nil
 }
 
 // bindMap exports a user-provided function as a Map func.
@@ -26,6 +26,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/merge"
 	"github.com/stretchr/testify/assert"
@@ -86,7 +87,7 @@ func TestScript(t *testing.T) {
 		Options:  &opts,
 	}, TargetSchema(schema))
 	r.NoError(err)
-	a.Equal(3, s.Sources.Len())
+	a.Equal(4, s.Sources.Len())
 	a.Equal(5, s.Targets.Len())
 	a.Equal(map[string]string{"hello": "world"}, opts.data)
 
@@ -115,6 +116,55 @@ func TestScript(t *testing.T) {
 				a.Equal(`[1]`, string(docs[1].Key))
 			}
 		}
+
+		meta := &TxMeta{
+			LSN:    "0/16B3748",
+			Source: ident.New("expander"),
+			Tables: []ident.Table{tbl1, tbl2},
+			Time:   hlc.New(100, 1),
+		}
+		if a.NotNil(cfg.OnBegin) {
+			a.NoError(cfg.OnBegin(context.Background(), meta))
+		}
+		if a.NotNil(cfg.OnCommit) {
+			extra, err := cfg.OnCommit(context.Background(), meta)
+			if a.NoError(err) && a.NotNil(extra) {
+				if docs := extra.GetZero(tbl2); a.Len(docs, 1) {
+					a.Nil(docs[0].Before)
+					a.Equal(`{"dest":"audit","idx":2,"msg":"expander"}`, string(docs[0].Data))
+					a.Equal(`[2]`, string(docs[0].Key))
+					a.Equal(hlc.New(100, 1), docs[0].Time)
+				}
+			}
+		}
+	}
+
+	if cfg := s.Sources.GetZero(ident.New("messages")); a.NotNil(cfg) {
+		a.Nil(cfg.Dispatch)
+		a.Equal(ident.Table{}, cfg.DeletesTo)
+		msg := &Message{
+			Content:       []byte("hello"),
+			LSN:           "0/16B3748",
+			Prefix:        "greeting",
+			Source:        ident.New("messages"),
+			Time:          hlc.New(100, 1),
+			Transactional: true,
+		}
+		if a.NotNil(cfg.OnMessage) {
+			extra, err := cfg.OnMessage(context.Background(), msg)
+			if a.NoError(err) && a.NotNil(extra) {
+				if docs := extra.GetZero(tbl1); a.Len(docs, 1) {
+					a.Equal(`{"dest":"message","msg":"hello","prefix":"greeting"}`, string(docs[0].Data))
+					a.Equal(`["hello"]`, string(docs[0].Key))
+					a.Equal(hlc.New(100, 1), docs[0].Time)
+				}
+			}
+
+			msg.Prefix = "ignore"
+			extra, err = cfg.OnMessage(context.Background(), msg)
+			a.NoError(err)
+			a.Zero(extra.Len())
+		}
 	}
 
 	if cfg := s.Sources.GetZero(ident.New("passthrough")); a.NotNil(cfg) {
@@ -114,12 +114,50 @@ declare module "cdc-sink@v1" {
          * The name of a destination table.
          */
         target: Table
+    } | {
+        /**
+         * A source may omit a destination if it only receives
+         * messages from the replication source.
+         */
+        onMessage: (msg: SourceMessage) => Record<Table, Document[]> | null;
     };
 
     /**
      * @see configureSource
      */
     type ConfigureSourceOptions = {
+        /**
+         * A function to be called when the source is first seen within
+         * a source transaction, before any of the source's documents
+         * are dispatched.
+         *
+         * @param meta - Information about the source transaction.
+         */
+        onBegin: (meta: TransactionMeta) => void;
+        /**
+         * A function to be called before a source transaction that
+         * contained documents from the source is committed. The
+         * returned documents will be applied within the same target
+         * transaction, e.g. to write an audit row or an aggregate of
+         * the transaction.
+         *
+         * @param meta - Information about the source transaction.
+         * @returns A mapping of target table names to documents, or
+         * null if no additional documents should be applied.
+         */
+        onCommit: (meta: TransactionMeta) => Record<Table, Document[]> | null;
+        /**
+         * A function to be called when the replication source emits an
+         * application-defined message, e.g. from PostgreSQL's
+         * <code>pg_logical_emit_message()</code>. Documents returned
+         * from a transactional message will be applied within the same
+         * target transaction as the source transaction.
+         *
+         * @param msg - The message.
+         * @returns A mapping of target table names to documents, or
+         * null if no documents should be applied.
+         */
+        onMessage: (msg: SourceMessage) => Record<Table, Document[]> | null;
         /**
          * Sources which support dynamic sub-collections of data may
          * set the recurse property. This will cause any sub-documents
@@ -128,6 +166,81 @@ declare module "cdc-sink@v1" {
         recurse: boolean;
     }
 
+    /**
+     * Describes a source transaction to the onBegin and onCommit
+     * functions.
+     *
+     * @see ConfigureSourceOptions
+     */
+    type TransactionMeta = {
+        /**
+         * The logical component of the transaction's HLC time, if
+         * provided by the replication source.
+         */
+        logical: number;
+        /**
+         * The log sequence number of the transaction, if provided by
+         * the replication source (e.g. PostgreSQL).
+         */
+        lsn?: string;
+        /**
+         * The wall-time component of the transaction's HLC time, if
+         * provided by the replication source.
+         */
+        nanos: number;
+        /**
+         * The name of the configured source.
+         */
+        source: string;
+        /**
+         * The tables that have received documents from the source in
+         * the transaction. This will be empty in onBegin.
+         */
+        tables: Table[];
+    }
+
+    /**
+     * Describes an application-defined message to the onMessage
+     * function.
+     *
+     * @see ConfigureSourceOptions
+     */
+    type SourceMessage = {
+        /**
+         * The message payload.
+         */
+        content: string;
+        /**
+         * The logical component of the message's HLC time, if provided
+         * by the replication source.
+         */
+        logical: number;
+        /**
+         * The log sequence number of the message, if provided by the
+         * replication source (e.g. PostgreSQL).
+         */
+        lsn?: string;
+        /**
+         * The wall-time component of the message's HLC time, if
+         * provided by the replication source.
+         */
+        nanos: number;
+        /**
+         * An application-defined prefix that identifies the kind of
+         * message.
+         */
+        prefix: string;
+        /**
+         * The name of the configured source.
+         */
+        source: string;
+        /**
+         * True if the message was emitted as part of a source
+         * transaction.
+         */
+        transactional: boolean;
+    }
+
     /**
      * Configure a table within the destination database.
      *
@@ -135,40 +248,66 @@ declare module "cdc-sink@v1" {
      * @param props - Properties to configure the table.
      * @see https://github.com/cockroachdb/cdc-sink#data-application-behaviors
      */
-    function configureTable(
-        tableName: Table,
-        props: Partial<ConfigureTableOptions>): void;
+    function configureTable<N extends Table>(
+        tableName: N,
+        props: Partial<ConfigureTableOptions<RowOf<N>>>): void;
+
+    /**
+     * A registry of row types, keyed by table name. This interface is
+     * empty by default and is extended by the declarations produced by
+     * <code>cdc-sink userscript typings</code>.
+     */
+    interface TableTypes {
+    }
+
+    /**
+     * The row type for a table, or Document if the table's type is not
+     * known.
+     */
+    type RowOf<N extends Table> = N extends keyof TableTypes ? TableTypes[N] : Document;
 
     /**
+     * The names of the columns in a table type. For an untyped
+     * Document, this is any string.
+     *
+     * @see ConfigureTableOptions
+     */
+    type ColumnOf<T> = keyof T & Column;
+
+    /**
+     * The type parameter allows typings generated by
+     * <code>cdc-sink userscript typings</code> to describe the rows of
+     * a specific table.
+     *
      * @see configureTable
      */
-    type ConfigureTableOptions = {
+    type ConfigureTableOptions<T = Document> = {
         /**
          * A list of columns to enable compare-and-set behavior.
          */
-        cas: Column[];
+        cas: ColumnOf<T>[];
         /**
          * Enable deadlining behavior, to discard mutations when the
          * named timestamp column is older than the given duration.
          */
-        deadlines: { [k: Column]: Duration };
+        deadlines: { [k in ColumnOf<T>]?: Duration };
         /**
          * Replacement SQL expressions to use when upserting columns.
          * The placeholder <code>$0</code> will be replaced with the
          * specific value.
          */
-        exprs: { [k: Column]: string };
+        exprs: { [k in ColumnOf<T>]?: string };
         /**
          * The name of a JSONB column that unmapped properties will be
          * stored in.
          */
-        extras: Column;
+        extras: ColumnOf<T>;
         /**
          * Columns that may be ignored in the input data. This allows,
          * for example, columns to be dropped from the destination
          * table.
          */
-        ignore: { [k: Column]: boolean }
+        ignore: { [k in ColumnOf<T>]?: boolean }
         /**
          * A mapping function which may modify or discard a single
          * mutation to be applied to the target table.
@@ -176,7 +315,7 @@ declare module "cdc-sink@v1" {
          * @param meta - Source-specific metadata about the document.
          * @returns The document to upsert, or null to do nothing.
          */
-        map: (d: Document, meta: Document) => Document | null;
+        map: (d: Document, meta: Document) => T | null;
         /**
          * Enables a user-defined, two- or three-way merge function.
          */
@@ -34,7 +34,22 @@ api.configureSource("expander", {
             ],
         };
     },
-    deletesTo: "table1"
+    deletesTo: "table1",
+    // Observe transaction boundaries.
+    onBegin: meta => {
+        console.log("begin", JSON.stringify(meta));
+    },
+    // Write an additional row at the end of each transaction.
+    onCommit: meta => ({
+        "table2": [{dest: "audit", idx: meta.tables.length, msg: meta.source}]
+    }),
+});
+
+// Turn application-defined messages into rows.
+api.configureSource("messages", {
+    onMessage: msg => msg.prefix === "ignore" ? null : ({
+        "table1": [{dest: "message", msg: msg.content, prefix: msg.prefix}]
+    }),
 });
 
 api.configureSource("passthrough", {
@@ -0,0 +1,196 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+)
+
+// jsIdentifier matches property names that need not be quoted.
+var jsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// rowID is the name of the synthetic primary key used by CockroachDB
+// for tables that do not declare one.
+var rowID = ident.New("rowid")
+
+// WriteTypings emits a TypeScript declaration file that describes the
+// rows of each table in the schema. The declarations augment the
+// cdc-sink@v1 module, so that api.configureTable() will be checked
+// against the columns of the named table.
+func WriteTypings(w io.Writer, schema ident.Schema, data *types.SchemaData) error {
+	var tables []ident.Table
+	_ = data.Columns.Range(func(tbl ident.Table, _ []types.ColData) error {
+		if schema.Contains(tbl) {
+			tables = append(tables, tbl)
+		}
+		return nil
+	})
+	if len(tables) == 0 {
+		return errors.Errorf("no tables found in %s", schema)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Raw() < tables[j].Raw()
+	})
+
+	out := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(out, `// Code generated by cdc-sink userscript typings. DO NOT EDIT.
+// Schema: %s
+
+declare module "cdc-sink@v1" {
+`, schema.Raw())
+
+	typeNames := make([]string, len(tables))
+	seen := make(map[string]bool, len(tables))
+	for idx, tbl := range tables {
+		typeName := rowTypeName(tbl.Table())
+		for i := 2; seen[typeName]; i++ {
+			typeName = rowTypeName(tbl.Table()) + strconv.Itoa(i)
+		}
+		seen[typeName] = true
+		typeNames[idx] = typeName
+
+		_, _ = fmt.Fprintf(out, "    /**\n     * A row in %s.\n     */\n", tbl.Raw())
+		_, _ = fmt.Fprintf(out, "    interface %s {\n", typeName)
+		for _, col := range data.Columns.GetZero(tbl) {
+			// Generated columns cannot be written to.
+			if col.Ignored {
+				continue
+			}
+			writeColumn(out, col)
+		}
+		_, _ = fmt.Fprint(out, "    }\n\n")
+	}
+
+	// Register the row types under both the relative and the fully
+	// qualified table names.
+	_, _ = fmt.Fprint(out, "    interface TableTypes {\n")
+	for idx, tbl := range tables {
+		_, _ = fmt.Fprintf(out, "        %s: %s;\n", strconv.Quote(tbl.Table().Raw()), typeNames[idx])
+		_, _ = fmt.Fprintf(out, "        %s: %s;\n", strconv.Quote(tbl.Raw()), typeNames[idx])
+	}
+	_, _ = fmt.Fprint(out, "    }\n}\n")
+
+	return errors.WithStack(out.Flush())
+}
+
+// writeColumn emits a property declaration for the column. Columns
+// which may be omitted from a row are declared as optional properties.
+func writeColumn(out io.Writer, col types.ColData) {
+	name := col.Name.Raw()
+	if !jsIdentifier.MatchString(name) {
+		name = strconv.Quote(name)
+	}
+
+	var notes []string
+	notes = append(notes, col.Type)
+	if col.Primary {
+		notes = append(notes, "primary key")
+	}
+	if col.DefaultExpr != "" {
+		notes = append(notes, "default "+col.DefaultExpr)
+	}
+
+	optional := col.Nullable || col.DefaultExpr != "" ||
+		(col.Primary && ident.Equal(col.Name, rowID) && col.Type == "INT8")
+	if optional {
+		name += "?"
+	}
+	jsType := typingsType(col.Type)
+	if col.Nullable {
+		jsType += " | null"
+	}
+
+	_, _ = fmt.Fprintf(out, "        /** %s */\n", strings.ReplaceAll(strings.Join(notes, ", "), "*/", "* /"))
+	_, _ = fmt.Fprintf(out, "        %s: %s;\n", name, jsType)
+}
+
+// rowTypeName converts a table name into a TypeScript type name.
+func rowTypeName(tbl ident.Ident) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range tbl.Raw() {
+		switch {
+		case r == '_' || r == '$' || !(unicode.IsLetter(r) || unicode.IsDigit(r)):
+			upper = true
+		case upper:
+			sb.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	ret := sb.String()
+	if ret == "" || !unicode.IsLetter([]rune(ret)[0]) {
+		ret = "T" + ret
+	}
+	return ret + "Row"
+}
+
+// typingsType maps a SQL type name, as reported by the schemawatch
+// package, onto the type of the equivalent JSON value.
+func typingsType(sqlType string) string {
+	if elem, ok := strings.CutSuffix(sqlType, "[]"); ok {
+		return "Array<" + typingsType(elem) + ">"
+	}
+
+	// Strip any size, precision, or collation details.
+	base := strings.ToLower(sqlType)
+	if idx := strings.IndexAny(base, "( "); idx >= 0 {
+		base = base[:idx]
+	}
+	// Strip any schema qualifier, e.g. pg_catalog.int8.
+	if idx := strings.LastIndex(base, "."); idx >= 0 {
+		base = base[idx+1:]
+	}
+	base = strings.Trim(base, `"`)
+	// PostgreSQL names array types with a leading underscore.
+	if elem, ok := strings.CutPrefix(base, "_"); ok {
+		return "Array<" + typingsType(elem) + ">"
+	}
+
+	switch base {
+	case "bool", "boolean":
+		return "boolean"
+
+	case "bigint", "binary_double", "binary_float", "bit", "double", "float", "float4",
+		"float8", "int", "int2", "int4", "int8", "integer", "mediumint", "real",
+		"smallint", "tinyint":
+		return "number"
+
+	case "decimal", "number", "numeric":
+		// Arbitrary-precision values may be encoded as strings.
+		return "number | string"
+
+	case "json", "jsonb":
+		return "DocumentValue"
+
+	default:
+		// Includes text, temporal, byte, UUID, and user-defined types.
+		return "string"
+	}
+}
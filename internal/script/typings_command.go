@@ -0,0 +1,99 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package script
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// typingsCommand returns a command that writes TypeScript declarations
+// for the tables in a target schema.
+func typingsCommand() *cobra.Command {
+	var out, targetConn string
+	var targetSchema ident.Schema
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Short: "generate TypeScript declarations for the tables in a target schema",
+		Use:   "typings --targetConn <url> --targetSchema <schema> -o tables.d.ts",
+		Example: strings.TrimSpace(`
+# Write declarations next to the userscript. The file should be
+# included by the tsconfig.json used to compile the userscript.
+cdc-sink userscript typings \
+  --targetConn postgresql://root@localhost:26257/?sslmode=disable \
+  --targetSchema my_db.public \
+  -o ./tables.d.ts
+`),
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if targetConn == "" {
+				return errors.New("no targetConn specified")
+			}
+			if targetSchema.Empty() {
+				return errors.New("no targetSchema specified")
+			}
+
+			// main.go provides a stopper.
+			ctx := stopper.From(cmd.Context())
+
+			pool, err := stdpool.OpenTarget(ctx, targetConn,
+				stdpool.WithConnectionLifetime(5*time.Minute),
+				stdpool.WithPoolSize(1),
+				stdpool.WithTransactionTimeout(time.Minute),
+			)
+			if err != nil {
+				return err
+			}
+
+			watchers, err := schemawatch.ProvideFactory(ctx, pool, diag.New(ctx))
+			if err != nil {
+				return err
+			}
+			watcher, err := watchers.Get(targetSchema)
+			if err != nil {
+				return err
+			}
+
+			var w io.Writer = os.Stdout
+			if out != "" {
+				f, err := os.Create(out)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				defer f.Close()
+				w = f
+			}
+			return WriteTypings(w, targetSchema, watcher.Get())
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&out, "out", "o", "", "a file to write the declarations to")
+	f.StringVar(&targetConn, "targetConn", "", "the target database's connection string")
+	f.Var(ident.NewSchemaFlag(&targetSchema), "targetSchema",
+		"the SQL database schema in the target cluster to describe")
+	return cmd
+}
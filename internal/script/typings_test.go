@@ -0,0 +1,101 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package script
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTypings(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	schema := ident.MustSchema(ident.New("my_db"), ident.Public)
+	other := ident.MustSchema(ident.New("other_db"), ident.Public)
+
+	data := &types.SchemaData{Columns: &ident.TableMap[[]types.ColData]{}}
+	data.Columns.Put(ident.NewTable(schema, ident.New("order_items")), []types.ColData{
+		{Name: ident.New("id"), Primary: true, Type: "INT8"},
+		{Name: ident.New("qty"), Type: "INT4"},
+		{Name: ident.New("price"), Nullable: true, Type: "NUMERIC(10,2)"},
+		{Name: ident.New("tags"), Nullable: true, Type: "STRING[]"},
+		{Name: ident.New("extras"), DefaultExpr: "'{}'::JSONB", Type: "JSONB"},
+		{Name: ident.New("two words"), Nullable: true, Type: "BOOL"},
+		{Name: ident.New("computed"), Ignored: true, Type: "INT8"},
+	})
+	data.Columns.Put(ident.NewTable(schema, ident.New("keyless")), []types.ColData{
+		{Name: ident.New("rowid"), Primary: true, Type: "INT8"},
+		{Name: ident.New("ts"), Type: "TIMESTAMPTZ"},
+	})
+	data.Columns.Put(ident.NewTable(other, ident.New("ignored")), []types.ColData{
+		{Name: ident.New("pk"), Primary: true, Type: "INT8"},
+	})
+
+	var sb strings.Builder
+	r.NoError(WriteTypings(&sb, schema, data))
+	out := sb.String()
+
+	a.Contains(out, `declare module "cdc-sink@v1" {`)
+	a.Contains(out, "interface OrderItemsRow {")
+	a.Contains(out, "        /** INT8, primary key */\n        id: number;\n")
+	a.Contains(out, "        qty: number;\n")
+	a.Contains(out, "        price?: number | string | null;\n")
+	a.Contains(out, "        tags?: Array<string> | null;\n")
+	a.Contains(out, "        /** JSONB, default '{}'::JSONB */\n        extras?: DocumentValue;\n")
+	a.Contains(out, `        "two words"?: boolean | null;`+"\n")
+	a.NotContains(out, "computed")
+
+	a.Contains(out, "interface KeylessRow {")
+	a.Contains(out, "        rowid?: number;\n")
+	a.Contains(out, "        ts: string;\n")
+
+	a.Contains(out, `        "order_items": OrderItemsRow;`)
+	a.Contains(out, `        "my_db.public.order_items": OrderItemsRow;`)
+	a.NotContains(out, "other_db")
+
+	a.Error(WriteTypings(&sb, ident.MustSchema(ident.New("empty")), data))
+}
+
+func TestTypingsType(t *testing.T) {
+	a := assert.New(t)
+	for sqlType, expected := range map[string]string{
+		"BOOL":                 "boolean",
+		"tinyint":              "number",
+		"bigint unsigned":      "number",
+		"FLOAT8":               "number",
+		"decimal(10,2)":        "number | string",
+		"NUMBER(10)":           "number | string",
+		"json":                 "DocumentValue",
+		"INT8[]":               "Array<number>",
+		"varchar(255)":         "string",
+		"TIMESTAMPTZ":          "string",
+		`"db"."public"."enm"`:  "string",
+		"pg_catalog.int8":      "number",
+		`"pg_catalog"."bool"`:  "boolean",
+		"pg_catalog._int4":     "Array<number>",
+		"_jsonb":               "Array<DocumentValue>",
+		"public.numeric(10,2)": "number | string",
+	} {
+		a.Equal(expected, typingsType(sqlType), sqlType)
+	}
+}
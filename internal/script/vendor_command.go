@@ -0,0 +1,161 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// vendorCommand returns a command that downloads all remote modules
+// required by a userscript into a directory next to the script.
+func vendorCommand() *cobra.Command {
+	var dir, importMapPath, userscript string
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Short: "download and pin the remote modules required by a userscript",
+		Use:   "vendor --userscript main.ts",
+		Example: strings.TrimSpace(`
+# Download remote modules into ./vendor and write ./importmap.json
+cdc-sink userscript vendor --userscript ./main.ts
+
+# Use the vendored modules.
+cdc-sink start --userscript ./main.ts --userscriptImportMap ./importmap.json ...
+`),
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if userscript == "" {
+				return errors.New("no userscript specified")
+			}
+			return vendor(userscript, dir, importMapPath)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&dir, "dir", "vendor",
+		"the directory, relative to the userscript, to write modules into")
+	f.StringVar(&importMapPath, "importMap", "",
+		"the import map to update; defaults to importmap.json next to the userscript")
+	f.StringVar(&userscript, "userscript", "",
+		"the path to the userscript to vendor")
+	return cmd
+}
+
+// discardOptions ignores calls to api.setOptions() while vendoring.
+type discardOptions struct{}
+
+func (discardOptions) Set(_, _ string) error { return nil }
+
+// vendor loads the userscript, recording every transitively-required
+// remote module. The modules are written into the vendor directory and
+// the import map is updated to pin and redirect each module.
+func vendor(userscript, dir, importMapPath string) error {
+	if !filepath.IsLocal(dir) {
+		return errors.Errorf("vendor directory %q must be within the userscript directory", dir)
+	}
+
+	userscript, err := filepath.Abs(userscript)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	scriptDir, scriptName := filepath.Split(userscript)
+	if importMapPath == "" {
+		importMapPath = filepath.Join(scriptDir, "importmap.json")
+	}
+
+	// Retain any existing mappings and pins, but fetch everything anew.
+	importMap := &ImportMap{}
+	if _, err := os.Stat(importMapPath); err == nil {
+		importMap, err = ReadImportMap(importMapPath)
+		if err != nil {
+			return err
+		}
+	}
+	importMap.Vendored = nil
+
+	loader, err := ProvideLoader(&Config{
+		FS:        os.DirFS(scriptDir),
+		ImportMap: importMap,
+		MainPath:  "/" + scriptName,
+		Options:   discardOptions{},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(loader.remotes) == 0 {
+		log.Info("userscript does not require any remote modules")
+		return nil
+	}
+
+	if importMap.Integrity == nil {
+		importMap.Integrity = make(map[string]string)
+	}
+	importMap.Vendored = make(map[string]string)
+
+	for _, key := range sortedKeys(loader.remotes) {
+		source, err := url.Parse(key)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		rel := filepath.Join(dir, vendorPath(source))
+		dest := filepath.Join(scriptDir, rel)
+		data := loader.remotes[key]
+
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := os.WriteFile(dest, data, 0644); err != nil {
+			return errors.WithStack(err)
+		}
+		importMap.Integrity[key] = Integrity(data)
+		importMap.Vendored[key] = "/" + filepath.ToSlash(rel)
+		log.Infof("vendored %s as %s", key, rel)
+	}
+
+	if err := importMap.WriteFile(importMapPath); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s; use --userscriptImportMap to enable\n", importMapPath)
+	return nil
+}
+
+// vendorPath returns a relative filesystem path to hold the contents
+// of the remote module. The host and path of the URL are retained to
+// make the vendor directory easy to inspect.
+func vendorPath(source *url.URL) string {
+	p := path.Clean("/" + source.Path)
+	if p == "/" {
+		p = "/index.js"
+	}
+	// Disambiguate URLs which vary only by query parameters.
+	if source.RawQuery != "" {
+		sum := sha256.Sum256([]byte(source.RawQuery))
+		ext := path.Ext(p)
+		p = strings.TrimSuffix(p, ext) + "_" + hex.EncodeToString(sum[:4]) + ext
+	}
+	return filepath.Join(strings.ReplaceAll(source.Host, ":", "_"), filepath.FromSlash(p))
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/staging"
 	"github.com/cockroachdb/cdc-sink/internal/target"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/google/wire"
 )
@@ -34,6 +35,7 @@ var TestSet = wire.NewSet(
 	target.Set,
 
 	ProvideDLQConfig,
+	ProvideEvolveConfig,
 	ProvideWatcher,
 
 	wire.Struct(new(Fixture), "*"),
@@ -45,6 +47,13 @@ func ProvideDLQConfig() (*dlq.Config, error) {
 	return cfg, cfg.Preflight()
 }
 
+// ProvideEvolveConfig emits a default configuration, which disables
+// schema evolution.
+func ProvideEvolveConfig() (*evolve.Config, error) {
+	cfg := &evolve.Config{}
+	return cfg, cfg.Preflight()
+}
+
 // ProvideWatcher is called by Wire to construct a Watcher
 // bound to the testing database.
 func ProvideWatcher(target sinktest.TargetSchema, watchers types.Watchers) (types.Watcher, error) {
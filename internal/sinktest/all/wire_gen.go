@@ -13,6 +13,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/staging/version"
 	"github.com/cockroachdb/cdc-sink/internal/target/apply"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
@@ -74,7 +75,15 @@ func NewFixture(t testing.TB) (*Fixture, error) {
 		return nil, err
 	}
 	dlQs := dlq.ProvideDLQs(config, targetPool, watchers)
-	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	evolveConfig, err := ProvideEvolveConfig()
+	if err != nil {
+		return nil, err
+	}
+	evolver, err := evolve.ProvideEvolver(context, evolveConfig, diagnostics, targetPool, watchers)
+	if err != nil {
+		return nil, err
+	}
+	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, evolver, targetPool, watchers)
 	if err != nil {
 		return nil, err
 	}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
@@ -71,6 +72,12 @@ type Config struct {
 	// has large blob values.
 	NDJsonBuffer int
 
+	// If non-zero, a target table whose mutations fail to apply this
+	// many consecutive times will be quarantined. The staged mutations
+	// for a quarantined table are held back until it is released, while
+	// the rest of the target schema continues to advance.
+	QuarantineAfter int
+
 	// Retain staged, applied data for an extra amount of time. This
 	// allows, for example, additional time to validate what was staged
 	// versus what was applied. When set to zero, staged mutations may
@@ -78,6 +85,14 @@ type Config struct {
 	// the timestamp of the mutation.
 	RetireOffset time.Duration
 
+	// A connection string for the changefeed's source database. This is
+	// used to read table definitions when creating target tables.
+	SourceConn string
+
+	// The schema in the source database that contains the tables
+	// emitted by the changefeed.
+	SourceSchema ident.Schema
+
 	// The maximum number of source transactions to unstage at once.
 	// This does not place a hard limit on the number of mutations that
 	// may be dequeued at once, but it does reduce the total number of
@@ -105,8 +120,15 @@ func (c *Config) Bind(f *pflag.FlagSet) {
 			"increase when source cluster has large blob values")
 	f.Var(ident.NewValue(defaultMetaTable, &c.MetaTableName), "metaTable",
 		"the name of the table in which to store resolved timestamps")
+	f.IntVar(&c.QuarantineAfter, "quarantineAfter", 0,
+		"if non-zero, hold back the mutations for a table after this many consecutive "+
+			"failures until it is released via the /_/quarantine endpoint")
 	f.DurationVar(&c.RetireOffset, "retireOffset", 0,
 		"if non-zero, retain staged, applied data for an extra duration")
+	f.StringVar(&c.SourceConn, "sourceConn", "",
+		"the changefeed source's connection string; required with --createTables")
+	f.Var(ident.NewSchemaFlag(&c.SourceSchema), "sourceSchema",
+		"the schema in the changefeed source that contains the replicated tables")
 	f.IntVar(&c.TimestampWindowSize, "timestampWindowSize", defaultTimestampWindowSize,
 		"the maximum number of source transaction timestamps to unstage at once")
 
@@ -125,6 +147,11 @@ func (c *Config) Preflight() error {
 	if c.BackupPolling == 0 {
 		c.BackupPolling = defaultBackupPolling
 	}
+	if c.StopAt != "" {
+		if _, err := hlc.Parse(c.StopAt); err != nil {
+			return errors.Wrap(err, "stopAt must be a resolved timestamp")
+		}
+	}
 	if c.IdealFlushBatchSize == 0 {
 		c.IdealFlushBatchSize = defaultIdealBatchSize
 	}
@@ -138,9 +165,20 @@ func (c *Config) Preflight() error {
 	if c.MetaTableName.Empty() {
 		c.MetaTableName = ident.New(defaultMetaTable)
 	}
+	if c.QuarantineAfter < 0 {
+		return errors.New("quarantineAfter must be >= 0")
+	}
 	if c.RetireOffset < 0 {
 		return errors.New("retireOffset must be >= 0")
 	}
+	if c.CreateTables {
+		if c.SourceConn == "" {
+			return errors.New("sourceConn must be set when creating tables")
+		}
+		if c.SourceSchema.Empty() {
+			return errors.New("sourceSchema must be set when creating tables")
+		}
+	}
 	if c.TimestampWindowSize < 0 {
 		return errors.New("timestampWindowSize must be >= 0")
 	} else if c.TimestampWindowSize == 0 {
@@ -0,0 +1,56 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cdc
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+)
+
+// TableCreator creates missing target tables, using the definition of
+// the table with the same name in the changefeed's source database.
+type TableCreator struct {
+	creator      *autocreate.Creator
+	source       types.Watcher
+	sourcePool   *types.TargetPool
+	sourceSchema ident.Schema
+}
+
+// Ensure creates the target table if it does not already exist. This
+// method is a no-op if the receiver is nil.
+func (c *TableCreator) Ensure(ctx context.Context, target ident.Table) error {
+	if c == nil {
+		return nil
+	}
+	sourceTable := ident.NewTable(c.sourceSchema, target.Table())
+	cols, ok := c.source.Get().Columns.Get(sourceTable)
+	if !ok {
+		// The table may have been created since the last refresh.
+		if err := c.source.Refresh(ctx, c.sourcePool); err != nil {
+			return err
+		}
+		cols, ok = c.source.Get().Columns.Get(sourceTable)
+		if !ok {
+			return errors.Errorf("source table %s not found", sourceTable)
+		}
+	}
+	return c.creator.Ensure(ctx, target, autocreate.Columns(cols))
+}
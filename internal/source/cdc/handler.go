@@ -42,6 +42,7 @@ var sanitizer = strings.NewReplacer("\n", " ", "\r", " ")
 type Handler struct {
 	Authenticator types.Authenticator // Access checks.
 	Config        *Config             // Runtime options.
+	Creator       *TableCreator       // Creates target tables; may be nil.
 	Immediate     *Immediate          // Non-transactional mutations.
 	Resolvers     *Resolvers          // Process resolved timestamps.
 	StagingPool   *types.StagingPool  // Access to the staging cluster.
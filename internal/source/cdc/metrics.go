@@ -64,6 +64,10 @@ var (
 		Help: "the wall time of the proposed resolved timestamp or " +
 			"zero if this is not the resolving instance",
 	}, schemaLabels)
+	quarantinedTables = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cdc_resolver_table_quarantined",
+		Help: "1 if the staged mutations for a table are quarantined after repeated failures",
+	}, metrics.TableLabels)
 	recordDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "cdc_resolver_record_duration_seconds",
 		Help:    "the amount of time it took to record a completed resolved timestamp",
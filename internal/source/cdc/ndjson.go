@@ -76,6 +76,9 @@ func parseNdjsonMutation(_ *request, rawBytes []byte) (types.Mutation, error) {
 // should the request fail partway through.
 func (h *Handler) ndjson(ctx context.Context, req *request, parser parseMutation) error {
 	target := req.target.(ident.Table)
+	if err := h.Creator.Ensure(ctx, target); err != nil {
+		return err
+	}
 
 	var commit func() error
 	var flush func(muts []types.Mutation) error
@@ -18,10 +18,15 @@ package cdc
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
 	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
 	"github.com/google/wire"
 	"github.com/pkg/errors"
@@ -33,6 +38,7 @@ var Set = wire.NewSet(
 	ProvideImmediate,
 	ProvideMetaTable,
 	ProvideResolvers,
+	ProvideTableCreator,
 )
 
 // MetaTable is an injectable configuration point.
@@ -57,6 +63,7 @@ func ProvideMetaTable(cfg *Config) MetaTable {
 func ProvideResolvers(
 	ctx *stopper.Context,
 	cfg *Config,
+	diags *diag.Diagnostics,
 	leases types.Leases,
 	loops *logical.Factory,
 	metaTable MetaTable,
@@ -79,6 +86,9 @@ func ProvideResolvers(
 		watchers:  watchers,
 	}
 	ret.mu.instances = &ident.SchemaMap[*logical.Loop]{}
+	if err := diags.Register("quarantine", diag.DiagnosticFn(ret.quarantineDiagnostic)); err != nil {
+		return nil, err
+	}
 
 	// Resume from previous state.
 	schemas, err := ScanForTargetSchemas(ctx, pool, ret.metaTable)
@@ -93,3 +103,46 @@ func ProvideResolvers(
 
 	return ret, nil
 }
+
+// ProvideTableCreator is called by Wire. It returns nil unless the
+// configuration enables the creation of target tables.
+func ProvideTableCreator(
+	ctx *stopper.Context, cfg *Config, creator *autocreate.Creator, diags *diag.Diagnostics,
+) (*TableCreator, error) {
+	if !cfg.CreateTables {
+		return nil, nil
+	}
+	pool, err := stdpool.OpenTarget(ctx, cfg.SourceConn,
+		stdpool.WithConnectionLifetime(5*time.Minute),
+		stdpool.WithDiagnostics(diags, "source"),
+		stdpool.WithMetrics("source"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := pool.Product.ExpandSchema(cfg.SourceSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	// Introspect the source tables using the same machinery as the
+	// target, but report its diagnostics separately.
+	sourceDiags, err := diags.Wrap("sourceSchema")
+	if err != nil {
+		return nil, err
+	}
+	watchers, err := schemawatch.ProvideFactory(ctx, pool, sourceDiags)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := watchers.Get(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &TableCreator{
+		creator:      creator,
+		source:       watcher,
+		sourcePool:   pool,
+		sourceSchema: schema,
+	}, nil
+}
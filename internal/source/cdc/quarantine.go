@@ -0,0 +1,174 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cdc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// A quarantine tracks target tables which have failed to apply
+// repeatedly. The resolver holds back the staged mutations for a
+// quarantined table, so that the rest of the schema can continue to
+// advance, until the table is released by an operator.
+type quarantine struct {
+	limit int // Consecutive failures before quarantine; zero disables.
+
+	mu struct {
+		sync.Mutex
+		failures    *ident.TableMap[int]
+		quarantined *ident.TableMap[time.Time]
+		released    *ident.TableMap[bool] // Not yet seen by the resolver.
+	}
+}
+
+func newQuarantine(limit int) *quarantine {
+	ret := &quarantine{limit: limit}
+	ret.mu.failures = &ident.TableMap[int]{}
+	ret.mu.quarantined = &ident.TableMap[time.Time]{}
+	ret.mu.released = &ident.TableMap[bool]{}
+	return ret
+}
+
+// Check returns true if the table is quarantined. The persisted value
+// indicates that the resolver's consistent point records the table as
+// being quarantined, which restores the quarantine after a restart. A
+// pending release request will be consumed by this method.
+func (q *quarantine) Check(table ident.Table, persisted bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, released := q.mu.released.Get(table); released {
+		q.mu.released.Delete(table)
+		q.mu.quarantined.Delete(table)
+		quarantinedTables.WithLabelValues(metrics.TableValues(table)...).Set(0)
+		log.WithField("table", table).Info("releasing quarantined table")
+		return false
+	}
+	if _, found := q.mu.quarantined.Get(table); found {
+		return true
+	}
+	if persisted {
+		q.mu.quarantined.Put(table, time.Now())
+		quarantinedTables.WithLabelValues(metrics.TableValues(table)...).Set(1)
+		return true
+	}
+	return false
+}
+
+// Diagnostic implements [diag.Diagnostic].
+func (q *quarantine) Diagnostic(_ context.Context) any {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ret := struct {
+		Failures    map[string]int       `json:"failures,omitempty"`
+		Quarantined map[string]time.Time `json:"quarantined,omitempty"`
+	}{
+		Failures:    make(map[string]int),
+		Quarantined: make(map[string]time.Time),
+	}
+	_ = q.mu.failures.Range(func(tbl ident.Table, count int) error {
+		ret.Failures[tbl.Raw()] = count
+		return nil
+	})
+	_ = q.mu.quarantined.Range(func(tbl ident.Table, since time.Time) error {
+		ret.Quarantined[tbl.Raw()] = since
+		return nil
+	})
+	return ret
+}
+
+// Failed records a failure to apply mutations to the table and returns
+// true if the table has just been quarantined.
+func (q *quarantine) Failed(table ident.Table, err error) bool {
+	if q.limit <= 0 {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := q.mu.failures.GetZero(table) + 1
+	if count < q.limit {
+		q.mu.failures.Put(table, count)
+		return false
+	}
+	q.mu.failures.Delete(table)
+	q.mu.quarantined.Put(table, time.Now())
+	quarantinedTables.WithLabelValues(metrics.TableValues(table)...).Set(1)
+	log.WithError(err).WithFields(log.Fields{
+		"failures": count,
+		"table":    table,
+	}).Error("quarantining table after repeated failures")
+	return true
+}
+
+// Release requests that the resolver stop holding back mutations for
+// the table.
+func (q *quarantine) Release(table ident.Table) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, found := q.mu.quarantined.Get(table); !found {
+		return errors.Errorf("table %s is not quarantined", table)
+	}
+	q.mu.released.Put(table, true)
+	return nil
+}
+
+// Succeeded resets the failure count for the table.
+func (q *quarantine) Succeeded(table ident.Table) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.mu.failures.Delete(table)
+}
+
+// QuarantineHandler returns an [http.Handler] that releases a
+// quarantined table. It expects a POST request with a table parameter
+// containing the fully-qualified name of the target table. The request
+// is protected by [logical.AdminGuard].
+func QuarantineHandler(
+	auth types.Authenticator, factory *logical.Factory, resolvers *Resolvers,
+) http.Handler {
+	return logical.AdminGuard(auth, factory, "quarantine", http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodPost {
+				http.Error(w, "POST required", http.StatusMethodNotAllowed)
+				return
+			}
+			table, err := ident.ParseTable(req.FormValue("table"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := resolvers.Release(table); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			log.Infof("table %s released from quarantine by operator request", table)
+			http.Error(w, "OK", http.StatusOK)
+		}))
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cdc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantine(t *testing.T) {
+	a := assert.New(t)
+
+	tbl := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+	other := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("other"))
+	err := errors.New("boom")
+
+	q := newQuarantine(3)
+	a.False(q.Check(tbl, false))
+	a.Error(q.Release(tbl))
+
+	// Successes reset the count of consecutive failures.
+	a.False(q.Failed(tbl, err))
+	a.False(q.Failed(tbl, err))
+	q.Succeeded(tbl)
+	a.False(q.Failed(tbl, err))
+	a.False(q.Failed(tbl, err))
+	a.True(q.Failed(tbl, err))
+	a.True(q.Check(tbl, false))
+	a.False(q.Check(other, false))
+
+	// Releasing is consumed by the next check.
+	a.NoError(q.Release(tbl))
+	a.False(q.Check(tbl, true))
+	a.False(q.Check(tbl, false))
+
+	// A persisted quarantine is restored.
+	a.True(q.Check(other, true))
+	a.True(q.Check(other, false))
+
+	// A zero limit disables quarantine.
+	q = newQuarantine(0)
+	for i := 0; i < 10; i++ {
+		a.False(q.Failed(tbl, err))
+	}
+}
+
+func TestHeldStampJSON(t *testing.T) {
+	r := require.New(t)
+
+	tbl := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+	held := &ident.TableMap[heldTable]{}
+	held.Put(tbl, heldTable{From: hlc.New(1, 2), Quarantined: true})
+
+	rs := (&resolvedStamp{CommittedTime: hlc.New(3, 4)}).WithHeld(held)
+	next, err := rs.NewProposed(hlc.New(5, 6))
+	r.NoError(err)
+	r.Same(held, next.Held)
+
+	buf, err := json.Marshal(next)
+	r.NoError(err)
+	var decoded resolvedStamp
+	r.NoError(json.Unmarshal(buf, &decoded))
+	found, ok := decoded.Held.Get(tbl)
+	r.True(ok)
+	r.Equal(heldTable{From: hlc.New(1, 2), Quarantined: true}, found)
+
+	r.Nil(rs.WithHeld(&ident.TableMap[heldTable]{}).Held)
+}
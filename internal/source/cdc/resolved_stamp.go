@@ -27,6 +27,17 @@ import (
 	"github.com/pkg/errors"
 )
 
+// A heldTable records when the resolver began holding back the staged
+// mutations for a table.
+type heldTable struct {
+	// The committed time at which the hold began.
+	From hlc.Time `json:"f"`
+	// Set if the table was quarantined, rather than paused due to a
+	// breaking schema change. Quarantined tables remain held until
+	// they are explicitly released.
+	Quarantined bool `json:"q,omitempty"`
+}
+
 // resolvedStamp tracks the progress of applying staged mutations for
 // a given resolved timestamp.
 // Partial progress of very large batches is maintained in the staging
@@ -35,6 +46,10 @@ type resolvedStamp struct {
 	// A resolved timestamp that represents a transactionally-consistent
 	// point in the history of the workload.
 	CommittedTime hlc.Time `json:"c,omitempty"`
+	// Held records the tables whose staged mutations are being held
+	// back. A table remains in this map until all of its held
+	// mutations have been applied, so that the hold survives a restart.
+	Held *ident.TableMap[heldTable] `json:"h,omitempty"`
 	// Iteration is used to provide well-ordered behavior within a
 	// single backfill window. Partial progress is maintained in the
 	// staging tables in case cdc-sink is interrupted in the middle
@@ -56,6 +71,22 @@ func (s *resolvedStamp) AsTime() time.Time {
 	return time.Unix(0, s.CommittedTime.Nanos())
 }
 
+// ParseStopPoint implements logical.StopPointStamp. It accepts a
+// resolved timestamp in the NNNN.LLL format. The stamp represents the
+// point at which the timestamp has been committed.
+func (s *resolvedStamp) ParseStopPoint(raw string) error {
+	ts, err := hlc.Parse(raw)
+	if err != nil {
+		return err
+	}
+	*s = resolvedStamp{CommittedTime: ts}
+	return nil
+}
+
+// ZeroStamp returns an empty resolved timestamp so that offline tools
+// can decode the values that resolvers store in the memo table.
+func ZeroStamp() stamp.Stamp { return &resolvedStamp{} }
+
 // Less implements stamp.Stamp.
 func (s *resolvedStamp) Less(other stamp.Stamp) bool {
 	o := other.(*resolvedStamp)
@@ -75,7 +106,7 @@ func (s *resolvedStamp) NewCommitted() (*resolvedStamp, error) {
 		return nil, errors.New("cannot make new committed timestamp without proposed value")
 	}
 
-	return &resolvedStamp{CommittedTime: s.ProposedTime}, nil
+	return &resolvedStamp{CommittedTime: s.ProposedTime, Held: s.Held}, nil
 }
 
 // NewProposed returns a new resolvedStamp that extends the existing
@@ -91,6 +122,7 @@ func (s *resolvedStamp) NewProposed(proposed hlc.Time) (*resolvedStamp, error) {
 
 	return &resolvedStamp{
 		CommittedTime: s.CommittedTime,
+		Held:          s.Held,
 		Iteration:     s.Iteration + 1,
 		ProposedTime:  proposed,
 	}, nil
@@ -101,6 +133,7 @@ func (s *resolvedStamp) NewProposed(proposed hlc.Time) (*resolvedStamp, error) {
 func (s *resolvedStamp) NewProgress(cursor *types.UnstageCursor) *resolvedStamp {
 	ret := &resolvedStamp{
 		CommittedTime: s.CommittedTime,
+		Held:          s.Held,
 		Iteration:     s.Iteration + 1,
 		ProposedTime:  s.ProposedTime,
 	}
@@ -114,6 +147,16 @@ func (s *resolvedStamp) NewProgress(cursor *types.UnstageCursor) *resolvedStamp
 	return ret
 }
 
+// WithHeld returns a copy of the stamp with the given held tables.
+func (s *resolvedStamp) WithHeld(held *ident.TableMap[heldTable]) *resolvedStamp {
+	ret := *s
+	ret.Held = nil
+	if held.Len() > 0 {
+		ret.Held = held
+	}
+	return &ret
+}
+
 // String is for debugging use only.
 func (s *resolvedStamp) String() string {
 	ret, _ := json.Marshal(s)
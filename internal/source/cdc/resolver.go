@@ -62,11 +62,13 @@ type resolver struct {
 	cfg        *Config
 	committed  notify.Var[hlc.Time] // Drives a goroutine to remove applied mutations.
 	leases     types.Leases
+	loops      *logical.Factory     // Provides the stop point.
 	marked     notify.Var[hlc.Time] // Called by Mark to fast-wake the processing loop.
 	processing atomic.Bool          // True whenever Process is running.
 	proposed   notify.Var[hlc.Time] // Drives metrics.
 	pool       *types.StagingPool
 	stagers    types.Stagers
+	quarantine *quarantine
 	target     ident.Schema
 	watcher    types.Watcher
 
@@ -99,6 +101,7 @@ var (
 func newResolver(
 	cfg *Config,
 	leases types.Leases,
+	loops *logical.Factory,
 	pool *types.StagingPool,
 	metaTable ident.Table,
 	stagers types.Stagers,
@@ -111,12 +114,14 @@ func newResolver(
 	}
 
 	ret := &resolver{
-		cfg:     cfg,
-		leases:  leases,
-		pool:    pool,
-		stagers: stagers,
-		target:  target,
-		watcher: watcher,
+		cfg:        cfg,
+		leases:     leases,
+		loops:      loops,
+		pool:       pool,
+		quarantine: newQuarantine(cfg.QuarantineAfter),
+		stagers:    stagers,
+		target:     target,
+		watcher:    watcher,
 	}
 
 	labels := prometheus.Labels{"schema": target.Raw()}
@@ -327,6 +332,31 @@ func (r *resolver) nextProposedStamp(
 		return nil, err
 	}
 
+	// Don't advance beyond a requested stop point. Once a resolved
+	// timestamp at or after the stop point is available, all data up
+	// to and including the stop point can be applied.
+	if raw, _ := r.loops.StopAt(); raw != "" {
+		stopAt, err := hlc.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if hlc.Compare(prev.CommittedTime, stopAt) > 0 {
+			return nil, errNoWork
+		}
+		if hlc.Compare(nextResolved, stopAt) >= 0 {
+			nextResolved = hlc.New(stopAt.Nanos(), stopAt.Logical()+1)
+		}
+	}
+
+	// In delayed-replica mode, the resolved timestamp must be old
+	// enough before it can be applied. The backup polling will cause
+	// the timestamp to be checked again.
+	if delay := r.cfg.ApplyDelay; delay > 0 {
+		if hlc.Compare(nextResolved, hlc.From(time.Now().Add(-delay))) > 0 {
+			return nil, errNoWork
+		}
+	}
+
 	// Create a new marker that verifies that we're rolling forward.
 	ret, err := prev.NewProposed(nextResolved)
 	if err != nil {
@@ -392,19 +422,62 @@ func (r *resolver) ZeroStamp() stamp.Stamp {
 // resolvedStamp.
 func (r *resolver) process(ctx context.Context, rs *resolvedStamp, events logical.Events) error {
 	processStart := time.Now()
-	targets := r.watcher.Get().Order
+	schema := r.watcher.Get()
+	targets := schema.Order
 
 	if len(targets) == 0 {
 		return errors.Errorf("no tables known in schema %s; have they been created?", r.target)
 	}
 
+	// Tables that are paused due to breaking schema changes or which
+	// have been quarantined are not unstaged, so their mutations are
+	// held in the staging tables while the rest of the schema
+	// continues to advance. Once a table is released, we start
+	// unstaging from the time at which the hold began; mutations that
+	// have already been applied to other tables will be skipped.
+	startAt := rs.CommittedTime
+	held := &ident.TableMap[heldTable]{}    // Tables to hold after this window.
+	holding := &ident.TableMap[heldTable]{} // Tables to hold until then.
+	if rs.Held != nil {
+		rs.Held.CopyInto(holding)
+	}
 	flattened := make([]ident.Table, 0, len(targets))
 	for _, tgts := range targets {
-		flattened = append(flattened, tgts...)
+		for _, tbl := range tgts {
+			hold, wasHeld := holding.Get(tbl)
+			quarantined := r.quarantine.Check(tbl, hold.Quarantined)
+			if quarantined || schema.PausedBy(tbl) != nil {
+				if !wasHeld {
+					hold.From = rs.CommittedTime
+					log.WithFields(log.Fields{
+						"from":        hold.From,
+						"quarantined": quarantined,
+						"table":       tbl,
+					}).Warn("holding staged mutations for table")
+				}
+				hold.Quarantined = quarantined
+				holding.Put(tbl, hold)
+				held.Put(tbl, hold)
+				continue
+			}
+			if wasHeld {
+				log.WithFields(log.Fields{
+					"from":  hold.From,
+					"table": tbl,
+				}).Info("releasing held mutations for table")
+				if hlc.Compare(hold.From, startAt) < 0 {
+					startAt = hold.From
+				}
+				// Retain the start of the hold until caught up.
+				holding.Put(tbl, heldTable{From: hold.From})
+			}
+			flattened = append(flattened, tbl)
+		}
 	}
+	rs = rs.WithHeld(holding)
 
 	cursor := &types.UnstageCursor{
-		StartAt:        rs.CommittedTime,
+		StartAt:        startAt,
 		EndBefore:      rs.ProposedTime,
 		Targets:        flattened,
 		TimestampLimit: r.cfg.TimestampWindowSize,
@@ -434,6 +507,12 @@ func (r *resolver) process(ctx context.Context, rs *resolvedStamp, events logica
 					if len(muts) == 0 {
 						continue
 					}
+					// Retrying the window will hold back a table that
+					// was paused after the window was started.
+					if changes := r.watcher.Get().PausedBy(table); changes != nil {
+						return errors.Errorf(
+							"table %s is paused due to breaking schema changes: %v", table, changes)
+					}
 					source := script.SourceName(r.target)
 					muts = append([]types.Mutation(nil), muts...)
 					if err := batch.OnData(ctx, source, table, muts); err != nil {
@@ -452,8 +531,19 @@ func (r *resolver) process(ctx context.Context, rs *resolvedStamp, events logica
 				return ctx.Err()
 			}
 		}); err != nil {
+			// Count persistent failures against the table that caused
+			// them. A quarantined table will be held back when the
+			// loop retries the resolving window.
+			var tblErr *types.TableError
+			if errors.As(err, &tblErr) && r.quarantine.Failed(tblErr.Table, err) {
+				return errors.Wrapf(err, "quarantined table %s", tblErr.Table)
+			}
 			return err
 		}
+		_ = toApply.Range(func(table ident.Table, _ []types.Mutation) error {
+			r.quarantine.Succeeded(table)
+			return nil
+		})
 		r.metrics.flushDuration.Observe(time.Since(processStart).Seconds())
 		log.WithFields(log.Fields{
 			"duration": time.Since(flushStart),
@@ -568,6 +658,8 @@ func (r *resolver) process(ctx context.Context, rs *resolvedStamp, events logica
 				if err != nil {
 					return err
 				}
+				// Released tables have caught up.
+				rs = rs.WithHeld(held)
 				// Mark the timestamp has being processed.
 				if err := r.Record(ctx, rs.CommittedTime); err != nil {
 					return err
@@ -747,7 +839,7 @@ func (r *Resolvers) get(target ident.Schema) (*logical.Loop, *resolver, error) {
 		return found, found.Dialect().(*resolver), nil
 	}
 
-	ret, err := newResolver(r.cfg, r.leases, r.pool, r.metaTable, r.stagers, target, r.watchers)
+	ret, err := newResolver(r.cfg, r.leases, r.loops, r.pool, r.metaTable, r.stagers, target, r.watchers)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -775,6 +867,38 @@ func (r *Resolvers) get(target ident.Schema) (*logical.Loop, *resolver, error) {
 	return loop, ret, nil
 }
 
+// Release requests that the resolver for the table's schema stop
+// holding back the staged mutations for a quarantined table. The
+// table's mutations will then be applied in order, starting from the
+// time at which it was quarantined. The request must be made to the
+// instance of cdc-sink that is currently processing the schema.
+func (r *Resolvers) Release(table ident.Table) error {
+	r.mu.Lock()
+	loop, ok := r.mu.instances.Get(table.Schema())
+	r.mu.Unlock()
+	if !ok {
+		return errors.Errorf("no resolver for schema %s", table.Schema())
+	}
+	res := loop.Dialect().(*resolver)
+	if !res.processing.Load() {
+		return errors.Errorf("schema %s is not being processed by this instance", table.Schema())
+	}
+	return res.quarantine.Release(table)
+}
+
+// quarantineDiagnostic reports the quarantine state of each resolver.
+func (r *Resolvers) quarantineDiagnostic(ctx context.Context) any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret := make(map[string]any)
+	_ = r.mu.instances.Range(func(sch ident.Schema, loop *logical.Loop) error {
+		ret[sch.Raw()] = loop.Dialect().(*resolver).quarantine.Diagnostic(ctx)
+		return nil
+	})
+	return ret
+}
+
 const scanForTargetTemplate = `
 SELECT DISTINCT target_schema
 FROM %[1]s
@@ -810,3 +934,76 @@ func ScanForTargetSchemas(
 
 	return ret, nil
 }
+
+const scanResolvedStatusTemplate = `
+WITH
+marked AS (
+  SELECT DISTINCT ON (target_schema) target_schema, source_nanos, source_logical
+    FROM %[1]s
+   ORDER BY target_schema, source_nanos DESC, source_logical DESC),
+applied AS (
+  SELECT DISTINCT ON (target_schema) target_schema, source_nanos, source_logical, target_applied_at
+    FROM %[1]s
+   WHERE target_applied_at IS NOT NULL
+   ORDER BY target_schema, source_nanos DESC, source_logical DESC),
+pending AS (
+  SELECT target_schema, count(*) AS ct
+    FROM %[1]s
+   WHERE target_applied_at IS NULL
+   GROUP BY target_schema)
+SELECT marked.target_schema, marked.source_nanos, marked.source_logical,
+       COALESCE(applied.source_nanos, 0), COALESCE(applied.source_logical, 0),
+       applied.target_applied_at, COALESCE(pending.ct, 0)
+  FROM marked
+  LEFT JOIN applied USING (target_schema)
+  LEFT JOIN pending USING (target_schema)
+ ORDER BY marked.target_schema
+`
+
+// ResolvedStatus summarizes the resolved timestamps that have been
+// recorded for a target schema.
+type ResolvedStatus struct {
+	Schema    ident.Schema `json:"schema"`    // The target schema.
+	Marked    hlc.Time     `json:"marked"`    // The latest resolved timestamp received.
+	Applied   hlc.Time     `json:"applied"`   // The latest resolved timestamp applied.
+	AppliedAt time.Time    `json:"appliedAt"` // The time at which Applied was applied.
+	Pending   int          `json:"pending"`   // The number of unapplied resolved timestamps.
+}
+
+// ScanResolvedStatus reports the progress of each target schema that
+// has recorded a resolved timestamp. It is used by tools which inspect
+// the staging database while cdc-sink is not running.
+func ScanResolvedStatus(
+	ctx context.Context, db types.StagingQuerier, metaTable ident.Table,
+) ([]*ResolvedStatus, error) {
+	q := fmt.Sprintf(scanResolvedStatusTemplate, metaTable)
+	rows, err := db.Query(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, q)
+	}
+	defer rows.Close()
+
+	var ret []*ResolvedStatus
+	for rows.Next() {
+		var appliedAt *time.Time
+		var markedNanos, appliedNanos int64
+		var markedLogical, appliedLogical int
+		var schemaRaw string
+		var status ResolvedStatus
+		if err := rows.Scan(&schemaRaw, &markedNanos, &markedLogical,
+			&appliedNanos, &appliedLogical, &appliedAt, &status.Pending); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		status.Schema, err = ident.ParseSchema(schemaRaw)
+		if err != nil {
+			return nil, err
+		}
+		status.Marked = hlc.New(markedNanos, markedLogical)
+		status.Applied = hlc.New(appliedNanos, appliedLogical)
+		if appliedAt != nil {
+			status.AppliedAt = *appliedAt
+		}
+		ret = append(ret, &status)
+	}
+	return ret, errors.WithStack(rows.Err())
+}
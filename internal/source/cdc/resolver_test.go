@@ -91,3 +91,17 @@ func TestResolverDeQueue(t *testing.T) {
 	r.NoError(err)
 	a.Empty(schemas)
 }
+
+func TestStopAtStamp(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	stopAt := &resolvedStamp{}
+	r.NoError(stopAt.ParseStopPoint("100.0000000002"))
+	a.Equal(hlc.New(100, 2), stopAt.CommittedTime)
+	r.Error(stopAt.ParseStopPoint("bogus"))
+
+	// Partial progress before the stop point has not reached it.
+	a.True((&resolvedStamp{CommittedTime: hlc.New(99, 0), ProposedTime: hlc.New(101, 0)}).Less(stopAt))
+	a.False((&resolvedStamp{CommittedTime: hlc.New(100, 3)}).Less(stopAt))
+}
@@ -24,6 +24,7 @@ import (
 	"net/http"
 
 	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
@@ -65,9 +66,17 @@ func ProvideListener(
 // ProvideMux is called by Wire to construct the http.ServeMux that
 // routes requests.
 func ProvideMux(
-	handler *cdc.Handler, stagingPool *types.StagingPool, targetPool *types.TargetPool,
+	handler *cdc.Handler,
+	loops *logical.Factory,
+	stagingPool *types.StagingPool,
+	targetPool *types.TargetPool,
 ) *http.ServeMux {
-	return stdserver.Mux(handler, stagingPool, targetPool)
+	mux := stdserver.Mux(handler, stagingPool, targetPool)
+	mux.Handle("/_/pause", logical.PauseHandler(handler.Authenticator, loops))
+	mux.Handle("/_/stopAt", logical.StopAtHandler(handler.Authenticator, loops))
+	mux.Handle("/_/admin/", logical.AdminHandler(handler.Authenticator, loops))
+	mux.Handle("/_/quarantine", cdc.QuarantineHandler(handler.Authenticator, loops, handler.Resolvers))
+	return mux
 }
 
 // ProvideServer is called by Wire to construct the top-level network
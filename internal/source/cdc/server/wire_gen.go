@@ -15,7 +15,9 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/staging/stage"
 	"github.com/cockroachdb/cdc-sink/internal/staging/version"
 	"github.com/cockroachdb/cdc-sink/internal/target/apply"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
@@ -77,7 +79,12 @@ func NewServer(ctx *stopper.Context, config *Config) (*stdserver.Server, error)
 		return nil, err
 	}
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, err := apply.ProvideFactory(ctx, targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	evolveConfig := logical.ProvideEvolveConfig(baseConfig)
+	evolver, err := evolve.ProvideEvolver(ctx, evolveConfig, diagnostics, targetPool, watchers)
+	if err != nil {
+		return nil, err
+	}
+	appliers, err := apply.ProvideFactory(ctx, targetStatements, configs, diagnostics, dlQs, evolver, targetPool, watchers)
 	if err != nil {
 		return nil, err
 	}
@@ -85,8 +92,9 @@ func NewServer(ctx *stopper.Context, config *Config) (*stdserver.Server, error)
 	if err != nil {
 		return nil, err
 	}
+	stagers := stage.ProvideFactory(stagingPool, stagingSchema, ctx)
 	checker := version.ProvideChecker(stagingPool, memoMemo)
-	factory, err := logical.ProvideFactory(ctx, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagingPool, targetPool, watchers, checker)
+	factory, err := logical.ProvideFactory(ctx, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagers, stagingPool, targetPool, watchers, checker)
 	if err != nil {
 		return nil, err
 	}
@@ -99,21 +107,26 @@ func NewServer(ctx *stopper.Context, config *Config) (*stdserver.Server, error)
 		return nil, err
 	}
 	metaTable := cdc.ProvideMetaTable(cdcConfig)
-	stagers := stage.ProvideFactory(stagingPool, stagingSchema, ctx)
-	resolvers, err := cdc.ProvideResolvers(ctx, cdcConfig, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
+	resolvers, err := cdc.ProvideResolvers(ctx, cdcConfig, diagnostics, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
+	if err != nil {
+		return nil, err
+	}
+	creator := autocreate.ProvideCreator(targetPool, watchers)
+	tableCreator, err := cdc.ProvideTableCreator(ctx, cdcConfig, creator, diagnostics)
 	if err != nil {
 		return nil, err
 	}
 	handler := &cdc.Handler{
 		Authenticator: authenticator,
 		Config:        cdcConfig,
+		Creator:       tableCreator,
 		Immediate:     immediate,
 		Resolvers:     resolvers,
 		StagingPool:   stagingPool,
 		Stores:        stagers,
 		TargetPool:    targetPool,
 	}
-	serveMux := ProvideMux(handler, stagingPool, targetPool)
+	serveMux := ProvideMux(handler, factory, stagingPool, targetPool)
 	tlsConfig, err := ProvideTLSConfig(config)
 	if err != nil {
 		return nil, err
@@ -175,7 +188,12 @@ func newTestFixture(context *stopper.Context, config *Config) (*testFixture, fun
 		return nil, nil, err
 	}
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	evolveConfig := logical.ProvideEvolveConfig(baseConfig)
+	evolver, err := evolve.ProvideEvolver(context, evolveConfig, diagnostics, targetPool, watchers)
+	if err != nil {
+		return nil, nil, err
+	}
+	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, evolver, targetPool, watchers)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -183,8 +201,9 @@ func newTestFixture(context *stopper.Context, config *Config) (*testFixture, fun
 	if err != nil {
 		return nil, nil, err
 	}
+	stagers := stage.ProvideFactory(stagingPool, stagingSchema, context)
 	checker := version.ProvideChecker(stagingPool, memoMemo)
-	factory, err := logical.ProvideFactory(context, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagingPool, targetPool, watchers, checker)
+	factory, err := logical.ProvideFactory(context, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagers, stagingPool, targetPool, watchers, checker)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -197,21 +216,26 @@ func newTestFixture(context *stopper.Context, config *Config) (*testFixture, fun
 		return nil, nil, err
 	}
 	metaTable := cdc.ProvideMetaTable(cdcConfig)
-	stagers := stage.ProvideFactory(stagingPool, stagingSchema, context)
-	resolvers, err := cdc.ProvideResolvers(context, cdcConfig, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
+	resolvers, err := cdc.ProvideResolvers(context, cdcConfig, diagnostics, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
+	if err != nil {
+		return nil, nil, err
+	}
+	creator := autocreate.ProvideCreator(targetPool, watchers)
+	tableCreator, err := cdc.ProvideTableCreator(context, cdcConfig, creator, diagnostics)
 	if err != nil {
 		return nil, nil, err
 	}
 	handler := &cdc.Handler{
 		Authenticator: authenticator,
 		Config:        cdcConfig,
+		Creator:       tableCreator,
 		Immediate:     immediate,
 		Resolvers:     resolvers,
 		StagingPool:   stagingPool,
 		Stores:        stagers,
 		TargetPool:    targetPool,
 	}
-	serveMux := ProvideMux(handler, stagingPool, targetPool)
+	serveMux := ProvideMux(handler, factory, stagingPool, targetPool)
 	tlsConfig, err := ProvideTLSConfig(config)
 	if err != nil {
 		return nil, nil, err
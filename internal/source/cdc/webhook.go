@@ -91,6 +91,11 @@ func (h *Handler) webhook(ctx context.Context, req *request) error {
 		}
 		toProcess.Put(table, append(toProcess.GetZero(table), mut))
 	}
+	if err := toProcess.Range(func(table ident.Table, _ []types.Mutation) error {
+		return h.Creator.Ensure(ctx, table)
+	}); err != nil {
+		return err
+	}
 	if h.Config.Immediate {
 		return h.processMutationsImmediate(ctx, target, toProcess)
 	}
@@ -33,6 +33,9 @@ import (
 // SELECT *, event_op() as __event_op__
 func (h *Handler) webhookForQuery(ctx context.Context, req *request) error {
 	table := req.target.(ident.Table)
+	if err := h.Creator.Ensure(ctx, table); err != nil {
+		return err
+	}
 	keys, err := h.getPrimaryKey(req)
 	if err != nil {
 		return err
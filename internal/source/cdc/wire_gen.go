@@ -12,7 +12,9 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
 	"github.com/cockroachdb/cdc-sink/internal/staging/leases"
 	"github.com/cockroachdb/cdc-sink/internal/target/apply"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/cockroachdb/cdc-sink/internal/util/auth/trust"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
@@ -52,7 +54,12 @@ func newTestFixture(fixture *all.Fixture, config *Config) (*testFixture, error)
 		return nil, err
 	}
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	evolveConfig := logical.ProvideEvolveConfig(baseConfig)
+	evolver, err := evolve.ProvideEvolver(context, evolveConfig, diagnostics, targetPool, watchers)
+	if err != nil {
+		return nil, err
+	}
+	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, evolver, targetPool, watchers)
 	if err != nil {
 		return nil, err
 	}
@@ -61,8 +68,9 @@ func newTestFixture(fixture *all.Fixture, config *Config) (*testFixture, error)
 	if err != nil {
 		return nil, err
 	}
+	stagers := fixture.Stagers
 	checker := fixture.VersionChecker
-	factory, err := logical.ProvideFactory(context, appliers, configs, baseConfig, diagnostics, memo, loader, stagingPool, targetPool, watchers, checker)
+	factory, err := logical.ProvideFactory(context, appliers, configs, baseConfig, diagnostics, memo, loader, stagers, stagingPool, targetPool, watchers, checker)
 	if err != nil {
 		return nil, err
 	}
@@ -79,14 +87,19 @@ func newTestFixture(fixture *all.Fixture, config *Config) (*testFixture, error)
 		return nil, err
 	}
 	metaTable := ProvideMetaTable(config)
-	stagers := fixture.Stagers
-	resolvers, err := ProvideResolvers(context, config, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
+	resolvers, err := ProvideResolvers(context, config, diagnostics, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
+	if err != nil {
+		return nil, err
+	}
+	creator := autocreate.ProvideCreator(targetPool, watchers)
+	tableCreator, err := ProvideTableCreator(context, config, creator, diagnostics)
 	if err != nil {
 		return nil, err
 	}
 	handler := &Handler{
 		Authenticator: authenticator,
 		Config:        config,
+		Creator:       tableCreator,
 		Immediate:     immediate,
 		Resolvers:     resolvers,
 		StagingPool:   stagingPool,
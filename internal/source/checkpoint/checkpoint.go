@@ -0,0 +1,290 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package checkpoint inspects and modifies the replication state that
+// cdc-sink stores in the staging database. It only requires a
+// connection to the staging cluster, so it may be used while cdc-sink
+// is not running.
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/staging/stage"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// The memo table is created by the memo package. The MVCC timestamp
+// of each row tells us when a loop last committed its consistent
+// point.
+const (
+	listTemplate = `
+SELECT key, value, crdb_internal_mvcc_timestamp::STRING FROM %[1]s ORDER BY key`
+	getTemplate = `
+SELECT value, crdb_internal_mvcc_timestamp::STRING FROM %[1]s WHERE key = $1`
+	updateTemplate = `
+UPDATE %[1]s SET value = $3 WHERE key = $1 AND value = $2`
+)
+
+// A Checkpoint is a loop's consistent point, as stored in the memo
+// table.
+type Checkpoint struct {
+	// The name of the loop.
+	Loop string `json:"loop"`
+	// The dialect of the loop, if it could be determined.
+	Dialect string `json:"dialect,omitempty"`
+	// A human-readable representation of the consistent point.
+	Point string `json:"point,omitempty"`
+	// The source time of the consistent point, if the dialect has one.
+	Time time.Time `json:"time"`
+	// The time at which the value was last written.
+	Updated time.Time `json:"updated"`
+	// The value that is stored in the memo table.
+	Value json.RawMessage `json:"value"`
+	// Set if the value could not be decoded.
+	Error string `json:"error,omitempty"`
+
+	stamp stamp.Stamp // Nil if the value could not be decoded.
+}
+
+// Status summarizes the replication state in the staging database.
+type Status struct {
+	Backlog     []*stage.Backlog      `json:"backlog"`
+	Checkpoints []*Checkpoint         `json:"checkpoints"`
+	Resolved    []*cdc.ResolvedStatus `json:"resolved"`
+}
+
+// memoTable returns the name of the table created by the memo package.
+func (c *Config) memoTable() ident.Table {
+	return ident.NewTable(c.StagingSchema, ident.New("memo"))
+}
+
+// parseMVCC converts the MVCC timestamp of a row to a wall time.
+func parseMVCC(mvcc string) (time.Time, error) {
+	ts, err := hlc.Parse(mvcc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ts.Nanos()).UTC(), nil
+}
+
+// newCheckpoint decodes a value from the memo table.
+func (c *Config) newCheckpoint(loop string, value []byte, updated time.Time) *Checkpoint {
+	ret := &Checkpoint{
+		Loop:    loop,
+		Updated: updated,
+	}
+	// The stored value should always be JSON, but we don't want a
+	// single bad value to break the listing.
+	if json.Valid(value) {
+		ret.Value = value
+	} else {
+		ret.Value, _ = json.Marshal(string(value))
+	}
+
+	var err error
+	ret.Dialect, ret.stamp, err = c.decode(loop, value)
+	switch {
+	case err != nil:
+		ret.Error = err.Error()
+	case ret.stamp == nil:
+		ret.Error = "unknown dialect; use --dialect to specify one"
+	default:
+		if s, ok := ret.stamp.(fmt.Stringer); ok {
+			ret.Point = s.String()
+		} else {
+			ret.Point = string(value)
+		}
+		if ts, ok := ret.stamp.(logical.TimeStamp); ok {
+			ret.Time = ts.AsTime().UTC()
+		}
+	}
+	return ret
+}
+
+// ReadCheckpoints returns the consistent points of all loops that
+// have stored one.
+func (c *Config) ReadCheckpoints(
+	ctx context.Context, db types.StagingQuerier,
+) ([]*Checkpoint, error) {
+	q := fmt.Sprintf(listTemplate, c.memoTable())
+	rows, err := db.Query(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, q)
+	}
+	defer rows.Close()
+
+	var ret []*Checkpoint
+	for rows.Next() {
+		var key, mvcc string
+		var value []byte
+		if err := rows.Scan(&key, &value, &mvcc); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !isConsistentPoint(key) {
+			continue
+		}
+		updated, err := parseMVCC(mvcc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loop %s", key)
+		}
+		ret = append(ret, c.newCheckpoint(key, value, updated))
+	}
+	return ret, errors.WithStack(rows.Err())
+}
+
+// ReadStatus returns the consistent points of all loops, the progress
+// of the cdc resolvers, and the number of unapplied mutations in each
+// staging table.
+func (c *Config) ReadStatus(ctx context.Context, db types.StagingQuerier) (*Status, error) {
+	ret := &Status{}
+	var err error
+	ret.Checkpoints, err = c.ReadCheckpoints(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	ret.Resolved, err = cdc.ScanResolvedStatus(ctx, db,
+		ident.NewTable(c.StagingSchema, c.MetaTableName))
+	if err != nil {
+		return nil, err
+	}
+	ret.Backlog, err = stage.ScanBacklog(ctx, db, c.StagingSchema)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// A ChangeResult describes a change to a loop's consistent point.
+type ChangeResult struct {
+	// Set if the new value was written.
+	Applied bool `json:"applied"`
+	// The existing consistent point.
+	From *Checkpoint `json:"from"`
+	// True if the consistent point is being moved backwards.
+	Rewind bool `json:"rewind"`
+	// The new consistent point.
+	To *Checkpoint `json:"to"`
+}
+
+// SetCheckpoint changes a loop's consistent point. The loop must have
+// already stored a consistent point, so that its dialect can be
+// determined. The following checks are made:
+//   - the new value must differ from the existing value;
+//   - moving the consistent point backwards requires Change.Rewind;
+//   - changing the consistent point of a loop that has recently
+//     updated it requires Change.Force, since the loop is likely
+//     running and would overwrite the change. This is only a
+//     heuristic: a running loop which has no new data to apply will
+//     not be detected;
+//   - the update is conditional on the stored value not having changed
+//     since it was read.
+//
+// No changes will be made unless Change.Apply is set.
+func (c *Config) SetCheckpoint(
+	ctx context.Context, db types.StagingQuerier, change *Change,
+) (*ChangeResult, error) {
+	if !isConsistentPoint(change.Loop) {
+		return nil, errors.Errorf("%s is not a loop's consistent point", change.Loop)
+	}
+	memo := c.memoTable()
+
+	var mvcc string
+	var value []byte
+	q := fmt.Sprintf(getTemplate, memo)
+	if err := db.QueryRow(ctx, q, change.Loop).Scan(&value, &mvcc); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.Errorf("loop %s has not stored a consistent point", change.Loop)
+		}
+		return nil, errors.Wrap(err, q)
+	}
+	updated, err := parseMVCC(mvcc)
+	if err != nil {
+		return nil, err
+	}
+	from := c.newCheckpoint(change.Loop, value, updated)
+	if from.stamp == nil {
+		return nil, errors.Errorf("loop %s: %s", change.Loop, from.Error)
+	}
+
+	// Decode the value again, so that the new point will inherit any
+	// dialect-specific configuration (e.g. a MySQL flavor).
+	_, next, err := c.decode(change.Loop, value)
+	if err != nil {
+		return nil, err
+	}
+	if !logical.CanParseStamp(next) {
+		return nil, errors.Errorf("the %s dialect does not support setting a consistent point",
+			from.Dialect)
+	}
+	if err := logical.ParseStamp(next, change.To); err != nil {
+		return nil, errors.Wrapf(err, "could not parse consistent point %q for loop %s",
+			change.To, change.Loop)
+	}
+	nextValue, err := json.Marshal(next)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ret := &ChangeResult{
+		From: from,
+		To:   c.newCheckpoint(change.Loop, nextValue, time.Time{}),
+	}
+
+	switch {
+	case next.Less(from.stamp):
+		if !change.Rewind {
+			return ret, errors.Errorf(
+				"moving loop %s backwards from %s to %s requires --rewind",
+				change.Loop, from.Point, ret.To.Point)
+		}
+		ret.Rewind = true
+	case !from.stamp.Less(next):
+		return ret, errors.Errorf("loop %s is already at %s", change.Loop, from.Point)
+	}
+
+	if age := time.Since(from.Updated); age < change.IdleFor && !change.Force {
+		return ret, errors.Errorf(
+			"loop %s updated its consistent point %s ago and may still be running; "+
+				"stop it first or use --force", change.Loop, age.Round(time.Second))
+	}
+
+	if !change.Apply {
+		return ret, nil
+	}
+
+	q = fmt.Sprintf(updateTemplate, memo)
+	tag, err := db.Exec(ctx, q, change.Loop, value, nextValue)
+	if err != nil {
+		return ret, errors.Wrap(err, q)
+	}
+	if tag.RowsAffected() != 1 {
+		return ret, errors.Errorf(
+			"the consistent point for loop %s was changed concurrently; try again", change.Loop)
+	}
+	ret.Applied = true
+	return ret, nil
+}
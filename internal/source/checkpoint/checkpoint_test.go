@@ -0,0 +1,147 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMemo implements enough of [types.StagingQuerier] to hold a single
+// consistent point.
+type fakeMemo struct {
+	// If true, the stored value is changed by another writer between
+	// the time that it is read and updated.
+	conflict bool
+	// The time at which the value was last written.
+	updated time.Time
+	// The stored value, or nil if there is none.
+	value []byte
+}
+
+var _ types.StagingQuerier = (*fakeMemo)(nil)
+
+func (f *fakeMemo) Exec(_ context.Context, _ string, args ...any) (pgconn.CommandTag, error) {
+	if f.conflict || !bytes.Equal(args[1].([]byte), f.value) {
+		return pgconn.NewCommandTag("UPDATE 0"), nil
+	}
+	f.value = args[2].([]byte)
+	f.updated = time.Now()
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (f *fakeMemo) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, errors.New("unimplemented")
+}
+
+func (f *fakeMemo) QueryRow(context.Context, string, ...any) pgx.Row {
+	return f
+}
+
+// Scan implements pgx.Row.
+func (f *fakeMemo) Scan(dest ...any) error {
+	if f.value == nil {
+		return pgx.ErrNoRows
+	}
+	*dest[0].(*[]byte) = f.value
+	*dest[1].(*string) = hlc.From(f.updated).String()
+	return nil
+}
+
+func TestSetCheckpoint(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	ctx := context.Background()
+
+	cfg := &Config{}
+	memo := &fakeMemo{
+		updated: time.Now().Add(-time.Hour),
+		value:   []byte(`{"lsn":32,"ts":"0001-01-01T00:00:00Z"}`), // 0/20
+	}
+	change := func(to string) *Change {
+		return &Change{IdleFor: time.Minute, Loop: "pglogical", To: to}
+	}
+	point := func() string {
+		cp := cfg.newCheckpoint("pglogical", memo.value, memo.updated)
+		r.Empty(cp.Error)
+		return cp.Point
+	}
+
+	// Only memo keys that hold consistent points may be changed.
+	_, err := cfg.SetCheckpoint(ctx, memo, &Change{Loop: "version-1", To: "0/30"})
+	a.ErrorContains(err, "not a loop's consistent point")
+	_, err = cfg.SetCheckpoint(ctx, &fakeMemo{}, change("0/30"))
+	a.ErrorContains(err, "has not stored a consistent point")
+
+	// Changes are only reported unless applied.
+	res, err := cfg.SetCheckpoint(ctx, memo, change("0/30"))
+	r.NoError(err)
+	a.False(res.Applied)
+	a.Equal("0/20", res.From.Point)
+	a.Equal("0/30", res.To.Point)
+	a.Equal("0/20", point())
+
+	// The new value must differ.
+	_, err = cfg.SetCheckpoint(ctx, memo, change("0/20"))
+	a.ErrorContains(err, "already at")
+
+	// Rewinding requires an explicit option.
+	c := change("0/10")
+	c.Apply = true
+	_, err = cfg.SetCheckpoint(ctx, memo, c)
+	a.ErrorContains(err, "requires --rewind")
+	a.Equal("0/20", point())
+	c.Rewind = true
+	res, err = cfg.SetCheckpoint(ctx, memo, c)
+	r.NoError(err)
+	a.True(res.Applied)
+	a.True(res.Rewind)
+	a.Equal("0/10", point())
+
+	// The value was just written, so the loop appears to be running.
+	c = change("0/40")
+	c.Apply = true
+	_, err = cfg.SetCheckpoint(ctx, memo, c)
+	a.ErrorContains(err, "may still be running")
+	a.Equal("0/10", point())
+	c.Force = true
+	res, err = cfg.SetCheckpoint(ctx, memo, c)
+	r.NoError(err)
+	a.True(res.Applied)
+	a.False(res.Rewind)
+	a.Equal("0/40", point())
+
+	// The update is conditional upon the value that was read.
+	memo.conflict = true
+	c = change("0/50")
+	c.Apply = true
+	c.Force = true
+	res, err = cfg.SetCheckpoint(ctx, memo, c)
+	a.ErrorContains(err, "changed concurrently")
+	a.False(res.Applied)
+	a.Equal("0/40", point())
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package checkpoint
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultIdleFor   = time.Minute
+	defaultMetaTable = "resolved_timestamps"
+)
+
+// Config describes how to find the state that cdc-sink keeps in the
+// staging database.
+type Config struct {
+	// Maps loop names to dialect names, for consistent points whose
+	// dialect cannot be inferred.
+	Dialects map[string]string
+	// The name of the cdc resolved-timestamp table.
+	MetaTableName ident.Ident
+	// Connection string for the staging cluster.
+	StagingConn string
+	// The name of the database and schema that hold the staging tables.
+	StagingSchema ident.Schema
+}
+
+// Bind adds flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	f.StringToStringVar(&c.Dialects, "dialect", nil,
+		"the dialect of a loop whose consistent point cannot be identified "+
+			"(e.g. --dialect myLoop=pglogical); one of "+dialectNames())
+	f.Var(ident.NewValue(defaultMetaTable, &c.MetaTableName), "metaTable",
+		"the name of the table that holds resolved timestamps")
+	f.StringVar(&c.StagingConn, "stagingConn", "",
+		"the staging CockroachDB cluster's connection string")
+	c.StagingSchema = ident.MustSchema(ident.New("_cdc_sink"), ident.Public)
+	f.Var(ident.NewSchemaFlag(&c.StagingSchema), "stagingSchema",
+		"a SQL database schema to store metadata in")
+}
+
+// Preflight updates the configuration with sane defaults or returns an
+// error if there are missing options for which a default cannot be
+// provided.
+func (c *Config) Preflight() error {
+	if c.StagingConn == "" {
+		return errors.New("no stagingConn was configured")
+	}
+	if c.MetaTableName.Empty() {
+		c.MetaTableName = ident.New(defaultMetaTable)
+	}
+	if c.StagingSchema.Empty() {
+		c.StagingSchema = ident.MustSchema(ident.New("_cdc_sink"), ident.Public)
+	}
+	for loop, dialect := range c.Dialects {
+		if _, ok := dialects[dialect]; !ok {
+			return errors.Errorf("unknown dialect %q for loop %s; expecting one of %s",
+				dialect, loop, dialectNames())
+		}
+	}
+	return nil
+}
+
+// Open returns a connection to the staging cluster, which will be
+// closed when the context is stopped.
+func (c *Config) Open(ctx *stopper.Context) (*types.StagingPool, error) {
+	return stdpool.OpenPgxAsStaging(ctx,
+		c.StagingConn,
+		stdpool.WithConnectionLifetime(5*time.Minute),
+		stdpool.WithPoolSize(2),
+		stdpool.WithTransactionTimeout(time.Minute),
+	)
+}
+
+// Change describes an update to a loop's consistent point.
+type Change struct {
+	// Write the new consistent point. Otherwise, the change is only
+	// validated.
+	Apply bool
+	// Allow the consistent point of a recently-active loop to be
+	// changed.
+	Force bool
+	// A loop whose consistent point has been updated more recently
+	// than this is assumed to be running. This cannot detect a running
+	// loop that has not recently made progress.
+	IdleFor time.Duration
+	// The name of the loop.
+	Loop string
+	// Allow the consistent point to be moved backwards.
+	Rewind bool
+	// The new consistent point, in the dialect's stop-point format.
+	To string
+}
+
+// Bind adds flags to the set.
+func (c *Change) Bind(f *pflag.FlagSet) {
+	f.BoolVar(&c.Apply, "apply", false,
+		"write the new consistent point; otherwise, only report what would change")
+	f.BoolVar(&c.Force, "force", false,
+		"change the consistent point even if the loop appears to be running")
+	f.DurationVar(&c.IdleFor, "idleFor", defaultIdleFor,
+		"a loop whose consistent point was updated within this duration is assumed to be running; "+
+			"a running loop that is not making progress will not be detected")
+	f.BoolVar(&c.Rewind, "rewind", false,
+		"allow the consistent point to be moved backwards")
+}
+
+// Preflight updates the configuration with sane defaults or returns an
+// error if there are missing options for which a default cannot be
+// provided.
+func (c *Change) Preflight() error {
+	if c.Loop == "" {
+		return errors.New("no loop name was specified")
+	}
+	if c.To == "" {
+		return errors.New("no consistent point was specified")
+	}
+	if c.IdleFor < 0 {
+		return errors.New("idleFor must not be negative")
+	}
+	return nil
+}
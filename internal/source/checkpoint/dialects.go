@@ -0,0 +1,130 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/fslogical"
+	"github.com/cockroachdb/cdc-sink/internal/source/mylogical"
+	"github.com/cockroachdb/cdc-sink/internal/source/pglogical"
+	"github.com/cockroachdb/cdc-sink/internal/source/querylogical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/pkg/errors"
+)
+
+// Dialect names that may be used with the --dialect flag.
+const (
+	DialectCDC       = "cdc"
+	DialectFirestore = "fslogical"
+	DialectMySQL     = "mylogical"
+	DialectPostgres  = "pglogical"
+	DialectQuery     = "querylogical"
+)
+
+// dialects maps dialect names to a constructor for the stamp type that
+// the dialect stores in the memo table.
+var dialects = map[string]func() stamp.Stamp{
+	DialectCDC:       cdc.ZeroStamp,
+	DialectFirestore: fslogical.ZeroStamp,
+	DialectMySQL:     mylogical.ZeroStamp,
+	DialectPostgres:  pglogical.ZeroStamp,
+	DialectQuery:     querylogical.ZeroStamp,
+}
+
+// dialectNames returns a comma-separated list of the known dialects.
+func dialectNames() string {
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// isConsistentPoint returns false for memo keys that are used for
+// something other than a loop's consistent point.
+func isConsistentPoint(key string) bool {
+	switch {
+	case strings.HasPrefix(key, "fs-doc-"):
+		// Firestore documents that have been processed.
+		return false
+	case strings.HasSuffix(key, "-snapshot"):
+		// Progress of a pglogical or mylogical snapshot.
+		return false
+	case strings.HasPrefix(key, "version-"):
+		// Markers written by the staging/version package.
+		return false
+	default:
+		return true
+	}
+}
+
+// inferDialect guesses the dialect of a loop from its name. The
+// default loop names of the dialects are recognized, as are the loops
+// that the cdc resolvers and querylogical create.
+func inferDialect(loop string) string {
+	switch {
+	case strings.HasPrefix(loop, "changefeed-"):
+		return DialectCDC
+	case strings.HasPrefix(loop, DialectQuery+"-"):
+		return DialectQuery
+	}
+	if _, ok := dialects[loop]; ok {
+		return loop
+	}
+	return ""
+}
+
+// decode determines the dialect of the stored consistent point and
+// decodes it. A dialect is chosen by, in order: the user-provided
+// hints, the name of the loop, or by finding the only stamp type that
+// strictly decodes the value. An empty dialect name is returned if
+// the value is ambiguous.
+func (c *Config) decode(loop string, data []byte) (string, stamp.Stamp, error) {
+	dialect := c.Dialects[loop]
+	if dialect == "" {
+		dialect = inferDialect(loop)
+	}
+	if dialect != "" {
+		ret := dialects[dialect]()
+		if err := json.Unmarshal(data, ret); err != nil {
+			return "", nil, errors.Wrapf(err, "could not decode %s consistent point", dialect)
+		}
+		return dialect, ret, nil
+	}
+
+	var found string
+	var ret stamp.Stamp
+	for name, ctor := range dialects {
+		s := ctor()
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(s); err != nil {
+			continue
+		}
+		if found != "" {
+			return "", nil, nil
+		}
+		found, ret = name, s
+	}
+	return found, ret, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package checkpoint
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	const mysqlPoint = `{"flavor":"mysql","gtid":"6fa7e6ef-c49a-11ec-950a-0242ac120002:1-10","ts":"2023-01-01T00:00:00Z"}`
+
+	encode := func(ctor func() stamp.Stamp, raw string) string {
+		s := ctor()
+		require.NoError(t, logical.ParseStamp(s, raw))
+		buf, err := json.Marshal(s)
+		require.NoError(t, err)
+		return string(buf)
+	}
+
+	tcs := []struct {
+		name    string
+		loop    string
+		value   string
+		hints   map[string]string
+		dialect string
+		point   string
+		err     string
+	}{
+		{
+			name:    "cdc by name",
+			loop:    "changefeed-db.public",
+			value:   encode(dialects[DialectCDC], "1234.0000000001"),
+			dialect: DialectCDC,
+		},
+		{
+			name:    "default loop name",
+			loop:    "pglogical",
+			value:   encode(dialects[DialectPostgres], "0/16B3748"),
+			dialect: DialectPostgres,
+			point:   "0/16B3748",
+		},
+		{
+			name:    "postgres by value",
+			loop:    "orders",
+			value:   encode(dialects[DialectPostgres], "0/16B3748"),
+			dialect: DialectPostgres,
+			point:   "0/16B3748",
+		},
+		{
+			name:    "mysql by value",
+			loop:    "orders",
+			value:   mysqlPoint,
+			dialect: DialectMySQL,
+			point:   "6fa7e6ef-c49a-11ec-950a-0242ac120002:1-10",
+		},
+		{
+			name:  "ambiguous",
+			loop:  "orders",
+			value: encode(dialects[DialectFirestore], "2023-01-01T00:00:00Z"),
+		},
+		{
+			name:    "hinted",
+			loop:    "orders",
+			value:   encode(dialects[DialectFirestore], "2023-01-01T00:00:00Z"),
+			hints:   map[string]string{"orders": DialectFirestore},
+			dialect: DialectFirestore,
+		},
+		{
+			name:  "bad hint",
+			loop:  "orders",
+			value: `{"lsn":"bogus"}`,
+			hints: map[string]string{"orders": DialectPostgres},
+			err:   "could not decode pglogical consistent point",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := assert.New(t)
+			cfg := &Config{Dialects: tc.hints}
+
+			cp := cfg.newCheckpoint(tc.loop, []byte(tc.value), time.Now())
+			if tc.err != "" {
+				a.Contains(cp.Error, tc.err)
+				return
+			}
+			a.Equal(tc.dialect, cp.Dialect)
+			if tc.dialect == "" {
+				a.Nil(cp.stamp)
+				a.NotEmpty(cp.Error)
+				return
+			}
+			a.Empty(cp.Error)
+			a.NotNil(cp.stamp)
+			if tc.point != "" {
+				a.Equal(tc.point, cp.Point)
+			}
+		})
+	}
+}
+
+func TestIsConsistentPoint(t *testing.T) {
+	a := assert.New(t)
+	a.True(isConsistentPoint("pglogical"))
+	a.True(isConsistentPoint("changefeed-db.public"))
+	a.False(isConsistentPoint("fs-doc-projects/p/databases/d/documents/c/1"))
+	a.False(isConsistentPoint("mylogical-snapshot"))
+	a.False(isConsistentPoint("version-1234"))
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package checkpoint
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// formatTime returns a placeholder for zero times.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// orDash returns a placeholder for empty strings.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// WriteCheckpoints writes a table of consistent points.
+func WriteCheckpoints(out io.Writer, checkpoints []*Checkpoint) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LOOP\tDIALECT\tPOINT\tTIME\tUPDATED")
+	for _, cp := range checkpoints {
+		point := cp.Point
+		if cp.Error != "" {
+			point = fmt.Sprintf("%s (%s)", cp.Value, cp.Error)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", cp.Loop, orDash(cp.Dialect),
+			point, formatTime(cp.Time), formatTime(cp.Updated))
+	}
+	return w.Flush()
+}
+
+// WriteText writes the status as a series of tables.
+func (s *Status) WriteText(out io.Writer) error {
+	fmt.Fprintln(out, "Consistent points:")
+	if err := WriteCheckpoints(out, s.Checkpoints); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nResolved timestamps:")
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCHEMA\tMARKED\tAPPLIED\tAPPLIED AT\tPENDING")
+	for _, r := range s.Resolved {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", r.Schema.Raw(), r.Marked,
+			r.Applied, formatTime(r.AppliedAt), r.Pending)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nStaging backlog:")
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tUNAPPLIED\tOLDEST")
+	for _, b := range s.Backlog {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", b.Table.Raw(), b.Unapplied, formatTime(b.Oldest))
+	}
+	return w.Flush()
+}
+
+// WriteText describes the change.
+func (r *ChangeResult) WriteText(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "loop:\t%s (%s)\n", r.From.Loop, r.From.Dialect)
+	fmt.Fprintf(w, "from:\t%s\t%s\n", r.From.Point, r.From.Value)
+	fmt.Fprintf(w, "to:\t%s\t%s\n", r.To.Point, r.To.Value)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	switch {
+	case r.Applied:
+		fmt.Fprintln(out, "the consistent point has been updated")
+	default:
+		fmt.Fprintln(out, "dry run; use --apply to update the consistent point")
+	}
+	return nil
+}
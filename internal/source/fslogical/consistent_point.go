@@ -20,7 +20,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
 	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/pkg/errors"
 )
 
 // A consistentPoint has two flavors to support backfilling
@@ -33,7 +35,10 @@ type consistentPoint struct {
 	Time time.Time `json:"t,omitempty"`
 }
 
-var _ stamp.Stamp = (*consistentPoint)(nil)
+var (
+	_ logical.StopPointStamp = (*consistentPoint)(nil)
+	_ stamp.Stamp            = (*consistentPoint)(nil)
+)
 
 func streamPoint(ts time.Time) *consistentPoint {
 	return &consistentPoint{
@@ -58,6 +63,20 @@ func (t *consistentPoint) IsZero() bool {
 	return t.BackfillID == "" && t.Time.IsZero()
 }
 
+// ParseStopPoint implements logical.StopPointStamp. It accepts an
+// RFC3339 timestamp.
+func (t *consistentPoint) ParseStopPoint(raw string) error {
+	ts, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	*t = consistentPoint{Time: ts}
+	return nil
+}
+
+// ZeroStamp returns an empty stamp for decoding stored consistent points.
+func ZeroStamp() stamp.Stamp { return &consistentPoint{} }
+
 // Less implements stamp.Stamp.
 func (t *consistentPoint) Less(other stamp.Stamp) bool {
 	o := other.(*consistentPoint)
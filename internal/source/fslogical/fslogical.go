@@ -22,6 +22,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -641,14 +642,32 @@ func processedKey(ref *firestore.DocumentRef) string {
 // FSLogical is the top-level injection type.
 type FSLogical struct {
 	Diagnostics *diag.Diagnostics
+	Factory     *logical.Factory
 	Loops       []*logical.Loop
 }
 
 var (
 	_ stdlogical.HasDiagnostics = (*FSLogical)(nil)
+	_ stdlogical.HasHandlers    = (*FSLogical)(nil)
+	_ stdlogical.HasStopPoint   = (*FSLogical)(nil)
 )
 
+// AddHandlers implements stdlogical.HasHandlers to allow the replica
+// to be paused or stopped at a specific point, and to provide the
+// admin API.
+func (l *FSLogical) AddHandlers(auth types.Authenticator, mux *http.ServeMux) {
+	mux.Handle("/_/pause", logical.PauseHandler(auth, l.Factory))
+	mux.Handle("/_/stopAt", logical.StopAtHandler(auth, l.Factory))
+	mux.Handle("/_/admin/", logical.AdminHandler(auth, l.Factory))
+}
+
 // GetDiagnostics implements stdlogical.HasDiagnostics.
 func (l *FSLogical) GetDiagnostics() *diag.Diagnostics {
 	return l.Diagnostics
 }
+
+// StopPoint implements stdlogical.HasStopPoint. The channel will be
+// closed once all collections have reached the stop point.
+func (l *FSLogical) StopPoint() (string, <-chan struct{}) {
+	return logical.StopPoint(l.Loops)
+}
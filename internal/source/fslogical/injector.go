@@ -52,13 +52,35 @@ func Start(*stopper.Context, *Config) (*FSLogical, error) {
 	))
 }
 
+// StartShared creates a Firestore logical replication loop which uses
+// resources that are shared with other loops in the process.
+func StartShared(
+	ctx *stopper.Context, config *Config, diags *diag.Diagnostics, shared *logical.Shared,
+) (*FSLogical, error) {
+	panic(wire.Build(
+		wire.Bind(new(context.Context), new(*stopper.Context)),
+		wire.Bind(new(logical.Config), new(*Config)),
+		wire.FieldsOf(new(*logical.Shared), "Appliers", "Configs", "Memo", "Stagers",
+			"StagingPool", "TargetPool", "VersionChecker", "Watchers"),
+		wire.Struct(new(FSLogical), "*"),
+		ProvideFirestoreClient,
+		ProvideLoops,
+		ProvideScriptTarget,
+		ProvideTombstones,
+		logical.ProvideBaseConfig,
+		logical.ProvideFactory,
+		logical.ProvideUserScriptConfig,
+		script.Set,
+	))
+}
+
 // Build remaining testable components from a common fixture.
 func startLoopsFromFixture(*all.Fixture, *Config) ([]*logical.Loop, error) {
 	panic(wire.Build(
 		wire.Bind(new(logical.Config), new(*Config)),
 		wire.FieldsOf(new(*base.Fixture), "Context"),
 		wire.FieldsOf(new(*all.Fixture),
-			"Fixture", "Configs", "Memo", "VersionChecker"),
+			"Fixture", "Configs", "Memo", "Stagers", "VersionChecker"),
 		ProvideFirestoreClient,
 		ProvideLoops,
 		ProvideScriptTarget,
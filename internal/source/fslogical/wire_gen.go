@@ -11,9 +11,11 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
 	"github.com/cockroachdb/cdc-sink/internal/staging/memo"
+	"github.com/cockroachdb/cdc-sink/internal/staging/stage"
 	"github.com/cockroachdb/cdc-sink/internal/staging/version"
 	"github.com/cockroachdb/cdc-sink/internal/target/apply"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
@@ -65,7 +67,12 @@ func Start(context *stopper.Context, config *Config) (*FSLogical, error) {
 	}
 	dlqConfig := logical.ProvideDLQConfig(baseConfig)
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	evolveConfig := logical.ProvideEvolveConfig(baseConfig)
+	evolver, err := evolve.ProvideEvolver(context, evolveConfig, diagnostics, targetPool, watchers)
+	if err != nil {
+		return nil, err
+	}
+	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, evolver, targetPool, watchers)
 	if err != nil {
 		return nil, err
 	}
@@ -81,8 +88,9 @@ func Start(context *stopper.Context, config *Config) (*FSLogical, error) {
 	if err != nil {
 		return nil, err
 	}
+	stagers := stage.ProvideFactory(stagingPool, stagingSchema, context)
 	checker := version.ProvideChecker(stagingPool, memoMemo)
-	factory, err := logical.ProvideFactory(context, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagingPool, targetPool, watchers, checker)
+	factory, err := logical.ProvideFactory(context, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagers, stagingPool, targetPool, watchers, checker)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +104,59 @@ func Start(context *stopper.Context, config *Config) (*FSLogical, error) {
 	}
 	fsLogical := &FSLogical{
 		Diagnostics: diagnostics,
+		Factory:     factory,
+		Loops:       v,
+	}
+	return fsLogical, nil
+}
+
+// StartShared creates a Firestore logical replication loop which uses
+// resources that are shared with other loops in the process.
+func StartShared(ctx *stopper.Context, config *Config, diags *diag.Diagnostics, shared *logical.Shared) (*FSLogical, error) {
+	configs := shared.Configs
+	scriptConfig, err := logical.ProvideUserScriptConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	loader, err := script.ProvideLoader(scriptConfig)
+	if err != nil {
+		return nil, err
+	}
+	targetSchema := ProvideScriptTarget(config)
+	watchers := shared.Watchers
+	userScript, err := script.ProvideUserScript(configs, loader, diags, targetSchema, watchers)
+	if err != nil {
+		return nil, err
+	}
+	client, err := ProvideFirestoreClient(ctx, config, userScript)
+	if err != nil {
+		return nil, err
+	}
+	appliers := shared.Appliers
+	baseConfig, err := logical.ProvideBaseConfig(config, loader)
+	if err != nil {
+		return nil, err
+	}
+	typesMemo := shared.Memo
+	stagers := shared.Stagers
+	stagingPool := shared.StagingPool
+	targetPool := shared.TargetPool
+	checker := shared.VersionChecker
+	factory, err := logical.ProvideFactory(ctx, appliers, configs, baseConfig, diags, typesMemo, loader, stagers, stagingPool, targetPool, watchers, checker)
+	if err != nil {
+		return nil, err
+	}
+	tombstones, err := ProvideTombstones(config, client, factory, userScript)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ProvideLoops(config, client, factory, typesMemo, stagingPool, tombstones, userScript)
+	if err != nil {
+		return nil, err
+	}
+	fsLogical := &FSLogical{
+		Diagnostics: diags,
+		Factory:     factory,
 		Loops:       v,
 	}
 	return fsLogical, nil
@@ -142,7 +203,12 @@ func startLoopsFromFixture(fixture *all.Fixture, config *Config) ([]*logical.Loo
 	}
 	dlqConfig := logical.ProvideDLQConfig(baseConfig)
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	evolveConfig := logical.ProvideEvolveConfig(baseConfig)
+	evolver, err := evolve.ProvideEvolver(context, evolveConfig, diagnostics, targetPool, watchers)
+	if err != nil {
+		return nil, err
+	}
+	appliers, err := apply.ProvideFactory(context, targetStatements, configs, diagnostics, dlQs, evolver, targetPool, watchers)
 	if err != nil {
 		return nil, err
 	}
@@ -151,8 +217,9 @@ func startLoopsFromFixture(fixture *all.Fixture, config *Config) ([]*logical.Loo
 	if err != nil {
 		return nil, err
 	}
+	stagers := fixture.Stagers
 	checker := fixture.VersionChecker
-	factory, err := logical.ProvideFactory(context, appliers, configs, baseConfig, diagnostics, typesMemo, loader, stagingPool, targetPool, watchers, checker)
+	factory, err := logical.ProvideFactory(context, appliers, configs, baseConfig, diagnostics, typesMemo, loader, stagers, stagingPool, targetPool, watchers, checker)
 	if err != nil {
 		return nil, err
 	}
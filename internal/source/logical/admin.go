@@ -0,0 +1,645 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/httpauth"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/retry"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// AdminSchema is passed to the authenticator by the admin API. A token
+// must carry a claim for this schema in order to use the API.
+var AdminSchema = ident.MustSchema(ident.New("_"), ident.New("admin"))
+
+// moveTokenLifetime bounds the time between a request to move a loop's
+// consistent point and its confirmation.
+const moveTokenLifetime = 5 * time.Minute
+
+// Name returns the name of the loop.
+func (l *Loop) Name() string {
+	return l.loop.loopConfig.LoopName
+}
+
+// TargetSchema returns the schema into which the loop writes.
+func (l *Loop) TargetSchema() ident.Schema {
+	return l.loop.loopConfig.TargetSchema
+}
+
+// Pause stops the loop from reading from its source and from applying
+// any staged data until Resume is called. Any data already being
+// processed by the loop will be allowed to complete. If the loop's
+// Dialect is a Lessor, the lease is retained while the loop is paused.
+func (l *Loop) Pause() {
+	l.loop.paused.Set(true)
+}
+
+// Paused returns true if Pause has been called and a channel that will
+// be closed when the state changes.
+func (l *Loop) Paused() (bool, <-chan struct{}) {
+	return l.loop.paused.Get()
+}
+
+// Resume reverses a call to Pause.
+func (l *Loop) Resume() {
+	l.loop.paused.Set(false)
+}
+
+// MoveConsistentPoint replaces the loop's consistent point. The format
+// of the value depends on the source, as with a stop point. The loop
+// will be paused while the new value is written and then resumed,
+// unless it had already been paused. If the loop's Dialect is a Lessor,
+// the move will be refused unless this process holds the lease, since
+// the instance of cdc-sink that does hold it would overwrite the new
+// value. This method returns the previous and new consistent points.
+func (l *Loop) MoveConsistentPoint(ctx context.Context, raw string) (from, to stamp.Stamp, _ error) {
+	to, err := l.loop.parseStamp(raw, "consistent point")
+	if err != nil {
+		return nil, nil, err
+	}
+	if to == nil {
+		return nil, nil, errors.New("a consistent point must be specified")
+	}
+
+	l.loop.moveMu.Lock()
+	defer l.loop.moveMu.Unlock()
+
+	_, isLessor := l.loop.loopConfig.Dialect.(Lessor)
+	leased, leaseChanged := l.loop.leased.Get()
+	if isLessor && !leased {
+		return nil, nil, &adminError{http.StatusConflict, errors.Errorf(
+			"loop %s is not leased by this process", l.Name())}
+	}
+
+	wasPaused, _ := l.loop.paused.Get()
+	l.loop.paused.Set(true)
+	if !wasPaused {
+		defer l.loop.paused.Set(false)
+	}
+
+	// Wait for the loop to stop processing. A Lessor loop only becomes
+	// idle while it holds the lease.
+	for {
+		idle, changed := l.loop.idle.Get()
+		if idle {
+			break
+		}
+		select {
+		case <-changed:
+		case <-leaseChanged:
+			if leased, leaseChanged = l.loop.leased.Get(); isLessor && !leased {
+				return nil, nil, &adminError{http.StatusConflict, errors.Errorf(
+					"loop %s lost its lease", l.Name())}
+			}
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-l.loop.running.Stopping():
+			return nil, nil, errors.Errorf("loop %s is stopping", l.Name())
+		}
+	}
+
+	from, _ = l.loop.consistentPoint.Get()
+	if err := l.loop.storeConsistentPoint(to); err != nil {
+		return nil, nil, err
+	}
+	l.loop.consistentPoint.Set(to)
+	log.WithFields(log.Fields{
+		"from": from,
+		"to":   to,
+	}).Warnf("consistent point for loop %s moved by operator request", l.Name())
+	return from, to, nil
+}
+
+// Loops returns the loops which have been started by the Factory.
+func (f *Factory) Loops() []*Loop {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*Loop(nil), f.mu.started...)
+}
+
+// An auditor records the actions taken through the admin API.
+type auditor interface {
+	// Begin records the request before the action is taken. The
+	// returned function will record the outcome of the action.
+	Begin(ctx context.Context, action, target, details, remote string) (func(outcome string), error)
+}
+
+// auditLog records actions in a table in the staging schema.
+type auditLog struct {
+	pool  *types.StagingPool
+	table ident.Table
+
+	mu struct {
+		sync.Mutex
+		ready bool // The table has been created.
+	}
+}
+
+const (
+	auditSchema = `
+CREATE TABLE IF NOT EXISTS %[1]s (
+  id      UUID        NOT NULL DEFAULT gen_random_uuid() PRIMARY KEY,
+  at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+  action  STRING      NOT NULL,
+  target  STRING      NOT NULL,
+  details STRING      NOT NULL,
+  remote  STRING      NOT NULL,
+  outcome STRING
+)`
+	auditInsertTemplate = `
+INSERT INTO %[1]s (action, target, details, remote) VALUES ($1, $2, $3, $4) RETURNING id`
+	auditOutcomeTemplate = `UPDATE %[1]s SET outcome = $2 WHERE id = $1`
+)
+
+// auditor returns the log of actions taken through the administrative
+// endpoints, which is shared by all of the endpoints of the Factory.
+func (f *Factory) auditor() auditor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.mu.audit == nil {
+		f.mu.audit = &auditLog{
+			pool:  f.stagingPool,
+			table: ident.NewTable(f.baseConfig.StagingSchema, ident.New("admin_audit")),
+		}
+	}
+	return f.mu.audit
+}
+
+// Begin implements auditor.
+func (a *auditLog) Begin(
+	ctx context.Context, action, target, details, remote string,
+) (func(outcome string), error) {
+	// Create the table on first use, so that the API adds no overhead
+	// to a process which never uses it.
+	a.mu.Lock()
+	if !a.mu.ready {
+		if err := retry.Execute(ctx, a.pool, fmt.Sprintf(auditSchema, a.table)); err != nil {
+			a.mu.Unlock()
+			return nil, errors.Wrap(err, "could not create audit table")
+		}
+		a.mu.ready = true
+	}
+	a.mu.Unlock()
+
+	var id uuid.UUID
+	if err := a.pool.QueryRow(ctx, fmt.Sprintf(auditInsertTemplate, a.table),
+		action, target, details, remote,
+	).Scan(&id); err != nil {
+		return nil, errors.Wrap(err, "could not write audit record")
+	}
+	return func(outcome string) {
+		// Use a background context, since the request may have been
+		// canceled after the action was taken.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := retry.Execute(ctx, a.pool,
+			fmt.Sprintf(auditOutcomeTemplate, a.table), id, outcome); err != nil {
+			log.WithError(err).Warnf("could not record outcome of admin action %s", id)
+		}
+	}, nil
+}
+
+// admin implements the handler returned by AdminHandler.
+type admin struct {
+	audit   auditor
+	auth    types.Authenticator
+	factory *Factory
+	mux     *http.ServeMux
+
+	mu struct {
+		sync.Mutex
+		// Confirmation tokens for requests to move a consistent point.
+		moves map[string]*pendingMove
+	}
+}
+
+// pendingMove is an unconfirmed request to move a consistent point.
+type pendingMove struct {
+	expires time.Time
+	loop    string
+	to      string
+}
+
+// AdminHandler returns an http.Handler that provides runtime control
+// over the loops created by the Factory. It should be mounted at
+// /_/admin/ and supports the following endpoints:
+//
+//   - GET  loops: Report the loops and their consistent points.
+//   - POST pause, resume: Control loops selected by a loop or schema
+//     parameter. Selecting a schema will affect a cdc resolver.
+//   - POST move: Set the consistent point of the named loop to the
+//     value in the to parameter. The first request returns a token
+//     which must be provided in a second request to confirm the move.
+//   - POST refresh: Refresh the target schema named by the schema
+//     parameter.
+//   - POST retire: Remove staged mutations in the table parameter that
+//     are older than the before parameter, an HLC timestamp.
+//
+// Requests will be authenticated against [AdminSchema]. Each action is
+// recorded in an admin_audit table in the staging schema.
+func AdminHandler(auth types.Authenticator, factory *Factory) http.Handler {
+	return newAdmin(auth, factory, factory.auditor())
+}
+
+// AdminGuard protects an administrative endpoint that is served
+// outside of the admin API, such as /_/pause. Requests are
+// authenticated against [AdminSchema] and any request other than a GET
+// is recorded, along with its response status, in the same audit table
+// as the admin API.
+func AdminGuard(
+	auth types.Authenticator, factory *Factory, name string, h http.Handler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ok, err := auth.Check(req.Context(), AdminSchema, httpauth.Token(req))
+		if err != nil {
+			log.WithError(err).Warn("could not authenticate request")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if req.Method == http.MethodGet {
+			h.ServeHTTP(w, req)
+			return
+		}
+		if err := req.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		details, err := json.Marshal(req.Form)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		finish, err := factory.auditor().Begin(req.Context(),
+			name+" "+req.Method, formTarget(req.Form), string(details), req.RemoteAddr)
+		if err != nil {
+			log.WithError(err).Warn("refusing admin action which could not be audited")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		h.ServeHTTP(rec, req)
+		if rec.code < http.StatusBadRequest {
+			finish("ok")
+		} else {
+			finish(http.StatusText(rec.code))
+		}
+	})
+}
+
+// statusRecorder captures the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// formTarget returns the object of an administrative request.
+func formTarget(form url.Values) string {
+	for _, key := range []string{"loop", "schema", "table"} {
+		if target := form.Get(key); target != "" {
+			return target
+		}
+	}
+	return ""
+}
+
+func newAdmin(auth types.Authenticator, factory *Factory, audit auditor) *admin {
+	ret := &admin{
+		audit:   audit,
+		auth:    auth,
+		factory: factory,
+		mux:     &http.ServeMux{},
+	}
+	ret.mu.moves = make(map[string]*pendingMove)
+
+	ret.mux.HandleFunc("/_/admin/loops", ret.loops)
+	ret.mux.Handle("/_/admin/move", ret.action("move", ret.move))
+	ret.mux.Handle("/_/admin/pause", ret.action("pause", ret.pause))
+	ret.mux.Handle("/_/admin/refresh", ret.action("refresh", ret.refresh))
+	ret.mux.Handle("/_/admin/resume", ret.action("resume", ret.resume))
+	ret.mux.Handle("/_/admin/retire", ret.action("retire", ret.retire))
+	return ret
+}
+
+// ServeHTTP implements http.Handler.
+func (a *admin) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ok, err := a.auth.Check(req.Context(), AdminSchema, httpauth.Token(req))
+	if err != nil {
+		log.WithError(err).Warn("could not authenticate request")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	a.mux.ServeHTTP(w, req)
+}
+
+// An adminError carries an HTTP status code.
+type adminError struct {
+	code int
+	err  error
+}
+
+func (e *adminError) Error() string { return e.err.Error() }
+
+// badRequest returns an error that will be reported to the caller with
+// a 400 status code.
+func badRequest(format string, args ...any) error {
+	return &adminError{http.StatusBadRequest, errors.Errorf(format, args...)}
+}
+
+// action wraps a mutating endpoint to require a POST and to record
+// the request and its outcome in the audit log. The function returns
+// the target of the action and a json-serializable result.
+func (a *admin) action(
+	name string, fn func(req *http.Request) (target string, result any, err error),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := req.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		details, err := json.Marshal(req.Form)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		finish, err := a.audit.Begin(req.Context(), name, formTarget(req.Form), string(details), req.RemoteAddr)
+		if err != nil {
+			log.WithError(err).Warn("refusing admin action which could not be audited")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		target, result, err := fn(req)
+		if err != nil {
+			finish(err.Error())
+			code := http.StatusInternalServerError
+			if x := (*adminError)(nil); errors.As(err, &x) {
+				code = x.code
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		finish("ok")
+		log.Infof("admin action %s on %s", name, target)
+
+		w.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
+
+// loopState is reported by the loops endpoint.
+type loopState struct {
+	ConsistentPoint stamp.Stamp `json:"consistentPoint"`
+	Name            string      `json:"name"`
+	Paused          bool        `json:"paused"`
+	StoppedAt       stamp.Stamp `json:"stoppedAt,omitempty"`
+	TargetSchema    string      `json:"targetSchema"`
+}
+
+// loops reports the current state of the loops.
+func (a *admin) loops(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "GET required", http.StatusMethodNotAllowed)
+		return
+	}
+	loops := a.factory.Loops()
+	ret := make([]*loopState, len(loops))
+	for idx, l := range loops {
+		ret[idx] = &loopState{
+			Name:         l.Name(),
+			TargetSchema: l.TargetSchema().Raw(),
+		}
+		ret[idx].ConsistentPoint, _ = l.GetConsistentPoint()
+		ret[idx].Paused, _ = l.Paused()
+		ret[idx].StoppedAt, _ = l.loop.stoppedAt.Get()
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"loops": ret})
+}
+
+// selectLoops returns the loops chosen by the loop or schema
+// parameters.
+func (a *admin) selectLoops(req *http.Request) (string, []*Loop, error) {
+	name, rawSchema := req.Form.Get("loop"), req.Form.Get("schema")
+	if (name == "") == (rawSchema == "") {
+		return "", nil, badRequest("exactly one of loop or schema must be specified")
+	}
+	var schema ident.Schema
+	if rawSchema != "" {
+		var err error
+		schema, err = ident.ParseSchema(rawSchema)
+		if err != nil {
+			return "", nil, badRequest("could not parse schema: %v", err)
+		}
+	}
+
+	var ret []*Loop
+	for _, l := range a.factory.Loops() {
+		if name != "" && l.Name() == name {
+			ret = append(ret, l)
+		} else if rawSchema != "" && ident.Equal(l.TargetSchema(), schema) {
+			ret = append(ret, l)
+		}
+	}
+	target := name
+	if target == "" {
+		target = rawSchema
+	}
+	if len(ret) == 0 {
+		return "", nil, &adminError{http.StatusNotFound, errors.Errorf("no loops match %s", target)}
+	}
+	return target, ret, nil
+}
+
+// pause is the pause endpoint.
+func (a *admin) pause(req *http.Request) (string, any, error) {
+	target, loops, err := a.selectLoops(req)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, l := range loops {
+		l.Pause()
+	}
+	return target, a.pauseState(loops), nil
+}
+
+// resume is the resume endpoint.
+func (a *admin) resume(req *http.Request) (string, any, error) {
+	target, loops, err := a.selectLoops(req)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, l := range loops {
+		l.Resume()
+	}
+	return target, a.pauseState(loops), nil
+}
+
+// pauseState reports the pause state of each loop.
+func (a *admin) pauseState(loops []*Loop) map[string]bool {
+	ret := make(map[string]bool, len(loops))
+	for _, l := range loops {
+		ret[l.Name()], _ = l.Paused()
+	}
+	return ret
+}
+
+// move is the move endpoint. An unconfirmed request returns a
+// confirmation token, which is single-use and is bound to the loop and
+// the requested consistent point.
+func (a *admin) move(req *http.Request) (string, any, error) {
+	name, to := req.Form.Get("loop"), req.Form.Get("to")
+	if name == "" || to == "" {
+		return "", nil, badRequest("loop and to parameters are required")
+	}
+	var loop *Loop
+	for _, l := range a.factory.Loops() {
+		if l.Name() == name {
+			loop = l
+			break
+		}
+	}
+	if loop == nil {
+		return "", nil, &adminError{http.StatusNotFound, errors.Errorf("no loop named %s", name)}
+	}
+	// Validate the requested value before issuing a token.
+	if _, err := loop.loop.parseStamp(to, "consistent point"); err != nil {
+		return "", nil, badRequest("%v", err)
+	}
+
+	token := req.Form.Get("token")
+	if token == "" {
+		var buf [16]byte
+		if _, err := rand.Read(buf[:]); err != nil {
+			return "", nil, errors.WithStack(err)
+		}
+		token = hex.EncodeToString(buf[:])
+		expires := time.Now().Add(moveTokenLifetime)
+
+		a.mu.Lock()
+		for key, pending := range a.mu.moves {
+			if time.Now().After(pending.expires) {
+				delete(a.mu.moves, key)
+			}
+		}
+		a.mu.moves[token] = &pendingMove{expires: expires, loop: name, to: to}
+		a.mu.Unlock()
+
+		from, _ := loop.GetConsistentPoint()
+		return name, map[string]any{
+			"confirm": token,
+			"expires": expires,
+			"from":    from,
+			"loop":    name,
+			"to":      to,
+		}, nil
+	}
+
+	a.mu.Lock()
+	pending, ok := a.mu.moves[token]
+	delete(a.mu.moves, token)
+	a.mu.Unlock()
+	if !ok || time.Now().After(pending.expires) || pending.loop != name || pending.to != to {
+		return "", nil, badRequest("invalid or expired confirmation token")
+	}
+
+	from, next, err := loop.MoveConsistentPoint(req.Context(), to)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, map[string]any{
+		"from": from,
+		"loop": name,
+		"to":   next,
+	}, nil
+}
+
+// refresh is the refresh endpoint.
+func (a *admin) refresh(req *http.Request) (string, any, error) {
+	schema, err := ident.ParseSchema(req.Form.Get("schema"))
+	if err != nil {
+		return "", nil, badRequest("could not parse schema: %v", err)
+	}
+	watcher, err := a.factory.watchers.Get(schema)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := watcher.Refresh(req.Context(), a.factory.targetPool); err != nil {
+		return "", nil, err
+	}
+	return schema.Raw(), map[string]string{"refreshed": schema.Raw()}, nil
+}
+
+// retire is the retire endpoint.
+func (a *admin) retire(req *http.Request) (string, any, error) {
+	table, err := ident.ParseTable(req.Form.Get("table"))
+	if err != nil {
+		return "", nil, badRequest("could not parse table: %v", err)
+	}
+	before, err := hlc.Parse(req.Form.Get("before"))
+	if err != nil {
+		return "", nil, badRequest("could not parse before: %v", err)
+	}
+	stager, err := a.factory.stagers.Get(req.Context(), table)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := stager.Retire(req.Context(), a.factory.stagingPool, before); err != nil {
+		return "", nil, err
+	}
+	return table.Raw(), map[string]string{
+		"before":  before.String(),
+		"retired": table.Raw(),
+	}, nil
+}
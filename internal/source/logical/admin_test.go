@@ -0,0 +1,267 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/reject"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/trust"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adminStamp can be parsed from user input.
+type adminStamp struct{ V int }
+
+func (s *adminStamp) Less(other stamp.Stamp) bool { return s.V < other.(*adminStamp).V }
+
+func (s *adminStamp) ParseStopPoint(raw string) (err error) {
+	s.V, err = strconv.Atoi(raw)
+	return err
+}
+
+type adminDialect struct{ fakeDialect }
+
+func (d *adminDialect) ZeroStamp() stamp.Stamp { return &adminStamp{} }
+
+// adminMemo is an in-memory types.Memo.
+type adminMemo struct {
+	sync.Mutex
+	data map[string][]byte
+}
+
+func (m *adminMemo) Get(_ context.Context, _ types.StagingQuerier, key string) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.data[key], nil
+}
+
+func (m *adminMemo) Put(_ context.Context, _ types.StagingQuerier, key string, value []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+// adminAudit records actions in memory.
+type adminAudit struct {
+	sync.Mutex
+	entries []string
+}
+
+func (a *adminAudit) Begin(
+	_ context.Context, action, target, _, _ string,
+) (func(outcome string), error) {
+	a.Lock()
+	defer a.Unlock()
+	idx := len(a.entries)
+	a.entries = append(a.entries, action+" "+target)
+	return func(outcome string) {
+		a.Lock()
+		defer a.Unlock()
+		a.entries[idx] += " " + outcome
+	}, nil
+}
+
+func TestAdminHandler(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	stop := stopper.WithContext(context.Background())
+	defer stop.Stop(0)
+
+	memo := &adminMemo{data: make(map[string][]byte)}
+	f := &Factory{memo: memo}
+	schema := ident.MustSchema(ident.New("db"), ident.New("public"))
+	l := &loop{
+		factory:    f,
+		loopConfig: &LoopConfig{Dialect: &adminDialect{}, LoopName: "fake", TargetSchema: schema},
+		running:    stop,
+	}
+	l.consistentPoint.Set(&adminStamp{V: 1})
+	f.mu.started = []*Loop{{loop: l}}
+
+	// Simulate the parts of loop.runOnce that respond to pausing.
+	stop.Go(func() error {
+		for {
+			_, changed := l.paused.Get()
+			if !l.waitWhilePaused(stop) {
+				return nil
+			}
+			select {
+			case <-changed:
+			case <-stop.Stopping():
+				return nil
+			}
+		}
+	})
+
+	audit := &adminAudit{}
+	h := newAdmin(trust.New(), f, audit)
+
+	do := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("content-type", "application/x-www-form-urlencoded")
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	// Authentication is required.
+	w := httptest.NewRecorder()
+	newAdmin(reject.New(), f, audit).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_/admin/loops", nil))
+	a.Equal(http.StatusForbidden, w.Code)
+
+	w = do(http.MethodGet, "/_/admin/loops", nil)
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"loops":[{"consistentPoint":{"V":1},"name":"fake","paused":false,"targetSchema":"db.public"}]}`,
+		w.Body.String())
+
+	// Actions require a POST.
+	w = do(http.MethodGet, "/_/admin/pause", nil)
+	a.Equal(http.StatusMethodNotAllowed, w.Code)
+
+	w = do(http.MethodPost, "/_/admin/pause", url.Values{"schema": {"db.public"}})
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"fake":true}`, w.Body.String())
+
+	w = do(http.MethodPost, "/_/admin/resume", url.Values{"loop": {"fake"}})
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"fake":false}`, w.Body.String())
+
+	w = do(http.MethodPost, "/_/admin/pause", url.Values{"loop": {"nope"}})
+	a.Equal(http.StatusNotFound, w.Code)
+
+	// Moving a consistent point requires confirmation.
+	w = do(http.MethodPost, "/_/admin/move", url.Values{"loop": {"fake"}, "to": {"bad"}})
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPost, "/_/admin/move", url.Values{"loop": {"fake"}, "to": {"10"}})
+	r.Equal(http.StatusOK, w.Code)
+	var pending struct{ Confirm string }
+	r.NoError(json.Unmarshal(w.Body.Bytes(), &pending))
+	r.NotEmpty(pending.Confirm)
+	cp, _ := l.consistentPoint.Get()
+	a.Equal(&adminStamp{V: 1}, cp)
+
+	// The token is bound to the requested value.
+	w = do(http.MethodPost, "/_/admin/move",
+		url.Values{"loop": {"fake"}, "to": {"11"}, "token": {pending.Confirm}})
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	// The token is single-use.
+	w = do(http.MethodPost, "/_/admin/move",
+		url.Values{"loop": {"fake"}, "to": {"10"}, "token": {pending.Confirm}})
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPost, "/_/admin/move", url.Values{"loop": {"fake"}, "to": {"10"}})
+	r.Equal(http.StatusOK, w.Code)
+	r.NoError(json.Unmarshal(w.Body.Bytes(), &pending))
+	w = do(http.MethodPost, "/_/admin/move",
+		url.Values{"loop": {"fake"}, "to": {"10"}, "token": {pending.Confirm}})
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"from":{"V":1},"loop":"fake","to":{"V":10}}`, w.Body.String())
+
+	cp, _ = l.consistentPoint.Get()
+	a.Equal(&adminStamp{V: 10}, cp)
+	a.JSONEq(`{"V":10}`, string(memo.data["fake"]))
+	// The loop was not paused before the move, so it is resumed.
+	paused, _ := l.paused.Get()
+	a.False(paused)
+
+	a.Equal([]string{
+		"pause db.public ok",
+		"resume fake ok",
+		"pause nope no loops match nope",
+		`move fake could not parse consistent point "bad" for loop fake: ` +
+			`strconv.Atoi: parsing "bad": invalid syntax`,
+		"move fake ok",
+		"move fake invalid or expired confirmation token",
+		"move fake invalid or expired confirmation token",
+		"move fake ok",
+		"move fake ok",
+	}, audit.entries)
+}
+
+// adminLessor is a Dialect that requires a lease.
+type adminLessor struct{ adminDialect }
+
+func (d *adminLessor) Acquire(context.Context) (types.Lease, error) {
+	return nil, errors.New("unimplemented")
+}
+
+func TestMoveRequiresLease(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	stop := stopper.WithContext(context.Background())
+	defer stop.Stop(0)
+
+	memo := &adminMemo{data: make(map[string][]byte)}
+	l := &loop{
+		factory:    &Factory{memo: memo},
+		loopConfig: &LoopConfig{Dialect: &adminLessor{}, LoopName: "leased"},
+		running:    stop,
+	}
+	l.consistentPoint.Set(&adminStamp{V: 1})
+	loop := &Loop{loop: l}
+
+	// Another instance holds the lease, so the move must be refused
+	// instead of waiting for the loop to become idle.
+	_, _, err := loop.MoveConsistentPoint(context.Background(), "10")
+	var adminErr *adminError
+	r.ErrorAs(err, &adminErr)
+	a.Equal(http.StatusConflict, adminErr.code)
+	a.Empty(memo.data)
+	paused, _ := loop.Paused()
+	a.False(paused)
+
+	// Simulate the loop holding the lease and waiting while paused.
+	l.leased.Set(true)
+	stop.Go(func() error {
+		for {
+			_, changed := l.paused.Get()
+			if !l.waitWhilePaused(stop) {
+				return nil
+			}
+			select {
+			case <-changed:
+			case <-stop.Stopping():
+				return nil
+			}
+		}
+	})
+	from, to, err := loop.MoveConsistentPoint(context.Background(), "10")
+	r.NoError(err)
+	a.Equal(&adminStamp{V: 1}, from)
+	a.Equal(&adminStamp{V: 10}, to)
+	a.JSONEq(`{"V":10}`, string(memo.data["leased"]))
+}
@@ -21,6 +21,7 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
@@ -151,6 +152,13 @@ func (e *chaosEvents) OnBegin(ctx context.Context) (Batch, error) {
 	return &chaosBatch{delegate, e.prob}, nil
 }
 
+func (e *chaosEvents) checkStopPoint(commit stamp.Stamp) error {
+	if checker, ok := e.delegate.(stopPointChecker); ok {
+		return checker.checkStopPoint(commit)
+	}
+	return nil
+}
+
 func (e *chaosEvents) SetConsistentPoint(ctx context.Context, cp stamp.Stamp) error {
 	if rand.Float32() < e.prob {
 		return doChaos("SetConsistentPoint")
@@ -196,6 +204,15 @@ func (e *chaosBatch) OnData(
 	return e.delegate.OnData(ctx, source, target, muts)
 }
 
+func (e *chaosBatch) OnMessage(
+	ctx context.Context, source ident.Ident, msg *script.Message,
+) error {
+	if rand.Float32() < e.prob {
+		return doChaos("OnMessage")
+	}
+	return e.delegate.OnMessage(ctx, source, msg)
+}
+
 func (e *chaosBatch) OnRollback(ctx context.Context) error {
 	if rand.Float32() < e.prob {
 		return doChaos("OnRollback")
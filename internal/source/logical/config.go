@@ -21,6 +21,7 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
@@ -49,6 +50,10 @@ type Config interface {
 
 // BaseConfig defines common configuration for all loops started by a Factory.
 type BaseConfig struct {
+	// If non-zero, data will be applied to the target only once it is
+	// at least this old. This allows a lagging replica to be paused
+	// before an erroneous change in the source reaches it.
+	ApplyDelay time.Duration
 	// The maximum length of time to wait for an incoming transaction
 	// to settle (i.e. to detect stalls in the target database).
 	ApplyTimeout time.Duration
@@ -64,8 +69,13 @@ type BaseConfig struct {
 	BytesInFlight int
 	// Used in testing to inject errors during processing.
 	ChaosProb float32
+	// Create missing target tables from source metadata. This is only
+	// supported by some dialects.
+	CreateTables bool
 	// Dead-letter queue configuration. Mainly about table naming.
 	DLQConfig dlq.Config
+	// Additive schema-evolution policy for the target.
+	EvolveConfig evolve.Config
 	// The number of concurrent connections to use when writing data in
 	// fan mode.
 	FanShards int
@@ -83,6 +93,9 @@ type BaseConfig struct {
 	ScriptConfig script.Config
 	// How often to commit the latest consistent point.
 	StandbyTimeout time.Duration
+	// If set, replication loops will stop once their consistent point
+	// has reached this value. The format is specific to the source.
+	StopAt string
 	// Connection stsring for the staging cluster.
 	StagingConn string
 	// The name of a SQL schema in the staging cluster to store
@@ -108,14 +121,19 @@ func (c *BaseConfig) Base() *BaseConfig {
 // Bind adds flags to the set.
 func (c *BaseConfig) Bind(f *pflag.FlagSet) {
 	c.DLQConfig.Bind(f)
+	c.EvolveConfig.Bind(f)
 	c.ScriptConfig.Bind(f)
 
+	f.DurationVar(&c.ApplyDelay, "applyDelay", 0,
+		"if non-zero, hold data in staging until it is at least this old before applying it")
 	f.DurationVar(&c.ApplyTimeout, "applyTimeout", defaultApplyTimeout,
 		"the maximum amount of time to wait for an update to be applied")
 	f.DurationVar(&c.BackfillWindow, "backfillWindow", defaultBackfillWindow,
 		"use a high-throughput, but non-transactional mode if replication is this far behind (0 disables this feature)")
 	f.IntVar(&c.BytesInFlight, "bytesInFlight", defaultBytesInFlight,
 		"apply backpressure when amount of in-flight mutation data reaches this limit")
+	f.BoolVar(&c.CreateTables, "createTables", false,
+		"create missing target tables from source metadata, if supported by the source")
 	f.BoolVar(&c.Immediate, "immediate", false,
 		"apply data without waiting for transaction boundaries")
 	f.IntVar(&c.FanShards, "fanShards", defaultFanShards,
@@ -136,6 +154,9 @@ func (c *BaseConfig) Bind(f *pflag.FlagSet) {
 		"a SQL database schema to store metadata in")
 	f.DurationVar(&c.StandbyTimeout, "standbyTimeout", defaultStandbyTimeout,
 		"how often to commit the consistent point")
+	f.StringVar(&c.StopAt, "stopAt", "",
+		"stop replication once this consistent point has been applied; "+
+			"the format depends on the source (e.g. resolved timestamp, LSN, GTID set, or RFC3339 time)")
 	f.StringVar(&c.StagingConn, "stagingConn", "",
 		"the staging CockroachDB cluster's connection string; required if target is other than CRDB")
 	f.StringVar(&c.TargetConn, "targetConn", "",
@@ -159,10 +180,19 @@ func (c *BaseConfig) Preflight() error {
 	if err := c.DLQConfig.Preflight(); err != nil {
 		return err
 	}
+	if err := c.EvolveConfig.Preflight(); err != nil {
+		return err
+	}
 	if err := c.ScriptConfig.Preflight(); err != nil {
 		return err
 	}
 
+	if c.ApplyDelay < 0 {
+		return errors.New("applyDelay must be >= 0")
+	}
+	if c.ApplyDelay > 0 && c.Immediate {
+		return errors.New("applyDelay incompatible with immediate mode")
+	}
 	if c.ApplyTimeout == 0 {
 		c.ApplyTimeout = defaultApplyTimeout
 	}
@@ -175,6 +205,9 @@ func (c *BaseConfig) Preflight() error {
 	if c.FanShards == 0 {
 		c.FanShards = defaultFanShards
 	}
+	if c.StopAt != "" && c.Immediate {
+		return errors.New("stopAt incompatible with immediate mode")
+	}
 	if c.ForeignKeysEnabled && c.Immediate {
 		return errors.New("foreign-key mode incompatible with immediate mode")
 	}
@@ -218,6 +251,13 @@ type LoopConfig struct {
 	DefaultConsistentPoint string
 	// The instance of the Dialect to send events to.
 	Dialect Dialect
+	// Set by dialects whose sources do not otherwise stage data. If
+	// BaseConfig.ApplyDelay is set, transactions will be written to
+	// the staging tables and applied once they are old enough. The
+	// loop will stop with an error if the source sends partial rows
+	// (e.g. a MySQL binlog_row_image other than FULL), since they
+	// cannot be staged.
+	HoldInStaging bool
 	// Uniquely identifies the replication loop.
 	LoopName string
 	// The SQL schema in the target cluster to write into. This value is
@@ -0,0 +1,320 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/msort"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// How often the applier looks for transactions which have aged
+	// past the configured delay.
+	delayPollInterval = time.Second
+	// How often applied transactions are removed from staging.
+	delayRetireInterval = time.Minute
+)
+
+// delayEvents implements a delayed-replica mode for sources which do
+// not otherwise stage their data. Transactions are written to the
+// staging tables, using the time at which they were committed in the
+// source, and are only applied to the target by the run method once
+// they are at least as old as the configured delay. If the Dialect
+// does not report the source time via [OnBeginAt], the time at which
+// the transaction was received is used instead.
+type delayEvents struct {
+	Events // The events that will apply staged data.
+
+	delay   time.Duration
+	factory *Factory
+	loop    *loop
+	watcher types.Watcher
+
+	mu struct {
+		sync.Mutex
+		// The most recently assigned staging time.
+		last hlc.Time
+	}
+}
+
+var _ Events = (*delayEvents)(nil)
+
+// OnBegin implements Events.
+func (e *delayEvents) OnBegin(ctx context.Context) (Batch, error) {
+	ret := &delayBatch{parent: e}
+	ret.sourceTime, _ = sourceCommitTime(ctx)
+	return ret, nil
+}
+
+// nextTime returns a staging timestamp for a newly committed
+// transaction. The values returned are strictly increasing, so that
+// transactions will be applied in the order in which they were
+// received, even if the source clock moves backwards.
+func (e *delayEvents) nextTime(now time.Time) hlc.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	next := hlc.From(now)
+	if hlc.Compare(next, e.mu.last) <= 0 {
+		next = hlc.New(e.mu.last.Nanos(), e.mu.last.Logical()+1)
+	}
+	e.mu.last = next
+	return next
+}
+
+// run applies staged transactions until the context is stopped.
+func (e *delayEvents) run(ctx *stopper.Context) {
+	defer log.Debugf("delayed applier for %s shut down", e.loop.loopConfig.LoopName)
+
+	var applied, retired hlc.Time
+	lastRetire := time.Now()
+	for {
+		wait := delayPollInterval
+		paused, pauseChanged := e.loop.paused.Get()
+		if !paused {
+			next, ok, err := e.applyOnce(ctx, applied)
+			if err != nil {
+				log.WithError(err).Warnf("could not apply delayed transaction for %s; retrying in %s",
+					e.loop.loopConfig.LoopName, e.factory.baseConfig.RetryDelay)
+				wait = e.factory.baseConfig.RetryDelay
+			} else if ok {
+				applied = next
+				delayedAppliedTime.WithLabelValues(e.loop.loopConfig.LoopName).
+					Set(float64(applied.Nanos()) / 1e9)
+				// Look for the next transaction immediately.
+				wait = 0
+			}
+		}
+
+		// Delete transactions that have been applied.
+		if hlc.Compare(applied, retired) > 0 && time.Since(lastRetire) >= delayRetireInterval {
+			if err := e.retire(ctx, applied); err != nil {
+				log.WithError(err).Warnf("could not retire delayed transactions for %s",
+					e.loop.loopConfig.LoopName)
+			} else {
+				retired = applied
+			}
+			lastRetire = time.Now()
+		}
+
+		select {
+		case <-pauseChanged:
+		case <-time.After(wait):
+		case <-ctx.Stopping():
+			return
+		}
+	}
+}
+
+// applyOnce applies the oldest staged transaction, if it is older than
+// the delay. It returns the time of the transaction and true if a
+// transaction was applied.
+func (e *delayEvents) applyOnce(
+	ctx context.Context, startAt hlc.Time,
+) (hlc.Time, bool, error) {
+	var targets []ident.Table
+	for _, group := range e.watcher.Get().Order {
+		for _, tbl := range group {
+			// Ensure that the staging table exists.
+			if _, err := e.factory.stagers.Get(ctx, tbl); err != nil {
+				return hlc.Zero(), false, err
+			}
+			targets = append(targets, tbl)
+		}
+	}
+
+	cursor := &types.UnstageCursor{
+		StartAt:        startAt,
+		EndBefore:      hlc.From(time.Now().Add(-e.delay)),
+		Targets:        targets,
+		TimestampLimit: 1, // Preserve source transaction boundaries.
+	}
+
+	unstageTX, err := e.factory.stagingPool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return hlc.Zero(), false, errors.Wrap(err, "could not open staging transaction")
+	}
+	defer func() { _ = unstageTX.Rollback(ctx) }()
+
+	var epoch hlc.Time
+	toApply := &ident.TableMap[[]types.Mutation]{}
+	_, hadData, err := e.factory.stagers.Unstage(ctx, unstageTX, cursor,
+		func(ctx context.Context, tbl ident.Table, mut types.Mutation) error {
+			epoch = mut.Time
+			toApply.Put(tbl, append(toApply.GetZero(tbl), mut))
+			return nil
+		})
+	if err != nil {
+		return hlc.Zero(), false, errors.Wrap(err, "could not unstage mutations")
+	}
+	if !hadData {
+		return startAt, false, nil
+	}
+
+	applyCtx, cancel := context.WithTimeout(ctx, e.factory.baseConfig.ApplyTimeout)
+	defer cancel()
+
+	batch, err := e.Events.OnBegin(applyCtx)
+	if err != nil {
+		return hlc.Zero(), false, err
+	}
+	defer func() { _ = batch.OnRollback(applyCtx) }()
+
+	for _, tbl := range targets {
+		muts := toApply.GetZero(tbl)
+		if len(muts) == 0 {
+			continue
+		}
+		if err := batch.OnData(applyCtx, tbl.Table(), tbl, muts); err != nil {
+			return hlc.Zero(), false, err
+		}
+	}
+	select {
+	case err := <-batch.OnCommit(applyCtx):
+		if err != nil {
+			return hlc.Zero(), false, err
+		}
+	case <-applyCtx.Done():
+		return hlc.Zero(), false, applyCtx.Err()
+	}
+
+	// As with the cdc resolver, there is a window in which the target
+	// transaction has committed, but the staged mutations have not
+	// been marked as applied. The mutations would be re-applied.
+	if err := unstageTX.Commit(ctx); err != nil {
+		return hlc.Zero(), false, errors.Wrap(err, "could not commit unstaging transaction; "+
+			"mutations may be reapplied")
+	}
+	return epoch, true, nil
+}
+
+// retire removes applied transactions from the staging tables.
+func (e *delayEvents) retire(ctx context.Context, applied hlc.Time) error {
+	for _, group := range e.watcher.Get().Order {
+		for _, tbl := range group {
+			stager, err := e.factory.stagers.Get(ctx, tbl)
+			if err != nil {
+				return err
+			}
+			if err := stager.Retire(ctx, e.factory.stagingPool, applied); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// delayUnsafeMeta lists the Mutation.Meta keys which change how a
+// mutation is applied. The staging tables do not persist Meta, so a
+// delayed mutation would be applied as an ordinary upsert. For
+// example, the columns that are absent from a sparse update would be
+// set to NULL instead of retaining their values.
+var delayUnsafeMeta = [...]string{types.CustomUpsert, types.JSONDiffs, types.SparseUpdate}
+
+// delayBatch accumulates the mutations in a source transaction, which
+// are then written to staging when the transaction is committed.
+type delayBatch struct {
+	parent     *delayEvents
+	data       ident.TableMap[[]types.Mutation]
+	sourceTime time.Time // Zero if the source time is unknown.
+}
+
+var _ Batch = (*delayBatch)(nil)
+
+// Flush returns nil, since the data is only staged by OnCommit.
+func (b *delayBatch) Flush(context.Context) error {
+	return nil
+}
+
+// OnCommit stages the accumulated mutations in a single transaction.
+func (b *delayBatch) OnCommit(ctx context.Context) <-chan error {
+	e := b.parent
+	deadlineFrom := b.sourceTime
+	if deadlineFrom.IsZero() {
+		deadlineFrom = time.Now()
+	}
+	stagedAt := e.nextTime(deadlineFrom)
+
+	tx, err := e.factory.stagingPool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return singletonChannel(errors.Wrap(err, "could not open staging transaction"))
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := b.data.Range(func(tbl ident.Table, muts []types.Mutation) error {
+		for idx := range muts {
+			muts[idx].Time = stagedAt
+		}
+		// Only the last update to a row within the transaction is
+		// retained, since all mutations share the same timestamp.
+		muts = msort.UniqueByTimeKey(muts)
+
+		stager, err := e.factory.stagers.Get(ctx, tbl)
+		if err != nil {
+			return err
+		}
+		return stager.Store(ctx, tx, muts)
+	}); err != nil {
+		return singletonChannel(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return singletonChannel(errors.WithStack(err))
+	}
+	b.data = ident.TableMap[[]types.Mutation]{}
+	return singletonChannel[error](nil)
+}
+
+// OnData implements Batch. It returns an error if a mutation could not
+// be applied correctly after it has been staged.
+func (b *delayBatch) OnData(
+	_ context.Context, _ ident.Ident, target ident.Table, muts []types.Mutation,
+) error {
+	for _, mut := range muts {
+		for _, key := range delayUnsafeMeta {
+			if _, ok := mut.Meta[key]; ok {
+				return errors.Errorf("a mutation for %s requires %s, which is not "+
+					"preserved when using applyDelay; the source must send complete rows",
+					target, key)
+			}
+		}
+	}
+	b.data.Put(target, append(b.data.GetZero(target), muts...))
+	return nil
+}
+
+// OnMessage implements Batch. Messages are only meaningful to a
+// user-script, which will have already consumed them.
+func (b *delayBatch) OnMessage(context.Context, ident.Ident, *script.Message) error {
+	return nil
+}
+
+// OnRollback implements Batch and discards the accumulated mutations.
+func (b *delayBatch) OnRollback(context.Context) error {
+	b.data = ident.TableMap[[]types.Mutation]{}
+	return nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/base"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/reject"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/trust"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelayNextTime(t *testing.T) {
+	a := assert.New(t)
+
+	e := &delayEvents{}
+	now := time.Now()
+
+	first := e.nextTime(now)
+	a.Equal(hlc.From(now), first)
+
+	// A repeated or backwards wall time must still advance.
+	second := e.nextTime(now)
+	a.Equal(hlc.New(first.Nanos(), 1), second)
+	third := e.nextTime(now.Add(-time.Second))
+	a.Equal(hlc.New(first.Nanos(), 2), third)
+
+	fourth := e.nextTime(now.Add(time.Second))
+	a.Equal(hlc.From(now.Add(time.Second)), fourth)
+}
+
+// delayStamp is a TimeStamp for testing.
+type delayStamp struct{ time.Time }
+
+func (s *delayStamp) AsTime() time.Time { return s.Time }
+
+func (s *delayStamp) Less(other stamp.Stamp) bool { return s.Before(other.(*delayStamp).Time) }
+
+// Verify that a delayed transaction is held relative to its source
+// time, if the Dialect provides one.
+func TestDelaySourceTime(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	ctx := context.Background()
+	e := &delayEvents{}
+	source := time.Unix(1_700_000_000, 0)
+
+	batch, err := OnBeginAt(ctx, e, &delayStamp{source})
+	r.NoError(err)
+	a.Equal(source, batch.(*delayBatch).sourceTime)
+
+	// Fall back to the arrival time.
+	batch, err = e.OnBegin(ctx)
+	r.NoError(err)
+	a.True(batch.(*delayBatch).sourceTime.IsZero())
+
+	batch, err = OnBeginAt(ctx, e, &adminStamp{V: 1})
+	r.NoError(err)
+	a.True(batch.(*delayBatch).sourceTime.IsZero())
+
+	batch, err = OnBeginAt(ctx, e, &delayStamp{})
+	r.NoError(err)
+	a.True(batch.(*delayBatch).sourceTime.IsZero())
+}
+
+func TestPauseHandler(t *testing.T) {
+	r := require.New(t)
+
+	f := &Factory{}
+	l := &loop{factory: f, loopConfig: &LoopConfig{LoopName: "fake"}}
+	f.mu.started = []*Loop{{loop: l}}
+	audit := &adminAudit{}
+	f.mu.audit = audit
+	h := PauseHandler(trust.New(), f)
+
+	do := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/_/pause", nil))
+		return w
+	}
+
+	w := do(http.MethodGet)
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"loops":{"fake":false},"paused":false}`, w.Body.String())
+
+	_, changed := l.paused.Get()
+	w = do(http.MethodPost)
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"loops":{"fake":true},"paused":true}`, w.Body.String())
+	r.True(f.Paused())
+	select {
+	case <-changed:
+	default:
+		r.Fail("pause should have notified")
+	}
+
+	w = do(http.MethodDelete)
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"loops":{"fake":false},"paused":false}`, w.Body.String())
+
+	// A loop that is paused individually is reported as such.
+	f.Loops()[0].Pause()
+	w = do(http.MethodGet)
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"loops":{"fake":true},"paused":true}`, w.Body.String())
+
+	w = do(http.MethodPut)
+	r.Equal(http.StatusMethodNotAllowed, w.Code)
+
+	// Changes are audited, but reads are not.
+	r.Equal([]string{
+		"pause POST  ok",
+		"pause DELETE  ok",
+		"pause PUT  Method Not Allowed",
+	}, audit.entries)
+
+	// The admin claim is required.
+	w = httptest.NewRecorder()
+	PauseHandler(reject.New(), f).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/_/pause", nil))
+	r.Equal(http.StatusForbidden, w.Code)
+	r.Len(audit.entries, 3)
+}
+
+// TestApplyDelay sends mutations through a delayed loop and verifies
+// what is applied to the target.
+func TestApplyDelay(t *testing.T) {
+	r := require.New(t)
+
+	fixture, err := base.NewFixture(t)
+	r.NoError(err)
+
+	ctx := fixture.Context
+	pool := fixture.TargetPool
+	tgt := ident.NewTable(fixture.TargetSchema.Schema(), ident.New("delayed"))
+	_, err = pool.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE %s (k INT PRIMARY KEY, v VARCHAR(2048), w VARCHAR(2048))`, tgt))
+	r.NoError(err)
+
+	f, err := NewFactoryForTests(ctx, &BaseConfig{
+		ApplyDelay:     time.Millisecond,
+		ApplyTimeout:   time.Minute,
+		RetryDelay:     time.Millisecond,
+		StagingConn:    fixture.StagingPool.ConnectionString,
+		StagingSchema:  fixture.StagingDB.Schema(),
+		StandbyTimeout: 5 * time.Millisecond,
+		TargetConn:     pool.ConnectionString,
+	})
+	r.NoError(err)
+	cfg, err := f.expandConfig(&LoopConfig{
+		Dialect:       &fakeDialect{},
+		HoldInStaging: true,
+		LoopName:      "delayed",
+		TargetSchema:  fixture.TargetSchema.Schema(),
+	})
+	r.NoError(err)
+	l, err := f.newLoop(ctx, cfg)
+	r.NoError(err)
+	events := l.loop.events.serial
+	r.IsType(&delayEvents{}, events)
+
+	send := func(muts ...types.Mutation) error {
+		batch, err := events.OnBegin(ctx)
+		r.NoError(err)
+		if err := batch.OnData(ctx, tgt.Table(), tgt, muts); err != nil {
+			r.NoError(batch.OnRollback(ctx))
+			return err
+		}
+		return <-batch.OnCommit(ctx)
+	}
+	waitFor := func(v, w string) {
+		for {
+			var gotV, gotW string
+			err := pool.QueryRowContext(ctx,
+				fmt.Sprintf("SELECT v, w FROM %s WHERE k = 1", tgt)).Scan(&gotV, &gotW)
+			if err == nil && gotV == v && gotW == w {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				r.FailNow("timed out", "waiting for v=%s w=%s", v, w)
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	// Complete rows are staged and then applied.
+	r.NoError(send(types.Mutation{
+		Data: []byte(`{"k":1,"v":"a","w":"b"}`),
+		Key:  []byte(`[1]`),
+	}))
+	waitFor("a", "b")
+
+	// Informational metadata doesn't affect how the row is applied.
+	r.NoError(send(types.Mutation{
+		Data: []byte(`{"k":1,"v":"c","w":"d"}`),
+		Key:  []byte(`[1]`),
+		Meta: map[string]any{script.MetaLSN: "0/1"},
+	}))
+	waitFor("c", "d")
+
+	// A sparse update can't be staged, since it would be applied as a
+	// complete row, setting w to NULL.
+	r.ErrorContains(send(types.Mutation{
+		Data: []byte(`{"k":1,"v":"e"}`),
+		Key:  []byte(`[1]`),
+		Meta: map[string]any{types.SparseUpdate: true},
+	}), "applyDelay")
+	waitFor("c", "d")
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
@@ -136,6 +137,10 @@ type Batch interface {
 	// to pass to the user-script, and will generally be the name of a
 	// table, doc-collection, or other named data product.
 	OnData(ctx context.Context, source ident.Ident, target ident.Table, muts []types.Mutation) error
+	// OnMessage delivers an application-defined message from the
+	// source to the user-script. Any mutations produced by the
+	// user-script will be applied within the transaction block.
+	OnMessage(ctx context.Context, source ident.Ident, msg *script.Message) error
 	// OnRollback must be called by Dialect.Process when a rollback
 	// message is encountered, to ensure that all internal state has
 	// been resynchronized.
@@ -18,12 +18,14 @@ package logical
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/notify"
 	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
 	"github.com/pkg/errors"
 )
@@ -37,10 +39,63 @@ type Factory struct {
 	diags        *diag.Diagnostics
 	memo         types.Memo
 	scriptLoader *script.Loader
+	stagers      types.Stagers
 	stagingPool  *types.StagingPool
 	stop         *stopper.Context
 	targetPool   *types.TargetPool
 	watchers     types.Watchers
+
+	// The raw stop point, which is interpreted by each loop's Dialect.
+	stopAt notify.Var[string]
+
+	mu struct {
+		sync.Mutex
+		// Records the actions taken through the administrative
+		// endpoints. Created on first use; see Factory.auditor.
+		audit auditor
+		// Loops created by Start, used to validate stop points and
+		// by the admin API.
+		started []*Loop
+	}
+}
+
+// Pause pauses every loop that has been started by the Factory, as
+// though [Loop.Pause] had been called. A loop which is started while
+// all other loops are paused will also be paused.
+func (f *Factory) Pause() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, l := range f.mu.started {
+		l.Pause()
+	}
+}
+
+// Paused returns true if every loop that has been started by the
+// Factory is paused. A loop may also be paused or resumed
+// individually, e.g. through the admin API.
+func (f *Factory) Paused() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pausedLocked()
+}
+
+// pausedLocked implements Paused and requires that the lock be held.
+func (f *Factory) pausedLocked() bool {
+	for _, l := range f.mu.started {
+		if paused, _ := l.Paused(); !paused {
+			return false
+		}
+	}
+	return len(f.mu.started) > 0
+}
+
+// Resume resumes every loop that has been started by the Factory.
+func (f *Factory) Resume() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, l := range f.mu.started {
+		l.Resume()
+	}
 }
 
 // Immediate supports use cases where it is desirable to write directly
@@ -90,6 +145,24 @@ func (f *Factory) Start(config *LoopConfig) (*Loop, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	// Ensure that any requested stop point is meaningful to the loop.
+	if raw, _ := f.StopAt(); raw != "" {
+		if config.HoldInStaging && f.baseConfig.ApplyDelay > 0 {
+			return nil, errors.New("stopAt incompatible with applyDelay for this source")
+		}
+		if _, err := loop.loop.parseStopPoint(raw); err != nil {
+			return nil, err
+		}
+	}
+	f.mu.Lock()
+	// A new loop joins a paused replica in the paused state.
+	if f.pausedLocked() {
+		loop.Pause()
+	}
+	f.mu.started = append(f.mu.started, loop)
+	f.mu.Unlock()
+
 	f.stop.Go(func() error {
 		loop.loop.run()
 		return nil
@@ -125,9 +198,10 @@ func (f *Factory) newLoop(ctx *stopper.Context, config *LoopConfig) (*Loop, erro
 	config = config.Copy()
 	config.Dialect = WithChaos(config.Dialect, f.baseConfig.ChaosProb)
 	loop := &loop{
-		factory:    f,
-		loopConfig: config,
-		running:    ctx,
+		factory:     f,
+		loopConfig:  config,
+		running:     ctx,
+		stopReached: make(chan struct{}),
 	}
 	initialPoint, err := loop.loadConsistentPoint(ctx)
 	if err != nil {
@@ -139,29 +213,55 @@ func (f *Factory) newLoop(ctx *stopper.Context, config *LoopConfig) (*Loop, erro
 		loop: loop,
 	}
 
-	loop.events.serial = &serialEvents{
+	serial := &serialEvents{
 		appliers:   f.appliers,
 		loop:       loop,
 		targetPool: f.targetPool,
 	}
+	loop.events.serial = serial
 
 	if f.baseConfig.ForeignKeysEnabled {
+		// Deferring foreign-key checks requires all tables to be
+		// written within a single transaction, so fan mode always
+		// uses the two-pass approach for cyclical references.
+		deferrable, _ := foreignKeyDeferral(f.targetPool.Product)
+		serial.watcher = watcher
 		loop.events.fan = &orderedEvents{
 			Events:  loop.events.fan,
 			Watcher: watcher,
 		}
 		loop.events.serial = &orderedEvents{
-			Events:  loop.events.serial,
-			Watcher: watcher,
+			Deferred: deferrable != "",
+			Events:   loop.events.serial,
+			Watcher:  watcher,
 		}
 	} else {
 		// Sanity-check that there are no FKs defined.
-		if len(watcher.Get().Order) > 1 {
+		if snapshot := watcher.Get(); len(snapshot.Order) > 1 || snapshot.Cycles != nil {
 			return nil, errors.New("the destination database has tables with foreign keys, " +
 				"but support for FKs is not enabled")
 		}
 	}
 
+	// Hold transactions in staging until they are old enough to be
+	// applied. The delayed events sit below the userscript, so the
+	// staged mutations are already mapped onto target tables.
+	if config.HoldInStaging && f.baseConfig.ApplyDelay > 0 {
+		delayed := &delayEvents{
+			Events:  loop.events.serial,
+			delay:   f.baseConfig.ApplyDelay,
+			factory: f,
+			loop:    loop,
+			watcher: watcher,
+		}
+		loop.events.fan = delayed
+		loop.events.serial = delayed
+		ctx.Go(func() error {
+			delayed.run(ctx)
+			return nil
+		})
+	}
+
 	// Create a branch in the diagnostics reporting for the loop.
 	loopDiags, err := f.diags.Wrap(config.LoopName)
 	if err != nil {
@@ -21,6 +21,7 @@ import (
 	"math/rand"
 
 	"github.com/bobvawter/latch"
+	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/batches"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
@@ -142,6 +143,12 @@ func (b *fanBatch) OnData(
 	return err
 }
 
+// OnMessage implements Batch. Messages are only meaningful to a
+// user-script, which will have already consumed them.
+func (b *fanBatch) OnMessage(context.Context, ident.Ident, *script.Message) error {
+	return nil
+}
+
 // OnRollback implements Events and resets any pending work.
 func (b *fanBatch) OnRollback(ctx context.Context) error {
 	select {
@@ -21,6 +21,7 @@ package logical_test
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -29,6 +30,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -37,11 +39,20 @@ type fakeMessage struct {
 	TS    time.Time `json:"ts"`
 }
 
-var _ stamp.Stamp = (*fakeMessage)(nil)
+var _ logical.StopPointStamp = (*fakeMessage)(nil)
 
 func (f *fakeMessage) AsInt() int        { return f.Index }
 func (f *fakeMessage) AsTime() time.Time { return f.TS }
 
+func (f *fakeMessage) ParseStopPoint(raw string) error {
+	idx, err := strconv.Atoi(raw)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	*f = fakeMessage{Index: idx}
+	return nil
+}
+
 func (f *fakeMessage) Less(other stamp.Stamp) bool {
 	return f.AsInt() < other.(*fakeMessage).AsInt()
 }
@@ -51,6 +62,8 @@ func (f *fakeMessage) Less(other stamp.Stamp) bool {
 type generatorDialect struct {
 	// Send an update for each table.
 	tables []ident.Table
+	// If non-zero, no transaction will be sent for this index.
+	gap int
 
 	// Counters to ensure we shut down cleanly.
 	atomic struct {
@@ -180,9 +193,12 @@ func (g *generatorDialect) Process(
 		}
 		msg := m.(*fakeMessage)
 		log.Tracef("received %d", msg.Index)
+		if msg.Index == g.gap {
+			continue
+		}
 
 		var err error
-		batch, err = events.OnBegin(ctx)
+		batch, err = logical.OnBeginAt(ctx, events, msg)
 		if err != nil {
 			return err
 		}
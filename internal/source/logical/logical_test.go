@@ -36,10 +36,12 @@ import (
 )
 
 type logicalTestMode struct {
-	backfill  bool
-	chaos     bool
-	immediate bool
-	fk        bool
+	backfill      bool
+	chaos         bool
+	cycle         bool // Implies fk.
+	immediate     bool
+	fk            bool
+	notDeferrable bool // The constraints of a cycle are NOT DEFERRABLE.
 }
 
 func TestLogical(t *testing.T) {
@@ -98,6 +100,29 @@ func TestLogical(t *testing.T) {
 				fk:    true,
 			},
 		},
+		{
+			name: "fk-cycle",
+			mode: &logicalTestMode{
+				cycle: true,
+				fk:    true,
+			},
+		},
+		{
+			name: "fk-cycle-backfill",
+			mode: &logicalTestMode{
+				backfill: true,
+				cycle:    true,
+				fk:       true,
+			},
+		},
+		{
+			name: "fk-cycle-not-deferrable",
+			mode: &logicalTestMode{
+				cycle:         true,
+				fk:            true,
+				notDeferrable: true,
+			},
+		},
 		{
 			name: "immediate",
 			mode: &logicalTestMode{
@@ -141,10 +166,17 @@ func testLogicalSmoke(t *testing.T, mode *logicalTestMode) {
 		tgts[idx] = ident.NewTable(dbName, ident.New(fmt.Sprintf("t%d", idx)))
 	}
 
-	// In FK mode, declare tables to enforce ordering.
+	// Backfill mode, and cycles which cannot be deferred, use a
+	// two-pass approach, which relies upon sparse updates.
+	if mode.cycle && (mode.backfill || mode.notDeferrable) && pool.Product == types.ProductOracle {
+		t.Skip("sparse updates are not supported for oracle")
+	}
+
+	// In FK mode, declare tables to enforce ordering. The constraints
+	// of a cycle are added below.
 	for idx, tgt := range tgts {
 		var schema string
-		if mode.fk && idx > 0 {
+		if mode.fk && !mode.cycle && idx > 0 {
 			schema = fmt.Sprintf(`
 			CREATE TABLE %s (
 				k INT PRIMARY KEY, v VARCHAR(2048), ref INT NOT NULL,
@@ -158,6 +190,27 @@ func testLogicalSmoke(t *testing.T, mode *logicalTestMode) {
 		}
 	}
 
+	// Create a reference cycle t0 <- t1 <- ... <- tN <- t0. Only
+	// some products support deferrable constraints.
+	if mode.cycle {
+		var deferrable string
+		switch pool.Product {
+		case types.ProductOracle, types.ProductPostgreSQL:
+			if !mode.notDeferrable {
+				deferrable = "DEFERRABLE"
+			}
+		}
+		for idx, tgt := range tgts {
+			parent := tgts[(idx+tableCount-1)%tableCount]
+			if _, err := pool.ExecContext(ctx, fmt.Sprintf(
+				`ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (ref) REFERENCES %s(k) %s`,
+				tgt, ident.New(fmt.Sprintf("cycle_%d", idx)), parent, deferrable),
+			); !a.NoError(err) {
+				return
+			}
+		}
+	}
+
 	// Ensure that sorting must happen.
 	rand.Shuffle(tableCount, func(i, j int) {
 		tgts[i], tgts[j] = tgts[j], tgts[i]
@@ -278,6 +331,77 @@ func testLogicalSmoke(t *testing.T, mode *logicalTestMode) {
 	a.Len(found, numEmits)
 }
 
+// TestStopPointBoundary verifies that a loop does not apply a
+// transaction which commits beyond its stop point, even if the stop
+// point falls between two transactions.
+func TestStopPointBoundary(t *testing.T) {
+	r := require.New(t)
+
+	fixture, err := base.NewFixture(t)
+	r.NoError(err)
+
+	ctx := fixture.Context
+	pool := fixture.TargetPool
+
+	tgt := ident.NewTable(fixture.TargetSchema.Schema(), ident.New("tgt"))
+	_, err = pool.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE %s (k INT PRIMARY KEY, v VARCHAR(2048), ref INT)`, tgt))
+	r.NoError(err)
+
+	// No transaction commits at the stop point.
+	gen := newGenerator([]ident.Table{tgt})
+	gen.gap = 5
+
+	factory, err := logical.NewFactoryForTests(ctx, &logical.BaseConfig{
+		ApplyTimeout:   time.Second,
+		RetryDelay:     time.Millisecond,
+		StagingConn:    fixture.StagingPool.ConnectionString,
+		StagingSchema:  fixture.StagingDB.Schema(),
+		StandbyTimeout: 5 * time.Millisecond,
+		TargetConn:     pool.ConnectionString,
+	})
+	r.NoError(err)
+	r.NoError(factory.SetStopAt("5"))
+
+	loop, err := factory.Start(&logical.LoopConfig{
+		Dialect:      gen,
+		LoopName:     "generator",
+		TargetSchema: fixture.TargetSchema.Schema(),
+	})
+	r.NoError(err)
+	gen.emit(10)
+
+	_, reached := loop.StopPoint()
+	select {
+	case <-reached:
+	case <-time.After(10 * time.Second):
+		r.Fail("timed out waiting for stop point")
+	}
+
+	// The transaction at 6 must not have been applied.
+	cp, _ := loop.GetConsistentPoint()
+	r.Equal(4, cp.(*fakeMessage).AsInt())
+	var count, last int
+	r.NoError(pool.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT count(*), max(k) FROM %s", tgt)).Scan(&count, &last))
+	r.Equal(4, count)
+	r.Equal(4, last)
+
+	// Clearing the stop point allows the loop to continue.
+	r.NoError(factory.SetStopAt(""))
+	for {
+		cp, changed := loop.GetConsistentPoint()
+		if cp.(*fakeMessage).AsInt() == 10 {
+			break
+		}
+		select {
+		case <-changed:
+		case <-time.After(10 * time.Second):
+			r.Fail("timed out waiting for consistent point")
+		}
+	}
+}
+
 // TestUserScript injects user-provided logic into a loop.
 func TestUserScript(t *testing.T) {
 	a := assert.New(t)
@@ -22,6 +22,7 @@ import (
 	"encoding"
 	"encoding/json"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/cockroachdb/cdc-sink/internal/types"
@@ -86,10 +87,33 @@ type loop struct {
 
 	// This represents a position in the source's transaction log.
 	consistentPoint notify.Var[stamp.Stamp]
+	// Set by an operator to suspend reading from the source and
+	// applying data to the target.
+	paused notify.Var[bool]
+	// True while the loop is waiting to be resumed. A loop whose
+	// Dialect is a Lessor will only become idle while it holds the
+	// lease.
+	idle notify.Var[bool]
+	// True while this process holds the loop's lease. Always false if
+	// the Dialect is not a Lessor.
+	leased notify.Var[bool]
+	// Serializes calls to Loop.MoveConsistentPoint.
+	moveMu sync.Mutex
 
 	metrics struct {
 		backfillStatus prometheus.Gauge
 	}
+
+	// Closed the first time that the loop stops at its stop point.
+	stopReached chan struct{}
+	stopOnce    sync.Once
+	// The consistent point at which the loop has stopped, or nil if
+	// the loop is running.
+	stoppedAt notify.Var[stamp.Stamp]
+	// The stop point for which a transaction committing beyond it was
+	// refused. The loop has stopped there, even though its consistent
+	// point may not have advanced to the stop point.
+	stopRefused notify.Var[string]
 }
 
 var (
@@ -126,6 +150,15 @@ func (l *loop) Diagnostic(ctx context.Context) any {
 		ret["dialect"] = x.Diagnostic(ctx)
 	}
 	ret["stamp"], _ = l.GetConsistentPoint()
+	if raw, _ := l.factory.StopAt(); raw != "" {
+		ret["stopAt"] = raw
+	}
+	if stoppedAt, _ := l.stoppedAt.Get(); stoppedAt != nil {
+		ret["stoppedAt"] = stoppedAt
+	}
+	if paused, _ := l.paused.Get(); paused {
+		ret["paused"] = true
+	}
 
 	return ret
 }
@@ -176,8 +209,26 @@ func (l *loop) run() {
 	defer log.Debugf("replication loop %q shut down", l.loopConfig.LoopName)
 
 	for {
+		// Refuse to make any further progress once the stop point has
+		// been reached, until it has been changed or cleared.
+		if l.waitAtStopPoint() {
+			continue
+		}
+		if l.running.IsStopping() {
+			return
+		}
+
 		err := l.runOnce()
 
+		// An error caused by reaching the stop point or by an operator
+		// pausing the loop isn't interesting.
+		if reached, _ := l.stopPointReached(); reached {
+			continue
+		}
+		if paused, _ := l.paused.Get(); paused {
+			continue
+		}
+
 		// Otherwise, log any error, and sleep for a bit.
 		if err != nil {
 			log.WithError(err).Errorf("error in replication loop %s; retrying in %s",
@@ -196,6 +247,64 @@ func (l *loop) run() {
 	}
 }
 
+// waitAtStopPoint blocks while the loop's consistent point is at or
+// beyond the requested stop point, or while the next transaction would
+// commit beyond it. It returns true if the loop was
+// stopped and the stop point has since changed, or false if the stop
+// point has not been reached.
+func (l *loop) waitAtStopPoint() bool {
+	_, stopChanged := l.factory.StopAt()
+	reached, err := l.stopPointReached()
+	if err != nil {
+		log.WithError(err).Errorf("ignoring stop point for %s", l.loopConfig.LoopName)
+		return false
+	}
+	if !reached {
+		return false
+	}
+
+	cp, _ := l.consistentPoint.Get()
+	l.stoppedAt.Set(cp)
+	l.stopOnce.Do(func() { close(l.stopReached) })
+	log.WithField("stamp", cp).Infof("replication loop %s has reached its stop point",
+		l.loopConfig.LoopName)
+
+	select {
+	case <-stopChanged:
+		l.stoppedAt.Set(nil)
+		l.stopRefused.Set("")
+		log.Infof("stop point for %s has changed; continuing", l.loopConfig.LoopName)
+		return true
+	case <-l.running.Stopping():
+		return false
+	}
+}
+
+// waitWhilePaused blocks while an operator has paused the loop. It
+// returns false if the context was stopped before the loop was
+// resumed.
+func (l *loop) waitWhilePaused(ctx *stopper.Context) bool {
+	paused, changed := l.paused.Get()
+	if !paused {
+		return true
+	}
+
+	l.idle.Set(true)
+	defer l.idle.Set(false)
+	log.Infof("replication loop %s is paused", l.loopConfig.LoopName)
+
+	for paused {
+		select {
+		case <-changed:
+			paused, changed = l.paused.Get()
+		case <-ctx.Stopping():
+			return false
+		}
+	}
+	log.Infof("replication loop %s has been resumed", l.loopConfig.LoopName)
+	return true
+}
+
 // runOnce is called by run. If the Dialect implements a leasing
 // behavior, a lease will be obtained before any further action is
 // taken.
@@ -232,23 +341,40 @@ func (l *loop) runOnce() error {
 			return err
 		}
 		defer lease.Release()
+		l.leased.Set(true)
+		defer l.leased.Set(false)
 		// Ensure that all work is bound to the lifetime of the lease.
 		stop = stopper.WithContext(lease.Context())
 	} else {
 		stop = l.running
 	}
 
-	// Ensure our in-memory consistent point matches the database.
-	point, err := l.loadConsistentPoint(stop)
-	if err != nil {
-		return err
-	}
-	l.consistentPoint.Set(point)
+	for {
+		// Do nothing while an operator has paused the loop. Any lease
+		// is retained, so that another instance of cdc-sink won't
+		// take over the loop.
+		if !l.waitWhilePaused(stop) {
+			return nil
+		}
 
-	// Determine how to perform the filling.
-	source, events, isBackfilling := l.chooseFillStrategy()
+		// Ensure our in-memory consistent point matches the database.
+		point, err := l.loadConsistentPoint(stop)
+		if err != nil {
+			return err
+		}
+		l.consistentPoint.Set(point)
+
+		// Determine how to perform the filling.
+		source, events, isBackfilling := l.chooseFillStrategy()
 
-	return l.runOnceUsing(stop, source, events, isBackfilling)
+		err = l.runOnceUsing(stop, source, events, isBackfilling)
+
+		// The iteration will have been stopped if the loop was paused.
+		if paused, _ := l.paused.Get(); paused && !stop.IsStopping() {
+			continue
+		}
+		return err
+	}
 }
 
 // runOnceUsing is called from runOnce or doBackfill.
@@ -265,7 +391,35 @@ func (l *loop) runOnceUsing(
 	ctx = stopper.WithContext(ctx)
 
 	// Make the stopping channel available to the dialect.
-	events = &stopperEvents{events, ctx.Stopping()}
+	events = &stopperEvents{&stopPointEvents{events, l}, ctx.Stopping()}
+
+	// Stop the iteration once the stop point has been reached, or
+	// immediately if it has been moved behind the consistent point.
+	// The iteration is also stopped if an operator pauses the loop.
+	ctx.Go(func() error {
+		_, stopChanged := l.factory.StopAt()
+		_, cpChanged := l.consistentPoint.Get()
+		_, refusedChanged := l.stopRefused.Get()
+		paused, pauseChanged := l.paused.Get()
+		for {
+			if reached, _ := l.stopPointReached(); reached || paused {
+				ctx.Stop(l.factory.baseConfig.ApplyTimeout)
+				return nil
+			}
+			select {
+			case <-pauseChanged:
+				paused, pauseChanged = l.paused.Get()
+			case <-stopChanged:
+				_, stopChanged = l.factory.StopAt()
+			case <-cpChanged:
+				_, cpChanged = l.consistentPoint.Get()
+			case <-refusedChanged:
+				_, refusedChanged = l.stopRefused.Get()
+			case <-ctx.Stopping():
+				return nil
+			}
+		}
+	})
 
 	// Start a background goroutine to maintain the replication
 	// connection. This source goroutine is set up to be robust; if
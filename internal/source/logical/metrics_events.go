@@ -52,6 +52,10 @@ var (
 		Name: "logical_last_commit_seconds",
 		Help: "the original time of the most recently applied commit from the source database",
 	}, loopLabels)
+	delayedAppliedTime = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "logical_delayed_applied_seconds",
+		Help: "the staging time of the most recently applied transaction when applyDelay is set",
+	}, loopLabels)
 )
 
 // metricsEvents decorates an Events implementation with metrics.
@@ -17,7 +17,9 @@
 package logical
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"reflect"
 
 	"github.com/cockroachdb/cdc-sink/internal/types"
@@ -37,17 +39,35 @@ type deferredData struct {
 // mutations to satisfy an (acyclic) FK dependency graph.
 type orderedEvents struct {
 	Events
-	Watcher types.Watcher
+	// If true, the underlying transaction defers foreign-key checks
+	// when the schema reports that the constraints between tables with
+	// cyclical references are deferrable. Those tables then need no
+	// special treatment.
+	Deferred bool
+	Watcher  types.Watcher
 	// Remember the last configuration.
 	lastDeps [][]ident.Table
 	// The table dependency tree.
 	levels *ident.TableMap[int]
+	// Tables with cyclical references that must be written in two
+	// passes, mapped to their referring columns. Nil if there are no
+	// such tables.
+	cycles *ident.TableMap[[]ident.Ident]
+	// Column data for the target tables.
+	columns *ident.TableMap[[]types.ColData]
 }
 
 // OnBegin implements Events. It will (re-)initialize the orderedEvents
 // fields in response to updated schema information.
 func (e *orderedEvents) OnBegin(ctx context.Context) (Batch, error) {
-	deps := e.Watcher.Get().Order
+	snapshot := e.Watcher.Get()
+	deps := snapshot.Order
+	e.columns = snapshot.Columns
+	if e.Deferred && snapshot.DeferrableCycles {
+		e.cycles = nil
+	} else {
+		e.cycles = snapshot.Cycles
+	}
 	if !reflect.DeepEqual(deps, e.lastDeps) {
 		e.lastDeps = deps
 		e.levels = &ident.TableMap[int]{}
@@ -72,6 +92,9 @@ func (e *orderedEvents) OnBegin(ctx context.Context) (Batch, error) {
 
 type orderedBatch struct {
 	Batch
+	// Mutations for tables with cyclical references, which will be
+	// written after all other tables.
+	cyclic []deferredData
 	// This only contains values for deferred mutations. That is,
 	// mutations to be applied to "root" tables will never be added
 	// here; they're immediately passed through.
@@ -83,7 +106,10 @@ var _ Batch = (*orderedBatch)(nil)
 
 // OnCommit implements Events. It will flush any deferred updates.
 func (e *orderedBatch) OnCommit(ctx context.Context) <-chan error {
-	defer func() { e.deferred = nil }()
+	defer func() {
+		e.cyclic = nil
+		e.deferred = nil
+	}()
 
 	for _, defs := range e.deferred {
 		// Ensure that previous levels have been completely written out
@@ -97,6 +123,11 @@ func (e *orderedBatch) OnCommit(ctx context.Context) <-chan error {
 			}
 		}
 	}
+	if len(e.cyclic) > 0 {
+		if err := e.applyCyclic(ctx); err != nil {
+			return singletonChannel(err)
+		}
+	}
 	return e.Batch.OnCommit(ctx)
 }
 
@@ -110,6 +141,12 @@ func (e *orderedBatch) OnData(
 	if !ok {
 		return errors.Errorf("unknown destination table %s", target)
 	}
+	if e.parent.cycles != nil {
+		if _, cyclic := e.parent.cycles.Get(target); cyclic {
+			e.cyclic = append(e.cyclic, deferredData{muts, source, target})
+			return nil
+		}
+	}
 	if destLevel == 0 {
 		return errors.Wrap(e.Batch.OnData(ctx, source, target, muts), "orderedEvents OnData")
 	}
@@ -119,6 +156,128 @@ func (e *orderedBatch) OnData(
 
 // OnRollback implements Events. It clears the internal state.
 func (e *orderedBatch) OnRollback(ctx context.Context) error {
+	e.cyclic = nil
 	e.deferred = nil
 	return errors.Wrap(e.Batch.OnRollback(ctx), "orderedEvents OnRollback")
 }
+
+// applyCyclic writes mutations to tables with cyclical references in
+// two passes. The first pass writes the rows with their referring
+// columns set to NULL. Once all rows exist, the second pass restores
+// the referring columns using sparse updates, which will not disturb
+// any other columns. Deletions are passed through as-is, so removing
+// rows that are still referenced relies upon the target's ON DELETE
+// behaviors.
+func (e *orderedBatch) applyCyclic(ctx context.Context) error {
+	if err := e.Batch.Flush(ctx); err != nil {
+		return errors.Wrap(err, "orderedEvents flush")
+	}
+	second := make([]deferredData, 0, len(e.cyclic))
+	for _, def := range e.cyclic {
+		refCols, _ := e.parent.cycles.Get(def.target)
+		cols, _ := e.parent.columns.Get(def.target)
+
+		first := make([]types.Mutation, len(def.muts))
+		var restore []types.Mutation
+		for idx, mut := range def.muts {
+			first[idx] = mut
+			if mut.IsDelete() {
+				continue
+			}
+			nulled, restored, changed, err := splitReferences(mut, cols, refCols)
+			if err != nil {
+				return errors.Wrapf(err, "could not split references for %s", def.target)
+			}
+			if changed {
+				first[idx] = nulled
+				restore = append(restore, restored)
+			}
+		}
+		if err := e.Batch.OnData(ctx, def.source, def.target, first); err != nil {
+			return errors.Wrap(err, "orderedEvents OnData")
+		}
+		if len(restore) > 0 {
+			second = append(second, deferredData{restore, def.source, def.target})
+		}
+	}
+	if len(second) == 0 {
+		return nil
+	}
+	if err := e.Batch.Flush(ctx); err != nil {
+		return errors.Wrap(err, "orderedEvents flush")
+	}
+	for _, def := range second {
+		if err := e.Batch.OnData(ctx, def.source, def.target, def.muts); err != nil {
+			return errors.Wrap(err, "orderedEvents OnData")
+		}
+	}
+	return nil
+}
+
+// splitReferences returns a copy of the mutation whose referring
+// columns have been set to NULL and a sparse update that contains only
+// the primary key and the original values of those columns. The
+// boolean will be false if the mutation has no non-NULL references.
+func splitReferences(
+	mut types.Mutation, cols []types.ColData, refCols []ident.Ident,
+) (types.Mutation, types.Mutation, bool, error) {
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(mut.Data, &data); err != nil {
+		return mut, mut, false, errors.WithStack(err)
+	}
+
+	changed := false
+	restore := make(map[string]json.RawMessage)
+	for name, value := range data {
+		col := ident.New(name)
+		if containsColumn(refCols, col) {
+			restore[name] = value
+			if !bytes.Equal(value, nullJSON) {
+				data[name] = nullJSON
+				changed = true
+			}
+			continue
+		}
+		for _, colData := range cols {
+			if colData.Primary && ident.Equal(colData.Name, col) {
+				restore[name] = value
+				break
+			}
+		}
+	}
+	if !changed {
+		return mut, mut, false, nil
+	}
+
+	nulled := mut
+	var err error
+	nulled.Data, err = json.Marshal(data)
+	if err != nil {
+		return mut, mut, false, errors.WithStack(err)
+	}
+
+	// Start with fresh metadata, since any dialect-specific behaviors
+	// were applied by the first pass.
+	restored := types.Mutation{
+		Key:  mut.Key,
+		Meta: map[string]any{types.SparseUpdate: true},
+		Time: mut.Time,
+	}
+	restored.Data, err = json.Marshal(restore)
+	if err != nil {
+		return mut, mut, false, errors.WithStack(err)
+	}
+	return nulled, restored, true, nil
+}
+
+var nullJSON = json.RawMessage("null")
+
+// containsColumn returns true if the column is in the slice.
+func containsColumn(cols []ident.Ident, col ident.Ident) bool {
+	for _, elt := range cols {
+		if ident.Equal(elt, col) {
+			return true
+		}
+	}
+	return false
+}
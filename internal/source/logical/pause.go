@@ -0,0 +1,65 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// PauseHandler returns an administrative endpoint which allows an
+// operator to freeze the replica. A POST request will pause all loops
+// created by the Factory, a DELETE request will resume them, and a GET
+// request reports the current state of each loop; the replica is only
+// reported as paused if every loop is paused. A paused loop stops reading from
+// its source and stops applying any data that it has staged, i.e. in
+// the cdc resolver or a source with an applyDelay. The request is
+// protected by [AdminGuard].
+func PauseHandler(auth types.Authenticator, factory *Factory) http.Handler {
+	return AdminGuard(auth, factory, "pause", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			factory.Pause()
+			log.Warn("replication paused by operator request")
+		case http.MethodDelete:
+			factory.Resume()
+			log.Info("replication resumed by operator request")
+		default:
+			http.Error(w, "GET, POST, or DELETE required", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Report the state of each loop, since loops may also be
+		// paused or resumed individually through the admin API.
+		ret := struct {
+			Loops  map[string]bool `json:"loops"`
+			Paused bool            `json:"paused"`
+		}{
+			Loops:  make(map[string]bool),
+			Paused: factory.Paused(),
+		}
+		for _, l := range factory.Loops() {
+			ret.Loops[l.Name()], _ = l.Paused()
+		}
+		w.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(ret)
+	}))
+}
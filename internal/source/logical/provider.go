@@ -22,6 +22,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/staging/version"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
@@ -39,6 +40,7 @@ var Set = wire.NewSet(
 	ProvideFactory,
 	ProvideBaseConfig,
 	ProvideDLQConfig,
+	ProvideEvolveConfig,
 	ProvideStagingDB,
 	ProvideStagingPool,
 	ProvideTargetPool,
@@ -63,6 +65,11 @@ func ProvideDLQConfig(config *BaseConfig) *dlq.Config {
 	return &config.DLQConfig
 }
 
+// ProvideEvolveConfig is called by Wire.
+func ProvideEvolveConfig(config *BaseConfig) *evolve.Config {
+	return &config.EvolveConfig
+}
+
 // ProvideFactory returns a utility which can create multiple logical
 // loops.
 func ProvideFactory(
@@ -73,6 +80,7 @@ func ProvideFactory(
 	diags *diag.Diagnostics,
 	memo types.Memo,
 	scriptLoader *script.Loader,
+	stagers types.Stagers,
 	stagingPool *types.StagingPool,
 	targetPool *types.TargetPool,
 	watchers types.Watchers,
@@ -89,18 +97,21 @@ func ProvideFactory(
 		return nil, errors.New("manual schema change required")
 	}
 
-	return &Factory{
+	ret := &Factory{
 		appliers:     appliers,
 		applyConfigs: applyConfigs,
 		baseConfig:   baseConfig,
 		diags:        diags,
 		memo:         memo,
 		scriptLoader: scriptLoader,
+		stagers:      stagers,
 		stagingPool:  stagingPool,
 		stop:         ctx,
 		targetPool:   targetPool,
 		watchers:     watchers,
-	}, nil
+	}
+	ret.stopAt.Set(baseConfig.StopAt)
+	return ret, nil
 }
 
 // ProvideStagingDB is called by Wire to retrieve the name of the
@@ -21,6 +21,7 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/pkg/errors"
 )
@@ -40,16 +41,63 @@ func (e *scriptEvents) OnBegin(ctx context.Context) (Batch, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &scriptBatch{delegate, e.Script}, nil
+	return &scriptBatch{Batch: delegate, Script: e.Script}, nil
 }
 
 type scriptBatch struct {
 	Batch
 	Script *script.UserScript
+
+	// Sources with transaction hooks that have been seen in the batch,
+	// in the order in which they were first seen.
+	txs []*scriptTx
 }
 
 var _ Batch = (*scriptBatch)(nil)
 
+// scriptTx tracks the portion of a source transaction that is
+// associated with a single configured source.
+type scriptTx struct {
+	cfg  *script.Source
+	meta script.TxMeta
+	seen ident.TableMap[struct{}]
+}
+
+// touch records the target table in the transaction metadata.
+func (t *scriptTx) touch(tbl ident.Table) {
+	if _, seen := t.seen.Get(tbl); seen {
+		return
+	}
+	t.seen.Put(tbl, struct{}{})
+	t.meta.Tables = append(t.meta.Tables, tbl)
+}
+
+// OnCommit implements Batch. It will call any transaction-commit hooks
+// that were defined for the sources within the batch and apply any
+// additional mutations that they return before committing the
+// underlying batch.
+func (e *scriptBatch) OnCommit(ctx context.Context) <-chan error {
+	txs := e.txs
+	e.txs = nil
+
+	for _, tx := range txs {
+		if tx.cfg.OnCommit == nil {
+			continue
+		}
+		extra, err := tx.cfg.OnCommit(ctx, &tx.meta)
+		if err == nil {
+			// Deletes are not expected from the hook.
+			err = e.route(ctx, tx.meta.Source, ident.Table{}, extra, nil)
+		}
+		if err != nil {
+			_ = e.Batch.OnRollback(ctx)
+			return singletonChannel(errors.Wrapf(err, "onCommit %s", tx.meta.Source))
+		}
+	}
+
+	return e.Batch.OnCommit(ctx)
+}
+
 // OnData implements Batch and calls any mapping logic provided by the
 // user-script for the given table.
 func (e *scriptBatch) OnData(
@@ -64,7 +112,19 @@ func (e *scriptBatch) OnData(
 	// call the user-provided logic.
 	var routing ident.TableMap[[]types.Mutation]
 
-	if cfg, ok := e.Script.Sources.Get(source); !ok {
+	cfg, ok := e.Script.Sources.Get(source)
+
+	// Track the source transaction if there are hooks defined.
+	var tx *scriptTx
+	if ok && (cfg.OnBegin != nil || cfg.OnCommit != nil) {
+		var err error
+		tx, err = e.sourceTx(ctx, source, cfg, muts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !ok {
 		// If no call to configureSource() has been made for this
 		// schema, we'll send the mutations to the existing target.
 		routing.Put(target, muts)
@@ -108,9 +168,56 @@ func (e *scriptBatch) OnData(
 		}
 	}
 
-	// We have established which mutations are going to which table. Now
-	// we can take the second step of calling the per-table map
-	// function, if any.
+	return e.route(ctx, source, deletesTo, &routing, tx)
+}
+
+// OnMessage implements Batch and calls the message hook provided by
+// the user-script for the source, if any. Mutations returned by the
+// hook are applied within the batch.
+func (e *scriptBatch) OnMessage(
+	ctx context.Context, source ident.Ident, msg *script.Message,
+) error {
+	cfg, ok := e.Script.Sources.Get(source)
+	if !ok || cfg.OnMessage == nil {
+		return nil
+	}
+
+	// Track the source transaction if there are hooks defined.
+	var tx *scriptTx
+	if cfg.OnBegin != nil || cfg.OnCommit != nil {
+		var err error
+		tx, err = e.sourceTx(ctx, source, cfg, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	extra, err := cfg.OnMessage(ctx, msg)
+	if err != nil {
+		return errors.Wrapf(err, "onMessage %s", source)
+	}
+	// Deletes are not expected from the hook.
+	return e.route(ctx, source, ident.Table{}, extra, tx)
+}
+
+// OnRollback implements Batch and discards any tracked source
+// transactions.
+func (e *scriptBatch) OnRollback(ctx context.Context) error {
+	e.txs = nil
+	return e.Batch.OnRollback(ctx)
+}
+
+// route takes the second step of calling the per-table map function,
+// if any, before passing the mutations to the underlying Batch. If the
+// source transaction is non-nil, it will be updated with the tables
+// that receive data.
+func (e *scriptBatch) route(
+	ctx context.Context,
+	source ident.Ident,
+	deletesTo ident.Table,
+	routing *ident.TableMap[[]types.Mutation],
+	tx *scriptTx,
+) error {
 	return routing.Range(func(tbl ident.Table, tblMuts []types.Mutation) error {
 		// Find the per-target map function.
 		var mapFn script.Map
@@ -120,6 +227,9 @@ func (e *scriptBatch) OnData(
 
 		// Fast-path: No map, so we can just send the data as-is.
 		if mapFn == nil {
+			if tx != nil && len(tblMuts) > 0 {
+				tx.touch(tbl)
+			}
 			return e.Batch.OnData(ctx, source, tbl, tblMuts)
 		}
 
@@ -150,11 +260,22 @@ func (e *scriptBatch) OnData(
 		}
 		tblMuts = tblMuts[:idx]
 		if len(tblMuts) > 0 {
+			if tx != nil {
+				tx.touch(tbl)
+			}
 			if err := e.Batch.OnData(ctx, source, tbl, tblMuts); err != nil {
 				return err
 			}
 		}
 		if len(deletes) > 0 {
+			if deletesTo.Empty() {
+				return errors.Errorf(
+					"cannot apply delete from %s because there is no "+
+						"table configured for receiving the delete", source)
+			}
+			if tx != nil {
+				tx.touch(deletesTo)
+			}
 			if err := e.Batch.OnData(ctx, source, deletesTo, deletes); err != nil {
 				return err
 			}
@@ -162,3 +283,41 @@ func (e *scriptBatch) OnData(
 		return nil
 	})
 }
+
+// sourceTx returns the tracking data for the source within the batch.
+// The source's transaction-begin hook will be invoked when the source
+// is first seen.
+func (e *scriptBatch) sourceTx(
+	ctx context.Context, source ident.Ident, cfg *script.Source, muts []types.Mutation,
+) (*scriptTx, error) {
+	var tx *scriptTx
+	for _, candidate := range e.txs {
+		if ident.Equal(candidate.meta.Source, source) {
+			tx = candidate
+			break
+		}
+	}
+
+	isNew := tx == nil
+	if isNew {
+		tx = &scriptTx{cfg: cfg, meta: script.TxMeta{Source: source}}
+		e.txs = append(e.txs, tx)
+	}
+
+	// Capture the latest time and log position in the transaction.
+	for _, mut := range muts {
+		if hlc.Compare(mut.Time, tx.meta.Time) > 0 {
+			tx.meta.Time = mut.Time
+		}
+		if lsn, ok := mut.Meta[script.MetaLSN].(string); ok {
+			tx.meta.LSN = lsn
+		}
+	}
+
+	if isNew && cfg.OnBegin != nil {
+		if err := cfg.OnBegin(ctx, &tx.meta); err != nil {
+			return nil, errors.Wrapf(err, "onBegin %s", source)
+		}
+	}
+	return tx, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingBatch records the calls made to it by a scriptBatch.
+type recordingBatch struct {
+	calls []string
+}
+
+var _ Batch = (*recordingBatch)(nil)
+
+func (b *recordingBatch) Flush(context.Context) error { return nil }
+
+func (b *recordingBatch) OnCommit(context.Context) <-chan error {
+	b.calls = append(b.calls, "commit")
+	return singletonChannel[error](nil)
+}
+
+func (b *recordingBatch) OnData(
+	_ context.Context, source ident.Ident, target ident.Table, muts []types.Mutation,
+) error {
+	b.calls = append(b.calls, fmt.Sprintf("data %s %s %d", source.Raw(), target.Table().Raw(), len(muts)))
+	return nil
+}
+
+func (b *recordingBatch) OnMessage(context.Context, ident.Ident, *script.Message) error {
+	return nil
+}
+
+func (b *recordingBatch) OnRollback(context.Context) error {
+	b.calls = append(b.calls, "rollback")
+	return nil
+}
+
+// Verify that the commit hooks of the sources in a batch are called in
+// the order in which the sources were first seen, with the tables that
+// received data, and that the batch is rolled back if a hook fails.
+func TestScriptBatchOnCommit(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	ctx := context.Background()
+
+	schema := ident.MustSchema(ident.New("db"), ident.Public)
+	tbl := func(name string) ident.Table { return ident.NewTable(schema, ident.New(name)) }
+	srcA, srcB, srcC := ident.New("a"), ident.New("b"), ident.New("c")
+
+	var hooks []string
+	var metas []script.TxMeta
+	var failB error
+	onCommit := func(ctx context.Context, meta *script.TxMeta) (*ident.TableMap[[]types.Mutation], error) {
+		hooks = append(hooks, "commit "+meta.Source.Raw())
+		metas = append(metas, *meta)
+		var extra ident.TableMap[[]types.Mutation]
+		if ident.Equal(meta.Source, srcB) {
+			return &extra, failB
+		}
+		// The hook for source a adds a row to an audit table.
+		extra.Put(tbl("audit"), []types.Mutation{{Data: []byte(`{}`), Key: []byte(`[1]`)}})
+		return &extra, nil
+	}
+
+	us := &script.UserScript{
+		Sources: &ident.Map[*script.Source]{},
+		Targets: &ident.TableMap[*script.Target]{},
+	}
+	us.Sources.Put(srcA, &script.Source{
+		OnBegin: func(_ context.Context, meta *script.TxMeta) error {
+			hooks = append(hooks, "begin "+meta.Source.Raw())
+			return nil
+		},
+		OnCommit: onCommit,
+	})
+	us.Sources.Put(srcB, &script.Source{OnCommit: onCommit})
+	us.Sources.Put(srcC, &script.Source{})
+
+	mut := func(nanos int64) []types.Mutation {
+		return []types.Mutation{{Data: []byte(`{}`), Key: []byte(`[1]`), Time: hlc.New(nanos, 0)}}
+	}
+	fill := func(e *scriptBatch) {
+		r.NoError(e.OnData(ctx, srcB, tbl("b1"), mut(1)))
+		r.NoError(e.OnData(ctx, srcA, tbl("a1"), mut(3)))
+		r.NoError(e.OnData(ctx, srcC, tbl("c1"), mut(4)))
+		r.NoError(e.OnData(ctx, srcA, tbl("a2"), mut(2)))
+		r.NoError(e.OnData(ctx, srcA, tbl("a1"), mut(1)))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		hooks, metas, failB = nil, nil, nil
+		delegate := &recordingBatch{}
+		e := &scriptBatch{Batch: delegate, Script: us}
+		fill(e)
+		r.NoError(<-e.OnCommit(ctx))
+
+		a.Equal([]string{"begin a", "commit b", "commit a"}, hooks)
+		if a.Len(metas, 2) {
+			a.Equal([]ident.Table{tbl("b1")}, metas[0].Tables)
+			a.Equal(hlc.New(1, 0), metas[0].Time)
+			a.Equal([]ident.Table{tbl("a1"), tbl("a2")}, metas[1].Tables)
+			a.Equal(hlc.New(3, 0), metas[1].Time)
+		}
+		// The extra mutations are applied before the delegate commits.
+		a.Equal([]string{
+			"data b b1 1",
+			"data a a1 1",
+			"data c c1 1",
+			"data a a2 1",
+			"data a a1 1",
+			"data a audit 1",
+			"commit",
+		}, delegate.calls)
+
+		// The source transactions are not carried into the next batch.
+		hooks = nil
+		r.NoError(<-e.OnCommit(ctx))
+		a.Empty(hooks)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		hooks, metas, failB = nil, nil, errors.New("boom")
+		delegate := &recordingBatch{}
+		e := &scriptBatch{Batch: delegate, Script: us}
+		fill(e)
+		err := <-e.OnCommit(ctx)
+		r.ErrorIs(err, failB)
+		a.ErrorContains(err, `onCommit "b"`)
+
+		// The failing hook stops any later hooks and the delegate is
+		// rolled back instead of committed.
+		a.Equal([]string{"begin a", "commit b"}, hooks)
+		a.Equal("rollback", delegate.calls[len(delegate.calls)-1])
+		a.NotContains(delegate.calls, "commit")
+	})
+}
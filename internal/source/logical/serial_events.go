@@ -19,6 +19,7 @@ package logical
 import (
 	"context"
 
+	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
@@ -30,6 +31,9 @@ type serialEvents struct {
 	appliers   types.Appliers
 	loop       *loop
 	targetPool *types.TargetPool
+	// If non-nil, foreign-key checks will be deferred within the
+	// target transaction whenever the schema contains cycles.
+	watcher types.Watcher
 }
 
 var _ Events = (*serialEvents)(nil)
@@ -53,7 +57,18 @@ func (e *serialEvents) OnBegin(ctx context.Context) (Batch, error) {
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return &serialBatch{e, tx}, nil
+	var restore string
+	if e.watcher != nil && e.watcher.Get().DeferrableCycles {
+		var begin string
+		begin, restore = foreignKeyDeferral(e.targetPool.Product)
+		if begin != "" {
+			if _, err := tx.ExecContext(ctx, begin); err != nil {
+				_ = tx.Rollback()
+				return nil, errors.Wrap(err, begin)
+			}
+		}
+	}
+	return &serialBatch{parent: e, restore: restore, tx: tx}, nil
 }
 
 // SetConsistentPoint implements State.
@@ -69,6 +84,9 @@ func (e *serialEvents) Stopping() <-chan struct{} {
 // A serialBatch corresponds exactly to a database transaction.
 type serialBatch struct {
 	parent *serialEvents
+	// A statement to execute before the transaction ends, to restore
+	// any session state altered by foreignKeyDeferral.
+	restore string
 
 	tx types.TargetTx
 }
@@ -81,11 +99,19 @@ func (e *serialBatch) Flush(context.Context) error {
 }
 
 // OnCommit implements Events.
-func (e *serialBatch) OnCommit(_ context.Context) <-chan error {
+func (e *serialBatch) OnCommit(ctx context.Context) <-chan error {
 	if e.tx == nil {
 		return singletonChannel(errors.New("OnCommit called without matching OnBegin"))
 	}
 
+	if e.restore != "" {
+		if _, err := e.tx.ExecContext(ctx, e.restore); err != nil {
+			_ = e.tx.Rollback()
+			e.tx = nil
+			return singletonChannel(errors.Wrap(err, e.restore))
+		}
+	}
+
 	err := e.tx.Commit()
 	e.tx = nil
 	if err != nil {
@@ -106,11 +132,42 @@ func (e *serialBatch) OnData(
 	return app.Apply(ctx, e.tx, muts)
 }
 
+// OnMessage implements Batch. Messages are only meaningful to a
+// user-script, which will have already consumed them.
+func (e *serialBatch) OnMessage(context.Context, ident.Ident, *script.Message) error {
+	return nil
+}
+
 // OnRollback implements Events and delegates to drain.
-func (e *serialBatch) OnRollback(_ context.Context) error {
+func (e *serialBatch) OnRollback(ctx context.Context) error {
 	if e.tx != nil {
+		if e.restore != "" {
+			_, _ = e.tx.ExecContext(ctx, e.restore)
+		}
 		_ = e.tx.Rollback()
 		e.tx = nil
 	}
 	return nil
 }
+
+// foreignKeyDeferral returns statements to execute at the beginning
+// and end of a target transaction so that foreign-key checks are
+// deferred until the transaction commits. This allows tables with
+// cyclical references to be written in any order. The returned values
+// will be empty if the product has no such capability, in which case
+// orderedEvents will use a two-pass strategy. The two-pass strategy is
+// also used if any of the constraints are NOT DEFERRABLE.
+func foreignKeyDeferral(product types.Product) (begin, end string) {
+	switch product {
+	case types.ProductOracle, types.ProductPostgreSQL:
+		// This only affects constraints that are DEFERRABLE.
+		return "SET CONSTRAINTS ALL DEFERRED", ""
+	case types.ProductMariaDB, types.ProductMySQL:
+		// MySQL cannot defer checks, only disable them. The setting
+		// is session-scoped, so it must be restored before the
+		// connection is returned to the pool.
+		return "SET foreign_key_checks = 0", "SET foreign_key_checks = 1"
+	default:
+		return "", ""
+	}
+}
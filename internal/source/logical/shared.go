@@ -0,0 +1,44 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/staging/version"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+)
+
+// Shared contains the resources that may be shared by several,
+// independently-configured loops running within a single process. The
+// dialect packages provide StartShared injectors which accept this
+// type.
+type Shared struct {
+	Appliers       types.Appliers
+	Configs        *applycfg.Configs
+	Creator        *autocreate.Creator
+	Diagnostics    *diag.Diagnostics
+	Evolver        *evolve.Evolver
+	Memo           types.Memo
+	Stagers        types.Stagers
+	StagingPool    *types.StagingPool
+	TargetPool     *types.TargetPool
+	VersionChecker *version.Checker
+	Watchers       types.Watchers
+}
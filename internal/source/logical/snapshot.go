@@ -0,0 +1,239 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// SnapshotProgress records the progress of an initial snapshot, which
+// copies the source tables in chunks before streaming begins. The
+// progress is persisted to the memo table, so that an interrupted copy
+// may be resumed from the last chunk that was committed to the target.
+// Dialects are responsible for reading the chunks from the source.
+type SnapshotProgress struct {
+	memo        types.Memo
+	memoKey     string
+	stagingPool *types.StagingPool
+
+	mu struct {
+		sync.Mutex
+		state snapshotState
+	}
+}
+
+// snapshotState is persisted to the memo table.
+type snapshotState struct {
+	// The dialect-specific position from which streaming must begin.
+	// This is nil until a snapshot has been taken, but may be empty.
+	Point *string `json:"cp,omitempty"`
+	// Progress, keyed by the source table name.
+	Tables map[string]*TableProgress `json:"tables,omitempty"`
+}
+
+// TableProgress records the key of the last row of the last chunk
+// that has been committed to the target.
+type TableProgress struct {
+	Done bool     `json:"done,omitempty"`
+	Last []string `json:"last,omitempty"`
+}
+
+// NewSnapshotProgress constructs a SnapshotProgress which is stored in
+// the memo under the given key.
+func NewSnapshotProgress(
+	memo types.Memo, stagingPool *types.StagingPool, memoKey string,
+) *SnapshotProgress {
+	ret := &SnapshotProgress{
+		memo:        memo,
+		memoKey:     memoKey,
+		stagingPool: stagingPool,
+	}
+	ret.mu.state.Tables = make(map[string]*TableProgress)
+	return ret
+}
+
+// Load retrieves any previous progress from the memo table.
+func (p *SnapshotProgress) Load(ctx context.Context) error {
+	data, err := p.memo.Get(ctx, p.stagingPool, p.memoKey)
+	if err != nil {
+		return err
+	}
+	var next snapshotState
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &next); err != nil {
+			return errors.Wrap(err, p.memoKey)
+		}
+	}
+	if next.Tables == nil {
+		next.Tables = make(map[string]*TableProgress)
+	}
+	p.mu.Lock()
+	p.mu.state = next
+	p.mu.Unlock()
+	return nil
+}
+
+// Point returns the position recorded by SetPoint. The boolean will be
+// false if no position has been recorded.
+func (p *SnapshotProgress) Point() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mu.state.Point == nil {
+		return "", false
+	}
+	return *p.mu.state.Point, true
+}
+
+// SetPoint records the position from which streaming must begin once
+// the copy has finished.
+func (p *SnapshotProgress) SetPoint(ctx context.Context, point string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mu.state.Point = &point
+	return p.storeLocked(ctx)
+}
+
+// Table returns a copy of the progress of the named source table, or
+// nil if no chunks have been committed.
+func (p *SnapshotProgress) Table(source string) *TableProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	found := p.mu.state.Tables[source]
+	if found == nil {
+		return nil
+	}
+	ret := *found
+	return &ret
+}
+
+// Pending returns the source tables which have not been fully copied.
+func (p *SnapshotProgress) Pending(sources []string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ret []string
+	for _, source := range sources {
+		if progress := p.mu.state.Tables[source]; progress != nil && progress.Done {
+			log.WithField("table", source).Debug("table already copied")
+			continue
+		}
+		ret = append(ret, source)
+	}
+	return ret
+}
+
+// CheckResume returns an error if a table without a primary key was
+// partially copied. Its rows were given random keys, so copying it
+// again would duplicate the rows that are already in the target.
+func (p *SnapshotProgress) CheckResume(tbl ident.Table, hasKey bool, progress *TableProgress) error {
+	if hasKey || progress == nil || progress.Done {
+		return nil
+	}
+	return errors.Errorf("table %s has no primary key and was partially copied; "+
+		"empty the target table and delete the %s memo entry to restart the snapshot",
+		tbl, p.memoKey)
+}
+
+// Send passes the chunk to the Dialect's Process method, which must
+// call [SnapshotChunk.Apply]. This method returns false if the loop is
+// stopping.
+func (p *SnapshotProgress) Send(
+	ctx context.Context, ch chan<- Message, state State, chunk *SnapshotChunk,
+) (bool, error) {
+	chunk.progress = p
+	select {
+	case ch <- chunk:
+		return true, nil
+	case <-state.Stopping():
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// record is called once a chunk has been committed to the target.
+func (p *SnapshotProgress) record(
+	ctx context.Context, source string, last []string, done bool,
+) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	progress := p.mu.state.Tables[source]
+	if progress == nil {
+		progress = &TableProgress{}
+		p.mu.state.Tables[source] = progress
+	}
+	if last != nil {
+		progress.Last = last
+	}
+	progress.Done = done
+	if done {
+		log.WithField("table", source).Info("copied table")
+	}
+	return p.storeLocked(ctx)
+}
+
+func (p *SnapshotProgress) storeLocked(ctx context.Context) error {
+	data, err := json.Marshal(p.mu.state)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return p.memo.Put(ctx, p.stagingPool, p.memoKey, data)
+}
+
+// A SnapshotChunk contains the rows from a single source query. It is
+// sent from a Dialect's BackfillInto method to its Process method by
+// [SnapshotProgress.Send].
+type SnapshotChunk struct {
+	Done   bool             // The last chunk of the table.
+	Last   []string         // The key of the last row, may be nil.
+	Muts   []types.Mutation // The rows to apply.
+	Source string           // The name of the source table.
+	Target ident.Table      // The table to apply the rows to.
+
+	progress *SnapshotProgress // Set by Send.
+}
+
+// Apply writes the chunk to the target and then records the progress
+// of the copy.
+func (c *SnapshotChunk) Apply(ctx context.Context, events Events) error {
+	if len(c.Muts) > 0 {
+		batch, err := events.OnBegin(ctx)
+		if err != nil {
+			return err
+		}
+		if err := batch.OnData(ctx, script.SourceName(c.Target), c.Target, c.Muts); err != nil {
+			_ = batch.OnRollback(ctx)
+			return err
+		}
+		select {
+		case err := <-batch.OnCommit(ctx):
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return c.progress.record(ctx, c.Source, c.Last, c.Done)
+}
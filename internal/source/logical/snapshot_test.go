@@ -0,0 +1,113 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stoppingState allows SnapshotProgress.Send to be tested without a
+// running loop.
+type stoppingState struct {
+	State
+	stopping chan struct{}
+}
+
+func (s *stoppingState) Stopping() <-chan struct{} { return s.stopping }
+
+// Verify that committed chunks are recorded and persisted, so that a
+// copy can be resumed.
+func TestSnapshotProgress(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	ctx := context.Background()
+
+	memo := &adminMemo{data: make(map[string][]byte)}
+	p := NewSnapshotProgress(memo, nil, "loop-snapshot")
+	r.NoError(p.Load(ctx))
+	_, ok := p.Point()
+	a.False(ok)
+	a.Nil(p.Table("t1"))
+
+	// An empty point is distinct from no point.
+	r.NoError(p.SetPoint(ctx, ""))
+	point, ok := p.Point()
+	a.True(ok)
+	a.Equal("", point)
+	r.NoError(p.SetPoint(ctx, "cp"))
+
+	ch := make(chan Message, 2)
+	state := &stoppingState{stopping: make(chan struct{})}
+	send := func(chunk *SnapshotChunk) {
+		ok, err := p.Send(ctx, ch, state, chunk)
+		r.NoError(err)
+		r.True(ok)
+		r.NoError((<-ch).(*SnapshotChunk).Apply(ctx, nil))
+	}
+	send(&SnapshotChunk{Last: []string{"1"}, Source: "t1"})
+	send(&SnapshotChunk{Done: true, Source: "t2"})
+	a.Equal(&TableProgress{Last: []string{"1"}}, p.Table("t1"))
+	a.Equal([]string{"t1", "t3"}, p.Pending([]string{"t1", "t2", "t3"}))
+
+	// A final chunk without rows retains the last key.
+	send(&SnapshotChunk{Done: true, Source: "t1"})
+	a.Equal(&TableProgress{Done: true, Last: []string{"1"}}, p.Table("t1"))
+
+	// Table returns a copy.
+	p.Table("t1").Done = false
+	a.True(p.Table("t1").Done)
+
+	// The progress is restored from the memo.
+	restored := NewSnapshotProgress(memo, nil, "loop-snapshot")
+	r.NoError(restored.Load(ctx))
+	point, ok = restored.Point()
+	a.True(ok)
+	a.Equal("cp", point)
+	a.Equal([]string{"t3"}, restored.Pending([]string{"t1", "t2", "t3"}))
+
+	// Nothing is sent once the loop is stopping.
+	close(state.stopping)
+	ch = make(chan Message)
+	ok, err := p.Send(ctx, ch, state, &SnapshotChunk{Source: "t3"})
+	a.NoError(err)
+	a.False(ok)
+}
+
+// A table without a primary key cannot be resumed once some of its
+// rows have been committed, since they were given random keys.
+func TestSnapshotCheckResume(t *testing.T) {
+	a := assert.New(t)
+
+	p := NewSnapshotProgress(nil, nil, "loop-snapshot")
+	tbl := ident.NewTable(ident.MustSchema(ident.New("db")), ident.New("my_table"))
+
+	a.NoError(p.CheckResume(tbl, true, nil))
+	a.NoError(p.CheckResume(tbl, true, &TableProgress{Last: []string{"1"}}))
+	a.NoError(p.CheckResume(tbl, false, nil))
+	a.NoError(p.CheckResume(tbl, false, &TableProgress{Done: true}))
+
+	err := p.CheckResume(tbl, false, &TableProgress{})
+	if a.Error(err) {
+		a.Contains(err.Error(), "has no primary key")
+		a.Contains(err.Error(), "loop-snapshot")
+	}
+}
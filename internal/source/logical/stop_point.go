@@ -0,0 +1,268 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrStopPointReached is returned when a loop is asked to apply data
+// after it has reached its requested stop point.
+var ErrStopPointReached = errors.New("replication stop point reached")
+
+// SetStopAt requests that all loops created by the Factory stop once
+// their consistent point has reached the given value. The format of
+// the value depends on the source. An empty string clears the stop
+// point, allowing stopped loops to continue.
+func (f *Factory) SetStopAt(raw string) error {
+	if raw != "" {
+		f.mu.Lock()
+		loops := append([]*Loop(nil), f.mu.started...)
+		f.mu.Unlock()
+		for _, l := range loops {
+			if _, err := l.loop.parseStopPoint(raw); err != nil {
+				return err
+			}
+		}
+	}
+	f.stopAt.Set(raw)
+	return nil
+}
+
+// StopAt returns the requested stop point, if any, and a channel that
+// will be closed when it has been changed.
+func (f *Factory) StopAt() (string, <-chan struct{}) {
+	return f.stopAt.Get()
+}
+
+// StopPoint returns the requested stop point, if any, and a channel
+// that will be closed once the loop has stopped at that point.
+func (l *Loop) StopPoint() (string, <-chan struct{}) {
+	raw, _ := l.loop.factory.StopAt()
+	return raw, l.loop.stopReached
+}
+
+// StopPoint combines the stop points of several loops created by the
+// same Factory. The returned channel will be closed once all of the
+// loops have stopped.
+func StopPoint(loops []*Loop) (string, <-chan struct{}) {
+	if len(loops) == 0 {
+		return "", nil
+	}
+	raw, _ := loops[0].StopPoint()
+	ret := make(chan struct{})
+	go func() {
+		for _, l := range loops {
+			_, reached := l.StopPoint()
+			select {
+			case <-reached:
+			case <-l.Stopped():
+				return
+			}
+		}
+		close(ret)
+	}()
+	return raw, ret
+}
+
+// StopPointStamp is an optional interface for [stamp.Stamp]
+// implementations that can be parsed from a user-provided stop point.
+// Stamps which implement [encoding.TextUnmarshaler] are also accepted.
+type StopPointStamp interface {
+	stamp.Stamp
+	// ParseStopPoint updates the stamp from the raw value.
+	ParseStopPoint(raw string) error
+}
+
+// parseStopPoint converts the raw value into the Dialect's stamp type.
+// A nil value will be returned if the raw value is empty.
+func (l *loop) parseStopPoint(raw string) (stamp.Stamp, error) {
+	return l.parseStamp(raw, "stop point")
+}
+
+// parseStamp converts a user-provided value into the Dialect's stamp
+// type. The description of the value is used in error messages.
+func (l *loop) parseStamp(raw, what string) (stamp.Stamp, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	ret := l.loopConfig.Dialect.ZeroStamp()
+	if !CanParseStamp(ret) {
+		return nil, errors.Errorf("loop %s does not support a %s", l.loopConfig.LoopName, what)
+	}
+	if err := ParseStamp(ret, raw); err != nil {
+		return nil, errors.Wrapf(err, "could not parse %s %q for loop %s",
+			what, raw, l.loopConfig.LoopName)
+	}
+	return ret, nil
+}
+
+// CanParseStamp returns true if the stamp implements [StopPointStamp]
+// or [encoding.TextUnmarshaler].
+func CanParseStamp(s stamp.Stamp) bool {
+	switch s.(type) {
+	case StopPointStamp, encoding.TextUnmarshaler:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseStamp updates the stamp from a user-provided value. The stamp
+// must implement [StopPointStamp] or [encoding.TextUnmarshaler].
+func ParseStamp(into stamp.Stamp, raw string) error {
+	switch x := into.(type) {
+	case StopPointStamp:
+		return x.ParseStopPoint(raw)
+	case encoding.TextUnmarshaler:
+		return x.UnmarshalText([]byte(raw))
+	default:
+		return errors.Errorf("%T cannot be parsed", into)
+	}
+}
+
+// stopPointReached returns true if the loop's consistent point is at
+// or beyond the requested stop point, or if the loop has refused a
+// transaction which commits beyond the stop point.
+func (l *loop) stopPointReached() (bool, error) {
+	raw, _ := l.factory.StopAt()
+	stopAt, err := l.parseStopPoint(raw)
+	if err != nil || stopAt == nil {
+		return false, err
+	}
+	if refused, _ := l.stopRefused.Get(); refused == raw {
+		return true, nil
+	}
+	cp, _ := l.consistentPoint.Get()
+	return stamp.Compare(cp, stopAt) >= 0, nil
+}
+
+// OnBeginAt is used by Dialects which know the commit position of a
+// source transaction before it is applied. The transaction will be
+// refused with ErrStopPointReached if it commits beyond the loop's
+// stop point, so that the loop stops exactly at the stop point rather
+// than at the first consistent point after it. The commit position is
+// also made available to the Events, so that a delayed loop can hold
+// the transaction relative to its source time.
+func OnBeginAt(ctx context.Context, events Events, commit stamp.Stamp) (Batch, error) {
+	if checker, ok := events.(stopPointChecker); ok {
+		if err := checker.checkStopPoint(commit); err != nil {
+			return nil, err
+		}
+	}
+	return events.OnBegin(context.WithValue(ctx, commitKey{}, commit))
+}
+
+// commitKey is a context key for the stamp passed to OnBeginAt.
+type commitKey struct{}
+
+// sourceCommitTime returns the source time of the transaction being
+// started by OnBeginAt. It returns false if the commit position is
+// unknown or cannot be represented as a time.
+func sourceCommitTime(ctx context.Context) (time.Time, bool) {
+	ts, ok := ctx.Value(commitKey{}).(TimeStamp)
+	if !ok || ts.AsTime().IsZero() {
+		return time.Time{}, false
+	}
+	return ts.AsTime(), true
+}
+
+// stopPointChecker is implemented by stopPointEvents and any Events
+// which delegate to it.
+type stopPointChecker interface {
+	checkStopPoint(commit stamp.Stamp) error
+}
+
+// stopPointEvents refuses to begin new batches once the loop has
+// reached its stop point.
+type stopPointEvents struct {
+	Events
+	loop *loop
+}
+
+var _ stopPointChecker = (*stopPointEvents)(nil)
+
+// OnBegin implements Events.
+func (e *stopPointEvents) OnBegin(ctx context.Context) (Batch, error) {
+	if reached, err := e.loop.stopPointReached(); err != nil {
+		return nil, err
+	} else if reached {
+		return nil, ErrStopPointReached
+	}
+	return e.Events.OnBegin(ctx)
+}
+
+// checkStopPoint returns ErrStopPointReached if a transaction that
+// commits at the given position would pass the stop point. The
+// refusal is recorded, so that the loop will treat its stop point as
+// having been reached.
+func (e *stopPointEvents) checkStopPoint(commit stamp.Stamp) error {
+	raw, _ := e.loop.factory.StopAt()
+	stopAt, err := e.loop.parseStopPoint(raw)
+	if err != nil || stopAt == nil {
+		return err
+	}
+	if stamp.Compare(commit, stopAt) > 0 {
+		e.loop.stopRefused.Set(raw)
+		return ErrStopPointReached
+	}
+	return nil
+}
+
+// StopAtHandler returns an administrative endpoint which allows an
+// operator to control the stop point of the loops created by the
+// Factory. A POST request with an "at" parameter sets the stop point, a
+// DELETE request clears it, and a GET request reports the current
+// value. A stop point set through this endpoint is not persisted. The
+// request is protected by [AdminGuard].
+func StopAtHandler(auth types.Authenticator, factory *Factory) http.Handler {
+	return AdminGuard(auth, factory, "stopAt", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			at := req.FormValue("at")
+			if at == "" {
+				http.Error(w, "at parameter required", http.StatusBadRequest)
+				return
+			}
+			if err := factory.SetStopAt(at); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Infof("replication stop point set to %s by operator request", at)
+		case http.MethodDelete:
+			_ = factory.SetStopAt("")
+			log.Info("replication stop point cleared by operator request")
+		default:
+			http.Error(w, "GET, POST, or DELETE required", http.StatusMethodNotAllowed)
+			return
+		}
+
+		at, _ := factory.StopAt()
+		w.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"stopAt": at})
+	}))
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/reject"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/trust"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopAtHandler(t *testing.T) {
+	r := require.New(t)
+
+	f := &Factory{}
+	audit := &adminAudit{}
+	f.mu.audit = audit
+	h := StopAtHandler(trust.New(), f)
+
+	do := func(method string, form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/_/stopAt", strings.NewReader(form.Encode()))
+		req.Header.Set("content-type", "application/x-www-form-urlencoded")
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, nil)
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"stopAt":""}`, w.Body.String())
+
+	w = do(http.MethodPost, nil)
+	r.Equal(http.StatusBadRequest, w.Code)
+
+	_, changed := f.StopAt()
+	w = do(http.MethodPost, url.Values{"at": {"1234.0000000001"}})
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"stopAt":"1234.0000000001"}`, w.Body.String())
+	select {
+	case <-changed:
+	default:
+		r.Fail("setting the stop point should have notified")
+	}
+
+	w = do(http.MethodDelete, nil)
+	r.Equal(http.StatusOK, w.Code)
+	r.JSONEq(`{"stopAt":""}`, w.Body.String())
+
+	r.Equal([]string{
+		"stopAt POST  Bad Request",
+		"stopAt POST  ok",
+		"stopAt DELETE  ok",
+	}, audit.entries)
+
+	// The admin claim is required.
+	w = httptest.NewRecorder()
+	StopAtHandler(reject.New(), f).ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/_/stopAt", nil))
+	r.Equal(http.StatusForbidden, w.Code)
+	r.Len(audit.entries, 3)
+}
+
+func TestStopPointUnsupported(t *testing.T) {
+	r := require.New(t)
+
+	f := &Factory{}
+	l := &loop{
+		factory:    f,
+		loopConfig: &LoopConfig{Dialect: &fakeDialect{}, LoopName: "fake"},
+	}
+	l.consistentPoint.Set(&fakeStamp{})
+
+	// No stop point is always acceptable.
+	reached, err := l.stopPointReached()
+	r.NoError(err)
+	r.False(reached)
+
+	// The fake dialect has no text representation of its stamps.
+	_, err = l.parseStopPoint("1")
+	r.ErrorContains(err, "does not support a stop point")
+
+	// Validation is performed against all started loops.
+	f.mu.started = []*Loop{{loop: l}}
+	r.Error(f.SetStopAt("1"))
+	raw, _ := f.StopAt()
+	r.Empty(raw)
+	r.NoError(f.SetStopAt(""))
+}
@@ -9,9 +9,11 @@ package logical
 import (
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/staging/memo"
+	"github.com/cockroachdb/cdc-sink/internal/staging/stage"
 	"github.com/cockroachdb/cdc-sink/internal/staging/version"
 	"github.com/cockroachdb/cdc-sink/internal/target/apply"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
@@ -52,7 +54,12 @@ func NewFactoryForTests(ctx *stopper.Context, config Config) (*Factory, error) {
 		return nil, err
 	}
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, err := apply.ProvideFactory(ctx, targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	evolveConfig := ProvideEvolveConfig(baseConfig)
+	evolver, err := evolve.ProvideEvolver(ctx, evolveConfig, diagnostics, targetPool, watchers)
+	if err != nil {
+		return nil, err
+	}
+	appliers, err := apply.ProvideFactory(ctx, targetStatements, configs, diagnostics, dlQs, evolver, targetPool, watchers)
 	if err != nil {
 		return nil, err
 	}
@@ -68,8 +75,9 @@ func NewFactoryForTests(ctx *stopper.Context, config Config) (*Factory, error) {
 	if err != nil {
 		return nil, err
 	}
+	stagers := stage.ProvideFactory(stagingPool, stagingSchema, ctx)
 	checker := version.ProvideChecker(stagingPool, memoMemo)
-	factory, err := ProvideFactory(ctx, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagingPool, targetPool, watchers, checker)
+	factory, err := ProvideFactory(ctx, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagers, stagingPool, targetPool, watchers, checker)
 	if err != nil {
 		return nil, err
 	}
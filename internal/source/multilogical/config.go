@@ -0,0 +1,255 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package multilogical
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultRefresh = 10 * time.Second
+
+// Dialects that may be named in a [LoopSpec].
+const (
+	DialectFirestore = "fslogical"
+	DialectMySQL     = "mylogical"
+	DialectPostgres  = "pglogical"
+)
+
+// sharedFlags are process-wide settings which may not be overridden
+// by an individual loop, since the connection pools and staging schema
+// are shared.
+var sharedFlags = map[string]bool{
+	"stagingConn":              true,
+	"stagingDB":                true,
+	"stagingSchema":            true,
+	"targetConn":               true,
+	"targetDBConns":            true,
+	"targetStatementCacheSize": true,
+}
+
+// Config contains the process-wide configuration. The embedded
+// BaseConfig provides the connection pools and staging schema that are
+// shared by all loops, as well as default values for the other
+// settings.
+type Config struct {
+	logical.BaseConfig
+
+	// The path to a YAML or JSON file that contains the loop specs.
+	File string
+	// How often to check the file for changes.
+	Refresh time.Duration
+
+	flags *pflag.FlagSet // Used to propagate defaults into loops.
+}
+
+// Bind adds flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.BaseConfig.Bind(f)
+	c.flags = f
+
+	f.StringVar(&c.File, "config", "",
+		"the path to a YAML or JSON file that describes the loops to run")
+	f.DurationVar(&c.Refresh, "configRefresh", defaultRefresh,
+		"how often to check the config file for changes")
+}
+
+// Preflight updates the configuration with sane defaults or returns an
+// error if there are missing options for which a default cannot be
+// provided.
+func (c *Config) Preflight() error {
+	if err := c.BaseConfig.Preflight(); err != nil {
+		return err
+	}
+	if c.File == "" {
+		return errors.New("no config file specified")
+	}
+	if c.Refresh <= 0 {
+		c.Refresh = defaultRefresh
+	}
+	if c.ScriptConfig.FS != nil {
+		return errors.New("a userscript must be specified for each loop in the config file")
+	}
+	if c.StopAt != "" {
+		return errors.New("stopAt must be specified for each loop in the config file")
+	}
+	return nil
+}
+
+// LoopSpec describes a single replication loop.
+type LoopSpec struct {
+	// One of the Dialect constants.
+	Dialect string `json:"dialect" yaml:"dialect"`
+	// A unique name for the loop, which is also used as the loopName.
+	Name string `json:"name" yaml:"name"`
+	// The schema into which the loop will write.
+	TargetSchema string `json:"targetSchema" yaml:"targetSchema"`
+	// An optional path to a userscript. Relative paths are resolved
+	// against the directory that contains the config file.
+	Userscript string `json:"userscript,omitempty" yaml:"userscript,omitempty"`
+	// Additional command-line flags for the dialect, without the
+	// leading dashes.
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// specFile is the top-level structure of the config file.
+type specFile struct {
+	Loops []*LoopSpec `json:"loops" yaml:"loops"`
+}
+
+// readSpecs loads and validates the loop specs in the file. Since YAML
+// is a superset of JSON, either format is accepted.
+func readSpecs(path string) ([]*LoopSpec, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parseSpecs(buf, filepath.Dir(path))
+}
+
+// parseSpecs decodes and validates the loop specs. Relative userscript
+// paths are resolved against the given directory.
+func parseSpecs(buf []byte, dir string) ([]*LoopSpec, error) {
+	var file specFile
+	if err := yaml.Unmarshal(buf, &file); err != nil {
+		return nil, errors.Wrap(err, "could not parse config file")
+	}
+
+	seen := make(map[string]bool, len(file.Loops))
+	targets := &ident.SchemaMap[string]{}
+	for idx, spec := range file.Loops {
+		if spec == nil {
+			return nil, errors.Errorf("loop %d: empty spec", idx)
+		}
+		if spec.Name == "" {
+			return nil, errors.Errorf("loop %d: no name specified", idx)
+		}
+		if seen[spec.Name] {
+			return nil, errors.Errorf("loop %s: duplicate name", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		switch spec.Dialect {
+		case DialectFirestore, DialectMySQL, DialectPostgres:
+		default:
+			return nil, errors.Errorf("loop %s: unknown dialect %q", spec.Name, spec.Dialect)
+		}
+		if spec.TargetSchema == "" {
+			return nil, errors.Errorf("loop %s: no targetSchema specified", spec.Name)
+		}
+		// The per-table apply configurations are shared by all loops,
+		// so the loops must not write into the same schema.
+		target, err := ident.ParseSchema(spec.TargetSchema)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loop %s: targetSchema", spec.Name)
+		}
+		if other, ok := targets.Get(target); ok {
+			return nil, errors.Errorf(
+				"loop %s: targetSchema %s is also used by loop %s", spec.Name, target, other)
+		}
+		targets.Put(target, spec.Name)
+		for key := range spec.Options {
+			key = strings.TrimLeft(key, "-")
+			switch key {
+			case "loopName", "targetDB", "targetSchema", "userscript":
+				return nil, errors.Errorf(
+					"loop %s: %s must be set using the spec field, not an option", spec.Name, key)
+			}
+			if sharedFlags[key] {
+				return nil, errors.Errorf(
+					"loop %s: %s is shared by all loops and cannot be set per loop", spec.Name, key)
+			}
+		}
+		if spec.Userscript != "" && !filepath.IsAbs(spec.Userscript) {
+			spec.Userscript = filepath.Join(dir, spec.Userscript)
+		}
+	}
+	return file.Loops, nil
+}
+
+// A loopConfig is implemented by the dialect-specific Config types.
+type loopConfig interface {
+	logical.Config
+	Bind(*pflag.FlagSet)
+}
+
+// configure populates the dialect-specific configuration for a loop.
+// Any flags that were set on the command line are applied first,
+// followed by the values from the spec. The shared connection settings
+// are then copied from the process-wide configuration.
+func (c *Config) configure(spec *LoopSpec, cfg loopConfig) error {
+	f := pflag.NewFlagSet(spec.Name, pflag.ContinueOnError)
+	cfg.Bind(f)
+
+	var err error
+	if c.flags != nil {
+		c.flags.Visit(func(flag *pflag.Flag) {
+			if err != nil || sharedFlags[flag.Name] || f.Lookup(flag.Name) == nil {
+				return
+			}
+			if slice, ok := flag.Value.(pflag.SliceValue); ok {
+				if dest, ok := f.Lookup(flag.Name).Value.(pflag.SliceValue); ok {
+					err = dest.Replace(slice.GetSlice())
+					return
+				}
+			}
+			err = f.Set(flag.Name, flag.Value.String())
+		})
+		if err != nil {
+			return errors.Wrapf(err, "loop %s", spec.Name)
+		}
+	}
+
+	set := func(name, value string) error {
+		if err := f.Set(name, value); err != nil {
+			return errors.Wrapf(err, "loop %s: %s", spec.Name, name)
+		}
+		return nil
+	}
+	if err := set("loopName", spec.Name); err != nil {
+		return err
+	}
+	if err := set("targetSchema", spec.TargetSchema); err != nil {
+		return err
+	}
+	if spec.Userscript != "" {
+		if err := set("userscript", spec.Userscript); err != nil {
+			return err
+		}
+	}
+	for key, value := range spec.Options {
+		if err := set(strings.TrimLeft(key, "-"), value); err != nil {
+			return err
+		}
+	}
+
+	base := cfg.Base()
+	base.StagingConn = c.StagingConn
+	base.StagingSchema = c.StagingSchema
+	base.TargetConn = c.TargetConn
+	base.TargetDBConns = c.TargetDBConns
+	base.TargetStatementCacheSize = c.TargetStatementCacheSize
+	return nil
+}
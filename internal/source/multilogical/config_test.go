@@ -0,0 +1,133 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package multilogical
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/pglogical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpecs(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	specs, err := parseSpecs([]byte(`
+loops:
+  - name: orders
+    dialect: pglogical
+    targetSchema: orders.public
+    userscript: scripts/orders.ts
+    options:
+      publicationName: orders_pub
+      slotName: orders_slot
+  - name: inventory
+    dialect: mylogical
+    targetSchema: inventory.public
+`), "/etc/cdc-sink")
+	r.NoError(err)
+	r.Len(specs, 2)
+	a.Equal("orders", specs[0].Name)
+	a.Equal("/etc/cdc-sink/scripts/orders.ts", specs[0].Userscript)
+	a.Equal("orders_pub", specs[0].Options["publicationName"])
+	a.Equal(DialectMySQL, specs[1].Dialect)
+
+	// JSON is also accepted.
+	specs, err = parseSpecs([]byte(`{"loops": [
+  {"name": "a", "dialect": "fslogical", "targetSchema": "a.public"}
+]}`), "")
+	r.NoError(err)
+	r.Len(specs, 1)
+
+	tcs := []struct {
+		file string
+		err  string
+	}{
+		{`loops: [{dialect: pglogical, targetSchema: a.public}]`, "no name"},
+		{`loops: [{name: a, dialect: nope, targetSchema: a.public}]`, "unknown dialect"},
+		{`loops: [{name: a, dialect: pglogical}]`, "no targetSchema"},
+		{`loops: [
+  {name: a, dialect: pglogical, targetSchema: a.public},
+  {name: a, dialect: pglogical, targetSchema: b.public}]`, "duplicate name"},
+		{`loops: [
+  {name: a, dialect: pglogical, targetSchema: a.public},
+  {name: b, dialect: mylogical, targetSchema: A.PUBLIC}]`, "also used by loop a"},
+		{`loops: [{name: a, dialect: pglogical, targetSchema: a.public,
+  options: {targetConn: "postgres://"}}]`, "shared by all loops"},
+		{`loops: [{name: a, dialect: pglogical, targetSchema: a.public,
+  options: {loopName: b}}]`, "spec field"},
+		{`loops: {`, "could not parse"},
+	}
+	for _, tc := range tcs {
+		_, err := parseSpecs([]byte(tc.file), "")
+		a.ErrorContains(err, tc.err, tc.file)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	cfg := &Config{}
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	cfg.Bind(flags)
+	r.NoError(flags.Parse([]string{
+		"--config", "loops.yaml",
+		"--retryDelay", "5s",
+		"--stagingSchema", "_cdc_sink.public",
+		"--targetConn", "postgresql://target",
+	}))
+	r.NoError(cfg.Preflight())
+
+	spec := &LoopSpec{
+		Dialect:      DialectPostgres,
+		Name:         "orders",
+		TargetSchema: "orders.public",
+		Options: map[string]string{
+			"publicationName": "orders_pub",
+			"retryDelay":      "10s",
+			"slotName":        "orders_slot",
+		},
+	}
+	loopCfg := &pglogical.Config{}
+	r.NoError(cfg.configure(spec, loopCfg))
+
+	a.Equal("orders", loopCfg.LoopName)
+	a.Equal(ident.MustSchema(ident.New("orders"), ident.New("public")), loopCfg.TargetSchema)
+	a.Equal("orders_pub", loopCfg.Publication)
+	a.Equal("orders_slot", loopCfg.Slot)
+	// The spec overrides the command-line value.
+	a.Equal(10*time.Second, loopCfg.RetryDelay)
+	// Shared values are copied from the process-wide config.
+	a.Equal("postgresql://target", loopCfg.TargetConn)
+	a.Equal(cfg.StagingConn, loopCfg.StagingConn)
+	a.Equal(cfg.StagingSchema, loopCfg.StagingSchema)
+
+	// Defaults from the command line are otherwise propagated.
+	spec.Options = map[string]string{"publicationName": "p"}
+	loopCfg = &pglogical.Config{}
+	r.NoError(cfg.configure(spec, loopCfg))
+	a.Equal(5*time.Second, loopCfg.RetryDelay)
+
+	spec.Options = map[string]string{"noSuchFlag": "x"}
+	a.ErrorContains(cfg.configure(spec, &pglogical.Config{}), "noSuchFlag")
+}
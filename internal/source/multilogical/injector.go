@@ -0,0 +1,50 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build wireinject
+// +build wireinject
+
+package multilogical
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/staging"
+	"github.com/cockroachdb/cdc-sink/internal/target"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/google/wire"
+)
+
+// Start creates the resources that are shared by all loops and then
+// starts the loops described by the config file.
+func Start(ctx *stopper.Context, config *Config) (*Multi, error) {
+	panic(wire.Build(
+		wire.Bind(new(context.Context), new(*stopper.Context)),
+		wire.Struct(new(logical.Shared), "*"),
+		Set,
+		diag.New,
+		logical.ProvideDLQConfig,
+		logical.ProvideEvolveConfig,
+		logical.ProvideStagingDB,
+		logical.ProvideStagingPool,
+		logical.ProvideTargetPool,
+		logical.ProvideTargetStatements,
+		staging.Set,
+		target.Set,
+	))
+}
@@ -0,0 +1,320 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package multilogical runs several, independently-configured logical
+// replication loops within a single process. The loops are described
+// by a declarative config file, which is periodically re-read so that
+// loops may be added, changed, or removed without disrupting the
+// others.
+package multilogical
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/fslogical"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/source/mylogical"
+	"github.com/cockroachdb/cdc-sink/internal/source/pglogical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// stopGracePeriod bounds the time that a removed or changed loop is
+// given to shut down cleanly.
+const stopGracePeriod = 30 * time.Second
+
+// Multi manages the loops described by a config file.
+type Multi struct {
+	Diagnostics *diag.Diagnostics
+
+	config *Config
+	shared *logical.Shared
+	// Starts the loop described by the spec. This is a field so that
+	// it may be replaced in tests.
+	start func(
+		ctx *stopper.Context, spec *LoopSpec, diags *diag.Diagnostics,
+	) (stdlogical.HasHandlers, error)
+	stop *stopper.Context
+
+	// Serializes calls to reconcile, which stops and starts loops
+	// without holding mu.
+	reconcileMu sync.Mutex
+
+	mu struct {
+		sync.Mutex
+		lastErr error
+		running map[string]*running
+	}
+}
+
+var (
+	_ stdlogical.HasDiagnostics = (*Multi)(nil)
+	_ stdlogical.HasHandlers    = (*Multi)(nil)
+)
+
+// loopsPrefix is the path beneath which the endpoints of each loop are
+// served.
+const loopsPrefix = "/_/loops/"
+
+// running tracks a loop that was started from a spec.
+type running struct {
+	diags    *diag.Diagnostics      // Registered under the loop's name.
+	err      error                  // Set if the loop could not be started.
+	handlers stdlogical.HasHandlers // The loop's endpoints, if it started.
+	mux      *http.ServeMux         // Built from handlers on first use.
+	spec     *LoopSpec              // The spec that the loop was started with.
+	stop     *stopper.Context       // Stops the loop.
+}
+
+// AddHandlers implements [stdlogical.HasHandlers]. Each loop has its
+// own pause, stop point, and admin endpoints, which are served beneath
+// /_/loops/<name>/ (e.g. /_/loops/<name>/_/admin/loops).
+func (m *Multi) AddHandlers(auth types.Authenticator, mux *http.ServeMux) {
+	mux.Handle(loopsPrefix, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, loopsPrefix), "/")
+
+		// The mux is retained, since the admin API has state.
+		var h http.Handler
+		m.mu.Lock()
+		if r, ok := m.mu.running[name]; ok && r.handlers != nil {
+			if r.mux == nil {
+				r.mux = &http.ServeMux{}
+				r.handlers.AddHandlers(auth, r.mux)
+			}
+			h = r.mux
+		}
+		m.mu.Unlock()
+
+		if h == nil {
+			http.NotFound(w, req)
+			return
+		}
+		http.StripPrefix(loopsPrefix+name, h).ServeHTTP(w, req)
+	}))
+}
+
+// Diagnostic implements [diag.Diagnostic].
+func (m *Multi) Diagnostic(_ context.Context) any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type loopState struct {
+		Error string    `json:"error,omitempty"`
+		Spec  *LoopSpec `json:"spec"`
+	}
+	ret := struct {
+		Error string       `json:"error,omitempty"`
+		File  string       `json:"file"`
+		Loops []*loopState `json:"loops"`
+	}{File: m.config.File}
+	if m.mu.lastErr != nil {
+		ret.Error = m.mu.lastErr.Error()
+	}
+	for _, r := range m.mu.running {
+		state := &loopState{Spec: r.spec}
+		if r.err != nil {
+			state.Error = r.err.Error()
+		}
+		ret.Loops = append(ret.Loops, state)
+	}
+	sort.Slice(ret.Loops, func(i, j int) bool {
+		return ret.Loops[i].Spec.Name < ret.Loops[j].Spec.Name
+	})
+	return ret
+}
+
+// GetDiagnostics implements [stdlogical.HasDiagnostics].
+func (m *Multi) GetDiagnostics() *diag.Diagnostics {
+	return m.Diagnostics
+}
+
+// refresh re-reads the config file and reconciles the running loops.
+// Errors in the file are reported, but do not affect the loops that
+// are already running.
+func (m *Multi) refresh() error {
+	specs, err := readSpecs(m.config.File)
+
+	m.mu.Lock()
+	m.mu.lastErr = err
+	m.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return m.reconcile(specs)
+}
+
+// reconcile stops any loops that are no longer present in the specs,
+// restarts those whose specs have changed, and starts new loops. The
+// first error encountered while starting a loop will be returned.
+func (m *Multi) reconcile(specs []*LoopSpec) error {
+	m.reconcileMu.Lock()
+	defer m.reconcileMu.Unlock()
+
+	wanted := make(map[string]*LoopSpec, len(specs))
+	for _, spec := range specs {
+		wanted[spec.Name] = spec
+	}
+
+	// Find removed or changed loops.
+	var toStop []*running
+	m.mu.Lock()
+	for name, r := range m.mu.running {
+		spec, ok := wanted[name]
+		if ok && r.err == nil && reflect.DeepEqual(spec, r.spec) {
+			continue
+		}
+		if ok && r.err != nil {
+			log.Infof("retrying loop %s", name)
+		} else if ok {
+			log.Infof("restarting loop %s", name)
+		} else {
+			log.Infof("stopping loop %s", name)
+		}
+		toStop = append(toStop, r)
+		delete(m.mu.running, name)
+	}
+	m.mu.Unlock()
+
+	// Stopping a loop may take some time, so mu is not held. A changed
+	// loop must stop before its replacement is started.
+	for _, r := range toStop {
+		m.stopLoop(r)
+	}
+
+	// Start new loops, in file order. Only reconcile modifies the
+	// running map, so it's safe to check and then start.
+	var firstErr error
+	for _, spec := range specs {
+		m.mu.Lock()
+		_, ok := m.mu.running[spec.Name]
+		m.mu.Unlock()
+		if ok {
+			continue
+		}
+		r := m.startLoop(spec)
+		if r.err != nil {
+			log.WithError(r.err).Warnf("could not start loop %s", spec.Name)
+			if firstErr == nil {
+				firstErr = r.err
+			}
+		} else {
+			log.Infof("started loop %s", spec.Name)
+		}
+		m.mu.Lock()
+		m.mu.running[spec.Name] = r
+		m.mu.Unlock()
+	}
+	return firstErr
+}
+
+// run periodically refreshes the loops until the context is stopped,
+// at which point all loops will be stopped.
+func (m *Multi) run() error {
+	for {
+		select {
+		case <-m.stop.Stopping():
+			// Stops all loops, but cannot fail to start any.
+			_ = m.reconcile(nil)
+			return nil
+		case <-time.After(m.config.Refresh):
+		}
+		if err := m.refresh(); err != nil {
+			log.WithError(err).Warnf("could not refresh loops from %s", m.config.File)
+		}
+	}
+}
+
+// startLoop creates a loop from the spec. Any error will be recorded
+// in the returned value, so that the start can be retried the next
+// time that the file is refreshed.
+func (m *Multi) startLoop(spec *LoopSpec) *running {
+	ret := &running{
+		spec: spec,
+		stop: stopper.WithContext(m.stop),
+	}
+	diags, err := m.shared.Diagnostics.Wrap(spec.Name)
+	if err != nil {
+		ret.err = errors.Wrapf(err, "loop %s", spec.Name)
+		ret.stop.Stop(0)
+		return ret
+	}
+	ret.diags = diags
+
+	handlers, err := m.start(ret.stop, spec, diags)
+	if err != nil {
+		ret.err = errors.Wrapf(err, "loop %s", spec.Name)
+		m.stopLoop(ret)
+		return ret
+	}
+	ret.handlers = handlers
+	return ret
+}
+
+// startDialect starts a loop using the dialect named by the spec.
+func (m *Multi) startDialect(
+	ctx *stopper.Context, spec *LoopSpec, diags *diag.Diagnostics,
+) (stdlogical.HasHandlers, error) {
+	switch spec.Dialect {
+	case DialectFirestore:
+		cfg := &fslogical.Config{}
+		if err := m.config.configure(spec, cfg); err != nil {
+			return nil, err
+		}
+		return fslogical.StartShared(ctx, cfg, diags, m.shared)
+	case DialectMySQL:
+		cfg := &mylogical.Config{}
+		if err := m.config.configure(spec, cfg); err != nil {
+			return nil, err
+		}
+		return mylogical.StartShared(ctx, cfg, diags, m.shared)
+	case DialectPostgres:
+		cfg := &pglogical.Config{}
+		if err := m.config.configure(spec, cfg); err != nil {
+			return nil, err
+		}
+		return pglogical.StartShared(ctx, cfg, diags, m.shared)
+	default:
+		return nil, errors.Errorf("unknown dialect %q", spec.Dialect)
+	}
+}
+
+// stopLoop stops the loop and waits for it to exit. Any apply
+// configurations set by the loop's userscript are discarded, since
+// they are shared by all loops.
+func (m *Multi) stopLoop(r *running) {
+	r.stop.Stop(stopGracePeriod)
+	<-r.stop.Done()
+	if r.diags != nil {
+		m.shared.Diagnostics.Unregister(r.spec.Name)
+		r.diags = nil
+	}
+	// The spec has already been validated.
+	if target, err := ident.ParseSchema(r.spec.TargetSchema); err == nil {
+		m.shared.Configs.Reset(target)
+	}
+}
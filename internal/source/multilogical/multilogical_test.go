@@ -0,0 +1,218 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package multilogical
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReconcile uses a stub start function to verify that loops are
+// added, restarted, and removed, and that a slow loop shutdown does
+// not block diagnostics.
+func TestReconcile(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	stop := stopper.WithContext(context.Background())
+	defer stop.Stop(0)
+
+	shared := &logical.Shared{
+		Configs:     &applycfg.Configs{},
+		Diagnostics: diag.New(stop),
+	}
+	m := &Multi{
+		Diagnostics: shared.Diagnostics,
+		config:      &Config{},
+		shared:      shared,
+		stop:        stop,
+	}
+	m.mu.running = make(map[string]*running)
+
+	// The stub records the context of each started loop. Loops with a
+	// "slow" option wait for release before shutting down.
+	var mu sync.Mutex
+	started := make(map[string]*stopper.Context)
+	release := make(chan struct{})
+	m.start = func(
+		ctx *stopper.Context, spec *LoopSpec, _ *diag.Diagnostics,
+	) (stdlogical.HasHandlers, error) {
+		if spec.Options["fail"] != "" {
+			return nil, errors.New(spec.Options["fail"])
+		}
+		mu.Lock()
+		started[spec.Name] = ctx
+		mu.Unlock()
+		ctx.Go(func() error {
+			<-ctx.Stopping()
+			if spec.Options["slow"] != "" {
+				<-release
+			}
+			return nil
+		})
+		return stubHandlers(spec.Name), nil
+	}
+	loopCtx := func(name string) *stopper.Context {
+		mu.Lock()
+		defer mu.Unlock()
+		return started[name]
+	}
+	loopNames := func() []string {
+		buf, err := json.Marshal(m.Diagnostic(stop))
+		r.NoError(err)
+		var payload struct {
+			Loops []struct {
+				Error string    `json:"error"`
+				Spec  *LoopSpec `json:"spec"`
+			} `json:"loops"`
+		}
+		r.NoError(json.Unmarshal(buf, &payload))
+		var ret []string
+		for _, l := range payload.Loops {
+			name := l.Spec.Name
+			if l.Error != "" {
+				name += ": " + l.Error
+			}
+			ret = append(ret, name)
+		}
+		return ret
+	}
+
+	specA := &LoopSpec{Dialect: DialectPostgres, Name: "a", TargetSchema: "a.public"}
+	specB := &LoopSpec{Dialect: DialectPostgres, Name: "b", TargetSchema: "b.public"}
+
+	// Add loops.
+	r.NoError(m.reconcile([]*LoopSpec{specA, specB}))
+	a.Equal([]string{"a", "b"}, loopNames())
+	firstA, firstB := loopCtx("a"), loopCtx("b")
+	r.NotNil(firstA)
+	r.NotNil(firstB)
+
+	// A changed loop is restarted and its apply configuration is
+	// discarded. An unchanged loop is left alone.
+	tblB := ident.NewTable(ident.MustSchema(ident.New("b"), ident.Public), ident.New("tbl"))
+	r.NoError(shared.Configs.Set(tblB, &applycfg.Config{Extras: ident.New("extras")}))
+	changedB := &LoopSpec{Dialect: DialectPostgres, Name: "b", TargetSchema: "b.public",
+		Options: map[string]string{"slow": "true"}}
+	r.NoError(m.reconcile([]*LoopSpec{specA, changedB}))
+	a.True(firstB.IsStopping())
+	a.False(firstA.IsStopping())
+	a.Same(firstA, loopCtx("a"))
+	a.NotSame(firstB, loopCtx("b"))
+	cfg, _ := shared.Configs.Get(tblB).Get()
+	a.True(cfg.IsZero())
+
+	// Remove the slow loop. Diagnostics must remain available while
+	// waiting for it to stop.
+	secondB := loopCtx("b")
+	done := make(chan error, 1)
+	go func() { done <- m.reconcile([]*LoopSpec{specA}) }()
+	for !secondB.IsStopping() {
+		time.Sleep(time.Millisecond)
+	}
+	a.Equal([]string{"a"}, loopNames())
+	select {
+	case <-done:
+		r.Fail("reconcile should be waiting for the loop to stop")
+	default:
+	}
+	close(release)
+	select {
+	case err := <-done:
+		r.NoError(err)
+	case <-time.After(time.Minute):
+		r.FailNow("timed out")
+	}
+	a.NotContains(shared.Diagnostics.Payload(stop), "b")
+
+	// A loop that cannot be started is reported and retried.
+	failC := &LoopSpec{Dialect: DialectPostgres, Name: "c", TargetSchema: "c.public",
+		Options: map[string]string{"fail": "boom"}}
+	r.ErrorContains(m.reconcile([]*LoopSpec{specA, failC}), "boom")
+	a.Equal([]string{"a", "c: loop c: boom"}, loopNames())
+	specC := &LoopSpec{Dialect: DialectPostgres, Name: "c", TargetSchema: "c.public"}
+	r.NoError(m.reconcile([]*LoopSpec{specA, specC}))
+	a.Equal([]string{"a", "c"}, loopNames())
+	a.False(firstA.IsStopping())
+
+	// Remove everything.
+	r.NoError(m.reconcile(nil))
+	a.Empty(loopNames())
+	a.True(firstA.IsStopping())
+	a.True(loopCtx("c").IsStopping())
+}
+
+// stubHandlers registers a pause endpoint that echoes the loop name.
+type stubHandlers string
+
+func (s stubHandlers) AddHandlers(_ types.Authenticator, mux *http.ServeMux) {
+	mux.HandleFunc("/_/pause", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, string(s))
+	})
+}
+
+// TestAddHandlers verifies that requests are dispatched to the
+// endpoints of the named loop.
+func TestAddHandlers(t *testing.T) {
+	a := assert.New(t)
+
+	m := &Multi{}
+	m.mu.running = map[string]*running{
+		"a":      {handlers: stubHandlers("a")},
+		"b":      {handlers: stubHandlers("b")},
+		"failed": {err: errors.New("boom")},
+	}
+	mux := &http.ServeMux{}
+	m.AddHandlers(nil, mux)
+
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	code, body := get("/_/loops/a/_/pause")
+	a.Equal(http.StatusOK, code)
+	a.Equal("a", body)
+	code, body = get("/_/loops/b/_/pause")
+	a.Equal(http.StatusOK, code)
+	a.Equal("b", body)
+
+	code, _ = get("/_/loops/a/_/nope")
+	a.Equal(http.StatusNotFound, code)
+	code, _ = get("/_/loops/failed/_/pause")
+	a.Equal(http.StatusNotFound, code)
+	code, _ = get("/_/loops/missing/_/pause")
+	a.Equal(http.StatusNotFound, code)
+}
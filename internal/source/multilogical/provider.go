@@ -0,0 +1,61 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package multilogical
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/google/wire"
+)
+
+// Set is used by Wire.
+var Set = wire.NewSet(
+	ProvideBaseConfig,
+	ProvideMulti,
+)
+
+// ProvideBaseConfig is called by Wire to validate the configuration
+// and extract the BaseConfig, which describes the shared resources.
+func ProvideBaseConfig(config *Config) (*logical.BaseConfig, error) {
+	if err := config.Preflight(); err != nil {
+		return nil, err
+	}
+	return &config.BaseConfig, nil
+}
+
+// ProvideMulti is called by Wire to start the loops described by the
+// config file. Unlike later refreshes, any error in the file or in
+// starting a loop will prevent the process from starting.
+func ProvideMulti(ctx *stopper.Context, config *Config, shared *logical.Shared) (*Multi, error) {
+	ret := &Multi{
+		Diagnostics: shared.Diagnostics,
+		config:      config,
+		shared:      shared,
+		stop:        ctx,
+	}
+	ret.mu.running = make(map[string]*running)
+	ret.start = ret.startDialect
+
+	if err := ret.Diagnostics.Register("multilogical", ret); err != nil {
+		return nil, err
+	}
+	if err := ret.refresh(); err != nil {
+		return nil, err
+	}
+	ctx.Go(ret.run)
+	return ret, nil
+}
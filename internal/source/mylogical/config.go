@@ -28,14 +28,31 @@ import (
 	"github.com/spf13/pflag"
 )
 
+const (
+	defaultSnapshotChunkSize   = 10_000
+	defaultSnapshotParallelism = 4
+)
+
 // Config contains the configuration necessary for creating a
 // replication connection. ServerID and SourceConn are mandatory.
 type Config struct {
 	logical.BaseConfig
 	logical.LoopConfig
 
+	// If true, replicate using binlog file and position coordinates
+	// instead of GTIDs.
+	BinlogPosition bool
+	// The starting binlog position, if BinlogPosition is set.
+	DefaultBinlogPosition string
+
 	FetchMetadata bool
 
+	// If true, the tables in the source database will be copied from a
+	// consistent snapshot before streaming begins.
+	InitialSnapshot     bool
+	SnapshotChunkSize   int // The maximum number of rows per snapshot query.
+	SnapshotParallelism int // The number of tables to copy concurrently.
+
 	SourceConn string // Connection string for the source db.
 	ProcessID  uint32 // A unique ID to identify this process to the master.
 
@@ -60,6 +77,19 @@ func (c *Config) Bind(f *pflag.FlagSet) {
 	f.StringVar(&c.LoopConfig.DefaultConsistentPoint, "defaultGTIDSet", "",
 		"default GTIDSet. Used if no state is persisted")
 
+	f.BoolVar(&c.BinlogPosition, "binlogPosition", false,
+		"replicate using binlog file and position coordinates instead of GTIDs; "+
+			"for sources that do not have gtid_mode enabled")
+	f.StringVar(&c.DefaultBinlogPosition, "defaultBinlogPosition", "",
+		"default binlog file and position (e.g. mysql-bin.000001:4) if binlogPosition is set. "+
+			"Used if no state is persisted")
+	f.BoolVar(&c.InitialSnapshot, "initialSnapshot", false,
+		"copy the source tables from a consistent snapshot before streaming; "+
+			"requires a non-zero backfillWindow")
+	f.IntVar(&c.SnapshotChunkSize, "snapshotChunkSize", defaultSnapshotChunkSize,
+		"the number of rows to read from the source in each initial snapshot query")
+	f.IntVar(&c.SnapshotParallelism, "snapshotParallelism", defaultSnapshotParallelism,
+		"the number of tables to copy concurrently during the initial snapshot")
 	f.Uint32Var(&c.ProcessID, "replicationProcessID", 10,
 		"the replication process id to report to the source database")
 	f.StringVar(&c.SourceConn, "sourceConn", "",
@@ -128,6 +158,37 @@ func (c *Config) Preflight() error {
 		return errors.New("no SourceConn was configured")
 	}
 
+	if c.BinlogPosition {
+		if c.DefaultBinlogPosition != "" {
+			if c.DefaultConsistentPoint != "" && c.DefaultConsistentPoint != c.DefaultBinlogPosition {
+				return errors.New("defaultGTIDSet cannot be used with binlogPosition")
+			}
+			if _, err := parsePosition(c.DefaultBinlogPosition); err != nil {
+				return err
+			}
+			c.DefaultConsistentPoint = c.DefaultBinlogPosition
+		}
+	} else if c.DefaultBinlogPosition != "" {
+		return errors.New("defaultBinlogPosition requires binlogPosition")
+	}
+
+	if c.InitialSnapshot {
+		// The loop only calls BackfillInto when backfilling is enabled.
+		if c.BackfillWindow <= 0 {
+			return errors.New("initialSnapshot requires a positive backfillWindow")
+		}
+		if c.DefaultConsistentPoint != "" {
+			return errors.New("initialSnapshot cannot be used with a default GTID set " +
+				"or binlog position; the initial snapshot determines where streaming begins")
+		}
+		if c.SnapshotChunkSize <= 0 {
+			c.SnapshotChunkSize = defaultSnapshotChunkSize
+		}
+		if c.SnapshotParallelism <= 0 {
+			c.SnapshotParallelism = defaultSnapshotParallelism
+		}
+	}
+
 	u, err := url.Parse(c.SourceConn)
 	if err != nil {
 		return err
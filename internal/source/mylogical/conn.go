@@ -18,6 +18,8 @@
 // replication feed.
 // It uses Replication with Global Transaction Identifiers.
 // See  https://dev.mysql.com/doc/refman/8.0/en/replication-gtids.html
+// Sources without GTIDs may be replicated using binlog file and
+// position coordinates instead.
 package mylogical
 
 import (
@@ -32,9 +34,12 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/jsondiff"
 	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
 	"github.com/go-mysql-org/go-mysql/client"
 	"github.com/go-mysql-org/go-mysql/mysql"
@@ -53,6 +58,11 @@ type conn struct {
 	columns *ident.TableMap[[]types.ColData]
 	// The connector configuration.
 	config *Config
+	// If non-nil, missing target tables will be created.
+	creator *autocreate.Creator
+	// If non-nil, source column types are offered as hints when
+	// adding columns to the target.
+	evolver *evolve.Evolver
 	// Flavor is one of the mysql.MySQLFlavor or mysql.MariaDBFlavor constants
 	flavor string
 	// Map source ids to target tables.
@@ -120,6 +130,22 @@ func (c *conn) Process(
 			streamCP = nextStamp
 			continue
 		}
+		switch msg := msg.(type) {
+		case *logical.SnapshotChunk:
+			// Sent by snapshotConn.BackfillInto.
+			if err := msg.Apply(ctx, events); err != nil {
+				return err
+			}
+			snapshotRowCount.Add(float64(len(msg.Muts)))
+			continue
+
+		case *snapshotComplete:
+			log.WithField("gtid", msg.cp).Info("initial snapshot complete")
+			if err := events.SetConsistentPoint(ctx, msg.cp); err != nil {
+				return err
+			}
+			continue
+		}
 		var ev, ok = msg.(replication.BinlogEvent)
 		if !ok {
 			return errors.Errorf("unexpected message %T", msg)
@@ -129,7 +155,7 @@ func (c *conn) Process(
 		// We will be handling Row Based Replication Events
 		//  https://dev.mysql.com/doc/internals/en/binlog-event.html#:~:text=Row%20Based%20Replication%20Events
 		//  Source settings:
-		//  binlog_row_image=full  (default setting)
+		//  binlog_row_image=full  (default setting), or minimal
 		//  https://dev.mysql.com/doc/refman/8.0/en/replication-options-binary-log.html#sysvar_binlog_row_image
 		//  binlog_row_metadata = full (default = minimal)
 		//  https://dev.mysql.com/doc/refman/8.0/en/replication-options-binary-log.html#sysvar_binlog_row_metadata
@@ -159,11 +185,32 @@ func (c *conn) Process(
 				return ctx.Err()
 			}
 
+			// Without GTIDs, the consistent point is the position of
+			// the event that follows the commit.
+			if streamCP.AsPosition() != nil {
+				streamCP = streamCP.withPosition(
+					time.Unix(int64(ev.Header.Timestamp), 0), ev.Header.LogPos)
+			}
+
 			if err := events.SetConsistentPoint(ctx, streamCP); err != nil {
 				return err
 			}
 
+		case *replication.RotateEvent:
+			// The binlog has moved to the next file. This is also sent
+			// when the connection is established.
+			if streamCP.AsPosition() != nil {
+				streamCP = streamCP.withRotation(mysql.Position{
+					Name: string(e.NextLogName),
+					Pos:  uint32(e.Position),
+				})
+			}
+
 		case *replication.GTIDEvent:
+			// Sources without GTIDs send anonymous GTID events.
+			if streamCP.AsPosition() != nil {
+				continue
+			}
 			// A transaction is executed and committed on the source.
 			// This client transaction is assigned a GTID composed of the source's UUID
 			// and the smallest nonzero transaction sequence number not yet used on this server (GNO)
@@ -185,13 +232,15 @@ func (c *conn) Process(
 			if e.IsStandalone() {
 				continue
 			}
-			ts := time.Unix(int64(ev.Header.Timestamp), 0)
 			var err error
-			streamCP, err = streamCP.withMariaGTIDSet(ts, &e.GTID)
-			if err != nil {
-				return err
+			if streamCP.AsPosition() == nil {
+				ts := time.Unix(int64(ev.Header.Timestamp), 0)
+				streamCP, err = streamCP.withMariaGTIDSet(ts, &e.GTID)
+				if err != nil {
+					return err
+				}
 			}
-			batch, err = events.OnBegin(ctx)
+			batch, err = c.onBegin(ctx, events, streamCP)
 			if err != nil {
 				return err
 			}
@@ -202,14 +251,14 @@ func (c *conn) Process(
 			log.Tracef("Query:  %s %+v\n", e.Query, e.GSet)
 			if bytes.Equal(e.Query, []byte("BEGIN")) {
 				var err error
-				batch, err = events.OnBegin(ctx)
+				batch, err = c.onBegin(ctx, events, streamCP)
 				if err != nil {
 					return err
 				}
 			}
 
 		case *replication.TableMapEvent:
-			if err := c.onRelation(e); err != nil {
+			if err := c.onRelation(ctx, e, events.GetTargetDB()); err != nil {
 				return err
 			}
 
@@ -226,7 +275,15 @@ func (c *conn) Process(
 				return errors.Errorf("Operation not supported %s", ev.Header.EventType)
 			}
 			mutationCount.With(prometheus.Labels{"type": operation.String()}).Inc()
-			if err := c.onDataTuple(ctx, batch, events.GetTargetDB(), e, operation); err != nil {
+			if err := c.onDataTuple(ctx, batch, events.GetTargetDB(), e, operation, nil); err != nil {
+				return err
+			}
+
+		case *partialRowsEvent:
+			mutationCount.With(prometheus.Labels{"type": updateMutation.String()}).Inc()
+			if err := c.onDataTuple(
+				ctx, batch, events.GetTargetDB(), e.RowsEvent, updateMutation, e.Diffs,
+			); err != nil {
 				return err
 			}
 
@@ -237,6 +294,19 @@ func (c *conn) Process(
 	return nil
 }
 
+// onBegin starts a batch for a source transaction. When GTIDs are in
+// use, the stream's consistent point already includes the GTID of the
+// transaction, so it is checked against the loop's stop point. A
+// binlog position is only known once the transaction is complete.
+func (c *conn) onBegin(
+	ctx context.Context, events logical.Events, streamCP *consistentPoint,
+) (logical.Batch, error) {
+	if streamCP.AsPosition() != nil {
+		return events.OnBegin(ctx)
+	}
+	return logical.OnBeginAt(ctx, events, streamCP)
+}
+
 // ReadInto implements logical.Dialect, opens a replication connection,
 // and writes supported events into the provided channel.
 func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state logical.State) error {
@@ -247,7 +317,13 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 	if cp == nil {
 		return errors.New("missing gtidset")
 	}
-	streamer, err := syncer.StartSyncGTID(cp.(*consistentPoint).AsGTIDSet())
+	var streamer *replication.BinlogStreamer
+	var err error
+	if pos := cp.(*consistentPoint).AsPosition(); pos != nil {
+		streamer, err = syncer.StartSync(*pos)
+	} else {
+		streamer, err = syncer.StartSyncGTID(cp.(*consistentPoint).AsGTIDSet())
+	}
 	if err != nil {
 		dialFailureCount.Inc()
 		return err
@@ -261,6 +337,7 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 		return ctx.Err()
 	}
 
+	partial := newPartialDecoder()
 	for {
 		// Make GetEvent interruptable.
 		eventCtx, cancelEventRead := context.WithCancel(ctx)
@@ -279,13 +356,20 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 		} else if err != nil {
 			return errors.WithStack(err)
 		}
+		// Rewrite partial updates, which the syncer doesn't decode.
+		ev, err = partial.observe(ev)
+		if err != nil {
+			return err
+		}
 		log.Tracef("received %T", ev.Event)
 		switch e := ev.Event.(type) {
 		case *replication.XIDEvent,
 			*replication.GTIDEvent,
 			*replication.TableMapEvent,
 			*replication.RowsEvent,
+			*partialRowsEvent,
 			*replication.QueryEvent,
+			*replication.RotateEvent,
 			*replication.MariadbGTIDEvent,
 			*replication.MariadbAnnotateRowsEvent:
 			select {
@@ -294,7 +378,6 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 				return nil
 			}
 		case *replication.GenericEvent,
-			*replication.RotateEvent,
 			*replication.PreviousGTIDsEvent,
 			*replication.MariadbGTIDListEvent,
 			*replication.MariadbBinlogCheckPointEvent:
@@ -318,15 +401,31 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 
 // ZeroStamp implements logical.Dialect.
 func (c *conn) ZeroStamp() stamp.Stamp {
+	return c.zeroPoint()
+}
+
+// zeroPoint returns an empty consistentPoint for the replication mode.
+func (c *conn) zeroPoint() *consistentPoint {
+	if c.config.BinlogPosition {
+		return newConsistentPoint(positionFlavor)
+	}
 	return newConsistentPoint(c.flavor)
 }
 
+// onDataTuple converts the rows of the event into mutations. If the
+// source writes minimal row images, columns that are absent from an
+// after image are omitted from the mutation's data, rather than being
+// set to NULL. Updates that omit columns are marked as sparse, so that
+// those columns retain their current values in the target. Partial
+// JSON updates are applied to the before image, if it is available, or
+// are otherwise passed along to be applied to the target's value.
 func (c *conn) onDataTuple(
 	ctx context.Context,
 	batch logical.Batch,
 	filter ident.Schema,
 	tuple *replication.RowsEvent,
 	operation mutationType,
+	diffs []map[int][]jsondiff.Diff,
 ) error {
 	tbl, ok := c.relations[tuple.TableID]
 	if !ok {
@@ -340,6 +439,18 @@ func (c *conn) onDataTuple(
 	if !ok {
 		return errors.Errorf("no column data for %s", tbl)
 	}
+	// skipped returns true if the column is absent from the row image.
+	skipped := func(rowNum, idx int) bool {
+		if rowNum >= len(tuple.SkippedColumns) {
+			return false
+		}
+		for _, skip := range tuple.SkippedColumns[rowNum] {
+			if skip == idx {
+				return true
+			}
+		}
+		return false
+	}
 	log.Tracef("%s on table %s (#rows: %d)", operation, tbl, len(tuple.Rows))
 	for rowNum, row := range tuple.Rows {
 		var err error
@@ -351,8 +462,42 @@ func (c *conn) onDataTuple(
 		if operation == updateMutation && (rowNum%2 == 0) {
 			continue
 		}
+		var rowDiffs map[int][]jsondiff.Diff
+		if rowNum < len(diffs) {
+			rowDiffs = diffs[rowNum]
+		}
+		sparse := false
+		targetDiffs := make(map[string][]jsondiff.Diff)
 		for idx, sourceCol := range row {
 			targetCol := targetCols[idx]
+			// The before image supplies the values of key columns and
+			// the documents to which JSON diffs apply.
+			hasBefore := operation == updateMutation && !skipped(rowNum-1, idx)
+			if colDiffs, ok := rowDiffs[idx]; ok {
+				doc, _ := tuple.Rows[rowNum-1][idx].(string)
+				if !hasBefore || doc == "" {
+					targetDiffs[targetCol.Name.Raw()] = colDiffs
+					sparse = true
+					continue
+				}
+				next, err := jsondiff.Apply([]byte(doc), colDiffs)
+				if err != nil {
+					return errors.Wrapf(err, "could not apply partial update to %s.%s",
+						tbl, targetCol.Name)
+				}
+				sourceCol = string(next)
+			} else if skipped(rowNum, idx) {
+				switch {
+				case targetCol.Primary && hasBefore:
+					sourceCol = tuple.Rows[rowNum-1][idx]
+				case targetCol.Primary && operation != deleteMutation:
+					return errors.Errorf("row image for %s is missing key column %s",
+						tbl, targetCol.Name)
+				default:
+					sparse = sparse || operation == updateMutation
+					continue
+				}
+			}
 			switch s := sourceCol.(type) {
 			case nil:
 				enc[targetCol.Name.Raw()] = nil
@@ -385,6 +530,13 @@ func (c *conn) onDataTuple(
 				return err
 			}
 		}
+		if sparse {
+			sparseUpdateCount.Inc()
+			mut.Meta = map[string]any{types.SparseUpdate: true}
+			if len(targetDiffs) > 0 {
+				mut.Meta[types.JSONDiffs] = targetDiffs
+			}
+		}
 		script.AddMeta("mylogical", tbl, &mut)
 		err = batch.OnData(ctx, script.SourceName(tbl), tbl, []types.Mutation{mut})
 		if err != nil {
@@ -425,10 +577,13 @@ func (c *conn) getColNames(table ident.Table) ([][]byte, []uint64, error) {
 	return names, keys, nil
 }
 
-// onRelation updates the source database namespace mappings.
-// Columns names are only available if
+// onRelation updates the source database namespace mappings and will
+// create the target table, if so configured. Columns names are only
+// available if
 // set global binlog_row_metadata = full;
-func (c *conn) onRelation(msg *replication.TableMapEvent) error {
+func (c *conn) onRelation(
+	ctx context.Context, msg *replication.TableMapEvent, filter ident.Schema,
+) error {
 	tbl := ident.NewTable(
 		ident.MustSchema(ident.New(string(msg.Schema)), ident.Public),
 		ident.New(string(msg.Table)))
@@ -467,7 +622,24 @@ func (c *conn) onRelation(msg *replication.TableMapEvent) error {
 		}
 	}
 	c.columns.Put(tbl, colData)
-	return nil
+
+	if !filter.Contains(tbl) || (c.creator == nil && !c.evolver.Enabled(tbl)) {
+		return nil
+	}
+	unsigned := msg.UnsignedMap()
+	cols := make([]autocreate.Column, len(colData))
+	for idx, col := range colData {
+		cols[idx] = autocreate.Column{
+			Kind:    kindOfType(msg.ColumnType[idx], unsigned[idx]),
+			Name:    col.Name,
+			Primary: col.Primary,
+		}
+	}
+	c.evolver.Hint(tbl, cols)
+	if c.creator == nil {
+		return nil
+	}
+	return c.creator.Ensure(ctx, tbl, cols)
 }
 
 var (
@@ -481,7 +653,7 @@ var (
 	mySQL5SystemSettings = [][]string{
 		{"gtid_mode", "ON", "1"},
 		{"enforce_gtid_consistency", "ON", "1"},
-		{"binlog_row_image", "FULL"},
+		{"binlog_row_image", "FULL", "MINIMAL", "NOBLOB"},
 		{"binlog_format", "ROW"},
 		{"log_bin", "1"},
 	}
@@ -522,11 +694,8 @@ func getFlavor(config *Config) (string, string, error) {
 	version := string(res.Values[0][0].AsString())
 	log.Infof("Version info: %s", version)
 	if strings.Contains(strings.ToLower(version), "mariadb") {
-		for _, v := range mariaDBSystemSettings {
-			err = checkSystemSetting(c, v[0], v[1:])
-			if err != nil {
-				return "", "", err
-			}
+		if err := checkSystemSettings(c, config, mariaDBSystemSettings); err != nil {
+			return "", "", err
 		}
 		return mysql.MariaDBFlavor, version, nil
 	}
@@ -535,23 +704,31 @@ func getFlavor(config *Config) (string, string, error) {
 		config.FetchMetadata = true
 	}
 	if config.FetchMetadata {
-		for _, v := range mySQL5SystemSettings {
-			err = checkSystemSetting(c, v[0], v[1:])
-			if err != nil {
-				return "", "", err
-			}
+		if err := checkSystemSettings(c, config, mySQL5SystemSettings); err != nil {
+			return "", "", err
 		}
 		return mysql.MySQLFlavor, version, nil
 	}
-	for _, v := range mySQLSystemSettings {
-		err = checkSystemSetting(c, v[0], v[1:])
-		if err != nil {
-			return "", "", err
-		}
+	if err := checkSystemSettings(c, config, mySQLSystemSettings); err != nil {
+		return "", "", err
 	}
 	return mysql.MySQLFlavor, version, nil
 }
 
+// checkSystemSettings verifies each of the settings. The GTID-related
+// settings are not required if replicating by binlog position.
+func checkSystemSettings(c *client.Conn, config *Config, settings [][]string) error {
+	for _, v := range settings {
+		if config.BinlogPosition && strings.Contains(v[0], "gtid") {
+			continue
+		}
+		if err := checkSystemSetting(c, v[0], v[1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // checkSystemSetting verifies that the given system variable is set to one of
 // the expected values.
 func checkSystemSetting(c *client.Conn, variable string, expected []string) error {
@@ -18,6 +18,9 @@ package mylogical
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
@@ -26,12 +29,19 @@ import (
 	"github.com/pkg/errors"
 )
 
+// positionFlavor is a pseudo-flavor for consistent points that are
+// binlog file and position coordinates, rather than GTID sets. This is
+// used with sources that do not have GTIDs enabled.
+const positionFlavor = "position"
+
 // consistentPoint provides a uniform API around the various
-// flavors of GTIDSet used by the replication library.
+// flavors of GTIDSet used by the replication library, or a binlog
+// file and position.
 type consistentPoint struct {
-	ma *mysql.MariadbGTIDSet
-	my *mysql.MysqlGTIDSet
-	ts time.Time // The approximate wall time of the consistent point.
+	ma  *mysql.MariadbGTIDSet
+	my  *mysql.MysqlGTIDSet
+	pos *mysql.Position
+	ts  time.Time // The approximate wall time of the consistent point.
 }
 
 var _ logical.TimeStamp = (*consistentPoint)(nil)
@@ -54,11 +64,19 @@ func newConsistentPoint(flavor string) *consistentPoint {
 			},
 		}
 
+	case positionFlavor:
+		return &consistentPoint{pos: &mysql.Position{}}
+
 	default:
 		panic(errors.Errorf("Invalid flavor %s", flavor))
 	}
 }
 
+// ZeroStamp returns an empty consistent point whose flavor will be
+// determined when it is decoded. It is used by tools which decode the
+// consistent points stored in the staging schema.
+func ZeroStamp() stamp.Stamp { return &consistentPoint{} }
+
 // AsGTIDSet returns the enclosed GTIDSet.
 func (c *consistentPoint) AsGTIDSet() mysql.GTIDSet {
 	switch {
@@ -71,6 +89,12 @@ func (c *consistentPoint) AsGTIDSet() mysql.GTIDSet {
 	}
 }
 
+// AsPosition returns the enclosed binlog position, or nil if the
+// consistentPoint is a GTID set.
+func (c *consistentPoint) AsPosition() *mysql.Position {
+	return c.pos
+}
+
 // AsTime implements logical.TimeStamp.
 func (c *consistentPoint) AsTime() time.Time {
 	return c.ts
@@ -83,6 +107,8 @@ func (c *consistentPoint) IsZero() bool {
 		return len(c.ma.Sets) == 0
 	case c.my != nil:
 		return len(c.my.Sets) == 0
+	case c.pos != nil:
+		return c.pos.Name == ""
 	default:
 		return true
 	}
@@ -97,6 +123,9 @@ func (c *consistentPoint) Less(other stamp.Stamp) bool {
 	if c.IsZero() {
 		return true
 	}
+	if c.pos != nil {
+		return c.pos.Compare(*oPoint.pos) < 0
+	}
 
 	cSet := c.AsGTIDSet()
 	oSet := oPoint.AsGTIDSet()
@@ -105,6 +134,9 @@ func (c *consistentPoint) Less(other stamp.Stamp) bool {
 
 // String is for debugging use only.
 func (c *consistentPoint) String() string {
+	if c.pos != nil {
+		return formatPosition(*c.pos)
+	}
 	return c.AsGTIDSet().String()
 }
 
@@ -117,6 +149,7 @@ func (c *consistentPoint) String() string {
 // Examples:
 // MySQL: E11FA47-71CA-11E1-9E33-C80AA9429562:1-3:11:47-49
 // MariaDB: 0-1-1
+// Binlog position: mysql-bin.000003:1234
 func (c *consistentPoint) parseFrom(text string) (*consistentPoint, error) {
 	switch {
 	case c.ma != nil:
@@ -133,6 +166,13 @@ func (c *consistentPoint) parseFrom(text string) (*consistentPoint, error) {
 		}
 		c.my = set.(*mysql.MysqlGTIDSet)
 
+	case c.pos != nil:
+		pos, err := parsePosition(text)
+		if err != nil {
+			return nil, err
+		}
+		c.pos = &pos
+
 	default:
 		return nil, errors.New("no flavor configured")
 	}
@@ -155,9 +195,48 @@ func (c *consistentPoint) withMysqlGTIDSet(ts time.Time, set *mysql.UUIDSet) *co
 	return &consistentPoint{my: cloned, ts: ts}
 }
 
+// withPosition returns a new consistentPoint at the given offset within
+// the current binlog file.
+func (c *consistentPoint) withPosition(ts time.Time, offset uint32) *consistentPoint {
+	return &consistentPoint{pos: &mysql.Position{Name: c.pos.Name, Pos: offset}, ts: ts}
+}
+
+// withRotation returns a new consistentPoint in the next binlog file.
+// The timestamp is unchanged, since a rotation is not a transaction.
+func (c *consistentPoint) withRotation(pos mysql.Position) *consistentPoint {
+	return &consistentPoint{pos: &pos, ts: c.ts}
+}
+
+// formatPosition returns a position in the form accepted by
+// parsePosition.
+func formatPosition(pos mysql.Position) string {
+	if pos.Name == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", pos.Name, pos.Pos)
+}
+
+// parsePosition decodes a binlog position of the form file:offset.
+func parsePosition(text string) (mysql.Position, error) {
+	if text == "" {
+		return mysql.Position{}, nil
+	}
+	idx := strings.LastIndexByte(text, ':')
+	if idx <= 0 {
+		return mysql.Position{}, errors.Errorf("binlog position %q must be of the form file:offset", text)
+	}
+	offset, err := strconv.ParseUint(text[idx+1:], 10, 32)
+	if err != nil {
+		return mysql.Position{}, errors.Wrapf(err, "invalid offset in binlog position %q", text)
+	}
+	return mysql.Position{Name: text[:idx], Pos: uint32(offset)}, nil
+}
+
 type consistentPointPayload struct {
 	Flavor string    `json:"flavor"`
-	GTID   string    `json:"gtid"`
+	GTID   string    `json:"gtid,omitempty"`
+	File   string    `json:"file,omitempty"`
+	Pos    uint32    `json:"pos,omitempty"`
 	TS     time.Time `json:"ts"`
 }
 
@@ -170,6 +249,10 @@ func (c *consistentPoint) MarshalJSON() ([]byte, error) {
 	case c.my != nil:
 		p.Flavor = mysql.MySQLFlavor
 		p.GTID = c.my.String()
+	case c.pos != nil:
+		p.Flavor = positionFlavor
+		p.File = c.pos.Name
+		p.Pos = c.pos.Pos
 	default:
 		return nil, errors.New("consistentPoint not initialized")
 	}
@@ -181,7 +264,20 @@ func (c *consistentPoint) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &p); err != nil {
 		return errors.WithStack(err)
 	}
+	// Adopt the stored flavor if none has been configured.
+	if c.ma == nil && c.my == nil && c.pos == nil {
+		switch p.Flavor {
+		case mysql.MariaDBFlavor, mysql.MySQLFlavor, positionFlavor:
+			*c = *newConsistentPoint(p.Flavor)
+		default:
+			return errors.Errorf("unknown consistent point flavor %q", p.Flavor)
+		}
+	}
 	c.ts = p.TS
+	if c.pos != nil {
+		c.pos = &mysql.Position{Name: p.File, Pos: p.Pos}
+		return nil
+	}
 	_, err := c.parseFrom(p.GTID)
 	return err
 }
@@ -106,6 +106,55 @@ func Test_mariadbStamp_Less(t *testing.T) {
 	}
 }
 
+func Test_positionStamp_Less(t *testing.T) {
+	tests := []struct {
+		name string
+		this string
+		that string
+		want bool
+	}{
+		{"empty0", "", "", false},
+		{"empty1", "", "mysql-bin.000001:4", true},
+		{"empty2", "mysql-bin.000001:4", "", false},
+		{"same", "mysql-bin.000001:4", "mysql-bin.000001:4", false},
+		{"offset0", "mysql-bin.000001:4", "mysql-bin.000001:157", true},
+		{"offset1", "mysql-bin.000001:157", "mysql-bin.000001:4", false},
+		{"file0", "mysql-bin.000001:157", "mysql-bin.000002:4", true},
+		{"file1", "mysql-bin.000002:4", "mysql-bin.000001:157", false},
+		{"file2", "mysql-bin.000009:4", "mysql-bin.000010:4", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			this, err := newConsistentPoint(positionFlavor).parseFrom(tt.this)
+			if !a.NoError(err) {
+				return
+			}
+			that, err := newConsistentPoint(positionFlavor).parseFrom(tt.that)
+			if !a.NoError(err) {
+				return
+			}
+			a.Equalf(tt.want, this.Less(that), "%s failed", tt.name)
+			a.Equal(tt.this, this.String())
+			checkMarshal(a, positionFlavor, this)
+			checkMarshal(a, positionFlavor, that)
+		})
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	a := assert.New(t)
+
+	pos, err := parsePosition("host:3306-bin.000042:1234")
+	a.NoError(err)
+	a.Equal(mysql.Position{Name: "host:3306-bin.000042", Pos: 1234}, pos)
+
+	for _, bad := range []string{"mysql-bin.000001", ":4", "mysql-bin.000001:x", "mysql-bin.000001:-1"} {
+		_, err := parsePosition(bad)
+		a.Errorf(err, bad)
+	}
+}
+
 func checkMarshal(a *assert.Assertions, flavor string, cp *consistentPoint) {
 	data, err := cp.MarshalJSON()
 	if !a.NoError(err) {
@@ -0,0 +1,86 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mylogical
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// kindOfType maps a binlog column type to a Kind. Unsigned BIGINT
+// values may exceed the range of a signed integer. BIT values are
+// replicated as strings of binary digits.
+func kindOfType(typ byte, unsigned bool) autocreate.Kind {
+	switch typ {
+	case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_SHORT, mysql.MYSQL_TYPE_INT24,
+		mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_YEAR:
+		return autocreate.KindInt
+	case mysql.MYSQL_TYPE_LONGLONG:
+		if unsigned {
+			return autocreate.KindDecimal
+		}
+		return autocreate.KindInt
+	case mysql.MYSQL_TYPE_FLOAT, mysql.MYSQL_TYPE_DOUBLE:
+		return autocreate.KindFloat
+	case mysql.MYSQL_TYPE_DECIMAL, mysql.MYSQL_TYPE_NEWDECIMAL:
+		return autocreate.KindDecimal
+	case mysql.MYSQL_TYPE_DATE, mysql.MYSQL_TYPE_NEWDATE:
+		return autocreate.KindDate
+	case mysql.MYSQL_TYPE_TIME, mysql.MYSQL_TYPE_TIME2:
+		return autocreate.KindTime
+	case mysql.MYSQL_TYPE_DATETIME, mysql.MYSQL_TYPE_DATETIME2:
+		return autocreate.KindTimestamp
+	case mysql.MYSQL_TYPE_TIMESTAMP, mysql.MYSQL_TYPE_TIMESTAMP2:
+		return autocreate.KindTimestampTZ
+	case mysql.MYSQL_TYPE_JSON:
+		return autocreate.KindJSON
+	default:
+		return autocreate.KindString
+	}
+}
+
+// kindOfName maps an INFORMATION_SCHEMA.COLUMNS.DATA_TYPE value to a
+// Kind, consistent with kindOfType.
+func kindOfName(name string, unsigned bool) autocreate.Kind {
+	switch strings.ToLower(name) {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "year":
+		return autocreate.KindInt
+	case "bigint":
+		if unsigned {
+			return autocreate.KindDecimal
+		}
+		return autocreate.KindInt
+	case "float", "double", "real":
+		return autocreate.KindFloat
+	case "decimal", "numeric":
+		return autocreate.KindDecimal
+	case "date":
+		return autocreate.KindDate
+	case "time":
+		return autocreate.KindTime
+	case "datetime":
+		return autocreate.KindTimestamp
+	case "timestamp":
+		return autocreate.KindTimestampTZ
+	case "json":
+		return autocreate.KindJSON
+	default:
+		return autocreate.KindString
+	}
+}
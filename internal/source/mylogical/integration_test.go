@@ -62,6 +62,7 @@ type fixtureConfig struct {
 	backfill  bool
 	chaosProb float32
 	immediate bool
+	position  bool
 	script    bool
 }
 
@@ -88,6 +89,15 @@ func TestMYLogical(t *testing.T) {
 	t.Run("immediate-script", func(t *testing.T) {
 		testMYLogical(t, &fixtureConfig{immediate: true, script: true})
 	})
+	t.Run("position", func(t *testing.T) {
+		testMYLogical(t, &fixtureConfig{position: true})
+	})
+	t.Run("position-backfill", func(t *testing.T) {
+		testMYLogical(t, &fixtureConfig{backfill: true, position: true})
+	})
+	t.Run("position-immediate", func(t *testing.T) {
+		testMYLogical(t, &fixtureConfig{immediate: true, position: true})
+	})
 }
 
 func testMYLogical(t *testing.T, fc *fixtureConfig) {
@@ -131,9 +141,16 @@ func testMYLogical(t *testing.T, fc *fixtureConfig) {
 	flavor, _, err := getFlavor(config)
 	r.NoError(err)
 
-	gtidSet, err := loadInitialGTIDSet(ctx, flavor, myPool)
-	config.DefaultConsistentPoint = gtidSet
-	r.NoError(err)
+	if fc.position {
+		pos, err := loadInitialPosition(ctx, myPool)
+		r.NoError(err)
+		config.BinlogPosition = true
+		config.DefaultBinlogPosition = pos
+	} else {
+		gtidSet, err := loadInitialGTIDSet(ctx, flavor, myPool)
+		config.DefaultConsistentPoint = gtidSet
+		r.NoError(err)
+	}
 
 	// Insert data into source table.
 	const rowCount = 1024
@@ -635,3 +652,18 @@ func loadInitialGTIDSet(ctx context.Context, flavor string, myPool *client.Pool)
 	log.Infof("gtidSet: %s", gtidSet)
 	return gtidSet, nil
 }
+
+// loadInitialPosition connects to the source database to return the
+// current binlog file and position.
+func loadInitialPosition(ctx context.Context, myPool *client.Pool) (string, error) {
+	res, err := myExec(ctx, myPool, "SHOW MASTER STATUS")
+	if err != nil {
+		return "", err
+	}
+	if len(res.Values) == 0 {
+		return "", errors.New("unable to retrieve master status")
+	}
+	pos := fmt.Sprintf("%s:%s", fieldString(res.Values[0][0]), fieldString(res.Values[0][1]))
+	log.Infof("binlog position: %s", pos)
+	return pos, nil
+}
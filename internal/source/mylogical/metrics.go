@@ -37,4 +37,12 @@ var (
 		},
 		[]string{"type"},
 	)
+	snapshotRowCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "mylogical_snapshot_rows_total",
+		Help: "the number of rows copied by the initial snapshot",
+	})
+	sparseUpdateCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "mylogical_sparse_updates_total",
+		Help: "the number of updates that omit columns or contain partial JSON values",
+	})
 )
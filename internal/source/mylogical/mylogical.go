@@ -17,7 +17,10 @@
 package mylogical
 
 import (
+	"net/http"
+
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
 )
@@ -25,14 +28,31 @@ import (
 // MYLogical is a MySQL/MariaDB logical replication loop.
 type MYLogical struct {
 	Diagnostics *diag.Diagnostics
+	Factory     *logical.Factory
 	Loop        *logical.Loop
 }
 
 var (
 	_ stdlogical.HasDiagnostics = (*MYLogical)(nil)
+	_ stdlogical.HasHandlers    = (*MYLogical)(nil)
+	_ stdlogical.HasStopPoint   = (*MYLogical)(nil)
 )
 
+// AddHandlers implements [stdlogical.HasHandlers] to allow the
+// replica to be paused or stopped at a specific point, and to provide
+// the admin API.
+func (l *MYLogical) AddHandlers(auth types.Authenticator, mux *http.ServeMux) {
+	mux.Handle("/_/pause", logical.PauseHandler(auth, l.Factory))
+	mux.Handle("/_/stopAt", logical.StopAtHandler(auth, l.Factory))
+	mux.Handle("/_/admin/", logical.AdminHandler(auth, l.Factory))
+}
+
 // GetDiagnostics implements [stdlogical.HasDiagnostics].
 func (l *MYLogical) GetDiagnostics() *diag.Diagnostics {
 	return l.Diagnostics
 }
+
+// StopPoint implements [stdlogical.HasStopPoint].
+func (l *MYLogical) StopPoint() (string, <-chan struct{}) {
+	return l.Loop.StopPoint()
+}
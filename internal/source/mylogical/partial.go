@@ -0,0 +1,585 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mylogical
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/jsondiff"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pkg/errors"
+)
+
+// This file contains support for the PARTIAL_UPDATE_ROWS_EVENT that
+// MySQL writes when binlog_row_value_options=PARTIAL_JSON is set. The
+// replication library doesn't decode this event, so it is rewritten as
+// an UPDATE_ROWS_EVENT, with the partially-updated JSON columns marked
+// as NULL, and fed to a private parser. The JSON diffs are extracted
+// separately.
+//
+// See https://dev.mysql.com/doc/dev/mysql-server/latest/classmysql_1_1binlog_1_1event_1_1Rows__event.html
+
+const (
+	// partialUpdateRowsEvent is not defined by the replication library.
+	partialUpdateRowsEvent replication.EventType = 39
+	// partialJSONUpdates is the bit in an after-image's value_options
+	// which indicates that a bitmap of partial JSON columns follows.
+	partialJSONUpdates = 1
+	// syntheticTableID identifies the table map that we construct to
+	// decode JSON values.
+	syntheticTableID = 0xFFFFFFFF
+)
+
+// partialRowsEvent is an update to a table where some JSON columns are
+// described by a sequence of diffs instead of their complete values.
+type partialRowsEvent struct {
+	*replication.RowsEvent
+	// Diffs has the same length as Rows. Each element maps a column
+	// index to the diffs to apply to it.
+	Diffs []map[int][]jsondiff.Diff
+}
+
+// partialDecoder converts PARTIAL_UPDATE_ROWS_EVENT messages into
+// partialRowsEvents. It must be shown every event received from the
+// source, in order to track the table maps.
+type partialDecoder struct {
+	checksum    bool
+	format      []byte // The raw FORMAT_DESCRIPTION_EVENT.
+	parser      *replication.BinlogParser
+	tableIDSize int
+	tableMaps   map[uint64][]byte // Raw TABLE_MAP_EVENTs.
+	tables      map[uint64]*replication.TableMapEvent
+}
+
+func newPartialDecoder() *partialDecoder {
+	return &partialDecoder{
+		tableIDSize: 6,
+		tableMaps:   make(map[uint64][]byte),
+		tables:      make(map[uint64]*replication.TableMapEvent),
+	}
+}
+
+// observe returns a replacement for the event if it is a partial
+// update. Otherwise, the event is returned unchanged.
+func (d *partialDecoder) observe(ev *replication.BinlogEvent) (*replication.BinlogEvent, error) {
+	switch e := ev.Event.(type) {
+	case *replication.FormatDescriptionEvent:
+		d.checksum = e.ChecksumAlgorithm == replication.BINLOG_CHECKSUM_ALG_CRC32
+		d.format = append([]byte(nil), ev.RawData...)
+		d.parser = nil
+		if int(replication.TABLE_MAP_EVENT) <= len(e.EventTypeHeaderLengths) &&
+			e.EventTypeHeaderLengths[replication.TABLE_MAP_EVENT-1] == 6 {
+			d.tableIDSize = 4
+		} else {
+			d.tableIDSize = 6
+		}
+
+	case *replication.TableMapEvent:
+		d.tableMaps[e.TableID] = append([]byte(nil), ev.RawData...)
+		d.tables[e.TableID] = e
+
+	case *replication.GenericEvent:
+		if ev.Header.EventType != partialUpdateRowsEvent {
+			return ev, nil
+		}
+		rows, err := d.decode(ev.Header, e.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decode partial update")
+		}
+		hdr := *ev.Header
+		hdr.EventType = replication.UPDATE_ROWS_EVENTv2
+		return &replication.BinlogEvent{Header: &hdr, Event: rows, RawData: ev.RawData}, nil
+	}
+	return ev, nil
+}
+
+// decode splits the body of a PARTIAL_UPDATE_ROWS_EVENT into an
+// equivalent UPDATE_ROWS_EVENT and the JSON diffs.
+func (d *partialDecoder) decode(
+	hdr *replication.EventHeader, data []byte,
+) (*partialRowsEvent, error) {
+	if d.format == nil {
+		return nil, errors.New("no format description event received")
+	}
+
+	// table_id, flags, extra_data, column count, and the bitmaps of
+	// columns present in the before and after images.
+	pos := d.tableIDSize + 2
+	if err := checkLength(data, pos, 2); err != nil {
+		return nil, err
+	}
+	tableID := mysql.FixedLengthInt(data[:d.tableIDSize])
+	pos += int(binary.LittleEndian.Uint16(data[pos:]))
+	colCount, n, err := lengthEncodedInt(data, pos)
+	if err != nil {
+		return nil, err
+	}
+	pos += n
+
+	table, ok := d.tables[tableID]
+	if !ok {
+		return nil, errors.Errorf("no table map for table id %d", tableID)
+	}
+	if colCount != uint64(len(table.ColumnType)) {
+		return nil, errors.Errorf("expecting %d columns in table id %d, had %d",
+			len(table.ColumnType), tableID, colCount)
+	}
+	bitmapSize := int(colCount+7) / 8
+	if err := checkLength(data, pos, uint64(2*bitmapSize)); err != nil {
+		return nil, err
+	}
+	beforeBitmap := data[pos : pos+bitmapSize]
+	pos += bitmapSize
+	afterBitmap := data[pos : pos+bitmapSize]
+	pos += bitmapSize
+	jsonCount := 0
+	for _, tp := range table.ColumnType {
+		if tp == mysql.MYSQL_TYPE_JSON {
+			jsonCount++
+		}
+	}
+
+	var body bytes.Buffer
+	body.Write(data[:pos])
+	var diffs []map[int][]jsondiff.Diff
+	for pos < len(data) {
+		// The before image is used as-is.
+		n, err := imageLength(data[pos:], table, beforeBitmap)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(data[pos : pos+n])
+		pos += n
+		diffs = append(diffs, nil)
+
+		// The after image has additional fields before the null bits.
+		opts, n, err := lengthEncodedInt(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		var partialBits []byte
+		if opts&partialJSONUpdates != 0 {
+			size := (jsonCount + 7) / 8
+			if err := checkLength(data, pos, uint64(size)); err != nil {
+				return nil, err
+			}
+			partialBits = data[pos : pos+size]
+			pos += size
+		}
+		image, rowDiffs, n, err := d.rewriteImage(data[pos:], table, afterBitmap, partialBits)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(image)
+		pos += n
+		diffs = append(diffs, rowDiffs)
+	}
+
+	synthetic, err := d.parse(replication.UPDATE_ROWS_EVENTv2, hdr, body.Bytes(), d.tableMaps[tableID])
+	if err != nil {
+		return nil, err
+	}
+	return &partialRowsEvent{RowsEvent: synthetic, Diffs: diffs}, nil
+}
+
+// rewriteImage copies the after image, omitting the values of any
+// partially-updated JSON columns and marking them as NULL instead. It
+// returns the new image, the diffs to apply to each partial column,
+// and the number of bytes consumed.
+func (d *partialDecoder) rewriteImage(
+	data []byte, table *replication.TableMapEvent, bitmap, partialBits []byte,
+) ([]byte, map[int][]jsondiff.Diff, int, error) {
+	present := 0
+	for i := range table.ColumnType {
+		if isBitSet(bitmap, i) {
+			present++
+		}
+	}
+	nullSize := (present + 7) / 8
+	if err := checkLength(data, 0, uint64(nullSize)); err != nil {
+		return nil, nil, 0, err
+	}
+	nulls := append([]byte(nil), data[:nullSize]...)
+	pos := len(nulls)
+
+	var values bytes.Buffer
+	var diffs map[int][]jsondiff.Diff
+	nullIdx, jsonIdx := 0, 0
+	for i, tp := range table.ColumnType {
+		// The partial bits contain an entry for every JSON column,
+		// whether it is present in the image or not.
+		partial := false
+		if tp == mysql.MYSQL_TYPE_JSON {
+			partial = partialBits != nil && isBitSet(partialBits, jsonIdx)
+			jsonIdx++
+		}
+		if !isBitSet(bitmap, i) {
+			continue
+		}
+		thisNull := nullIdx
+		nullIdx++
+		if isBitSet(nulls, thisNull) {
+			continue
+		}
+		n, err := valueLength(data[pos:], tp, table.ColumnMeta[i])
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if err := checkLength(data, pos, uint64(n)); err != nil {
+			return nil, nil, 0, err
+		}
+		if partial {
+			meta := int(table.ColumnMeta[i])
+			colDiffs, err := d.decodeDiffs(data[pos+meta : pos+n])
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			if diffs == nil {
+				diffs = make(map[int][]jsondiff.Diff)
+			}
+			diffs[i] = colDiffs
+			nulls[thisNull/8] |= 1 << (thisNull % 8)
+		} else {
+			values.Write(data[pos : pos+n])
+		}
+		pos += n
+	}
+	return append(nulls, values.Bytes()...), diffs, pos, nil
+}
+
+// decodeDiffs decodes the sequence of diffs that replaces the value of
+// a partially-updated JSON column.
+func (d *partialDecoder) decodeDiffs(data []byte) ([]jsondiff.Diff, error) {
+	var ret []jsondiff.Diff
+	var values [][]byte
+	for pos := 0; pos < len(data); {
+		op := jsondiff.Op(data[pos])
+		pos++
+		if op > jsondiff.Remove {
+			return nil, errors.Errorf("unknown JSON diff operation %d", op)
+		}
+		pathLen, n, err := lengthEncodedInt(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		if err := checkLength(data, pos, pathLen); err != nil {
+			return nil, err
+		}
+		diff := jsondiff.Diff{Op: op, Path: string(data[pos : pos+int(pathLen)])}
+		pos += int(pathLen)
+		if op != jsondiff.Remove {
+			valueLen, n, err := lengthEncodedInt(data, pos)
+			if err != nil {
+				return nil, err
+			}
+			pos += n
+			if err := checkLength(data, pos, valueLen); err != nil {
+				return nil, err
+			}
+			values = append(values, data[pos:pos+int(valueLen)])
+			pos += int(valueLen)
+		}
+		ret = append(ret, diff)
+	}
+
+	// The values are encoded in MySQL's binary JSON format.
+	decoded, err := d.decodeJSON(values)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ret {
+		if ret[i].Op != jsondiff.Remove {
+			ret[i].Value, decoded = []byte(decoded[0]), decoded[1:]
+		}
+	}
+	return ret, nil
+}
+
+// decodeJSON converts binary JSON values into text by constructing a
+// table with one JSON column per value and a WRITE_ROWS_EVENT which
+// contains the values.
+func (d *partialDecoder) decodeJSON(values [][]byte) ([]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	count := uint64(len(values))
+	bitmapSize := (len(values) + 7) / 8
+
+	var tableMap bytes.Buffer
+	d.writeTableID(&tableMap, syntheticTableID)
+	tableMap.Write([]byte{0, 0}) // Flags
+	tableMap.Write([]byte{0, 0}) // Empty schema name and terminator.
+	tableMap.Write([]byte{0, 0}) // Empty table name and terminator.
+	tableMap.Write(mysql.PutLengthEncodedInt(count))
+	tableMap.Write(bytes.Repeat([]byte{mysql.MYSQL_TYPE_JSON}, len(values)))
+	// Each column has a four-byte length prefix.
+	tableMap.Write(mysql.PutLengthEncodedInt(count))
+	tableMap.Write(bytes.Repeat([]byte{4}, len(values)))
+	tableMap.Write(bytes.Repeat([]byte{0xFF}, bitmapSize)) // Nullable
+
+	var rows bytes.Buffer
+	d.writeTableID(&rows, syntheticTableID)
+	rows.Write([]byte{0, 0}) // Flags
+	rows.Write([]byte{2, 0}) // Extra data length, including itself.
+	rows.Write(mysql.PutLengthEncodedInt(count))
+	rows.Write(bytes.Repeat([]byte{0xFF}, bitmapSize)) // All present
+	rows.Write(make([]byte, bitmapSize))               // No nulls
+	for _, value := range values {
+		var length [4]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(len(value)))
+		rows.Write(length[:])
+		rows.Write(value)
+	}
+
+	hdr := &replication.EventHeader{}
+	rawMap := d.frame(replication.TABLE_MAP_EVENT, hdr, tableMap.Bytes())
+	decoded, err := d.parse(replication.WRITE_ROWS_EVENTv2, hdr, rows.Bytes(), rawMap)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded.Rows) != 1 {
+		return nil, errors.Errorf("expecting one row of JSON values, had %d", len(decoded.Rows))
+	}
+	ret := make([]string, len(values))
+	for i, v := range decoded.Rows[0] {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.Errorf("unexpected JSON value %T", v)
+		}
+		ret[i] = s
+	}
+	return ret, nil
+}
+
+// parse decodes a synthetic rows event, after feeding the table map to
+// a private parser.
+func (d *partialDecoder) parse(
+	tp replication.EventType, hdr *replication.EventHeader, body, tableMap []byte,
+) (*replication.RowsEvent, error) {
+	if d.parser == nil {
+		d.parser = replication.NewBinlogParser()
+		if _, err := d.parser.Parse(d.format); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if _, err := d.parser.Parse(tableMap); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ev, err := d.parser.Parse(d.frame(tp, hdr, body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ret, ok := ev.Event.(*replication.RowsEvent)
+	if !ok {
+		return nil, errors.Errorf("unexpected event %T", ev.Event)
+	}
+	return ret, nil
+}
+
+// frame adds an event header and, if required, space for a checksum to
+// the body of an event.
+func (d *partialDecoder) frame(
+	tp replication.EventType, hdr *replication.EventHeader, body []byte,
+) []byte {
+	size := replication.EventHeaderSize + len(body)
+	if d.checksum {
+		size += replication.BinlogChecksumLength
+	}
+	ret := make([]byte, replication.EventHeaderSize, size)
+	binary.LittleEndian.PutUint32(ret[0:], hdr.Timestamp)
+	ret[4] = byte(tp)
+	binary.LittleEndian.PutUint32(ret[5:], hdr.ServerID)
+	binary.LittleEndian.PutUint32(ret[9:], uint32(size))
+	binary.LittleEndian.PutUint32(ret[13:], hdr.LogPos)
+	binary.LittleEndian.PutUint16(ret[17:], hdr.Flags)
+	ret = append(ret, body...)
+	// The checksum isn't verified by the parser.
+	return ret[:size]
+}
+
+func (d *partialDecoder) writeTableID(buf *bytes.Buffer, id uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], id)
+	buf.Write(b[:d.tableIDSize])
+}
+
+// imageLength returns the number of bytes used by a row image.
+func imageLength(data []byte, table *replication.TableMapEvent, bitmap []byte) (int, error) {
+	present := 0
+	for i := range table.ColumnType {
+		if isBitSet(bitmap, i) {
+			present++
+		}
+	}
+	nullSize := (present + 7) / 8
+	if err := checkLength(data, 0, uint64(nullSize)); err != nil {
+		return 0, err
+	}
+	nulls := data[:nullSize]
+	pos := len(nulls)
+	nullIdx := 0
+	for i, tp := range table.ColumnType {
+		if !isBitSet(bitmap, i) {
+			continue
+		}
+		isNull := isBitSet(nulls, nullIdx)
+		nullIdx++
+		if isNull {
+			continue
+		}
+		n, err := valueLength(data[pos:], tp, table.ColumnMeta[i])
+		if err != nil {
+			return 0, err
+		}
+		if err := checkLength(data, pos, uint64(n)); err != nil {
+			return 0, err
+		}
+		pos += n
+	}
+	return pos, nil
+}
+
+// valueLength returns the number of bytes used to encode a column
+// value in a row image. This follows the replication library's
+// decoding logic. An error is returned if data is too short to contain
+// the value's length prefix; the caller must check that the value
+// itself is present.
+func valueLength(data []byte, tp byte, meta uint16) (int, error) {
+	length := 0
+	if tp == mysql.MYSQL_TYPE_STRING {
+		if meta >= 256 {
+			b0 := uint8(meta >> 8)
+			b1 := uint8(meta & 0xFF)
+			if b0&0x30 != 0x30 {
+				length = int(uint16(b1) | (uint16((b0&0x30)^0x30) << 4))
+				tp = b0 | 0x30
+			} else {
+				length = int(meta & 0xFF)
+				tp = b0
+			}
+		} else {
+			length = int(meta)
+		}
+	}
+
+	switch tp {
+	case mysql.MYSQL_TYPE_NULL:
+		return 0, nil
+	case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_YEAR:
+		return 1, nil
+	case mysql.MYSQL_TYPE_SHORT:
+		return 2, nil
+	case mysql.MYSQL_TYPE_INT24, mysql.MYSQL_TYPE_TIME, mysql.MYSQL_TYPE_DATE:
+		return 3, nil
+	case mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_FLOAT, mysql.MYSQL_TYPE_TIMESTAMP:
+		return 4, nil
+	case mysql.MYSQL_TYPE_LONGLONG, mysql.MYSQL_TYPE_DOUBLE, mysql.MYSQL_TYPE_DATETIME:
+		return 8, nil
+	case mysql.MYSQL_TYPE_NEWDECIMAL:
+		return decimalLength(int(meta>>8), int(meta&0xFF)), nil
+	case mysql.MYSQL_TYPE_BIT:
+		nbits := ((meta >> 8) * 8) + (meta & 0xFF)
+		return int(nbits+7) / 8, nil
+	case mysql.MYSQL_TYPE_TIMESTAMP2:
+		return int(4 + (meta+1)/2), nil
+	case mysql.MYSQL_TYPE_DATETIME2:
+		return int(5 + (meta+1)/2), nil
+	case mysql.MYSQL_TYPE_TIME2:
+		return int(3 + (meta+1)/2), nil
+	case mysql.MYSQL_TYPE_ENUM, mysql.MYSQL_TYPE_SET:
+		return int(meta & 0xFF), nil
+	case mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_GEOMETRY, mysql.MYSQL_TYPE_JSON:
+		if meta < 1 || meta > 4 {
+			return 0, errors.Errorf("invalid length size %d", meta)
+		}
+		if err := checkLength(data, 0, uint64(meta)); err != nil {
+			return 0, err
+		}
+		return int(meta) + int(mysql.FixedLengthInt(data[:meta])), nil
+	case mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_VAR_STRING:
+		return stringLength(data, int(meta))
+	case mysql.MYSQL_TYPE_STRING:
+		return stringLength(data, length)
+	default:
+		return 0, errors.Errorf("unsupported type %d in binlog", tp)
+	}
+}
+
+// stringLength returns the length of a string with a one- or two-byte
+// length prefix, depending on the maximum length of the column.
+func stringLength(data []byte, maxLength int) (int, error) {
+	if maxLength < 256 {
+		if err := checkLength(data, 0, 1); err != nil {
+			return 0, err
+		}
+		return 1 + int(data[0]), nil
+	}
+	if err := checkLength(data, 0, 2); err != nil {
+		return 0, err
+	}
+	return 2 + int(binary.LittleEndian.Uint16(data)), nil
+}
+
+// lengthEncodedInt decodes the length-encoded integer at pos, returning
+// its value and the number of bytes used. The replication library's
+// decoder does not check that the input is long enough.
+func lengthEncodedInt(data []byte, pos int) (uint64, int, error) {
+	if err := checkLength(data, pos, 1); err != nil {
+		return 0, 0, err
+	}
+	size := uint64(1)
+	switch data[pos] {
+	case 0xfc:
+		size = 3
+	case 0xfd:
+		size = 4
+	case 0xfe:
+		size = 9
+	}
+	if err := checkLength(data, pos, size); err != nil {
+		return 0, 0, err
+	}
+	value, _, n := mysql.LengthEncodedInt(data[pos:])
+	return value, n, nil
+}
+
+// checkLength returns an error if data does not contain n bytes
+// starting at pos.
+func checkLength(data []byte, pos int, n uint64) error {
+	if pos < 0 || pos > len(data) || n > uint64(len(data)-pos) {
+		return errors.Errorf("truncated event: expecting %d bytes at offset %d of %d",
+			n, pos, len(data))
+	}
+	return nil
+}
+
+// decimalLength returns the size of a packed DECIMAL value. Each group
+// of nine digits is stored in four bytes, with any leftover digits
+// using the minimum number of bytes.
+func decimalLength(precision, scale int) int {
+	compressed := [...]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+	integral := precision - scale
+	return integral/9*4 + compressed[integral%9] + scale/9*4 + compressed[scale%9]
+}
+
+func isBitSet(bitmap []byte, i int) bool {
+	return bitmap[i>>3]&(1<<(uint(i)&7)) > 0
+}
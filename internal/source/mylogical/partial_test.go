@@ -0,0 +1,171 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mylogical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/jsondiff"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Construct a PARTIAL_UPDATE_ROWS_EVENT by hand and verify that it is
+// converted into an update with JSON diffs.
+func TestPartialDecoder(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	d := newPartialDecoder()
+	hdr := &replication.EventHeader{Timestamp: 1, ServerID: 1, LogPos: 100}
+
+	// FORMAT_DESCRIPTION_EVENT for a server that writes checksums.
+	var fde bytes.Buffer
+	fde.Write([]byte{4, 0})
+	fde.Write(append([]byte("8.0.32"), make([]byte, 44)...))
+	fde.Write([]byte{0, 0, 0, 0})
+	fde.WriteByte(replication.EventHeaderSize)
+	fde.Write(bytes.Repeat([]byte{10}, 40))
+	fde.WriteByte(replication.BINLOG_CHECKSUM_ALG_CRC32)
+	fde.Write([]byte{0, 0, 0, 0})
+	ev, err := replication.NewBinlogParser().Parse(
+		d.frame(replication.FORMAT_DESCRIPTION_EVENT, hdr, fde.Bytes()))
+	r.NoError(err)
+	_, err = d.observe(ev)
+	r.NoError(err)
+	a.True(d.checksum)
+
+	// TABLE_MAP_EVENT for (k INT, j JSON, v VARCHAR(10)).
+	var tableMap bytes.Buffer
+	d.writeTableID(&tableMap, 42)
+	tableMap.Write([]byte{0, 0})
+	tableMap.Write([]byte{2, 'd', 'b', 0})
+	tableMap.Write([]byte{3, 't', 'b', 'l', 0})
+	tableMap.WriteByte(3)
+	tableMap.Write([]byte{mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_JSON, mysql.MYSQL_TYPE_VARCHAR})
+	tableMap.Write([]byte{3, 4, 10, 0})
+	tableMap.WriteByte(0x06)
+	parser := replication.NewBinlogParser()
+	_, err = parser.Parse(d.format)
+	r.NoError(err)
+	ev, err = parser.Parse(d.frame(replication.TABLE_MAP_EVENT, hdr, tableMap.Bytes()))
+	r.NoError(err)
+	_, err = d.observe(ev)
+	r.NoError(err)
+
+	// {"a":1} and 2 in MySQL's binary JSON format.
+	object := []byte{0x00, 1, 0, 12, 0, 11, 0, 1, 0, 0x05, 1, 0, 'a'}
+	scalar := []byte{0x05, 2, 0}
+	withLength := func(b []byte) []byte {
+		var ret [4]byte
+		binary.LittleEndian.PutUint32(ret[:], uint32(len(b)))
+		return append(ret[:], b...)
+	}
+
+	var body bytes.Buffer
+	d.writeTableID(&body, 42)
+	body.Write([]byte{1, 0})       // Flags
+	body.Write([]byte{2, 0})       // Extra data
+	body.WriteByte(3)              // Column count
+	body.Write([]byte{0x07, 0x07}) // Before and after bitmaps
+	// Before image.
+	body.WriteByte(0)
+	body.Write([]byte{1, 0, 0, 0})
+	body.Write(withLength(object))
+	body.Write([]byte{1, 'x'})
+	// After image, with a replacement of $.a.
+	body.WriteByte(partialJSONUpdates)
+	body.WriteByte(0x01)
+	body.WriteByte(0)
+	body.Write([]byte{1, 0, 0, 0})
+	diff := append([]byte{byte(jsondiff.Replace), 3, '$', '.', 'a', byte(len(scalar))}, scalar...)
+	body.Write(withLength(diff))
+	body.Write([]byte{1, 'y'})
+
+	ev = &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: partialUpdateRowsEvent},
+		Event:  &replication.GenericEvent{Data: body.Bytes()},
+	}
+	ev, err = d.observe(ev)
+	r.NoError(err)
+	a.Equal(replication.UPDATE_ROWS_EVENTv2, ev.Header.EventType)
+
+	rows, ok := ev.Event.(*partialRowsEvent)
+	r.True(ok)
+	r.Len(rows.Rows, 2)
+	a.Equal([]any{int32(1), `{"a":1}`, "x"}, rows.Rows[0])
+	a.Equal([]any{int32(1), nil, "y"}, rows.Rows[1])
+	r.Len(rows.Diffs, 2)
+	a.Nil(rows.Diffs[0])
+	a.Equal(map[int][]jsondiff.Diff{
+		1: {{Op: jsondiff.Replace, Path: "$.a", Value: []byte("2")}},
+	}, rows.Diffs[1])
+
+	// A truncated event must be rejected rather than cause a panic.
+	raw := body.Bytes()
+	for i := 0; i < len(raw); i++ {
+		a.NotPanicsf(func() {
+			_, err := d.decode(hdr, raw[:i])
+			// A truncation immediately after the bitmaps looks like an
+			// event with no rows.
+			if i != d.tableIDSize+7 {
+				a.Errorf(err, "length %d", i)
+			}
+		}, "length %d", i)
+	}
+	for i := 0; i < len(diff); i++ {
+		a.NotPanicsf(func() {
+			_, err := d.decodeDiffs(diff[:i])
+			if i > 0 {
+				a.Errorf(err, "length %d", i)
+			}
+		}, "length %d", i)
+	}
+}
+
+func TestValueLength(t *testing.T) {
+	a := assert.New(t)
+
+	tcs := []struct {
+		tp       byte
+		meta     uint16
+		data     []byte
+		expected int
+	}{
+		{mysql.MYSQL_TYPE_LONGLONG, 0, nil, 8},
+		{mysql.MYSQL_TYPE_NEWDECIMAL, 10<<8 | 2, nil, 5},
+		{mysql.MYSQL_TYPE_NEWDECIMAL, 20<<8 | 10, nil, 10},
+		{mysql.MYSQL_TYPE_DATETIME2, 6, nil, 8},
+		{mysql.MYSQL_TYPE_BIT, 1<<8 | 1, nil, 2},
+		{mysql.MYSQL_TYPE_VARCHAR, 300, []byte{3, 0}, 5},
+		{mysql.MYSQL_TYPE_BLOB, 2, []byte{3, 0}, 5},
+		// CHAR(10)
+		{mysql.MYSQL_TYPE_STRING, uint16(mysql.MYSQL_TYPE_STRING)<<8 | 10, []byte{4}, 5},
+		// ENUM stored in a STRING column.
+		{mysql.MYSQL_TYPE_STRING, uint16(mysql.MYSQL_TYPE_ENUM)<<8 | 2, nil, 2},
+	}
+	for _, tc := range tcs {
+		n, err := valueLength(tc.data, tc.tp, tc.meta)
+		if a.NoError(err) {
+			a.Equalf(tc.expected, n, "type %d meta %d", tc.tp, tc.meta)
+		}
+	}
+}
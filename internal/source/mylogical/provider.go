@@ -19,6 +19,8 @@ package mylogical
 import (
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/go-mysql-org/go-mysql/replication"
@@ -34,7 +36,14 @@ var Set = wire.NewSet(
 // ProvideDialect is called by Wire to construct this package's
 // logical.Dialect implementation. There's a fake dependency on
 // the script loader so that flags can be evaluated first.
-func ProvideDialect(config *Config, _ *script.Loader) (logical.Dialect, error) {
+func ProvideDialect(
+	config *Config,
+	creator *autocreate.Creator,
+	evolver *evolve.Evolver,
+	memo types.Memo,
+	stagingPool *types.StagingPool,
+	_ *script.Loader,
+) (logical.Dialect, error) {
 	if err := config.Preflight(); err != nil {
 		return nil, err
 	}
@@ -53,12 +62,26 @@ func ProvideDialect(config *Config, _ *script.Loader) (logical.Dialect, error) {
 		Password:  config.password,
 		TLSConfig: config.tlsConfig,
 	}
-	return &conn{
+	ret := &conn{
 		config:       config,
 		columns:      &ident.TableMap[[]types.ColData]{},
 		flavor:       flavor,
 		relations:    make(map[uint64]ident.Table),
 		sourceConfig: cfg,
+	}
+	if config.CreateTables {
+		ret.creator = creator
+	}
+	ret.evolver = evolver
+	if !config.InitialSnapshot {
+		return ret, nil
+	}
+	return &snapshotConn{
+		conn:        ret,
+		chunkSize:   config.SnapshotChunkSize,
+		parallelism: config.SnapshotParallelism,
+		progress: logical.NewSnapshotProgress(
+			memo, stagingPool, config.LoopName+"-snapshot"),
 	}, nil
 }
 
@@ -68,5 +91,8 @@ func ProvideLoop(
 	cfg *Config, dialect logical.Dialect, loops *logical.Factory,
 ) (*logical.Loop, error) {
 	cfg.Dialect = dialect
+	// The source only retains data until it is acknowledged, so any
+	// applyDelay must be implemented by holding data in staging.
+	cfg.HoldInStaging = true
 	return loops.Start(&cfg.LoopConfig)
 }
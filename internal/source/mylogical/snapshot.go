@@ -0,0 +1,583 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mylogical
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// A snapshotConn extends conn to copy the contents of the source
+// database's tables before streaming from the binlog.
+//
+// The source is briefly locked with FLUSH TABLES WITH READ LOCK while
+// each copying connection starts a transaction with a consistent
+// snapshot and the executed GTID set, or binlog position, is captured.
+// This guarantees that the copied data corresponds exactly to the
+// point from which streaming will begin. If the copy is interrupted,
+// it will resume using new snapshots from the last chunk that was
+// committed to the target, but streaming will begin from the original
+// point. This is
+// safe, since all mutations are idempotent upserts or deletes. Tables
+// without a primary key cannot be resumed, since their rows are given
+// random keys; the copy will stop with an error if such a table was
+// only partially copied.
+type snapshotConn struct {
+	*conn
+
+	chunkSize   int                       // The maximum number of rows per query.
+	parallelism int                       // Number of tables to copy concurrently.
+	progress    *logical.SnapshotProgress // Persists the progress of the copy.
+}
+
+var (
+	_ logical.Backfiller = (*snapshotConn)(nil)
+	_ logical.Dialect    = (*snapshotConn)(nil)
+)
+
+// snapshotComplete is sent from BackfillInto to Process once all
+// tables have been copied. The chunks of each table are sent as
+// [logical.SnapshotChunk] messages.
+type snapshotComplete struct {
+	cp *consistentPoint
+}
+
+// A snapshotColumn describes a column in a source table.
+type snapshotColumn struct {
+	bit    bool            // Values are converted to match the binlog encoding.
+	keyIdx int             // The 1-based position in the primary key, or zero.
+	kind   autocreate.Kind // Used when creating target tables.
+	name   string
+}
+
+// BackfillInto implements logical.Backfiller. If the loop has not yet
+// reached a consistent point, the source tables will be copied.
+// Otherwise, this will catch up by streaming from the binlog.
+func (c *snapshotConn) BackfillInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	if cp, _ := state.GetConsistentPoint(); snapshotTaken(cp) {
+		return c.ReadInto(ctx, ch, state)
+	}
+
+	if err := c.copyTables(ctx, ch, state); err != nil {
+		return err
+	}
+
+	// Wait for Process to record the consistent point.
+	for {
+		cp, updated := state.GetConsistentPoint()
+		if snapshotTaken(cp) {
+			break
+		}
+		select {
+		case <-updated:
+		case <-state.Stopping():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return c.ReadInto(ctx, ch, state)
+}
+
+// snapshotTaken returns true if the consistent point has been set by
+// a snapshot or by streaming. The GTID set will be empty if the source
+// has not executed any transactions, so the timestamp is also checked.
+func snapshotTaken(cp stamp.Stamp) bool {
+	point, ok := cp.(*consistentPoint)
+	return ok && (!point.IsZero() || !point.AsTime().IsZero())
+}
+
+// copyTables sends the contents of the source tables to Process.
+func (c *snapshotConn) copyTables(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	if err := c.progress.Load(ctx); err != nil {
+		return err
+	}
+
+	targetDB := state.GetTargetDB()
+	db, _ := targetDB.Split()
+	tables, err := c.sourceTables(db)
+	if err != nil {
+		return err
+	}
+
+	pending := c.progress.Pending(tables)
+	work := make(chan string, len(pending))
+	for _, tbl := range pending {
+		work <- tbl
+	}
+	close(work)
+
+	// Always open at least one snapshot, to capture the GTID set.
+	count := c.parallelism
+	if len(work) < count {
+		count = len(work)
+	}
+	if count == 0 {
+		count = 1
+	}
+	conns, cp, err := c.openSnapshots(ctx, count)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	for _, conn := range conns {
+		conn := conn // Capture.
+		eg.Go(func() error {
+			for tbl := range work {
+				target := ident.NewTable(targetDB, ident.New(tbl))
+				progress := c.progress.Table(tbl)
+				if err := c.copyTable(egCtx, ch, state, conn, db, target, progress); err != nil {
+					return errors.Wrap(err, tbl)
+				}
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	// The copy may have been interrupted.
+	select {
+	case <-state.Stopping():
+		return nil
+	default:
+	}
+
+	// The transaction time is used by the loop to determine when the
+	// backfill has caught up.
+	cp.ts = time.Now()
+	select {
+	case ch <- &snapshotComplete{cp: cp}:
+		return nil
+	case <-state.Stopping():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// openSnapshots returns the requested number of connections, each of
+// which has an open transaction with an identical snapshot of the
+// source. The returned consistentPoint is the GTID set or binlog
+// position from which streaming must begin.
+func (c *snapshotConn) openSnapshots(
+	ctx context.Context, count int,
+) ([]*client.Conn, *consistentPoint, error) {
+	lock, err := getConnection(c.config)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	// Closing the connection will also release the lock.
+	defer lock.Close()
+
+	if _, err := lock.Execute("FLUSH TABLES WITH READ LOCK"); err != nil {
+		return nil, nil, errors.Wrap(err, "could not lock source tables")
+	}
+
+	conns := make([]*client.Conn, 0, count)
+	fail := func(err error) ([]*client.Conn, *consistentPoint, error) {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		return nil, nil, errors.WithStack(err)
+	}
+	for i := 0; i < count; i++ {
+		conn, err := getConnection(c.config)
+		if err != nil {
+			return fail(err)
+		}
+		conns = append(conns, conn)
+		for _, stmt := range []string{
+			"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+			"START TRANSACTION WITH CONSISTENT SNAPSHOT",
+		} {
+			if _, err := conn.Execute(stmt); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	var q string
+	switch {
+	case c.config.BinlogPosition:
+		q = "SHOW MASTER STATUS"
+	case c.flavor == mysql.MariaDBFlavor:
+		q = "SELECT @@GLOBAL.gtid_binlog_pos"
+	default:
+		q = "SELECT @@GLOBAL.gtid_executed"
+	}
+	res, err := lock.Execute(q)
+	if err != nil {
+		return fail(err)
+	}
+	if len(res.Values) == 0 {
+		return fail(errors.Errorf("no results from %s", q))
+	}
+	var captured string
+	if c.config.BinlogPosition {
+		captured = fmt.Sprintf("%s:%s",
+			fieldString(res.Values[0][0]), fieldString(res.Values[0][1]))
+	} else {
+		// MySQL will add line breaks to long sets.
+		captured = strings.ReplaceAll(string(res.Values[0][0].AsString()), "\n", "")
+	}
+
+	if _, err := lock.Execute("UNLOCK TABLES"); err != nil {
+		return fail(err)
+	}
+
+	// Streaming must begin from the point of the first snapshot.
+	point, ok := c.progress.Point()
+	if !ok {
+		point = captured
+		if err := c.progress.SetPoint(ctx, point); err != nil {
+			return fail(err)
+		}
+		log.WithField("cp", captured).Info("created snapshot for initial copy")
+	} else {
+		log.WithField("cp", point).Info("resuming initial snapshot")
+	}
+	cp, err := c.zeroPoint().parseFrom(point)
+	if err != nil {
+		return fail(err)
+	}
+	return conns, cp, nil
+}
+
+// copyTable reads a single table in chunks. The progress parameter
+// will be nil if no chunks have previously been committed.
+func (c *snapshotConn) copyTable(
+	ctx context.Context,
+	ch chan<- logical.Message,
+	state logical.State,
+	conn *client.Conn,
+	db ident.Ident,
+	target ident.Table,
+	progress *logical.TableProgress,
+) error {
+	cols, err := snapshotColumns(conn, db, target.Table())
+	if err != nil {
+		return err
+	}
+	q := newChunkQuery(db, target.Table(), cols, c.chunkSize)
+	if err := c.progress.CheckResume(target, q.hasKey, progress); err != nil {
+		return err
+	}
+	var last []string
+	if progress != nil {
+		last = progress.Last
+	}
+	if c.creator != nil {
+		created := make([]autocreate.Column, len(cols))
+		for idx, col := range cols {
+			created[idx] = autocreate.Column{
+				Kind:    col.kind,
+				Name:    ident.New(col.name),
+				Primary: col.keyIdx > 0,
+			}
+		}
+		if err := c.creator.Ensure(ctx, target, created); err != nil {
+			return err
+		}
+	}
+	log.WithFields(log.Fields{
+		"resume": last != nil,
+		"table":  target,
+	}).Info("copying table")
+
+	// send returns false if the copy should stop.
+	send := func(chunk *logical.SnapshotChunk) (bool, error) {
+		return c.progress.Send(ctx, ch, state, chunk)
+	}
+
+	// Tables without a key are read in a single pass, since there is
+	// no way to resume from a particular row.
+	if !q.hasKey {
+		chunk := &logical.SnapshotChunk{Source: target.Table().Raw(), Target: target}
+		var result mysql.Result
+		if err := conn.ExecuteSelectStreaming(q.first, &result,
+			func(row []mysql.FieldValue) error {
+				mut, _, err := decodeSnapshotRow(cols, row, target)
+				if err != nil {
+					return err
+				}
+				chunk.Muts = append(chunk.Muts, mut)
+				if len(chunk.Muts) < c.chunkSize {
+					return nil
+				}
+				if ok, err := send(chunk); err != nil {
+					return err
+				} else if !ok {
+					return errStopping
+				}
+				chunk = &logical.SnapshotChunk{Source: target.Table().Raw(), Target: target}
+				return nil
+			}, nil,
+		); err != nil {
+			if errors.Is(err, errStopping) {
+				return nil
+			}
+			return errors.WithStack(err)
+		}
+		chunk.Done = true
+		_, err := send(chunk)
+		return err
+	}
+
+	for {
+		var args []any
+		sql := q.first
+		if last != nil {
+			sql = q.next
+			for _, v := range last {
+				args = append(args, v)
+			}
+		}
+		res, err := conn.Execute(sql, args...)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		chunk := &logical.SnapshotChunk{
+			Done:   len(res.Values) < c.chunkSize,
+			Source: target.Table().Raw(),
+			Target: target,
+		}
+		for _, row := range res.Values {
+			mut, key, err := decodeSnapshotRow(cols, row, target)
+			if err != nil {
+				return err
+			}
+			chunk.Muts = append(chunk.Muts, mut)
+			chunk.Last = key
+		}
+		if chunk.Last != nil {
+			last = chunk.Last
+		}
+
+		if ok, err := send(chunk); err != nil || !ok {
+			return err
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+}
+
+// errStopping is used to break out of a streaming query.
+var errStopping = errors.New("stopping")
+
+// decodeSnapshotRow converts the row into a mutation, using the same
+// encoding as onDataTuple. It also returns the row's primary key, in
+// key order, for resuming the copy.
+func decodeSnapshotRow(
+	cols []snapshotColumn, row []mysql.FieldValue, target ident.Table,
+) (types.Mutation, []string, error) {
+	enc := make(map[string]any, len(cols))
+	var key []any
+	var last []string
+	for idx, col := range cols {
+		fv := row[idx]
+		var value any
+		switch {
+		case fv.Type == mysql.FieldValueTypeNull:
+			value = nil
+		case col.bit:
+			// The binlog contains bits as an integer.
+			var bits uint64
+			for _, b := range fv.AsString() {
+				bits = bits<<8 | uint64(b)
+			}
+			value = strconv.FormatUint(bits, 2)
+		case fv.Type == mysql.FieldValueTypeString:
+			value = string(fv.AsString())
+		default:
+			value = fv.Value()
+		}
+		enc[col.name] = value
+
+		if col.keyIdx > 0 {
+			key = append(key, value)
+			if last == nil {
+				last = make([]string, countKeys(cols))
+			}
+			last[col.keyIdx-1] = fieldString(fv)
+		}
+	}
+
+	var mut types.Mutation
+	var err error
+	if len(key) == 0 {
+		// Rows without a key must not be coalesced with one another.
+		mut.Key, err = json.Marshal([]string{uuid.New().String()})
+	} else {
+		mut.Key, err = json.Marshal(key)
+	}
+	if err != nil {
+		return mut, nil, errors.WithStack(err)
+	}
+	mut.Data, err = json.Marshal(enc)
+	if err != nil {
+		return mut, nil, errors.WithStack(err)
+	}
+	script.AddMeta("mylogical", target, &mut)
+	return mut, last, nil
+}
+
+// fieldString returns the text representation of a value.
+func fieldString(fv mysql.FieldValue) string {
+	if fv.Type == mysql.FieldValueTypeString {
+		return string(fv.AsString())
+	}
+	return fmt.Sprint(fv.Value())
+}
+
+// sourceTables returns the names of the tables in the source database.
+func (c *snapshotConn) sourceTables(db ident.Ident) ([]string, error) {
+	conn, err := getConnection(c.config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	res, err := conn.Execute(`
+		SELECT TABLE_NAME
+		FROM INFORMATION_SCHEMA.TABLES
+		WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY TABLE_NAME`, db.Raw())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ret := make([]string, 0, len(res.Values))
+	for _, row := range res.Values {
+		ret = append(ret, string(row[0].AsString()))
+	}
+	return ret, nil
+}
+
+// snapshotColumns returns the columns of the table, in the order used
+// by the binlog.
+func snapshotColumns(
+	conn *client.Conn, db, tbl ident.Ident,
+) ([]snapshotColumn, error) {
+	res, err := conn.Execute(`
+		SELECT c.COLUMN_NAME,
+		       CAST(COALESCE(s.SEQ_IN_INDEX, 0) AS SIGNED),
+		       c.DATA_TYPE = 'bit',
+		       c.DATA_TYPE,
+		       c.COLUMN_TYPE LIKE '%unsigned%'
+		FROM INFORMATION_SCHEMA.COLUMNS c
+		LEFT JOIN INFORMATION_SCHEMA.STATISTICS s
+		  ON s.TABLE_SCHEMA = c.TABLE_SCHEMA
+		 AND s.TABLE_NAME = c.TABLE_NAME
+		 AND s.COLUMN_NAME = c.COLUMN_NAME
+		 AND s.INDEX_NAME = 'PRIMARY'
+		WHERE c.TABLE_SCHEMA = ? AND c.TABLE_NAME = ?
+		ORDER BY c.ORDINAL_POSITION`, db.Raw(), tbl.Raw())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(res.Values) == 0 {
+		return nil, errors.Errorf("no columns found for %s.%s", db, tbl)
+	}
+	ret := make([]snapshotColumn, len(res.Values))
+	for idx, row := range res.Values {
+		ret[idx] = snapshotColumn{
+			bit:    row[2].AsInt64() == 1,
+			keyIdx: int(row[1].AsInt64()),
+			kind:   kindOfName(string(row[3].AsString()), row[4].AsInt64() == 1),
+			name:   string(row[0].AsString()),
+		}
+	}
+	return ret, nil
+}
+
+// countKeys returns the number of primary key columns.
+func countKeys(cols []snapshotColumn) int {
+	var ret int
+	for _, col := range cols {
+		if col.keyIdx > 0 {
+			ret++
+		}
+	}
+	return ret
+}
+
+// chunkQuery contains the SQL used to read a table.
+type chunkQuery struct {
+	first  string // Reads the first chunk, or the entire table if no key.
+	hasKey bool   // If false, the table must be read in a single pass.
+	next   string // Reads the chunk after the key given as arguments.
+}
+
+// newChunkQuery generates SQL to read the table in key order.
+func newChunkQuery(db, tbl ident.Ident, cols []snapshotColumn, limit int) *chunkQuery {
+	selects := make([]string, len(cols))
+	keys := make([]string, countKeys(cols))
+	for idx, col := range cols {
+		selects[idx] = quoteName(col.name)
+		if col.keyIdx > 0 {
+			keys[col.keyIdx-1] = quoteName(col.name)
+		}
+	}
+	from := fmt.Sprintf("SELECT %s FROM %s.%s",
+		strings.Join(selects, ", "), quoteName(db.Raw()), quoteName(tbl.Raw()))
+
+	if len(keys) == 0 {
+		return &chunkQuery{first: from}
+	}
+	params := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	order := fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(keys, ", "), limit)
+	return &chunkQuery{
+		first:  from + order,
+		hasKey: true,
+		next: fmt.Sprintf("%s WHERE (%s) > (%s)%s", from,
+			strings.Join(keys, ", "), params, order),
+	}
+}
+
+// quoteName returns a quoted MySQL identifier.
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
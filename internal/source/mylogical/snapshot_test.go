@@ -0,0 +1,145 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mylogical
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/base"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that the initial snapshot copies existing rows, in several
+// chunks, and then continues with streaming replication.
+func TestInitialSnapshot(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	fixture, err := all.NewFixture(t)
+	r.NoError(err)
+	ctx := fixture.Context
+	dbName := fixture.TargetSchema.Schema()
+	crdbPool := fixture.TargetPool
+
+	keyed := ident.NewTable(dbName, ident.New("keyed"))
+	keyless := ident.NewTable(dbName, ident.New("keyless"))
+
+	config, err := getConfig(fixture, &fixtureConfig{backfill: true}, keyed)
+	r.NoError(err)
+	config.InitialSnapshot = true
+	config.SnapshotChunkSize = 7
+	config.SnapshotParallelism = 2
+	r.NoError(config.Preflight())
+
+	myPool, cancel, err := setupMYPool(config)
+	r.NoError(err)
+	defer cancel()
+
+	for _, stmt := range []string{
+		fmt.Sprintf("CREATE TABLE %s (a INT, b VARCHAR(20), v VARCHAR(20), PRIMARY KEY (b, a))",
+			keyed.Table().Raw()),
+		fmt.Sprintf("CREATE TABLE %s (v VARCHAR(20))", keyless.Table().Raw()),
+	} {
+		_, err := myExec(ctx, myPool, stmt)
+		r.NoError(err)
+	}
+	for _, stmt := range []string{
+		fmt.Sprintf("CREATE TABLE %s (a INT, b STRING, v STRING, PRIMARY KEY (b, a))", keyed),
+		fmt.Sprintf("CREATE TABLE %s (v STRING)", keyless),
+	} {
+		_, err := crdbPool.ExecContext(ctx, stmt)
+		r.NoError(err)
+	}
+
+	const rowCount = 100
+	_, err = myDo(ctx, myPool,
+		func(ctx context.Context, conn *client.Conn) (*mysql.Result, error) {
+			for i := 0; i < rowCount; i++ {
+				if _, err := conn.Execute(
+					fmt.Sprintf("INSERT INTO %s VALUES (?, ?, 'v')", keyed.Table().Raw()),
+					i%10, fmt.Sprintf("b%d", i/10),
+				); err != nil {
+					return nil, err
+				}
+				if _, err := conn.Execute(
+					fmt.Sprintf("INSERT INTO %s VALUES (?)", keyless.Table().Raw()),
+					fmt.Sprintf("v%d", i),
+				); err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+		})
+	r.NoError(err)
+
+	repl, err := Start(ctx, config)
+	r.NoError(err)
+
+	waitFor := func(tbl ident.Table, expected int) {
+		for {
+			count, err := base.GetRowCount(ctx, crdbPool, tbl)
+			r.NoError(err)
+			if count == expected {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	waitFor(keyed, rowCount)
+	waitFor(keyless, rowCount)
+
+	// The progress for each table should have been recorded.
+	progress := repl.Loop.Dialect().(*snapshotConn).progress
+	for _, tbl := range []ident.Table{keyed, keyless} {
+		if tblProgress := progress.Table(tbl.Table().Raw()); a.NotNil(tblProgress) {
+			a.True(tblProgress.Done)
+		}
+	}
+	_, ok := progress.Point()
+	a.True(ok)
+
+	// Changes made after the snapshot are streamed.
+	_, err = myExec(ctx, myPool, fmt.Sprintf("DELETE FROM %s WHERE a < 5", keyed.Table().Raw()))
+	r.NoError(err)
+	waitFor(keyed, rowCount/2)
+}
+
+func TestChunkQuery(t *testing.T) {
+	a := assert.New(t)
+
+	q := newChunkQuery(ident.New("db"), ident.New("my_table"), []snapshotColumn{
+		{name: "a", keyIdx: 2},
+		{name: "Value"},
+		{name: "b`", keyIdx: 1},
+	}, 100)
+	a.True(q.hasKey)
+	a.Equal("SELECT `a`, `Value`, `b```"+" FROM `db`.`my_table` ORDER BY `b```, `a` LIMIT 100", q.first)
+	a.Equal("SELECT `a`, `Value`, `b```"+" FROM `db`.`my_table` "+
+		"WHERE (`b```, `a`) > (?, ?) ORDER BY `b```, `a` LIMIT 100", q.next)
+
+	q = newChunkQuery(ident.New("db"), ident.New("my_table"), []snapshotColumn{{name: "v"}}, 100)
+	a.False(q.hasKey)
+	a.Equal("SELECT `v` FROM `db`.`my_table`", q.first)
+}
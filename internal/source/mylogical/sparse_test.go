@@ -0,0 +1,116 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mylogical
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that updates written with binlog_row_image=MINIMAL don't
+// clobber unchanged columns and that partial JSON updates are applied.
+func TestSparseRowImages(t *testing.T) {
+	r := require.New(t)
+
+	fixture, err := all.NewFixture(t)
+	r.NoError(err)
+	ctx := fixture.Context
+	crdbPool := fixture.TargetPool
+	tgt := ident.NewTable(fixture.TargetSchema.Schema(), ident.New("sparse"))
+
+	config, err := getConfig(fixture, &fixtureConfig{}, tgt)
+	r.NoError(err)
+
+	myPool, cancel, err := setupMYPool(config)
+	r.NoError(err)
+	defer cancel()
+
+	_, err = myExec(ctx, myPool, fmt.Sprintf(
+		`CREATE TABLE %s (pk INT PRIMARY KEY, v VARCHAR(20), n INT, j JSON)`, tgt.Table().Raw()))
+	r.NoError(err)
+	_, err = crdbPool.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE %s (pk INT PRIMARY KEY, v STRING, n INT, j JSONB)`, tgt))
+	r.NoError(err)
+
+	flavor, _, err := getFlavor(config)
+	r.NoError(err)
+	gtidSet, err := loadInitialGTIDSet(ctx, flavor, myPool)
+	r.NoError(err)
+	config.DefaultConsistentPoint = gtidSet
+
+	_, err = Start(ctx, config)
+	r.NoError(err)
+
+	waitFor := func(where string) {
+		for {
+			var count int
+			r.NoError(crdbPool.QueryRowContext(ctx,
+				fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", tgt, where)).Scan(&count))
+			if count == 1 {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	_, err = myExec(ctx, myPool, fmt.Sprintf(
+		`INSERT INTO %s VALUES (1, 'a', 10, '{"a": 1, "b": "x"}')`, tgt.Table().Raw()))
+	r.NoError(err)
+	waitFor("pk = 1 AND v = 'a' AND n = 10")
+
+	stmts := []string{
+		"SET SESSION binlog_row_image = 'MINIMAL'",
+		fmt.Sprintf("UPDATE %s SET v = 'b' WHERE pk = 1", tgt.Table().Raw()),
+	}
+	expected := `pk = 1 AND v = 'b' AND n = 10 AND j->>'a' = '1'`
+	if flavor == mysql.MySQLFlavor {
+		stmts = append(stmts,
+			"SET SESSION binlog_row_value_options = 'PARTIAL_JSON'",
+			fmt.Sprintf(`UPDATE %s SET j = JSON_SET(j, '$.a', 2) WHERE pk = 1`, tgt.Table().Raw()),
+		)
+		expected = `pk = 1 AND v = 'b' AND n = 10 AND j->>'a' = '2' AND j->>'b' = 'x'`
+	}
+	_, err = myDo(ctx, myPool,
+		func(ctx context.Context, conn *client.Conn) (*mysql.Result, error) {
+			for _, stmt := range stmts {
+				if _, err := conn.Execute(stmt); err != nil {
+					return nil, err
+				}
+			}
+			// Restore the defaults, since the connection is pooled.
+			if _, err := conn.Execute("SET SESSION binlog_row_image = DEFAULT"); err != nil {
+				return nil, err
+			}
+			if flavor == mysql.MySQLFlavor {
+				return conn.Execute("SET SESSION binlog_row_value_options = DEFAULT")
+			}
+			return nil, nil
+		})
+	r.NoError(err)
+	waitFor(expected)
+
+	ctx.Stop(time.Second)
+	r.NoError(ctx.Wait())
+}
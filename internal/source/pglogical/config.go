@@ -22,6 +22,12 @@ import (
 	"github.com/spf13/pflag"
 )
 
+const (
+	defaultSnapshotChunkSize   = 10_000
+	defaultSnapshotParallelism = 4
+	defaultStreamSpillSize     = 64 << 20
+)
+
 // Config contains the configuration necessary for creating a
 // replication connection. All field, other than TestControls, are
 // mandatory unless explicitly indicated.
@@ -29,12 +35,40 @@ type Config struct {
 	logical.BaseConfig
 	logical.LoopConfig
 
+	// If true, the publication will be created if it does not exist.
+	CreatePublication bool
+	// If true, the replication slot will be created if it does not
+	// exist. No data will be copied; see also InitialSnapshot.
+	CreateSlot bool
+	// If true, the replication slot will be created, and the published
+	// tables will be copied from the slot's exported snapshot before
+	// streaming begins.
+	InitialSnapshot bool
+	// If true, messages written by pg_logical_emit_message() will be
+	// delivered to the userscript.
+	LogicalMessages bool
 	// The name of the publication to attach to.
 	Publication string
+	// The tables to include if the publication is created. All tables
+	// will be included if this is empty.
+	PublicationTables []string
 	// The replication slot to attach to.
 	Slot string
+	// The maximum number of rows to read in a single snapshot query.
+	SnapshotChunkSize int
+	// The number of tables to copy concurrently.
+	SnapshotParallelism int
 	// Connection string for the source db.
 	SourceConn string
+	// The number of bytes of a streamed transaction to hold in memory
+	// before spooling it to disk.
+	StreamSpillSize int
+	// The directory in which to spool streamed transactions. The
+	// system's temporary directory is used if empty.
+	StreamSpoolDir string
+	// If true, use protocol version 2 so that large transactions are
+	// sent by the source before they have committed.
+	StreamTransactions bool
 	// Enable support for toasted columns
 	ToastedColumns bool
 }
@@ -45,10 +79,33 @@ func (c *Config) Bind(f *pflag.FlagSet) {
 
 	c.LoopConfig.LoopName = "pglogical"
 	c.LoopConfig.Bind(f)
+	f.BoolVar(&c.CreatePublication, "createPublication", false,
+		"create the publication if it does not exist")
+	f.BoolVar(&c.CreateSlot, "createSlot", false,
+		"create the replication slot if it does not exist, without copying any data")
+	f.BoolVar(&c.InitialSnapshot, "initialSnapshot", false,
+		"create the replication slot and copy the published tables before streaming; "+
+			"requires a non-zero backfillWindow")
+	f.BoolVar(&c.LogicalMessages, "logicalMessages", false,
+		"deliver messages written by pg_logical_emit_message() to the userscript's onMessage "+
+			"function; requires PostgreSQL 14 or later")
+	f.IntVar(&c.SnapshotChunkSize, "snapshotChunkSize", defaultSnapshotChunkSize,
+		"the number of rows to read from the source in each initial snapshot query")
+	f.IntVar(&c.SnapshotParallelism, "snapshotParallelism", defaultSnapshotParallelism,
+		"the number of tables to copy concurrently during the initial snapshot")
 	f.StringVar(&c.Slot, "slotName", "cdc_sink", "the replication slot in the source database")
 	f.StringVar(&c.SourceConn, "sourceConn", "", "the source database's connection string")
+	f.IntVar(&c.StreamSpillSize, "streamSpillSize", defaultStreamSpillSize,
+		"the number of bytes of a streamed transaction to buffer in memory before spooling to disk")
+	f.StringVar(&c.StreamSpoolDir, "streamSpoolDir", "",
+		"the directory in which to spool streamed transactions; defaults to the system temp directory")
+	f.BoolVar(&c.StreamTransactions, "streamTransactions", false,
+		"receive large transactions before they commit; requires PostgreSQL 14 or later")
 	f.StringVar(&c.Publication, "publicationName", "",
 		"the publication within the source database to replicate")
+	f.StringSliceVar(&c.PublicationTables, "publicationTables", nil,
+		"a comma-separated list of schema-qualified tables to include if the publication is "+
+			"created; all tables are included if empty")
 	f.BoolVar(&c.ToastedColumns, "enableToastedColumns", false,
 		"Enable support for toasted columns")
 }
@@ -72,5 +129,27 @@ func (c *Config) Preflight() error {
 	if c.SourceConn == "" {
 		return errors.New("no source connection was configured")
 	}
+	if len(c.PublicationTables) > 0 && !c.CreatePublication {
+		return errors.New("publicationTables requires createPublication")
+	}
+	if c.CreateSlot && c.InitialSnapshot {
+		return errors.New("createSlot and initialSnapshot are mutually exclusive; " +
+			"the initial snapshot creates the replication slot")
+	}
+	if c.StreamSpillSize <= 0 {
+		c.StreamSpillSize = defaultStreamSpillSize
+	}
+	if c.InitialSnapshot {
+		// The loop only calls BackfillInto when backfilling is enabled.
+		if c.BackfillWindow <= 0 {
+			return errors.New("initialSnapshot requires a positive backfillWindow")
+		}
+		if c.SnapshotChunkSize <= 0 {
+			c.SnapshotChunkSize = defaultSnapshotChunkSize
+		}
+		if c.SnapshotParallelism <= 0 {
+			c.SnapshotParallelism = defaultSnapshotParallelism
+		}
+	}
 	return nil
 }
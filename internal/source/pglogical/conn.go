@@ -19,6 +19,7 @@
 package pglogical
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -26,11 +27,14 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
 	"github.com/google/uuid"
 	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgproto3"
 	"github.com/pkg/errors"
@@ -44,14 +48,31 @@ type lsnStamp struct {
 }
 
 var (
-	_ stamp.Stamp         = (*lsnStamp)(nil)
-	_ logical.OffsetStamp = (*lsnStamp)(nil)
+	_ stamp.Stamp            = (*lsnStamp)(nil)
+	_ logical.OffsetStamp    = (*lsnStamp)(nil)
+	_ logical.StopPointStamp = (*lsnStamp)(nil)
 )
 
 func (s *lsnStamp) AsLSN() pglogrepl.LSN        { return s.LSN }
 func (s *lsnStamp) AsTime() time.Time           { return s.TxTime }
 func (s *lsnStamp) AsOffset() uint64            { return uint64(s.LSN) }
 func (s *lsnStamp) Less(other stamp.Stamp) bool { return s.LSN < other.(*lsnStamp).LSN }
+func (s *lsnStamp) String() string              { return s.LSN.String() }
+
+// ZeroStamp returns an empty LSN, into which a stored consistent point
+// may be decoded.
+func ZeroStamp() stamp.Stamp { return &lsnStamp{} }
+
+// ParseStopPoint implements logical.StopPointStamp. It accepts an LSN
+// in the usual XXX/XXX format.
+func (s *lsnStamp) ParseStopPoint(raw string) error {
+	lsn, err := pglogrepl.ParseLSN(raw)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	*s = lsnStamp{LSN: lsn}
+	return nil
+}
 
 // A conn encapsulates all wire-connection behavior. It is
 // responsible for receiving replication messages and replying with
@@ -59,8 +80,25 @@ func (s *lsnStamp) Less(other stamp.Stamp) bool { return s.LSN < other.(*lsnStam
 type conn struct {
 	// Columns, as ordered by the source database.
 	columns *ident.TableMap[[]types.ColData]
+	// If non-nil, missing target tables will be created.
+	creator *autocreate.Creator
+	// If non-nil, source column types are offered as hints when
+	// adding columns to the target.
+	evolver *evolve.Evolver
+	// Tables with REPLICA IDENTITY FULL, whose old tuples contain the
+	// entire row.
+	fullIdentity *ident.TableMap[bool]
+	// Maps source type OIDs to the kinds of columns to create.
+	kindOf func(oid uint32) autocreate.Kind
+	// Request logical decoding messages from the source.
+	logicalMessages bool
 	// The pg publication name to subscribe to.
 	publicationName string
+	// Looks up table metadata. This is opened by keyColumns and closed
+	// when Process exits, since it is only used by that goroutine.
+	queryConn *pgx.Conn
+	// Creates non-replication connections to look up table metadata.
+	queryConfig *pgx.ConnConfig
 	// Map source ids to target tables.
 	relations map[uint32]ident.Table
 	// The name of the slot within the publication.
@@ -69,6 +107,12 @@ type conn struct {
 	sourceConfig *pgconn.Config
 	// How ofter to commit the consistent point
 	standbyTimeout time.Duration
+	// Spool streamed transactions to disk after this many bytes.
+	streamSpillSize int
+	// The directory for streamed-transaction spool files.
+	streamSpoolDir string
+	// Use protocol version 2 to receive in-progress transactions.
+	streamTransactions bool
 	// Support for toasted columns
 	toastedColumns bool
 }
@@ -86,6 +130,7 @@ func (c *conn) Process(
 			_ = batch.OnRollback(ctx)
 		}
 	}()
+	defer c.closeQueryConn()
 	cpDeadline := time.Now().Add(c.standbyTimeout)
 
 	// We rely on the upstream database to replay events in the case of
@@ -98,6 +143,8 @@ func (c *conn) Process(
 	// still product transactions, but they have no content
 	// (see https://github.com/postgres/postgres/commit/d5a9d86d8f)
 	var emptyTransaction bool
+	// The final LSN of the transaction being processed.
+	var txLSN pglogrepl.LSN
 	for msg := range ch {
 		// Ensure that we resynchronize.
 		if logical.IsRollback(msg) {
@@ -117,7 +164,7 @@ func (c *conn) Process(
 			// The replication protocol says that we'll see these
 			// descriptors before any use of the relation id in the
 			// stream. We'll map the int value to our table identifiers.
-			c.onRelation(msg, events.GetTargetDB())
+			err = c.onRelation(ctx, msg, events.GetTargetDB())
 
 		case *pglogrepl.BeginMessage:
 			if msg.FinalLSN <= ignoreLSN {
@@ -125,10 +172,14 @@ func (c *conn) Process(
 					msg.FinalLSN, ignoreLSN)
 				continue
 			}
-			batch, err = events.OnBegin(ctx)
+			// The stop point is checked against the position at which
+			// the transaction commits.
+			batch, err = logical.OnBeginAt(ctx, events,
+				&lsnStamp{LSN: msg.FinalLSN, TxTime: msg.CommitTime})
 			if err != nil {
 				return err
 			}
+			txLSN = msg.FinalLSN
 			// Resetting the emptyTransaction detector, and continuing.
 			emptyTransaction = true
 			continue
@@ -166,17 +217,32 @@ func (c *conn) Process(
 			}
 
 		case *pglogrepl.DeleteMessage:
-			err = c.onDataTuple(ctx, batch, msg.RelationID, msg.OldTuple, true /* isDelete */)
+			err = c.onDataTuple(ctx, batch, txLSN, msg.RelationID, msg.OldTuple, nil)
 
 		case *pglogrepl.InsertMessage:
-			err = c.onDataTuple(ctx, batch, msg.RelationID, msg.Tuple, false /* isDelete */)
+			err = c.onDataTuple(ctx, batch, txLSN, msg.RelationID, nil, msg.Tuple)
 
 		case *pglogrepl.UpdateMessage:
-			err = c.onDataTuple(ctx, batch, msg.RelationID, msg.NewTuple, false /* isDelete */)
+			// The old tuple will be present if the table has REPLICA
+			// IDENTITY FULL or if the replica identity has changed.
+			err = c.onDataTuple(ctx, batch, txLSN, msg.RelationID, msg.OldTuple, msg.NewTuple)
+
+		case *pglogrepl.LogicalDecodingMessage:
+			err = c.onMessage(ctx, events, batch, ignoreLSN, msg)
 
 		case *pglogrepl.TruncateMessage:
 			err = errors.Errorf("the TRUNCATE operation cannot be supported on table %d", msg.RelationNum)
 
+		case *logical.SnapshotChunk:
+			// Sent by snapshotConn.BackfillInto.
+			if err = msg.Apply(ctx, events); err == nil {
+				snapshotRowCount.Add(float64(len(msg.Muts)))
+			}
+
+		case *snapshotComplete:
+			log.WithField("lsn", msg.cp.LSN).Info("initial snapshot complete")
+			err = events.SetConsistentPoint(ctx, msg.cp)
+
 		case *pglogrepl.TypeMessage:
 			// This type is intentionally discarded. We interpret the
 			// type of the data based on the target table, not the
@@ -209,12 +275,25 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 	if x, ok := cp.(*lsnStamp); ok {
 		startLogPos = x.AsLSN()
 	}
+	pluginArgs := []string{
+		"proto_version '1'",
+		fmt.Sprintf("publication_names '%s'", c.publicationName)}
+	// Any transactions that were partially streamed before a restart
+	// will be sent again, so the spool files are not durable.
+	var streams *streamReader
+	if c.logicalMessages {
+		pluginArgs = append(pluginArgs, "messages 'true'")
+	}
+	if c.streamTransactions {
+		pluginArgs[0] = "proto_version '2'"
+		pluginArgs = append(pluginArgs, "streaming 'on'")
+		streams = newStreamReader(c.streamSpoolDir, c.streamSpillSize)
+		defer streams.Close()
+	}
 	if err := pglogrepl.StartReplication(ctx,
 		replConn, c.slotName, startLogPos,
 		pglogrepl.StartReplicationOptions{
-			PluginArgs: []string{
-				"proto_version '1'",
-				fmt.Sprintf("publication_names '%s'", c.publicationName)},
+			PluginArgs: pluginArgs,
 		},
 	); err != nil {
 		dialFailureCount.Inc()
@@ -298,20 +377,42 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 					"WALStart":     xld.WALStart,
 				}).Debug("xlog data")
 
-				logicalMsg, err := pglogrepl.Parse(xld.WALData)
-				if err != nil {
-					return errors.WithStack(err)
+				send := func(logicalMsg pglogrepl.Message) error {
+					log.WithFields(log.Fields{
+						"logicalMsg": logicalMsg.Type().String(),
+					}).Debug("xlog data")
+
+					select {
+					case ch <- logicalMsg:
+						return nil
+					case <-state.Stopping():
+						return errStopping
+					case <-ctx.Done():
+						return errors.WithStack(ctx.Err())
+					}
+				}
+
+				if streams != nil {
+					consumed, err := streams.accept(xld.WALData, send)
+					if errors.Is(err, errStopping) {
+						return nil
+					} else if err != nil {
+						return err
+					}
+					if consumed {
+						continue
+					}
 				}
-				log.WithFields(log.Fields{
-					"logicalMsg": logicalMsg.Type().String(),
-				}).Debug("xlog data")
 
-				select {
-				case ch <- logicalMsg:
-				case <-state.Stopping():
-					return nil
-				case <-ctx.Done():
-					return errors.WithStack(ctx.Err())
+				logicalMsg, err := parseMessage(xld.WALData)
+				if err != nil {
+					return err
+				}
+				if err := send(logicalMsg); err != nil {
+					if errors.Is(err, errStopping) {
+						return nil
+					}
+					return err
 				}
 			}
 		case *pgproto3.NotificationResponse:
@@ -327,21 +428,96 @@ func (c *conn) ZeroStamp() stamp.Stamp {
 	return &lsnStamp{}
 }
 
-// decodeMutation converts the incoming tuple data into a Mutation.
+// decodeMutation converts the incoming tuple data into a Mutation. The
+// after tuple will be nil for a delete. The before tuple, if present,
+// is only used to construct a before-image if the table has REPLICA
+// IDENTITY FULL.
 func (c *conn) decodeMutation(
-	tbl ident.Table, data *pglogrepl.TupleData, isDelete bool,
+	tbl ident.Table, before, after *pglogrepl.TupleData,
 ) (types.Mutation, error) {
 	var mut types.Mutation
-	var key []string
-	enc := make(map[string]any)
 	targetCols, ok := c.columns.Get(tbl)
 	if !ok {
 		return mut, errors.Errorf("no column data for %s", tbl)
 	}
+	full := c.fullIdentity.GetZero(tbl)
+
+	var beforeEnc, enc map[string]any
+	var beforeKey, key []string
+	var err error
+	if before != nil {
+		beforeEnc, beforeKey, err = c.decodeTuple(tbl, targetCols, before, nil)
+		if err != nil {
+			return mut, err
+		}
+	}
+	if after == nil {
+		// For a delete, the key is found in the old tuple.
+		enc, key = beforeEnc, beforeKey
+	} else {
+		var fill map[string]any
+		if full {
+			// The old tuple contains the entire row, so it can supply
+			// the values of unchanged TOASTed columns.
+			fill = beforeEnc
+		}
+		enc, key, err = c.decodeTuple(tbl, targetCols, after, fill)
+		if err != nil {
+			return mut, err
+		}
+	}
+	for _, v := range enc {
+		if v == types.ToastedColumnPlaceholder {
+			if mut.Meta == nil {
+				mut.Meta = make(map[string]any)
+			}
+			mut.Meta[types.CustomUpsert] = "toasted"
+			break
+		}
+	}
+
+	// In the pathological case where a table has no primary key, we'll
+	// generate a random uuid value to use as the staging key. This is
+	// fine, because the underlying data has no particular identity to
+	// update. If the table has REPLICA IDENTITY FULL, updates and
+	// deletes will carry a before-image of the row, which allows the
+	// target row to be located by its contents.
+	if len(key) == 0 {
+		key = []string{uuid.New().String()}
+	}
+	mut.Key, err = json.Marshal(key)
+	if err != nil {
+		return mut, errors.WithStack(err)
+	}
+	if full && beforeEnc != nil {
+		mut.Before, err = json.Marshal(beforeEnc)
+		if err != nil {
+			return mut, errors.WithStack(err)
+		}
+	}
+	// We don't need the actual column data for delete operations.
+	if after != nil {
+		mut.Data, err = json.Marshal(enc)
+		if err != nil {
+			return mut, errors.WithStack(err)
+		}
+	}
+	return mut, errors.WithStack(err)
+}
+
+// decodeTuple converts the tuple into a map of column names to values
+// and also returns the values of any key columns. Unchanged TOASTed
+// column values are taken from the fill map, if present, or are
+// replaced by a placeholder value.
+func (c *conn) decodeTuple(
+	tbl ident.Table, targetCols []types.ColData, data *pglogrepl.TupleData, fill map[string]any,
+) (map[string]any, []string, error) {
 	if len(targetCols) != len(data.Columns) {
-		return mut, errors.Errorf("column count mismatch is %s: %d vs %d",
+		return nil, nil, errors.Errorf("column count mismatch is %s: %d vs %d",
 			tbl, len(targetCols), len(data.Columns))
 	}
+	var key []string
+	enc := make(map[string]any, len(data.Columns))
 	for idx, sourceCol := range data.Columns {
 		targetCol := targetCols[idx]
 		switch sourceCol.DataType {
@@ -354,90 +530,169 @@ func (c *conn) decodeMutation(
 				key = append(key, string(sourceCol.Data))
 			}
 		case pglogrepl.TupleDataTypeToast:
+			if v, ok := fill[targetCol.Name.Raw()]; ok && v != types.ToastedColumnPlaceholder {
+				enc[targetCol.Name.Raw()] = v
+				continue
+			}
 			if c.toastedColumns {
 				// TupleDataTypeToast is just a marker that tells us
 				// that a TOASTed column has not changed.
 				// Putting a placeholders for downstream apply handlers,
 				// and a custom template that will be able to handle it.
 				unchangedToastedColumns.Inc()
-				if mut.Meta == nil {
-					mut.Meta = make(map[string]any)
-				}
-				mut.Meta[types.CustomUpsert] = "toasted"
 				enc[targetCol.Name.Raw()] = types.ToastedColumnPlaceholder
 				continue
 			}
-			return mut, errors.Errorf(
+			return nil, nil, errors.Errorf(
 				"TOASTed columns are not supported in %s.%s", tbl, targetCol.Name)
 		default:
-			return mut, errors.Errorf(
+			return nil, nil, errors.Errorf(
 				"unimplemented tuple data type %q", string(sourceCol.DataType))
 		}
 	}
+	return enc, key, nil
+}
 
-	// In the pathological case where a table has no primary key, we'll
-	// generate a random uuid value to use as the staging key. This is
-	// fine, because the underlying data has no particular identity to
-	// update. In fact, it's not possible to issue an UPDATE to Postgres
-	// when a row has no replication identity.
-	if len(key) == 0 {
-		key = []string{uuid.New().String()}
+// onMessage delivers a logical decoding message to the userscript.
+// Transactional messages are delivered within the enclosing batch.
+// Non-transactional messages are not bracketed by a BEGIN and COMMIT,
+// so they are delivered in a batch of their own.
+func (c *conn) onMessage(
+	ctx context.Context,
+	events logical.Events,
+	batch logical.Batch,
+	ignoreLSN pglogrepl.LSN,
+	msg *pglogrepl.LogicalDecodingMessage,
+) error {
+	log.WithFields(log.Fields{
+		"lsn":           msg.LSN,
+		"prefix":        msg.Prefix,
+		"transactional": msg.Transactional,
+	}).Trace("logical decoding message")
+	source := script.SourceName(events.GetTargetDB())
+	m := &script.Message{
+		Content:       msg.Content,
+		LSN:           msg.LSN.String(),
+		Prefix:        msg.Prefix,
+		Source:        source,
+		Transactional: msg.Transactional,
 	}
-	var err error
-	mut.Key, err = json.Marshal(key)
+
+	if msg.Transactional {
+		// Will be nil if we're ignoring replayed messages.
+		if batch == nil {
+			return nil
+		}
+		return batch.OnMessage(ctx, source, m)
+	}
+
+	if msg.LSN <= ignoreLSN {
+		log.Tracef("ignoring message at %s before %s", msg.LSN, ignoreLSN)
+		return nil
+	}
+	if batch != nil {
+		return errors.Errorf("non-transactional message at %s within a transaction", msg.LSN)
+	}
+	batch, err := events.OnBegin(ctx)
 	if err != nil {
-		return mut, errors.WithStack(err)
+		return err
 	}
-	// We don't need the actual column data for delete operations.
-	if !isDelete {
-		mut.Data, err = json.Marshal(enc)
-		if err != nil {
-			return mut, errors.WithStack(err)
-		}
+	if err := batch.OnMessage(ctx, source, m); err != nil {
+		_ = batch.OnRollback(ctx)
+		return err
+	}
+	select {
+	case err := <-batch.OnCommit(ctx):
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return mut, errors.WithStack(err)
 }
 
 // onDataTuple will add an incoming row tuple to the in-memory slice,
-// possibly flushing it when the batch size limit is reached.
+// possibly flushing it when the batch size limit is reached. The
+// before tuple is the old tuple of an update or a delete, and the
+// after tuple will be nil for a delete.
 func (c *conn) onDataTuple(
 	ctx context.Context,
 	batch logical.Batch,
+	lsn pglogrepl.LSN,
 	relation uint32,
-	tuple *pglogrepl.TupleData,
-	isDelete bool,
+	before, after *pglogrepl.TupleData,
 ) error {
 	// Will be nil if we're ignoring replayed messages.
 	if batch == nil {
 		return nil
 	}
-	traceTuple(tuple)
+	if after != nil {
+		traceTuple(after)
+	} else {
+		traceTuple(before)
+	}
 	tbl, ok := c.relations[relation]
 	if !ok {
 		return errors.Errorf("unknown relation id %d", relation)
 	}
-	mut, err := c.decodeMutation(tbl, tuple, isDelete)
+	muts := make([]types.Mutation, 0, 2)
+
+	mut, err := c.decodeMutation(tbl, before, after)
 	if err != nil {
 		return err
 	}
-	script.AddMeta("pglogical", tbl, &mut)
 
-	return batch.OnData(ctx, script.SourceName(tbl), tbl, []types.Mutation{mut})
+	// If an update changes the replica identity of a row, the old
+	// tuple will contain the previous key. We'll delete the row under
+	// its old key before writing the new row. With REPLICA IDENTITY
+	// FULL, the old tuple is always sent, so the keys are compared. A
+	// table without a primary key has no key to change; its rows are
+	// located by their before-images.
+	if before != nil && after != nil {
+		del, err := c.decodeMutation(tbl, before, nil)
+		if err != nil {
+			return err
+		}
+		full := c.fullIdentity.GetZero(tbl)
+		if !full || (hasPrimaryKey(c.columns.GetZero(tbl)) && !bytes.Equal(del.Key, mut.Key)) {
+			muts = append(muts, del)
+		}
+	}
+	muts = append(muts, mut)
+
+	for i := range muts {
+		script.AddMeta("pglogical", tbl, &muts[i])
+		muts[i].Meta[script.MetaLSN] = lsn.String()
+	}
+
+	return batch.OnData(ctx, script.SourceName(tbl), tbl, muts)
 }
 
-// learn updates the source database namespace mappings.
-func (c *conn) onRelation(msg *pglogrepl.RelationMessage, targetDB ident.Schema) {
+// onRelation updates the source database namespace mappings and will
+// create the target table, if so configured.
+func (c *conn) onRelation(
+	ctx context.Context, msg *pglogrepl.RelationMessage, targetDB ident.Schema,
+) error {
 	// The replication protocol says that we'll see these
 	// descriptors before any use of the relation id in the
 	// stream. We'll map the int value to our table identifiers.
 	tbl := ident.NewTable(targetDB, ident.New(msg.RelationName))
 	c.relations[msg.RelationID] = tbl
 
+	full := msg.ReplicaIdentity == 'f'
+	c.fullIdentity.Put(tbl, full)
+
+	// The column flags describe the replica identity, which isn't
+	// necessarily the primary key. With REPLICA IDENTITY FULL, every
+	// column is flagged, so the key is read from the catalog instead.
+	keyCols, err := c.keyColumns(ctx, msg.RelationID)
+	if err != nil {
+		return err
+	}
+
 	colNames := make([]types.ColData, len(msg.Columns))
 	for idx, col := range msg.Columns {
 		colNames[idx] = types.ColData{
 			Name:    ident.New(col.Name),
-			Primary: col.Flags == 1,
+			Primary: keyCols[col.Name],
 			// This could be made textual if we used the
 			// ConnInfo metadata methods.
 			Type: fmt.Sprintf("%d", col.DataType),
@@ -447,9 +702,101 @@ func (c *conn) onRelation(msg *pglogrepl.RelationMessage, targetDB ident.Schema)
 
 	log.WithFields(log.Fields{
 		"Columns":    colNames,
+		"Full":       full,
 		"RelationID": msg.RelationID,
 		"Table":      tbl,
 	}).Trace("learned relation")
+
+	if c.creator == nil && !c.evolver.Enabled(tbl) {
+		return nil
+	}
+	cols := make([]autocreate.Column, len(msg.Columns))
+	for idx, col := range msg.Columns {
+		cols[idx] = autocreate.Column{
+			Kind:    c.kindOf(col.DataType),
+			Name:    colNames[idx].Name,
+			Primary: colNames[idx].Primary,
+		}
+	}
+	c.evolver.Hint(tbl, cols)
+	if c.creator == nil {
+		return nil
+	}
+	return c.creator.Ensure(ctx, tbl, cols)
+}
+
+// keyColumnsQuery finds the columns of a table's primary key or, if
+// there is none, of the index used as its replica identity.
+const keyColumnsQuery = `
+SELECT a.attname
+  FROM pg_index i
+  JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+ WHERE i.indexrelid = (
+       SELECT indexrelid
+         FROM pg_index
+        WHERE indrelid = $1
+          AND (indisprimary OR indisreplident)
+        ORDER BY indisprimary DESC
+        LIMIT 1)`
+
+// keyColumns returns the names of the columns that uniquely identify
+// a row in the source table. The map will be empty if the table has no
+// primary key or replica-identity index.
+func (c *conn) keyColumns(ctx context.Context, relationID uint32) (map[string]bool, error) {
+	if c.queryConn == nil || c.queryConn.IsClosed() {
+		conn, err := pgx.ConnectConfig(ctx, c.queryConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not connect to source database")
+		}
+		c.queryConn = conn
+	}
+
+	ret, err := queryKeyColumns(ctx, c.queryConn, relationID)
+	if err != nil {
+		// The connection may be broken, so open a new one next time.
+		c.closeQueryConn()
+		return nil, errors.Wrapf(err, "could not find key columns for relation %d", relationID)
+	}
+	return ret, nil
+}
+
+// queryKeyColumns executes keyColumnsQuery.
+func queryKeyColumns(
+	ctx context.Context, conn *pgx.Conn, relationID uint32,
+) (map[string]bool, error) {
+	rows, err := conn.Query(ctx, keyColumnsQuery, relationID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	ret := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret[name] = true
+	}
+	return ret, errors.WithStack(rows.Err())
+}
+
+// closeQueryConn closes the connection used by keyColumns, if open.
+func (c *conn) closeQueryConn() {
+	if c.queryConn != nil {
+		_ = c.queryConn.Close(context.Background())
+		c.queryConn = nil
+	}
+}
+
+// hasPrimaryKey returns true if any of the columns is part of a key.
+func hasPrimaryKey(cols []types.ColData) bool {
+	for _, col := range cols {
+		if col.Primary {
+			return true
+		}
+	}
+	return false
 }
 
 // traceTuple emits log messages if tracing is enabled.
@@ -0,0 +1,135 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/base"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that updates and deletes to a table without a primary key
+// are applied using the before-images provided by REPLICA IDENTITY
+// FULL, and that a change to a row's key removes the old row. A table
+// with a primary key and REPLICA IDENTITY FULL must still be keyed by
+// its primary key.
+func TestReplicaIdentity(t *testing.T) {
+	r := require.New(t)
+
+	fixture, err := base.NewFixture(t)
+	r.NoError(err)
+
+	ctx := fixture.Context
+	dbSchema := fixture.TargetSchema.Schema()
+	dbName := dbSchema.Idents(nil)[0] // Extract first name part.
+	crdbPool := fixture.TargetPool
+
+	pgPool, cancel, err := setupPGPool(dbName)
+	r.NoError(err)
+	defer cancel()
+
+	keyed := ident.NewTable(dbSchema, ident.New("keyed"))
+	keyless := ident.NewTable(dbSchema, ident.New("keyless"))
+	full := ident.NewTable(dbSchema, ident.New("full"))
+	for _, schema := range []string{
+		fmt.Sprintf("CREATE TABLE %s (k INT PRIMARY KEY, v TEXT)", keyed),
+		fmt.Sprintf("CREATE TABLE %s (k INT, v TEXT)", keyless),
+		fmt.Sprintf("CREATE TABLE %s (k INT PRIMARY KEY, v TEXT)", full),
+	} {
+		_, err := pgPool.Exec(ctx, schema)
+		r.NoError(err)
+		_, err = crdbPool.ExecContext(ctx, schema)
+		r.NoError(err)
+	}
+	for _, tbl := range []ident.Table{keyless, full} {
+		_, err = pgPool.Exec(ctx, fmt.Sprintf("ALTER TABLE %s REPLICA IDENTITY FULL", tbl))
+		r.NoError(err)
+	}
+
+	cancel, err = setupPublication(ctx, pgPool, dbName, "ALL TABLES")
+	r.NoError(err)
+	defer cancel()
+	pubNameRaw := publicationName(dbName).Raw()
+
+	_, err = Start(ctx, &Config{
+		BaseConfig: logical.BaseConfig{
+			RetryDelay:     time.Millisecond,
+			StagingSchema:  fixture.StagingDB.Schema(),
+			StandbyTimeout: 100 * time.Millisecond,
+			TargetConn:     crdbPool.ConnectionString,
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:     "pglogicaltest",
+			TargetSchema: dbSchema,
+		},
+		Publication: pubNameRaw,
+		Slot:        pubNameRaw,
+		SourceConn:  *pgConnString + dbName.Raw(),
+	})
+	r.NoError(err)
+
+	// waitFor polls the target until the query returns the expected
+	// number of rows.
+	waitFor := func(tbl ident.Table, where string, expected int) {
+		for {
+			var count int
+			r.NoError(crdbPool.QueryRowContext(ctx,
+				fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", tbl, where)).Scan(&count))
+			if count == expected {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	for _, stmt := range []string{
+		fmt.Sprintf("INSERT INTO %s VALUES (1, 'a'), (1, 'a'), (2, 'b')", keyless),
+		fmt.Sprintf("INSERT INTO %s VALUES (1, 'a'), (2, 'b')", keyed),
+		fmt.Sprintf("INSERT INTO %s VALUES (1, 'a'), (2, 'b'), (3, 'c')", full),
+	} {
+		_, err := pgPool.Exec(ctx, stmt)
+		r.NoError(err)
+	}
+	waitFor(keyless, "true", 3)
+	waitFor(keyed, "true", 2)
+	waitFor(full, "true", 3)
+
+	// Duplicate rows must be deleted one at a time.
+	for _, stmt := range []string{
+		fmt.Sprintf("UPDATE %s SET v = 'z' WHERE k = 2", keyless),
+		fmt.Sprintf("DELETE FROM %s WHERE k = 1", keyless),
+		fmt.Sprintf("UPDATE %s SET k = 10 WHERE k = 1", keyed),
+		fmt.Sprintf("UPDATE %s SET v = 'z' WHERE k = 1", full),
+		fmt.Sprintf("UPDATE %s SET k = 20 WHERE k = 2", full),
+		fmt.Sprintf("DELETE FROM %s WHERE k = 3", full),
+	} {
+		_, err := pgPool.Exec(ctx, stmt)
+		r.NoError(err)
+	}
+	waitFor(keyless, "true", 1)
+	waitFor(keyless, "v = 'z'", 1)
+	waitFor(keyed, "k = 10", 1)
+	waitFor(keyed, "true", 2)
+	waitFor(full, "k = 1 AND v = 'z'", 1)
+	waitFor(full, "k = 20 AND v = 'b'", 1)
+	waitFor(full, "true", 2)
+}
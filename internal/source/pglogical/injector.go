@@ -46,3 +46,22 @@ func Start(ctx *stopper.Context, config *Config) (*PGLogical, error) {
 		target.Set,
 	))
 }
+
+// StartShared creates a PostgreSQL logical replication loop which uses
+// resources that are shared with other loops in the process.
+func StartShared(
+	ctx *stopper.Context, config *Config, diags *diag.Diagnostics, shared *logical.Shared,
+) (*PGLogical, error) {
+	panic(wire.Build(
+		wire.Bind(new(context.Context), new(*stopper.Context)),
+		wire.Bind(new(logical.Config), new(*Config)),
+		wire.FieldsOf(new(*logical.Shared), "Appliers", "Configs", "Creator", "Evolver",
+			"Memo", "Stagers", "StagingPool", "TargetPool", "VersionChecker", "Watchers"),
+		wire.Struct(new(PGLogical), "*"),
+		Set,
+		logical.ProvideBaseConfig,
+		logical.ProvideFactory,
+		logical.ProvideUserScriptConfig,
+		script.ProvideLoader,
+	))
+}
@@ -0,0 +1,178 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// slotLagInterval controls how often the replication slot's lag is
+// reported.
+const slotLagInterval = 10 * time.Second
+
+// ensurePublication verifies that the publication exists, creating it
+// if so configured.
+func ensurePublication(ctx context.Context, source *pgx.Conn, config *Config) error {
+	var count int
+	if err := source.QueryRow(ctx,
+		"SELECT count(*) FROM pg_publication WHERE pubname = $1",
+		config.Publication,
+	).Scan(&count); err != nil {
+		return errors.WithStack(err)
+	}
+	if count == 1 {
+		log.Tracef("validated that publication %q exists", config.Publication)
+		return nil
+	}
+	if !config.CreatePublication {
+		return errors.Errorf(
+			`run CREATE PUBLICATION %s FOR ALL TABLES; in source database, or use --createPublication`,
+			config.Publication)
+	}
+
+	scope := "ALL TABLES"
+	if len(config.PublicationTables) > 0 {
+		tables := make([]string, len(config.PublicationTables))
+		for idx, tbl := range config.PublicationTables {
+			tables[idx] = pgx.Identifier(strings.Split(tbl, ".")).Sanitize()
+		}
+		scope = "TABLE " + strings.Join(tables, ", ")
+	}
+	if _, err := source.Exec(ctx, fmt.Sprintf("CREATE PUBLICATION %s FOR %s",
+		pgx.Identifier{config.Publication}.Sanitize(), scope),
+	); err != nil {
+		return errors.Wrapf(err, "could not create publication %s", config.Publication)
+	}
+	log.WithFields(log.Fields{
+		"publication": config.Publication,
+		"scope":       scope,
+	}).Info("created publication")
+	return nil
+}
+
+// ensureSlot verifies that the replication slot exists, creating it if
+// so configured. If the initial snapshot has been requested, a missing
+// slot will be created later.
+func ensureSlot(ctx context.Context, source *pgx.Conn, config *Config) error {
+	var count int
+	if err := source.QueryRow(ctx,
+		"SELECT count(*) FROM pg_replication_slots WHERE slot_name = $1",
+		config.Slot,
+	).Scan(&count); err != nil {
+		return errors.WithStack(err)
+	}
+	switch {
+	case count == 1:
+		log.Tracef("validated that replication slot %q exists", config.Slot)
+		return nil
+	case config.InitialSnapshot:
+		return nil
+	case config.CreateSlot:
+		if _, err := source.Exec(ctx,
+			"SELECT pg_create_logical_replication_slot($1, 'pgoutput')",
+			config.Slot,
+		); err != nil {
+			return errors.Wrapf(err, "could not create replication slot %s", config.Slot)
+		}
+		log.WithField("slot", config.Slot).Info("created replication slot")
+		return nil
+	default:
+		return errors.Errorf(
+			"run SELECT pg_create_logical_replication_slot('%s', 'pgoutput'); in source database, "+
+				"then perform bulk data copy, or use --initialSnapshot",
+			config.Slot)
+	}
+}
+
+// reportSlotLag starts a goroutine which periodically updates the
+// slot lag metric. The connection must not be used by any other
+// goroutine and will be closed when the context is stopped. The
+// goroutine returns an error if the connection has been broken.
+func reportSlotLag(ctx *stopper.Context, source *pgx.Conn, slot string) {
+	gauge := slotLagBytes.WithLabelValues(slot)
+	ctx.Go(func() error {
+		defer func() { _ = source.Close(context.Background()) }()
+		for {
+			var lag *int64
+			err := source.QueryRow(ctx,
+				"SELECT pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn)::INT8 "+
+					"FROM pg_replication_slots WHERE slot_name = $1",
+				slot,
+			).Scan(&lag)
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				// The slot may not have been created yet.
+			case err != nil && source.IsClosed():
+				return errors.Wrap(err, "could not query replication slot lag")
+			case err != nil:
+				log.WithError(err).Warn("could not query replication slot lag")
+			case lag != nil:
+				gauge.Set(float64(*lag))
+			}
+
+			select {
+			case <-time.After(slotLagInterval):
+			case <-ctx.Stopping():
+				return nil
+			}
+		}
+	})
+}
+
+// Teardown drops the replication slot and, optionally, the publication.
+// This should be called once a migration is complete, since an unused
+// replication slot will cause WAL to accumulate in the source database.
+func Teardown(
+	ctx context.Context, source *pgx.Conn, slot, publication string,
+) error {
+	if slot != "" {
+		var active bool
+		err := source.QueryRow(ctx,
+			"SELECT active FROM pg_replication_slots WHERE slot_name = $1", slot,
+		).Scan(&active)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			log.WithField("slot", slot).Info("replication slot does not exist")
+		case err != nil:
+			return errors.WithStack(err)
+		case active:
+			return errors.Errorf("replication slot %s is in use; stop cdc-sink first", slot)
+		default:
+			if _, err := source.Exec(ctx, "SELECT pg_drop_replication_slot($1)", slot); err != nil {
+				return errors.Wrapf(err, "could not drop replication slot %s", slot)
+			}
+			log.WithField("slot", slot).Info("dropped replication slot")
+		}
+	}
+	if publication != "" {
+		if _, err := source.Exec(ctx, fmt.Sprintf("DROP PUBLICATION IF EXISTS %s",
+			pgx.Identifier{publication}.Sanitize()),
+		); err != nil {
+			return errors.Wrapf(err, "could not drop publication %s", publication)
+		}
+		log.WithField("publication", publication).Info("dropped publication")
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/base"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that the publication and slot can be created on demand and
+// removed by Teardown.
+func TestLifecycle(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	fixture, err := base.NewFixture(t)
+	r.NoError(err)
+
+	ctx := fixture.Context
+	dbSchema := fixture.TargetSchema.Schema()
+	dbName := dbSchema.Idents(nil)[0] // Extract first name part.
+	crdbPool := fixture.TargetPool
+
+	pgPool, cancel, err := setupPGPool(dbName)
+	r.NoError(err)
+	defer cancel()
+
+	included := ident.NewTable(dbSchema, ident.New("included"))
+	excluded := ident.NewTable(dbSchema, ident.New("excluded"))
+	for _, tbl := range []ident.Table{included, excluded} {
+		schema := fmt.Sprintf("CREATE TABLE %s (pk INT PRIMARY KEY, v TEXT)", tbl)
+		_, err := pgPool.Exec(ctx, schema)
+		r.NoError(err)
+		_, err = crdbPool.ExecContext(ctx, schema)
+		r.NoError(err)
+	}
+
+	pubNameRaw := publicationName(dbName).Raw()
+	cfg := &Config{
+		BaseConfig: logical.BaseConfig{
+			RetryDelay:     time.Millisecond,
+			StagingSchema:  fixture.StagingDB.Schema(),
+			StandbyTimeout: 100 * time.Millisecond,
+			TargetConn:     crdbPool.ConnectionString,
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:     "pglogicaltest",
+			TargetSchema: dbSchema,
+		},
+		CreatePublication: true,
+		CreateSlot:        true,
+		Publication:       pubNameRaw,
+		PublicationTables: []string{"public.included"},
+		Slot:              pubNameRaw,
+		SourceConn:        *pgConnString + dbName.Raw(),
+	}
+	_, err = Start(ctx, cfg)
+	r.NoError(err)
+
+	var pubTables []string
+	rows, err := pgPool.Query(ctx,
+		"SELECT tablename FROM pg_publication_tables WHERE pubname = $1", pubNameRaw)
+	r.NoError(err)
+	for rows.Next() {
+		var tbl string
+		r.NoError(rows.Scan(&tbl))
+		pubTables = append(pubTables, tbl)
+	}
+	r.NoError(rows.Err())
+	a.Equal([]string{"included"}, pubTables)
+
+	for _, tbl := range []ident.Table{included, excluded} {
+		_, err := pgPool.Exec(ctx, fmt.Sprintf("INSERT INTO %s VALUES (1, 'v')", tbl))
+		r.NoError(err)
+	}
+	for {
+		count, err := base.GetRowCount(ctx, crdbPool, included)
+		r.NoError(err)
+		if count == 1 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	count, err := base.GetRowCount(ctx, crdbPool, excluded)
+	r.NoError(err)
+	a.Zero(count)
+
+	// Stop replication, so that the slot is no longer in use.
+	ctx.Stop(time.Second)
+	r.NoError(ctx.Wait())
+
+	teardownCtx := stopper.WithContext(context.Background())
+	defer teardownCtx.Stop(time.Second)
+	source, err := stdpool.OpenPgxAsConn(teardownCtx, cfg.SourceConn)
+	r.NoError(err)
+	r.NoError(Teardown(teardownCtx, source, cfg.Slot, cfg.Publication))
+
+	var slots, pubs int
+	r.NoError(pgPool.QueryRow(teardownCtx,
+		"SELECT count(*) FROM pg_replication_slots WHERE slot_name = $1", cfg.Slot).Scan(&slots))
+	r.NoError(pgPool.QueryRow(teardownCtx,
+		"SELECT count(*) FROM pg_publication WHERE pubname = $1", cfg.Publication).Scan(&pubs))
+	a.Zero(slots)
+	a.Zero(pubs)
+
+	// Teardown is idempotent.
+	r.NoError(Teardown(teardownCtx, source, cfg.Slot, cfg.Publication))
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/base"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that messages written by pg_logical_emit_message() are
+// delivered to the userscript and that the documents that it returns
+// are applied to the target.
+func TestLogicalMessages(t *testing.T) {
+	r := require.New(t)
+
+	fixture, err := base.NewFixture(t)
+	r.NoError(err)
+
+	ctx := fixture.Context
+	dbSchema := fixture.TargetSchema.Schema()
+	dbName := dbSchema.Idents(nil)[0] // Extract first name part.
+	crdbPool := fixture.TargetPool
+
+	pgPool, cancel, err := setupPGPool(dbName)
+	r.NoError(err)
+	defer cancel()
+
+	// The messages table only exists in the target.
+	tbl := ident.NewTable(dbSchema, ident.New("messages"))
+	_, err = crdbPool.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE %s (content TEXT PRIMARY KEY, prefix TEXT, transactional BOOL)", tbl))
+	r.NoError(err)
+
+	cancel, err = setupPublication(ctx, pgPool, dbName, "ALL TABLES")
+	r.NoError(err)
+	defer cancel()
+	pubNameRaw := publicationName(dbName).Raw()
+
+	_, err = Start(ctx, &Config{
+		BaseConfig: logical.BaseConfig{
+			RetryDelay: time.Millisecond,
+			ScriptConfig: script.Config{
+				MainPath: "/main.ts",
+				FS: &fstest.MapFS{
+					"main.ts": &fstest.MapFile{Data: []byte(fmt.Sprintf(`
+import * as api from "cdc-sink@v1";
+api.configureSource(%q, {
+  onMessage: (msg) => msg.prefix === "ignored" ? null : ({
+    "messages": [{
+      content: msg.content,
+      prefix: msg.prefix,
+      transactional: msg.transactional
+    }]
+  })
+});
+`, script.SourceName(dbSchema).Raw()))},
+				},
+			},
+			StagingSchema:  fixture.StagingDB.Schema(),
+			StandbyTimeout: 100 * time.Millisecond,
+			TargetConn:     crdbPool.ConnectionString,
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:     "pglogicaltest",
+			TargetSchema: dbSchema,
+		},
+		LogicalMessages: true,
+		Publication:     pubNameRaw,
+		Slot:            pubNameRaw,
+		SourceConn:      *pgConnString + dbName.Raw(),
+	})
+	r.NoError(err)
+
+	for _, stmt := range []string{
+		"SELECT pg_logical_emit_message(true, 'ignored', 'skip me')",
+		"SELECT pg_logical_emit_message(true, 'greeting', 'hello')",
+		"SELECT pg_logical_emit_message(false, 'greeting', 'world')",
+	} {
+		_, err := pgPool.Exec(ctx, stmt)
+		r.NoError(err)
+	}
+
+	for {
+		count, err := base.GetRowCount(ctx, crdbPool, tbl)
+		r.NoError(err)
+		if count == 2 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	var transactional bool
+	r.NoError(crdbPool.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT transactional FROM %s WHERE content = 'hello'", tbl)).Scan(&transactional))
+	r.True(transactional)
+	r.NoError(crdbPool.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT transactional FROM %s WHERE content = 'world'", tbl)).Scan(&transactional))
+	r.False(transactional)
+}
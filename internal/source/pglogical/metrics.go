@@ -34,6 +34,26 @@ var (
 		Name: "pglogical_empty_transactions",
 		Help: "the number of empty transactions we have seen",
 	})
+	slotLagBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pglogical_slot_lag_bytes",
+		Help: "the number of WAL bytes between the source's current position and the slot's confirmed position",
+	}, []string{"slot"})
+	snapshotRowCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglogical_snapshot_rows_total",
+		Help: "the number of rows copied by the initial snapshot",
+	})
+	streamSpillBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglogical_stream_spill_bytes_total",
+		Help: "the number of bytes of streamed transactions written to spool files",
+	})
+	streamSpillCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglogical_stream_spill_total",
+		Help: "the number of streamed transactions that were spooled to disk",
+	})
+	streamedTransactions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglogical_streamed_transactions_total",
+		Help: "the number of in-progress transactions streamed from the source",
+	})
 	unchangedToastedColumns = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "pglogical_unchanged_toasted_columns",
 		Help: "the number of times we see unchanged toasted columns",
@@ -17,7 +17,10 @@
 package pglogical
 
 import (
+	"net/http"
+
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
 )
@@ -25,14 +28,31 @@ import (
 // PGLogical is a PostgreSQL logical replication loop.
 type PGLogical struct {
 	Diagnostics *diag.Diagnostics
+	Factory     *logical.Factory
 	Loop        *logical.Loop
 }
 
 var (
 	_ stdlogical.HasDiagnostics = (*PGLogical)(nil)
+	_ stdlogical.HasHandlers    = (*PGLogical)(nil)
+	_ stdlogical.HasStopPoint   = (*PGLogical)(nil)
 )
 
+// AddHandlers implements [stdlogical.HasHandlers] to allow the
+// replica to be paused or stopped at a specific point, and to provide
+// the admin API.
+func (l *PGLogical) AddHandlers(auth types.Authenticator, mux *http.ServeMux) {
+	mux.Handle("/_/pause", logical.PauseHandler(auth, l.Factory))
+	mux.Handle("/_/stopAt", logical.StopAtHandler(auth, l.Factory))
+	mux.Handle("/_/admin/", logical.AdminHandler(auth, l.Factory))
+}
+
 // GetDiagnostics implements [stdlogical.HasDiagnostics].
 func (l *PGLogical) GetDiagnostics() *diag.Diagnostics {
 	return l.Diagnostics
 }
+
+// StopPoint implements [stdlogical.HasStopPoint].
+func (l *PGLogical) StopPoint() (string, <-chan struct{}) {
+	return l.Loop.StopPoint()
+}
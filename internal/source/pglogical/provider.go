@@ -19,13 +19,14 @@ package pglogical
 import (
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
 	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
 	"github.com/google/wire"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 )
 
 // Set is used by Wire.
@@ -40,62 +41,71 @@ var Set = wire.NewSet(
 // has been configured. There's a fake dependency on the script loader
 // so that flags can be evaluated first.
 func ProvideDialect(
-	ctx *stopper.Context, config *Config, _ *script.Loader,
+	ctx *stopper.Context,
+	config *Config,
+	creator *autocreate.Creator,
+	evolver *evolve.Evolver,
+	memo types.Memo,
+	stagingPool *types.StagingPool,
+	_ *script.Loader,
 ) (logical.Dialect, error) {
 	if err := config.Preflight(); err != nil {
 		return nil, err
 	}
-	// Verify that the publication and replication slots were configured
-	// by the user. We could create the replication slot ourselves, but
-	// we want to coordinate the timing of the backup, restore, and
-	// streaming operations.
+	// Verify that the publication and replication slots exist. They
+	// will only be created if requested by the user, since the timing
+	// of the backup, restore, and streaming operations must otherwise be
+	// coordinated by the user.
 	source, err := stdpool.OpenPgxAsConn(ctx, config.SourceConn)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not connect to source database")
 	}
-
-	// Ensure that the requested publication exists.
-	var count int
-	if err := source.QueryRow(ctx,
-		"SELECT count(*) FROM pg_publication WHERE pubname = $1",
-		config.Publication,
-	).Scan(&count); err != nil {
-		return nil, errors.WithStack(err)
-	}
-	if count != 1 {
-		return nil, errors.Errorf(
-			`run CREATE PUBLICATION %s FOR ALL TABLES; in source database`,
-			config.Publication)
-	}
-	log.Tracef("validated that publication %q exists", config.Publication)
-
-	// Verify that the consumer slot exists.
-	if err := source.QueryRow(ctx,
-		"SELECT count(*) FROM pg_replication_slots WHERE slot_name = $1",
-		config.Slot,
-	).Scan(&count); err != nil {
-		return nil, errors.WithStack(err)
+	if err := ensurePublication(ctx, source, config); err != nil {
+		return nil, err
 	}
-	if count != 1 {
-		return nil, errors.Errorf(
-			"run SELECT pg_create_logical_replication_slot('%s', 'pgoutput'); in source database, "+
-				"then perform bulk data copy",
-			config.Slot)
+	if err := ensureSlot(ctx, source, config); err != nil {
+		return nil, err
 	}
-	log.Tracef("validated that replication slot %q exists", config.Slot)
 
 	// Copy the configuration and tweak it for replication behavior.
 	sourceConfig := source.Config().Config.Copy()
 	sourceConfig.RuntimeParams["replication"] = "database"
 
-	return &conn{
-		columns:         &ident.TableMap[[]types.ColData]{},
-		publicationName: config.Publication,
-		relations:       make(map[uint32]ident.Table),
-		slotName:        config.Slot,
-		sourceConfig:    sourceConfig,
-		standbyTimeout:  config.StandbyTimeout,
-		toastedColumns:  config.ToastedColumns,
+	ret := &conn{
+		columns:            &ident.TableMap[[]types.ColData]{},
+		fullIdentity:       &ident.TableMap[bool]{},
+		kindOf:             creator.KindOfOID,
+		logicalMessages:    config.LogicalMessages,
+		publicationName:    config.Publication,
+		queryConfig:        source.Config().Copy(),
+		relations:          make(map[uint32]ident.Table),
+		slotName:           config.Slot,
+		sourceConfig:       sourceConfig,
+		standbyTimeout:     config.StandbyTimeout,
+		streamSpillSize:    config.StreamSpillSize,
+		streamSpoolDir:     config.StreamSpoolDir,
+		streamTransactions: config.StreamTransactions,
+		toastedColumns:     config.ToastedColumns,
+	}
+	if config.CreateTables {
+		ret.creator = creator
+	}
+	ret.evolver = evolver
+	// Copy the connection configuration before the connection is
+	// handed off to the metrics goroutine.
+	copyConfig := source.Config().Copy()
+	reportSlotLag(ctx, source, config.Slot)
+
+	if !config.InitialSnapshot {
+		return ret, nil
+	}
+	return &snapshotConn{
+		conn:        ret,
+		chunkSize:   config.SnapshotChunkSize,
+		copyConfig:  copyConfig,
+		parallelism: config.SnapshotParallelism,
+		progress: logical.NewSnapshotProgress(
+			memo, stagingPool, config.LoopName+"-snapshot"),
 	}, nil
 }
 
@@ -105,5 +115,8 @@ func ProvideLoop(
 	cfg *Config, dialect logical.Dialect, loops *logical.Factory,
 ) (*logical.Loop, error) {
 	cfg.Dialect = dialect
+	// The source only retains data until it is acknowledged, so any
+	// applyDelay must be implemented by holding data in staging.
+	cfg.HoldInStaging = true
 	return loops.Start(&cfg.LoopConfig)
 }
@@ -0,0 +1,552 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/google/uuid"
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// A snapshotConn extends conn to copy the contents of the published
+// tables before streaming from the replication slot.
+//
+// If the replication slot does not exist, it is created with an
+// exported snapshot, which is used by all of the copying transactions.
+// This guarantees that the copied data corresponds exactly to the
+// slot's consistent point. If the copy is interrupted, it will resume
+// using a new snapshot from the last chunk that was committed to the
+// target. This is safe, since replication will restart from the
+// original consistent point and all mutations are idempotent upserts
+// or deletes. Tables without a primary key cannot be resumed, since
+// their rows are given random keys; the copy will stop with an error
+// if such a table was only partially copied.
+type snapshotConn struct {
+	*conn
+
+	chunkSize   int                       // The maximum number of rows per query.
+	copyConfig  *pgx.ConnConfig           // Creates non-replication connections.
+	parallelism int                       // Number of tables to copy concurrently.
+	progress    *logical.SnapshotProgress // Persists the progress of the copy.
+}
+
+var (
+	_ logical.Backfiller = (*snapshotConn)(nil)
+	_ logical.Dialect    = (*snapshotConn)(nil)
+)
+
+// snapshotComplete is sent from BackfillInto to Process once all
+// tables have been copied. The chunks of each table are sent as
+// [logical.SnapshotChunk] messages.
+type snapshotComplete struct {
+	cp *lsnStamp
+}
+
+// A snapshotColumn describes a column in a source table.
+type snapshotColumn struct {
+	name    string
+	primary bool
+	typ     string // The SQL type of the column, for casting.
+}
+
+// BackfillInto implements logical.Backfiller. If the loop has not yet
+// reached a consistent point, the published tables will be copied.
+// Otherwise, this will catch up by streaming from the replication slot.
+func (c *snapshotConn) BackfillInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	cp, _ := state.GetConsistentPoint()
+	if cp.(*lsnStamp).AsLSN() != 0 {
+		return c.ReadInto(ctx, ch, state)
+	}
+
+	if err := c.copyTables(ctx, ch, state); err != nil {
+		return err
+	}
+
+	// Wait for Process to record the consistent point.
+	for {
+		cp, updated := state.GetConsistentPoint()
+		if cp.(*lsnStamp).AsLSN() != 0 {
+			break
+		}
+		select {
+		case <-updated:
+		case <-state.Stopping():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return c.ReadInto(ctx, ch, state)
+}
+
+// copyTables sends the contents of the published tables to Process.
+func (c *snapshotConn) copyTables(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	if err := c.progress.Load(ctx); err != nil {
+		return err
+	}
+
+	snapshotName, lsn, release, err := c.exportSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tables, err := c.publishedTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(c.parallelism)
+	names := make([]string, len(tables))
+	byName := make(map[string]ident.Table, len(tables))
+	for idx, tbl := range tables {
+		names[idx] = tbl.Raw()
+		byName[tbl.Raw()] = tbl
+	}
+	for _, name := range c.progress.Pending(names) {
+		tbl := byName[name]
+		progress := c.progress.Table(name)
+		eg.Go(func() error {
+			return errors.Wrap(
+				c.copyTable(egCtx, ch, state, snapshotName, tbl, progress),
+				tbl.Raw())
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	// The transaction time is used by the loop to determine when the
+	// backfill has caught up.
+	select {
+	case ch <- &snapshotComplete{cp: &lsnStamp{LSN: lsn, TxTime: time.Now()}}:
+		return nil
+	case <-state.Stopping():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// copyTable reads a single table in chunks. The progress parameter
+// will be nil if no chunks have previously been committed.
+func (c *snapshotConn) copyTable(
+	ctx context.Context,
+	ch chan<- logical.Message,
+	state logical.State,
+	snapshotName string,
+	tbl ident.Table,
+	progress *logical.TableProgress,
+) error {
+	conn, err := pgx.ConnectConfig(ctx, c.copyConfig)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer conn.Close(context.Background())
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{
+		AccessMode: pgx.ReadOnly,
+		IsoLevel:   pgx.RepeatableRead,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	if _, err := tx.Exec(ctx,
+		fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", snapshotName),
+	); err != nil {
+		return errors.WithStack(err)
+	}
+
+	cols, err := snapshotColumns(ctx, tx, tbl)
+	if err != nil {
+		return err
+	}
+	q := newChunkQuery(tbl, cols, c.chunkSize)
+	if err := c.progress.CheckResume(tbl, q.hasKey, progress); err != nil {
+		return err
+	}
+	var last []string
+	if progress != nil {
+		last = progress.Last
+	}
+	target := ident.NewTable(state.GetTargetDB(), tbl.Table())
+	if c.creator != nil {
+		created := make([]autocreate.Column, len(cols))
+		for idx, col := range cols {
+			created[idx] = autocreate.Column{
+				Kind:    autocreate.KindOf(col.typ),
+				Name:    ident.New(col.name),
+				Primary: col.primary,
+			}
+		}
+		if err := c.creator.Ensure(ctx, target, created); err != nil {
+			return err
+		}
+	}
+	log.WithFields(log.Fields{
+		"resume": last != nil,
+		"table":  tbl,
+	}).Info("copying table")
+
+	// Tables without a key are read through a cursor, since there is
+	// no way to resume from a particular row.
+	if !q.hasKey {
+		if _, err := tx.Exec(ctx, "DECLARE snapshot_cursor NO SCROLL CURSOR FOR "+q.first); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	for {
+		var args []any
+		var sql string
+		switch {
+		case !q.hasKey:
+			sql = fmt.Sprintf("FETCH %d FROM snapshot_cursor", c.chunkSize)
+		case last == nil:
+			sql = q.first
+		default:
+			sql = q.next
+			for _, v := range last {
+				args = append(args, v)
+			}
+		}
+		chunk, err := c.readChunk(ctx, tx, sql, args, cols, tbl, target)
+		if err != nil {
+			return err
+		}
+		chunk.Done = len(chunk.Muts) < c.chunkSize
+		if chunk.Last != nil {
+			last = chunk.Last
+		}
+		if ok, err := c.progress.Send(ctx, ch, state, chunk); err != nil || !ok {
+			return err
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+}
+
+// readChunk executes the query and converts the rows into mutations.
+func (c *snapshotConn) readChunk(
+	ctx context.Context,
+	tx pgx.Tx,
+	sql string,
+	args []any,
+	cols []snapshotColumn,
+	source, target ident.Table,
+) (*logical.SnapshotChunk, error) {
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	ret := &logical.SnapshotChunk{Source: source.Raw(), Target: target}
+	for rows.Next() {
+		// Values are cast to text, to match the streaming encoding.
+		values := make([]*string, len(cols))
+		dest := make([]any, len(cols))
+		for idx := range values {
+			dest[idx] = &values[idx]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		enc := make(map[string]any, len(cols))
+		var key []string
+		for idx, col := range cols {
+			if values[idx] == nil {
+				enc[col.name] = nil
+				continue
+			}
+			enc[col.name] = *values[idx]
+			if col.primary {
+				key = append(key, *values[idx])
+			}
+		}
+
+		var mut types.Mutation
+		if len(key) == 0 {
+			// See discussion in decodeMutation.
+			mut.Key, err = json.Marshal([]string{uuid.New().String()})
+		} else {
+			ret.Last = key
+			mut.Key, err = json.Marshal(key)
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		mut.Data, err = json.Marshal(enc)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		script.AddMeta("pglogical", target, &mut)
+		ret.Muts = append(ret.Muts, mut)
+	}
+	return ret, errors.WithStack(rows.Err())
+}
+
+// exportSnapshot returns the name of a snapshot that corresponds to
+// the replication slot's consistent point. If the replication slot does
+// not exist, it will be created. The release function must be called
+// once all copying transactions have finished.
+func (c *snapshotConn) exportSnapshot(
+	ctx context.Context,
+) (name string, lsn pglogrepl.LSN, release func(), err error) {
+	conn, err := pgx.ConnectConfig(ctx, c.copyConfig)
+	if err != nil {
+		return "", 0, nil, errors.WithStack(err)
+	}
+	closeConn := func() { _ = conn.Close(context.Background()) }
+
+	var slotLSN *string
+	if err := conn.QueryRow(ctx,
+		"SELECT confirmed_flush_lsn::TEXT FROM pg_replication_slots WHERE slot_name = $1",
+		c.slotName,
+	).Scan(&slotLSN); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		closeConn()
+		return "", 0, nil, errors.WithStack(err)
+	} else if errors.Is(err, pgx.ErrNoRows) {
+		closeConn()
+		return c.createSlot(ctx)
+	}
+
+	// The slot already exists, so we'll use a new snapshot. The
+	// exporting transaction must remain open while it is in use.
+	lsn, err = c.progressLSN()
+	if err != nil {
+		closeConn()
+		return "", 0, nil, err
+	}
+	if lsn == 0 && slotLSN != nil {
+		lsn, err = pglogrepl.ParseLSN(*slotLSN)
+		if err != nil {
+			closeConn()
+			return "", 0, nil, errors.WithStack(err)
+		}
+		if err := c.setProgressLSN(ctx, lsn); err != nil {
+			closeConn()
+			return "", 0, nil, err
+		}
+	}
+	if lsn == 0 {
+		closeConn()
+		return "", 0, nil, errors.Errorf(
+			"replication slot %s has not reached a consistent point", c.slotName)
+	}
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{
+		AccessMode: pgx.ReadOnly,
+		IsoLevel:   pgx.RepeatableRead,
+	})
+	if err != nil {
+		closeConn()
+		return "", 0, nil, errors.WithStack(err)
+	}
+	if err := tx.QueryRow(ctx, "SELECT pg_export_snapshot()").Scan(&name); err != nil {
+		closeConn()
+		return "", 0, nil, errors.WithStack(err)
+	}
+	log.WithFields(log.Fields{
+		"lsn":      lsn,
+		"snapshot": name,
+	}).Info("resuming initial snapshot using existing replication slot")
+	return name, lsn, func() {
+		_ = tx.Rollback(context.Background())
+		closeConn()
+	}, nil
+}
+
+// createSlot creates the replication slot and exports its snapshot.
+// The replication connection must remain idle while the snapshot is
+// in use.
+func (c *snapshotConn) createSlot(
+	ctx context.Context,
+) (name string, lsn pglogrepl.LSN, release func(), err error) {
+	replConn, err := pgconn.ConnectConfig(ctx, c.sourceConfig)
+	if err != nil {
+		return "", 0, nil, errors.WithStack(err)
+	}
+	closeConn := func() { _ = replConn.Close(context.Background()) }
+
+	res, err := pglogrepl.CreateReplicationSlot(ctx, replConn, c.slotName, "pgoutput",
+		pglogrepl.CreateReplicationSlotOptions{
+			Mode:           pglogrepl.LogicalReplication,
+			SnapshotAction: "EXPORT_SNAPSHOT",
+		})
+	if err != nil {
+		closeConn()
+		return "", 0, nil, errors.Wrapf(err, "could not create replication slot %s", c.slotName)
+	}
+	lsn, err = pglogrepl.ParseLSN(res.ConsistentPoint)
+	if err != nil {
+		closeConn()
+		return "", 0, nil, errors.WithStack(err)
+	}
+	// Persist the consistent point, in case the copy is interrupted.
+	if err := c.setProgressLSN(ctx, lsn); err != nil {
+		closeConn()
+		return "", 0, nil, err
+	}
+	log.WithFields(log.Fields{
+		"lsn":      lsn,
+		"slot":     c.slotName,
+		"snapshot": res.SnapshotName,
+	}).Info("created replication slot for initial snapshot")
+	return res.SnapshotName, lsn, closeConn, nil
+}
+
+// publishedTables returns the tables in the publication.
+func (c *snapshotConn) publishedTables(ctx context.Context) ([]ident.Table, error) {
+	conn, err := pgx.ConnectConfig(ctx, c.copyConfig)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer conn.Close(context.Background())
+
+	rows, err := conn.Query(ctx,
+		"SELECT schemaname, tablename FROM pg_publication_tables "+
+			"WHERE pubname = $1 ORDER BY schemaname, tablename",
+		c.publicationName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	var ret []ident.Table
+	for rows.Next() {
+		var sch, tbl string
+		if err := rows.Scan(&sch, &tbl); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret = append(ret, ident.NewTable(ident.MustSchema(ident.New(sch)), ident.New(tbl)))
+	}
+	return ret, errors.WithStack(rows.Err())
+}
+
+// progressLSN returns the consistent point recorded by setProgressLSN,
+// or zero if none has been recorded.
+func (c *snapshotConn) progressLSN() (pglogrepl.LSN, error) {
+	point, ok := c.progress.Point()
+	if !ok {
+		return 0, nil
+	}
+	lsn, err := pglogrepl.ParseLSN(point)
+	return lsn, errors.WithStack(err)
+}
+
+// setProgressLSN records the snapshot's consistent point.
+func (c *snapshotConn) setProgressLSN(ctx context.Context, lsn pglogrepl.LSN) error {
+	return c.progress.SetPoint(ctx, lsn.String())
+}
+
+// snapshotColumns returns the replicated columns of the table, in
+// the order used by the replication stream.
+func snapshotColumns(ctx context.Context, tx pgx.Tx, tbl ident.Table) ([]snapshotColumn, error) {
+	const q = `
+SELECT a.attname,
+       COALESCE(a.attnum = ANY(i.indkey), false),
+       format_type(a.atttypid, a.atttypmod)
+  FROM pg_attribute a
+  LEFT JOIN pg_index i ON i.indrelid = a.attrelid AND i.indisprimary
+ WHERE a.attrelid = $1::REGCLASS
+   AND a.attnum > 0
+   AND NOT a.attisdropped
+   AND a.attgenerated = ''
+ ORDER BY a.attnum`
+	rows, err := tx.Query(ctx, q, quoteTable(tbl))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	var ret []snapshotColumn
+	for rows.Next() {
+		var col snapshotColumn
+		if err := rows.Scan(&col.name, &col.primary, &col.typ); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret = append(ret, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(ret) == 0 {
+		return nil, errors.Errorf("no columns found for %s", tbl)
+	}
+	return ret, nil
+}
+
+// chunkQuery contains the SQL used to read a table.
+type chunkQuery struct {
+	first  string // Reads the first chunk, or the entire table if no key.
+	hasKey bool   // If false, the table must be read in a single pass.
+	next   string // Reads the chunk after the key given as arguments.
+}
+
+// newChunkQuery generates SQL to read the table in key order.
+func newChunkQuery(tbl ident.Table, cols []snapshotColumn, limit int) *chunkQuery {
+	var selects, keys, params []string
+	for _, col := range cols {
+		name := pgx.Identifier{col.name}.Sanitize()
+		selects = append(selects, name+"::TEXT")
+		if col.primary {
+			keys = append(keys, name)
+			params = append(params, fmt.Sprintf("$%d::TEXT::%s", len(params)+1, col.typ))
+		}
+	}
+	from := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), quoteTable(tbl))
+
+	if len(keys) == 0 {
+		return &chunkQuery{first: from}
+	}
+	order := fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(keys, ", "), limit)
+	return &chunkQuery{
+		first:  from + order,
+		hasKey: true,
+		next: fmt.Sprintf("%s WHERE (%s) > (%s)%s", from,
+			strings.Join(keys, ", "), strings.Join(params, ", "), order),
+	}
+}
+
+// quoteTable returns a quoted, schema-qualified table name.
+func quoteTable(tbl ident.Table) string {
+	return pgx.Identifier{tbl.Schema().Raw(), tbl.Table().Raw()}.Sanitize()
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/base"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Verify that the initial snapshot copies existing rows, in several
+// chunks, and then continues with streaming replication.
+func TestInitialSnapshot(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	fixture, err := base.NewFixture(t)
+	r.NoError(err)
+
+	ctx := fixture.Context
+	dbSchema := fixture.TargetSchema.Schema()
+	dbName := dbSchema.Idents(nil)[0] // Extract first name part.
+	crdbPool := fixture.TargetPool
+
+	pgPool, cancel, err := setupPGPool(dbName)
+	r.NoError(err)
+	defer cancel()
+
+	keyed := ident.NewTable(dbSchema, ident.New("keyed"))
+	keyless := ident.NewTable(dbSchema, ident.New("keyless"))
+	for _, schema := range []string{
+		fmt.Sprintf("CREATE TABLE %s (a INT, b TEXT, v TEXT, PRIMARY KEY (b, a))", keyed),
+		fmt.Sprintf("CREATE TABLE %s (v TEXT)", keyless),
+	} {
+		_, err := pgPool.Exec(ctx, schema)
+		r.NoError(err)
+		_, err = crdbPool.ExecContext(ctx, schema)
+		r.NoError(err)
+	}
+
+	const rowCount = 100
+	for i := 0; i < rowCount; i++ {
+		_, err := pgPool.Exec(ctx,
+			fmt.Sprintf("INSERT INTO %s VALUES ($1, $2, 'v')", keyed), i%10, fmt.Sprintf("b%d", i/10))
+		r.NoError(err)
+		_, err = pgPool.Exec(ctx,
+			fmt.Sprintf("INSERT INTO %s VALUES ($1)", keyless), fmt.Sprintf("v%d", i))
+		r.NoError(err)
+	}
+
+	// The snapshot will create the replication slot.
+	cancel, err = setupPublication(ctx, pgPool, dbName, "ALL TABLES")
+	r.NoError(err)
+	defer cancel()
+	pubNameRaw := publicationName(dbName).Raw()
+	_, err = pgPool.Exec(ctx, "SELECT pg_drop_replication_slot($1)", pubNameRaw)
+	r.NoError(err)
+
+	repl, err := Start(ctx, &Config{
+		BaseConfig: logical.BaseConfig{
+			BackfillWindow: time.Minute,
+			RetryDelay:     time.Millisecond,
+			StagingSchema:  fixture.StagingDB.Schema(),
+			StandbyTimeout: 100 * time.Millisecond,
+			TargetConn:     crdbPool.ConnectionString,
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:     "pglogicaltest",
+			TargetSchema: dbSchema,
+		},
+		InitialSnapshot:     true,
+		Publication:         pubNameRaw,
+		Slot:                pubNameRaw,
+		SnapshotChunkSize:   7,
+		SnapshotParallelism: 2,
+		SourceConn:          *pgConnString + dbName.Raw(),
+	})
+	r.NoError(err)
+
+	waitFor := func(tbl ident.Table, expected int) {
+		for {
+			count, err := base.GetRowCount(ctx, crdbPool, tbl)
+			r.NoError(err)
+			if count == expected {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	waitFor(keyed, rowCount)
+	waitFor(keyless, rowCount)
+
+	// The progress for each table should have been recorded.
+	conn := repl.Loop.Dialect().(*snapshotConn)
+	for _, tbl := range []ident.Table{keyed, keyless} {
+		// Progress is keyed by the source table's name.
+		source := ident.NewTable(ident.MustSchema(ident.Public), tbl.Table())
+		if progress := conn.progress.Table(source.Raw()); a.NotNil(progress) {
+			a.True(progress.Done)
+		}
+	}
+	lsn, err := conn.progressLSN()
+	r.NoError(err)
+	a.NotZero(lsn)
+
+	// Changes made after the snapshot are streamed.
+	_, err = pgPool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE a < 5", keyed))
+	r.NoError(err)
+	waitFor(keyed, rowCount/2)
+}
+
+func TestChunkQuery(t *testing.T) {
+	a := assert.New(t)
+
+	tbl := ident.NewTable(ident.MustSchema(ident.New("public")), ident.New("my_table"))
+	q := newChunkQuery(tbl, []snapshotColumn{
+		{name: "a", primary: true, typ: "integer"},
+		{name: "Value", typ: "text"},
+		{name: "b", primary: true, typ: "character varying(10)"},
+	}, 100)
+	a.True(q.hasKey)
+	a.Equal(`SELECT "a"::TEXT, "Value"::TEXT, "b"::TEXT FROM "public"."my_table" `+
+		`ORDER BY "a", "b" LIMIT 100`, q.first)
+	a.Equal(`SELECT "a"::TEXT, "Value"::TEXT, "b"::TEXT FROM "public"."my_table" `+
+		`WHERE ("a", "b") > ($1::TEXT::integer, $2::TEXT::character varying(10)) `+
+		`ORDER BY "a", "b" LIMIT 100`, q.next)
+
+	q = newChunkQuery(tbl, []snapshotColumn{{name: "v", typ: "text"}}, 100)
+	a.False(q.hasKey)
+	a.Equal(`SELECT "v"::TEXT FROM "public"."my_table"`, q.first)
+}
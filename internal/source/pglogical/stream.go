@@ -0,0 +1,333 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+// This file contains support for logical replication protocol version
+// 2, which allows the source database to send the contents of a large
+// transaction before it has committed.
+//
+// https://www.postgresql.org/docs/current/protocol-logicalrep-message-formats.html
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Message types which are only sent when streaming is enabled.
+const (
+	streamAbortByte  = 'A'
+	streamCommitByte = 'c'
+	streamStartByte  = 'S'
+	streamStopByte   = 'E'
+)
+
+// errStopping is returned by an emit callback to indicate that the
+// reader should exit without an error.
+var errStopping = errors.New("stopping")
+
+// microsecFromUnixEpochToY2K is the offset of the Postgres epoch.
+const microsecFromUnixEpochToY2K = 946684800 * 1000000
+
+// A streamReader reassembles in-progress transactions. The messages
+// for each transaction are spooled until the transaction commits, at
+// which point they are replayed as though the transaction had been
+// sent in a single piece. Aborted transactions are discarded.
+type streamReader struct {
+	dir   string // Directory for spool files, or empty for the default.
+	limit int    // Spill to disk after this many bytes.
+
+	current *spool            // The stream block being read, if any.
+	spools  map[uint32]*spool // Open transactions, by top-level xid.
+}
+
+// newStreamReader constructs a streamReader.
+func newStreamReader(dir string, limit int) *streamReader {
+	return &streamReader{
+		dir:    dir,
+		limit:  limit,
+		spools: make(map[uint32]*spool),
+	}
+}
+
+// accept consumes stream-control messages and the data messages within
+// a stream block. It returns false if the message should be processed
+// normally. The contents of a committed transaction are passed to the
+// emit callback.
+func (r *streamReader) accept(data []byte, emit func(pglogrepl.Message) error) (bool, error) {
+	switch data[0] {
+	case streamStartByte:
+		if len(data) < 6 {
+			return false, errors.New("short StreamStart message")
+		}
+		xid := binary.BigEndian.Uint32(data[1:])
+		sp, ok := r.spools[xid]
+		if !ok {
+			sp = &spool{aborted: make(map[uint32]struct{}), xid: xid}
+			r.spools[xid] = sp
+			streamedTransactions.Inc()
+		}
+		r.current = sp
+		log.WithField("xid", xid).Trace("stream start")
+		return true, nil
+
+	case streamStopByte:
+		r.current = nil
+		return true, nil
+
+	case streamCommitByte:
+		if len(data) < 30 {
+			return false, errors.New("short StreamCommit message")
+		}
+		xid := binary.BigEndian.Uint32(data[1:])
+		// data[5] is an unused flags byte.
+		commitLSN := pglogrepl.LSN(binary.BigEndian.Uint64(data[6:]))
+		endLSN := pglogrepl.LSN(binary.BigEndian.Uint64(data[14:]))
+		micros := int64(binary.BigEndian.Uint64(data[22:]))
+		commitTime := time.UnixMicro(micros + microsecFromUnixEpochToY2K)
+
+		sp, ok := r.spools[xid]
+		if !ok {
+			// A transaction may have had no changes to stream.
+			sp = &spool{aborted: make(map[uint32]struct{}), xid: xid}
+		}
+		delete(r.spools, xid)
+		defer sp.Close()
+		log.WithFields(log.Fields{
+			"commitLSN": commitLSN,
+			"xid":       xid,
+		}).Debug("replaying streamed transaction")
+		return true, sp.replay(commitLSN, endLSN, commitTime, emit)
+
+	case streamAbortByte:
+		if len(data) < 9 {
+			return false, errors.New("short StreamAbort message")
+		}
+		xid := binary.BigEndian.Uint32(data[1:])
+		subXid := binary.BigEndian.Uint32(data[5:])
+		sp, ok := r.spools[xid]
+		if !ok {
+			return true, nil
+		}
+		if xid == subXid {
+			delete(r.spools, xid)
+			log.WithField("xid", xid).Debug("discarding aborted streamed transaction")
+			return true, sp.Close()
+		}
+		// Only a subtransaction has aborted.
+		sp.aborted[subXid] = struct{}{}
+		return true, nil
+
+	default:
+		if r.current == nil {
+			return false, nil
+		}
+		// Messages within a stream block contain the xid of the
+		// (sub-)transaction after the type byte. We remove it, so that
+		// the message can be parsed as in protocol version 1.
+		if len(data) < 5 {
+			return false, errors.Errorf("short streamed message %q", data[0])
+		}
+		subXid := binary.BigEndian.Uint32(data[1:])
+		msg := make([]byte, 0, len(data)-4)
+		msg = append(msg, data[0])
+		msg = append(msg, data[5:]...)
+		return true, r.current.add(r.dir, r.limit, subXid, msg)
+	}
+}
+
+// Close releases any spool files.
+func (r *streamReader) Close() {
+	for xid, sp := range r.spools {
+		if err := sp.Close(); err != nil {
+			log.WithError(err).Warn("could not remove spool file")
+		}
+		delete(r.spools, xid)
+	}
+	r.current = nil
+}
+
+// A spoolEntry is a message from a (sub-)transaction.
+type spoolEntry struct {
+	data   []byte
+	subXid uint32
+}
+
+// A spool accumulates the messages for a single transaction. Messages
+// are retained in memory until a size limit is reached, after which all
+// messages are written to a temporary file.
+type spool struct {
+	aborted map[uint32]struct{} // Aborted subtransactions.
+	entries []spoolEntry        // Messages held in memory.
+	file    *os.File            // Non-nil once spilled to disk.
+	size    int                 // Number of bytes in memory.
+	writer  *bufio.Writer       // Writes to file.
+	xid     uint32              // The top-level transaction id.
+}
+
+// add appends the message to the spool, spilling to disk if the size
+// limit has been exceeded.
+func (s *spool) add(dir string, limit int, subXid uint32, data []byte) error {
+	if s.file != nil {
+		return s.write(spoolEntry{data, subXid})
+	}
+	s.entries = append(s.entries, spoolEntry{data, subXid})
+	s.size += len(data)
+	if s.size <= limit {
+		return nil
+	}
+
+	f, err := os.CreateTemp(dir, "cdc-sink-stream-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.file = f
+	s.writer = bufio.NewWriter(f)
+	streamSpillCount.Inc()
+	log.WithFields(log.Fields{
+		"file": f.Name(),
+		"xid":  s.xid,
+	}).Debug("spilling streamed transaction to disk")
+	for _, entry := range s.entries {
+		if err := s.write(entry); err != nil {
+			return err
+		}
+	}
+	s.entries = nil
+	s.size = 0
+	return nil
+}
+
+// write appends a length-prefixed entry to the spool file.
+func (s *spool) write(entry spoolEntry) error {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[0:], uint32(len(entry.data)))
+	binary.BigEndian.PutUint32(hdr[4:], entry.subXid)
+	if _, err := s.writer.Write(hdr[:]); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := s.writer.Write(entry.data)
+	streamSpillBytes.Add(float64(len(entry.data)))
+	return errors.WithStack(err)
+}
+
+// replay sends the contents of the spool, bracketed by synthetic
+// begin and commit messages. Messages from aborted subtransactions are
+// skipped.
+func (s *spool) replay(
+	commitLSN, endLSN pglogrepl.LSN, commitTime time.Time, emit func(pglogrepl.Message) error,
+) error {
+	if err := emit(&pglogrepl.BeginMessage{
+		FinalLSN:   commitLSN,
+		CommitTime: commitTime,
+		Xid:        s.xid,
+	}); err != nil {
+		return err
+	}
+
+	send := func(entry spoolEntry) error {
+		if _, aborted := s.aborted[entry.subXid]; aborted {
+			return nil
+		}
+		msg, err := parseMessage(entry.data)
+		if err != nil {
+			return err
+		}
+		return emit(msg)
+	}
+
+	if s.file == nil {
+		for _, entry := range s.entries {
+			if err := send(entry); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := s.writer.Flush(); err != nil {
+			return errors.WithStack(err)
+		}
+		if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+			return errors.WithStack(err)
+		}
+		reader := bufio.NewReader(s.file)
+		var hdr [8]byte
+		for {
+			if _, err := io.ReadFull(reader, hdr[:]); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return errors.WithStack(err)
+			}
+			data := make([]byte, binary.BigEndian.Uint32(hdr[0:]))
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return errors.WithStack(err)
+			}
+			if err := send(spoolEntry{data, binary.BigEndian.Uint32(hdr[4:])}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return emit(&pglogrepl.CommitMessage{
+		CommitLSN:         commitLSN,
+		TransactionEndLSN: endLSN,
+		CommitTime:        commitTime,
+	})
+}
+
+// Close removes the spool file, if one was created.
+func (s *spool) Close() error {
+	s.entries = nil
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	_ = s.file.Close()
+	s.file = nil
+	s.writer = nil
+	return errors.WithStack(os.Remove(name))
+}
+
+// parseMessage wraps pglogrepl.Parse, which does not return an error
+// for unknown message types.
+func parseMessage(data []byte) (pglogrepl.Message, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty logical replication message")
+	}
+	switch pglogrepl.MessageType(data[0]) {
+	case pglogrepl.MessageTypeBegin,
+		pglogrepl.MessageTypeCommit,
+		pglogrepl.MessageTypeOrigin,
+		pglogrepl.MessageTypeRelation,
+		pglogrepl.MessageTypeType,
+		pglogrepl.MessageTypeInsert,
+		pglogrepl.MessageTypeUpdate,
+		pglogrepl.MessageTypeDelete,
+		pglogrepl.MessageTypeTruncate,
+		pglogrepl.MessageTypeMessage:
+		msg, err := pglogrepl.Parse(data)
+		return msg, errors.WithStack(err)
+	default:
+		return nil, errors.Errorf("unknown logical replication message type %q", data[0])
+	}
+}
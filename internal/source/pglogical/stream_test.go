@@ -0,0 +1,131 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamMsg builds a protocol message from a type byte and fields.
+func streamMsg(typ byte, fields ...any) []byte {
+	ret := []byte{typ}
+	for _, f := range fields {
+		switch t := f.(type) {
+		case byte:
+			ret = append(ret, t)
+		case uint32:
+			ret = binary.BigEndian.AppendUint32(ret, t)
+		case uint64:
+			ret = binary.BigEndian.AppendUint64(ret, t)
+		case string:
+			ret = append(ret, t...)
+		default:
+			panic(t)
+		}
+	}
+	return ret
+}
+
+// streamedInsert builds an InsertMessage for a single-column table,
+// with the xid used within a stream block.
+func streamedInsert(xid uint32, value string) []byte {
+	return streamMsg('I', xid, uint32(1), byte('N'),
+		string([]byte{0, 1}), byte('t'), uint32(len(value)), value)
+}
+
+func TestStreamReader(t *testing.T) {
+	for _, limit := range []int{0, 1 << 20} {
+		name := "memory"
+		if limit == 0 {
+			name = "spill"
+		}
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+			dir := t.TempDir()
+
+			var received []pglogrepl.Message
+			emit := func(msg pglogrepl.Message) error {
+				received = append(received, msg)
+				return nil
+			}
+			reader := newStreamReader(dir, limit)
+			defer reader.Close()
+			accept := func(data []byte) bool {
+				ok, err := reader.accept(data, emit)
+				r.NoError(err)
+				return ok
+			}
+
+			// Two interleaved transactions, one of which has an
+			// aborted subtransaction.
+			a.True(accept(streamMsg(streamStartByte, uint32(100), byte(1))))
+			a.True(accept(streamedInsert(100, "a")))
+			a.True(accept(streamedInsert(101, "aborted")))
+			a.True(accept(streamMsg(streamStopByte)))
+			a.True(accept(streamMsg(streamStartByte, uint32(200), byte(1))))
+			a.True(accept(streamedInsert(200, "discarded")))
+			a.True(accept(streamMsg(streamStopByte)))
+
+			// Messages outside a stream block are not consumed.
+			a.False(accept(streamMsg('B')))
+
+			a.True(accept(streamMsg(streamStartByte, uint32(100), byte(0))))
+			a.True(accept(streamedInsert(100, "b")))
+			a.True(accept(streamMsg(streamStopByte)))
+
+			a.True(accept(streamMsg(streamAbortByte, uint32(100), uint32(101))))
+			a.True(accept(streamMsg(streamAbortByte, uint32(200), uint32(200))))
+			a.Empty(received)
+
+			commitTime := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+			a.True(accept(streamMsg(streamCommitByte, uint32(100), byte(0),
+				uint64(1000), uint64(1010),
+				uint64(commitTime.UnixMicro()-microsecFromUnixEpochToY2K))))
+
+			r.Len(received, 4)
+			if begin, ok := received[0].(*pglogrepl.BeginMessage); a.True(ok) {
+				a.Equal(pglogrepl.LSN(1000), begin.FinalLSN)
+				a.Equal(uint32(100), begin.Xid)
+				a.True(commitTime.Equal(begin.CommitTime))
+			}
+			for idx, expected := range []string{"a", "b"} {
+				if ins, ok := received[idx+1].(*pglogrepl.InsertMessage); a.True(ok) {
+					a.Equal(uint32(1), ins.RelationID)
+					a.Equal(expected, string(ins.Tuple.Columns[0].Data))
+				}
+			}
+			if commit, ok := received[3].(*pglogrepl.CommitMessage); a.True(ok) {
+				a.Equal(pglogrepl.LSN(1000), commit.CommitLSN)
+				a.Equal(pglogrepl.LSN(1010), commit.TransactionEndLSN)
+			}
+
+			// All spool files should have been removed.
+			a.Empty(reader.spools)
+			files, err := os.ReadDir(dir)
+			r.NoError(err)
+			a.Empty(files)
+		})
+	}
+}
@@ -10,9 +10,12 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
 	"github.com/cockroachdb/cdc-sink/internal/staging/memo"
+	"github.com/cockroachdb/cdc-sink/internal/staging/stage"
 	"github.com/cockroachdb/cdc-sink/internal/staging/version"
 	"github.com/cockroachdb/cdc-sink/internal/target/apply"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
@@ -33,10 +36,6 @@ func Start(ctx *stopper.Context, config *Config) (*PGLogical, error) {
 	if err != nil {
 		return nil, err
 	}
-	dialect, err := ProvideDialect(ctx, config, loader)
-	if err != nil {
-		return nil, err
-	}
 	baseConfig, err := logical.ProvideBaseConfig(config, loader)
 	if err != nil {
 		return nil, err
@@ -59,7 +58,12 @@ func Start(ctx *stopper.Context, config *Config) (*PGLogical, error) {
 		return nil, err
 	}
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, err := apply.ProvideFactory(ctx, targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	evolveConfig := logical.ProvideEvolveConfig(baseConfig)
+	evolver, err := evolve.ProvideEvolver(ctx, evolveConfig, diagnostics, targetPool, watchers)
+	if err != nil {
+		return nil, err
+	}
+	appliers, err := apply.ProvideFactory(ctx, targetStatements, configs, diagnostics, dlQs, evolver, targetPool, watchers)
 	if err != nil {
 		return nil, err
 	}
@@ -75,8 +79,14 @@ func Start(ctx *stopper.Context, config *Config) (*PGLogical, error) {
 	if err != nil {
 		return nil, err
 	}
+	creator := autocreate.ProvideCreator(targetPool, watchers)
+	dialect, err := ProvideDialect(ctx, config, creator, evolver, memoMemo, stagingPool, loader)
+	if err != nil {
+		return nil, err
+	}
+	stagers := stage.ProvideFactory(stagingPool, stagingSchema, ctx)
 	checker := version.ProvideChecker(stagingPool, memoMemo)
-	factory, err := logical.ProvideFactory(ctx, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagingPool, targetPool, watchers, checker)
+	factory, err := logical.ProvideFactory(ctx, appliers, configs, baseConfig, diagnostics, memoMemo, loader, stagers, stagingPool, targetPool, watchers, checker)
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +96,52 @@ func Start(ctx *stopper.Context, config *Config) (*PGLogical, error) {
 	}
 	pgLogical := &PGLogical{
 		Diagnostics: diagnostics,
+		Factory:     factory,
+		Loop:        loop,
+	}
+	return pgLogical, nil
+}
+
+// StartShared creates a PostgreSQL logical replication loop which uses
+// resources that are shared with other loops in the process.
+func StartShared(ctx *stopper.Context, config *Config, diags *diag.Diagnostics, shared *logical.Shared) (*PGLogical, error) {
+	creator := shared.Creator
+	evolver := shared.Evolver
+	memoMemo := shared.Memo
+	stagingPool := shared.StagingPool
+	scriptConfig, err := logical.ProvideUserScriptConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	loader, err := script.ProvideLoader(scriptConfig)
+	if err != nil {
+		return nil, err
+	}
+	dialect, err := ProvideDialect(ctx, config, creator, evolver, memoMemo, stagingPool, loader)
+	if err != nil {
+		return nil, err
+	}
+	appliers := shared.Appliers
+	configs := shared.Configs
+	baseConfig, err := logical.ProvideBaseConfig(config, loader)
+	if err != nil {
+		return nil, err
+	}
+	stagers := shared.Stagers
+	targetPool := shared.TargetPool
+	watchers := shared.Watchers
+	checker := shared.VersionChecker
+	factory, err := logical.ProvideFactory(ctx, appliers, configs, baseConfig, diags, memoMemo, loader, stagers, stagingPool, targetPool, watchers, checker)
+	if err != nil {
+		return nil, err
+	}
+	loop, err := ProvideLoop(config, dialect, factory)
+	if err != nil {
+		return nil, err
+	}
+	pgLogical := &PGLogical{
+		Diagnostics: diags,
+		Factory:     factory,
 		Loop:        loop,
 	}
 	return pgLogical, nil
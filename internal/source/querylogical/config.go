@@ -0,0 +1,113 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package querylogical
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultBatchSize    = 10_000
+	defaultPollInterval = time.Second
+)
+
+// Config contains the configuration necessary for polling a source
+// database. SourceConn, SourceSchema, and Tables are mandatory.
+type Config struct {
+	logical.BaseConfig
+	logical.LoopConfig
+
+	// The maximum number of rows to read from a table in each query.
+	BatchSize int
+	// If non-zero, the primary keys of each source table will be
+	// compared with this frequency to detect deleted rows.
+	DeleteCheckInterval time.Duration
+	// The time to wait before querying a table that has no new rows.
+	PollInterval time.Duration
+	// Connection string for the source db.
+	SourceConn string
+	// The schema that contains the tables to poll.
+	SourceSchema ident.Schema
+	// The names of the tables to poll, relative to SourceSchema.
+	Tables []string
+	// The name of a column that is updated whenever a row changes.
+	UpdatedAtColumn ident.Ident
+}
+
+// Bind adds flags to the set. It delegates to the embedded Config.Bind.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.BaseConfig.Bind(f)
+
+	c.LoopConfig.LoopName = "querylogical"
+	c.LoopConfig.Bind(f)
+
+	f.IntVar(&c.BatchSize, "pollBatchSize", defaultBatchSize,
+		"the maximum number of rows to read from a source table in each query")
+	f.DurationVar(&c.DeleteCheckInterval, "deleteCheckInterval", 0,
+		"if non-zero, compare the primary keys of each source table at this "+
+			"interval to detect deleted rows")
+	f.DurationVar(&c.PollInterval, "pollInterval", defaultPollInterval,
+		"the time to wait before querying a source table that has no new rows")
+	f.StringVar(&c.SourceConn, "sourceConn", "",
+		"the source database's connection string")
+	f.Var(ident.NewSchemaFlag(&c.SourceSchema), "sourceSchema",
+		"the SQL database schema that contains the source tables")
+	f.StringSliceVar(&c.Tables, "table", nil,
+		"the name of a source table to poll; may be repeated")
+	f.Var(ident.NewValue("updated_at", &c.UpdatedAtColumn), "updatedAt",
+		"the name of a column that is updated whenever a source row changes")
+}
+
+// Preflight updates the configuration with sane defaults or returns an
+// error if there are missing options for which a default cannot be
+// provided.
+func (c *Config) Preflight() error {
+	if err := c.BaseConfig.Preflight(); err != nil {
+		return err
+	}
+	if err := c.LoopConfig.Preflight(); err != nil {
+		return err
+	}
+
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.DeleteCheckInterval < 0 {
+		return errors.New("deleteCheckInterval must not be negative")
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if c.SourceConn == "" {
+		return errors.New("no SourceConn was configured")
+	}
+	if c.SourceSchema.Empty() {
+		return errors.New("no SourceSchema was configured")
+	}
+	if len(c.Tables) == 0 {
+		return errors.New("no source tables were configured")
+	}
+	if c.UpdatedAtColumn.Empty() {
+		return errors.New("no updated_at column name given")
+	}
+	return nil
+}
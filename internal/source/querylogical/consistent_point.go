@@ -0,0 +1,138 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package querylogical
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+)
+
+// A consistentPoint is the high-water mark of a polled table. Rows are
+// read in (updated_at, key) order, so the key of the last row read is
+// necessary to resume reading when many rows share the same timestamp.
+type consistentPoint struct {
+	// The updated_at value of the last row read.
+	Time time.Time `json:"t,omitempty"`
+	// The primary key of the last row read, as a JSON array.
+	Key json.RawMessage `json:"k,omitempty"`
+}
+
+var (
+	_ logical.TimeStamp = (*consistentPoint)(nil)
+	_ stamp.Stamp       = (*consistentPoint)(nil)
+)
+
+// ZeroStamp returns an empty high-water mark, into which a stored
+// consistent point may be decoded.
+func ZeroStamp() stamp.Stamp { return &consistentPoint{} }
+
+// AsTime implements logical.TimeStamp.
+func (p *consistentPoint) AsTime() time.Time {
+	return p.Time
+}
+
+// IsZero returns true if no rows have been read.
+func (p *consistentPoint) IsZero() bool {
+	return p.Time.IsZero() && len(p.Key) == 0
+}
+
+// Less implements stamp.Stamp.
+func (p *consistentPoint) Less(other stamp.Stamp) bool {
+	o := other.(*consistentPoint)
+	if p.Time.Before(o.Time) {
+		return true
+	}
+	if p.Time.After(o.Time) {
+		return false
+	}
+	return compareKeys(p.Key, o.Key) < 0
+}
+
+// compareKeys orders two JSON-encoded keys in the same way as the
+// ORDER BY clause of the poll queries: element by element, with
+// numbers compared numerically and strings compared lexically. Keys
+// that cannot be decoded fall back to a byte comparison.
+func compareKeys(a, b json.RawMessage) int {
+	aVals, aErr := decodeKeyValues(a)
+	bVals, bErr := decodeKeyValues(b)
+	if aErr != nil || bErr != nil {
+		return bytes.Compare(a, b)
+	}
+	for idx := 0; idx < len(aVals) && idx < len(bVals); idx++ {
+		if c := compareKeyValues(aVals[idx], bVals[idx]); c != 0 {
+			return c
+		}
+	}
+	return len(aVals) - len(bVals)
+}
+
+// decodeKeyValues decodes a JSON array, preserving numbers.
+func decodeKeyValues(data json.RawMessage) ([]any, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var ret []any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(&ret)
+	return ret, err
+}
+
+// compareKeyValues compares two decoded key elements. Values of
+// different types are ordered nulls, numbers, strings, then anything
+// else.
+func compareKeyValues(a, b any) int {
+	if c := keyValueRank(a) - keyValueRank(b); c != 0 {
+		return c
+	}
+	switch a := a.(type) {
+	case json.Number:
+		aNum, aOK := new(big.Rat).SetString(a.String())
+		bNum, bOK := new(big.Rat).SetString(b.(json.Number).String())
+		if aOK && bOK {
+			return aNum.Cmp(bNum)
+		}
+		return strings.Compare(a.String(), b.(json.Number).String())
+	case string:
+		return strings.Compare(a, b.(string))
+	case nil:
+		return 0
+	default:
+		aBytes, _ := json.Marshal(a)
+		bBytes, _ := json.Marshal(b)
+		return bytes.Compare(aBytes, bBytes)
+	}
+}
+
+func keyValueRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case json.Number:
+		return 1
+	case string:
+		return 2
+	default:
+		return 3
+	}
+}
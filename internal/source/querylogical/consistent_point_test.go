@@ -0,0 +1,65 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package querylogical
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistentPointLess(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0).UTC()
+	later := now.Add(time.Second)
+
+	tcs := []struct {
+		name     string
+		a, b     consistentPoint
+		expected bool
+	}{
+		{"earlier time", consistentPoint{Time: now, Key: json.RawMessage(`[2]`)},
+			consistentPoint{Time: later, Key: json.RawMessage(`[1]`)}, true},
+		{"later time", consistentPoint{Time: later, Key: json.RawMessage(`[1]`)},
+			consistentPoint{Time: now, Key: json.RawMessage(`[2]`)}, false},
+		{"multi-digit numbers", consistentPoint{Time: now, Key: json.RawMessage(`[900]`)},
+			consistentPoint{Time: now, Key: json.RawMessage(`[1000]`)}, true},
+		{"multi-digit numbers reversed", consistentPoint{Time: now, Key: json.RawMessage(`[1000]`)},
+			consistentPoint{Time: now, Key: json.RawMessage(`[900]`)}, false},
+		{"decimals", consistentPoint{Time: now, Key: json.RawMessage(`[9.5]`)},
+			consistentPoint{Time: now, Key: json.RawMessage(`[10]`)}, true},
+		{"negative", consistentPoint{Time: now, Key: json.RawMessage(`[-10]`)},
+			consistentPoint{Time: now, Key: json.RawMessage(`[-9]`)}, true},
+		{"strings", consistentPoint{Time: now, Key: json.RawMessage(`["1000"]`)},
+			consistentPoint{Time: now, Key: json.RawMessage(`["900"]`)}, true},
+		{"second element", consistentPoint{Time: now, Key: json.RawMessage(`[1, 99]`)},
+			consistentPoint{Time: now, Key: json.RawMessage(`[1, 100]`)}, true},
+		{"first element wins", consistentPoint{Time: now, Key: json.RawMessage(`[2, 1]`)},
+			consistentPoint{Time: now, Key: json.RawMessage(`[10, 0]`)}, true},
+		{"equal", consistentPoint{Time: now, Key: json.RawMessage(`[1, "a"]`)},
+			consistentPoint{Time: now, Key: json.RawMessage(`[1,"a"]`)}, false},
+		{"empty key", consistentPoint{Time: now},
+			consistentPoint{Time: now, Key: json.RawMessage(`[1]`)}, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.a.Less(&tc.b))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build wireinject
+// +build wireinject
+
+package querylogical
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/base"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/staging"
+	"github.com/cockroachdb/cdc-sink/internal/target"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/google/wire"
+)
+
+// Start creates a polling logical replication loop for each configured
+// table using the provided configuration.
+func Start(ctx *stopper.Context, config *Config) (*QueryLogical, error) {
+	panic(wire.Build(
+		wire.Bind(new(context.Context), new(*stopper.Context)),
+		wire.Bind(new(logical.Config), new(*Config)),
+		wire.Struct(new(QueryLogical), "*"),
+		Set,
+		diag.New,
+		logical.Set,
+		script.Set,
+		staging.Set,
+		target.Set,
+	))
+}
+
+// Build remaining testable components from a common fixture.
+func startLoopsFromFixture(*all.Fixture, *Config) ([]*logical.Loop, error) {
+	panic(wire.Build(
+		wire.Bind(new(logical.Config), new(*Config)),
+		wire.FieldsOf(new(*base.Fixture), "Context", "SourcePool"),
+		wire.FieldsOf(new(*all.Fixture),
+			"Fixture", "Configs", "Memo", "Stagers", "VersionChecker"),
+		ProvideLoops,
+		diag.New,
+		logical.Set,
+		script.Set,
+		target.Set,
+	))
+}
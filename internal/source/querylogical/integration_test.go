@@ -0,0 +1,136 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package querylogical
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/base"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolling(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	const rowCount = 100
+
+	fixture, err := all.NewFixture(t)
+	r.NoError(err)
+	ctx := fixture.Context
+
+	if fixture.SourcePool.Product == types.ProductOracle {
+		t.Skip("the test schema is not compatible with oracle")
+	}
+
+	const schema = "CREATE TABLE %s (id INT PRIMARY KEY, v VARCHAR(2048), updated_at TIMESTAMP)"
+	src, err := fixture.CreateSourceTable(ctx, schema)
+	r.NoError(err)
+
+	// The target table has the same name as the source table.
+	dest := base.NewTableInfo(fixture.TargetPool,
+		ident.NewTable(fixture.TargetSchema.Schema(), src.Name().Table()))
+	r.NoError(dest.Exec(ctx, schema))
+
+	// Insert all rows with the same timestamp to ensure that key-based
+	// pagination works.
+	values := make([]string, rowCount)
+	for i := range values {
+		values[i] = fmt.Sprintf("(%d, 'value %d', '2023-01-01 00:00:00')", i, i)
+	}
+	r.NoError(src.Exec(ctx, "INSERT INTO %s VALUES "+strings.Join(values, ", ")))
+
+	cfg := &Config{
+		BaseConfig: logical.BaseConfig{
+			ApplyTimeout:  2 * time.Minute, // Increase to make using the debugger easier.
+			Immediate:     true,
+			RetryDelay:    time.Millisecond,
+			StagingConn:   fixture.StagingPool.ConnectionString,
+			StagingSchema: fixture.StagingDB.Schema(),
+			TargetConn:    fixture.TargetPool.ConnectionString,
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:     "querylogicaltest",
+			TargetSchema: fixture.TargetSchema.Schema(),
+		},
+		BatchSize:           10,
+		DeleteCheckInterval: 10 * time.Millisecond,
+		PollInterval:        10 * time.Millisecond,
+		SourceConn:          fixture.SourcePool.ConnectionString,
+		SourceSchema:        fixture.SourceSchema.Schema(),
+		Tables:              []string{src.Name().Table().Raw()},
+		UpdatedAtColumn:     ident.New("updated_at"),
+	}
+	loops, err := startLoopsFromFixture(fixture, cfg)
+	r.NoError(err)
+	a.Len(loops, 1)
+
+	countWhere := func(where string) int {
+		var ct int
+		r.NoError(fixture.TargetPool.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", dest.Name(), where)).Scan(&ct))
+		return ct
+	}
+
+	log.Info("waiting for initial rows")
+	for {
+		ct, err := dest.RowCount(ctx)
+		r.NoError(err)
+		if ct == rowCount {
+			break
+		}
+		log.Infof("saw only %d rows", ct)
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	log.Info("updating rows")
+	r.NoError(src.Exec(ctx,
+		"UPDATE %s SET v = 'updated', updated_at = '2023-01-02 00:00:00' WHERE id < 50"))
+	for {
+		ct := countWhere("v = 'updated'")
+		if ct == 50 {
+			break
+		}
+		log.Infof("saw only %d updated rows", ct)
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	log.Info("deleting rows")
+	r.NoError(src.Exec(ctx, "DELETE FROM %s WHERE id >= 90"))
+	for {
+		ct, err := dest.RowCount(ctx)
+		r.NoError(err)
+		if ct == 90 {
+			break
+		}
+		log.Infof("still have %d rows", ct)
+		time.Sleep(100 * time.Millisecond)
+	}
+	a.Equal(0, countWhere("id >= 90"))
+
+	// Ensure diagnostics can be reported.
+	sinktest.CheckDiagnostics(ctx, t, fixture.Diagnostics)
+}
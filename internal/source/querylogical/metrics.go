@@ -0,0 +1,39 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package querylogical
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/util/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	deleteCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "querylogical_deletes_total",
+		Help: "the number of deleted rows detected by comparing primary keys",
+	}, metrics.TableLabels)
+	pollDurations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "querylogical_poll_duration_seconds",
+		Help:    "the length of time it took to query a source table",
+		Buckets: metrics.LatencyBuckets,
+	}, metrics.TableLabels)
+	pollRowCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "querylogical_poll_rows_total",
+		Help: "the number of rows read from source tables",
+	}, metrics.TableLabels)
+)
@@ -0,0 +1,119 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package querylogical
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/google/wire"
+	log "github.com/sirupsen/logrus"
+)
+
+// Set is used by Wire.
+var Set = wire.NewSet(
+	ProvideLoops,
+	ProvideSourcePool,
+)
+
+// ProvideLoops is called by Wire to construct a logical-replication
+// loop for each configured table.
+func ProvideLoops(
+	ctx *stopper.Context,
+	cfg *Config,
+	diags *diag.Diagnostics,
+	loops *logical.Factory,
+	pool *types.SourcePool,
+) ([]*logical.Loop, error) {
+	if err := cfg.Preflight(); err != nil {
+		return nil, err
+	}
+
+	schema, err := pool.Product.ExpandSchema(cfg.SourceSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	// Introspect the source tables using the same machinery as the
+	// target, but report its diagnostics separately.
+	sourceDiags, err := diags.Wrap("sourceSchema")
+	if err != nil {
+		return nil, err
+	}
+	watchers, err := schemawatch.ProvideFactory(ctx, (*types.TargetPool)(pool), sourceDiags)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := watchers.Get(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*logical.Loop, len(cfg.Tables))
+	for idx, name := range cfg.Tables {
+		sourceTable := ident.NewTable(schema, ident.New(name))
+		target := ident.NewTable(cfg.TargetSchema, sourceTable.Table())
+
+		loopCfg := cfg.LoopConfig.Copy()
+		loopCfg.Dialect = &Dialect{
+			batchSize:           cfg.BatchSize,
+			deleteCheckInterval: cfg.DeleteCheckInterval,
+			pollInterval:        cfg.PollInterval,
+			pool:                pool,
+			sourceName:          script.SourceName(target),
+			sourceTable:         sourceTable,
+			target:              target,
+			updatedAt:           cfg.UpdatedAtColumn,
+			watcher:             watcher,
+		}
+		loopCfg.LoopName = fmt.Sprintf("%s-%s", cfg.LoopName, name)
+
+		ret[idx], err = loops.Start(loopCfg)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("started polling loop for %s", sourceTable)
+	}
+	return ret, nil
+}
+
+// ProvideSourcePool is called by Wire to connect to the source
+// database. Any product supported by [stdpool.OpenTarget] may be used.
+func ProvideSourcePool(
+	ctx *stopper.Context, cfg *Config, diags *diag.Diagnostics,
+) (*types.SourcePool, error) {
+	if err := cfg.Preflight(); err != nil {
+		return nil, err
+	}
+	ret, err := stdpool.OpenTarget(ctx, cfg.SourceConn,
+		stdpool.WithConnectionLifetime(5*time.Minute),
+		stdpool.WithDiagnostics(diags, "source"),
+		stdpool.WithMetrics("source"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return (*types.SourcePool)(ret), nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package querylogical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+)
+
+// pollQuery contains the SQL used to read a table in (updated_at, key)
+// order and to convert the rows that it returns into mutations.
+type pollQuery struct {
+	columns   []types.ColData // The columns to read, in SELECT order.
+	keys      []int           // The indexes of the primary key columns.
+	updatedAt int             // The index of the updated_at column.
+
+	first   string // Reads the first rows of the table.
+	keySet  string // Reads all primary keys in the table.
+	next    string // Reads the rows that follow a consistent point.
+	nextArg []int  // Maps placeholders in next to 0=updated_at, N=keys[N-1].
+}
+
+// newPollQuery generates the SQL to poll the table. The columns are
+// expected to be in the order returned by a [types.Watcher], with the
+// primary key columns first.
+func newPollQuery(
+	product types.Product,
+	table ident.Table,
+	cols []types.ColData,
+	updatedAt ident.Ident,
+	limit int,
+) (*pollQuery, error) {
+	q := &pollQuery{updatedAt: -1}
+	var selects []string
+	for _, col := range cols {
+		if col.Ignored {
+			continue
+		}
+		if col.Primary {
+			q.keys = append(q.keys, len(q.columns))
+		}
+		if ident.Equal(col.Name, updatedAt) {
+			q.updatedAt = len(q.columns)
+		}
+		q.columns = append(q.columns, col)
+		selects = append(selects, col.Name.String())
+	}
+	if len(q.keys) == 0 {
+		return nil, errors.Errorf("table %s has no primary key", table)
+	}
+	if q.updatedAt < 0 {
+		return nil, errors.Errorf("table %s has no column named %s", table, updatedAt)
+	}
+
+	// The columns to order by.
+	terms := make([]string, len(q.keys)+1)
+	terms[0] = updatedAt.String()
+	for idx, colIdx := range q.keys {
+		terms[idx+1] = q.columns[colIdx].Name.String()
+	}
+
+	// Expand the row-value comparison (a, b, c) > (?, ?, ?) into
+	// a > ? OR (a = ? AND (b > ? OR (b = ? AND c > ?))), since not
+	// all products support the former.
+	var where strings.Builder
+	for idx, term := range terms {
+		if idx == len(terms)-1 {
+			fmt.Fprintf(&where, "%s > %s", term, placeholder(product, len(q.nextArg)+1))
+			q.nextArg = append(q.nextArg, idx)
+			break
+		}
+		fmt.Fprintf(&where, "%s > %s OR (%s = %s AND (",
+			term, placeholder(product, len(q.nextArg)+1),
+			term, placeholder(product, len(q.nextArg)+2))
+		q.nextArg = append(q.nextArg, idx, idx)
+	}
+	where.WriteString(strings.Repeat("))", len(terms)-1))
+
+	var fetch string
+	if product == types.ProductOracle {
+		fetch = fmt.Sprintf("FETCH FIRST %d ROWS ONLY", limit)
+	} else {
+		fetch = fmt.Sprintf("LIMIT %d", limit)
+	}
+	from := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), table)
+	orderBy := strings.Join(terms, ", ")
+
+	q.first = fmt.Sprintf("%s WHERE %s IS NOT NULL ORDER BY %s %s",
+		from, updatedAt, orderBy, fetch)
+	q.keySet = fmt.Sprintf("SELECT %s FROM %s", strings.Join(terms[1:], ", "), table)
+	q.next = fmt.Sprintf("%s WHERE %s ORDER BY %s %s",
+		from, where.String(), orderBy, fetch)
+	return q, nil
+}
+
+// nextArgs returns the arguments for the next query.
+func (q *pollQuery) nextArgs(cp *consistentPoint) ([]any, error) {
+	var key []any
+	dec := json.NewDecoder(bytes.NewReader(cp.Key))
+	dec.UseNumber()
+	if err := dec.Decode(&key); err != nil {
+		return nil, errors.Wrap(err, "could not decode consistent point key")
+	}
+	if len(key) != len(q.keys) {
+		return nil, errors.Errorf(
+			"consistent point has %d key elements, but the table has %d key columns",
+			len(key), len(q.keys))
+	}
+	ret := make([]any, len(q.nextArg))
+	for idx, term := range q.nextArg {
+		if term == 0 {
+			ret[idx] = cp.Time
+			continue
+		}
+		v := key[term-1]
+		if n, ok := v.(json.Number); ok {
+			v = n.String()
+		}
+		ret[idx] = v
+	}
+	return ret, nil
+}
+
+// decode converts a row read by the first or next queries into a
+// mutation. It also returns the consistent point that follows the row.
+func (q *pollQuery) decode(vals []any) (types.Mutation, *consistentPoint, error) {
+	var mut types.Mutation
+	if len(vals) != len(q.columns) {
+		return mut, nil, errors.Errorf("expecting %d values, had %d", len(q.columns), len(vals))
+	}
+	ts, err := parseTime(vals[q.updatedAt])
+	if err != nil {
+		return mut, nil, errors.Wrapf(err, "column %s", q.columns[q.updatedAt].Name)
+	}
+
+	data := make(map[string]any, len(vals))
+	for idx, val := range vals {
+		data[q.columns[idx].Name.Raw()] = normalize(val)
+	}
+	key, err := q.decodeKey(vals, q.keys)
+	if err != nil {
+		return mut, nil, err
+	}
+
+	mut.Data, err = json.Marshal(data)
+	if err != nil {
+		return mut, nil, errors.WithStack(err)
+	}
+	mut.Key = key
+	mut.Time = hlc.New(ts.UnixNano(), 0)
+	return mut, &consistentPoint{Time: ts, Key: key}, nil
+}
+
+// decodeKey returns the JSON array of the key values at the given
+// indexes.
+func (q *pollQuery) decodeKey(vals []any, indexes []int) (json.RawMessage, error) {
+	key := make([]any, len(indexes))
+	for idx, valIdx := range indexes {
+		key[idx] = normalize(vals[valIdx])
+	}
+	ret, err := json.Marshal(key)
+	return ret, errors.WithStack(err)
+}
+
+// normalize converts the byte slices returned by some drivers into
+// strings, so that they are encoded as text rather than base64.
+func normalize(v any) any {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+// timeLayouts are tried, in order, when a driver returns timestamps as
+// text (e.g. MySQL without parseTime).
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// parseTime converts an updated_at value into a time.Time. Textual
+// values without a zone are interpreted as UTC.
+func parseTime(v any) (time.Time, error) {
+	var s string
+	switch t := v.(type) {
+	case nil:
+		return time.Time{}, errors.New("unexpected NULL timestamp")
+	case time.Time:
+		return t, nil
+	case []byte:
+		s = string(t)
+	case string:
+		s = t
+	default:
+		return time.Time{}, errors.Errorf("unexpected timestamp type %T", v)
+	}
+	for _, layout := range timeLayouts {
+		if ts, err := time.Parse(layout, s); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("could not parse timestamp %q", s)
+}
+
+// placeholder returns the nth (one-based) query parameter.
+func placeholder(product types.Product, n int) string {
+	switch product {
+	case types.ProductMariaDB, types.ProductMySQL:
+		return "?"
+	case types.ProductOracle:
+		return fmt.Sprintf(":%d", n)
+	default:
+		return fmt.Sprintf("$%d", n)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package querylogical
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollQuery(t *testing.T) {
+	tbl := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+	cols := []types.ColData{
+		{Name: ident.New("a"), Primary: true},
+		{Name: ident.New("b"), Primary: true},
+		{Name: ident.New("hidden"), Ignored: true},
+		{Name: ident.New("updated_at")},
+		{Name: ident.New("v")},
+	}
+
+	tcs := []struct {
+		product types.Product
+		first   string
+		next    string
+	}{
+		{
+			product: types.ProductCockroachDB,
+			first: `SELECT "a", "b", "updated_at", "v" FROM "db"."public"."tbl" ` +
+				`WHERE "updated_at" IS NOT NULL ORDER BY "updated_at", "a", "b" LIMIT 10`,
+			next: `SELECT "a", "b", "updated_at", "v" FROM "db"."public"."tbl" ` +
+				`WHERE "updated_at" > $1 OR ("updated_at" = $2 AND (` +
+				`"a" > $3 OR ("a" = $4 AND ("b" > $5)))) ` +
+				`ORDER BY "updated_at", "a", "b" LIMIT 10`,
+		},
+		{
+			product: types.ProductMySQL,
+			first: `SELECT "a", "b", "updated_at", "v" FROM "db"."public"."tbl" ` +
+				`WHERE "updated_at" IS NOT NULL ORDER BY "updated_at", "a", "b" LIMIT 10`,
+			next: `SELECT "a", "b", "updated_at", "v" FROM "db"."public"."tbl" ` +
+				`WHERE "updated_at" > ? OR ("updated_at" = ? AND (` +
+				`"a" > ? OR ("a" = ? AND ("b" > ?)))) ` +
+				`ORDER BY "updated_at", "a", "b" LIMIT 10`,
+		},
+		{
+			product: types.ProductOracle,
+			first: `SELECT "a", "b", "updated_at", "v" FROM "db"."public"."tbl" ` +
+				`WHERE "updated_at" IS NOT NULL ORDER BY "updated_at", "a", "b" ` +
+				`FETCH FIRST 10 ROWS ONLY`,
+			next: `SELECT "a", "b", "updated_at", "v" FROM "db"."public"."tbl" ` +
+				`WHERE "updated_at" > :1 OR ("updated_at" = :2 AND (` +
+				`"a" > :3 OR ("a" = :4 AND ("b" > :5)))) ` +
+				`ORDER BY "updated_at", "a", "b" FETCH FIRST 10 ROWS ONLY`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.product.String(), func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+
+			q, err := newPollQuery(tc.product, tbl, cols, ident.New("updated_at"), 10)
+			r.NoError(err)
+			a.Equal(tc.first, q.first)
+			a.Equal(tc.next, q.next)
+			a.Equal(`SELECT "a", "b" FROM "db"."public"."tbl"`, q.keySet)
+		})
+	}
+
+	t.Run("decode", func(t *testing.T) {
+		a := assert.New(t)
+		r := require.New(t)
+
+		q, err := newPollQuery(types.ProductMySQL, tbl, cols, ident.New("updated_at"), 10)
+		r.NoError(err)
+
+		ts := time.Date(2023, 1, 2, 3, 4, 5, 600000000, time.UTC)
+		mut, cp, err := q.decode([]any{int64(1), []byte("two"), []byte("2023-01-02 03:04:05.6"), nil})
+		r.NoError(err)
+		a.JSONEq(`{"a":1,"b":"two","updated_at":"2023-01-02 03:04:05.6","v":null}`, string(mut.Data))
+		a.JSONEq(`[1,"two"]`, string(mut.Key))
+		a.Equal(hlc.New(ts.UnixNano(), 0), mut.Time)
+		a.Equal(ts, cp.Time)
+		a.Equal(json.RawMessage(`[1,"two"]`), cp.Key)
+
+		args, err := q.nextArgs(cp)
+		r.NoError(err)
+		a.Equal([]any{ts, ts, "1", "1", "two"}, args)
+
+		_, err = q.nextArgs(&consistentPoint{Time: ts, Key: json.RawMessage(`[1]`)})
+		a.ErrorContains(err, "1 key elements")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		a := assert.New(t)
+
+		_, err := newPollQuery(types.ProductCockroachDB, tbl, cols, ident.New("missing"), 10)
+		a.ErrorContains(err, "no column named")
+
+		_, err = newPollQuery(types.ProductCockroachDB, tbl, cols[2:], ident.New("updated_at"), 10)
+		a.ErrorContains(err, "no primary key")
+	})
+}
@@ -0,0 +1,357 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package querylogical contains a logical-replication dialect that
+// polls source tables for rows whose updated_at column has advanced.
+// It is intended for sources that do not expose a replication log,
+// such as read replicas, managed databases, or views.
+package querylogical
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/metrics"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Dialect reads a single source table by repeatedly querying for rows
+// that follow the table's high-water mark. Rows that are updated with
+// an updated_at value older than the high-water mark (e.g. by a
+// long-running transaction) will not be observed until they are
+// updated again.
+//
+// If delete detection is enabled, the primary keys of the source table
+// are periodically compared to the keys that have been previously
+// read. Only rows that have been read by this process can be detected
+// as deleted.
+type Dialect struct {
+	batchSize           int
+	deleteCheckInterval time.Duration
+	pollInterval        time.Duration
+	pool                *types.SourcePool
+	sourceName          ident.Ident // Passed to the user-script.
+	sourceTable         ident.Table
+	target              ident.Table
+	updatedAt           ident.Ident
+	watcher             types.Watcher
+
+	// These fields are only accessed from ReadInto.
+	known     map[string]struct{} // Keys read since the last key scan.
+	lastCheck time.Time           // The time of the last key scan.
+}
+
+var (
+	_ diag.Diagnostic = (*Dialect)(nil)
+	_ logical.Dialect = (*Dialect)(nil)
+)
+
+// A pollBatch is sent from ReadInto to Process.
+type pollBatch struct {
+	cp   *consistentPoint // Nil if the batch only contains deletes.
+	muts []types.Mutation
+}
+
+// ReadInto implements logical.Dialect.
+func (d *Dialect) ReadInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	cp, _ := state.GetConsistentPoint()
+	point := cp.(*consistentPoint)
+
+	// send returns false if the loop is stopping.
+	send := func(batch *pollBatch) (bool, error) {
+		select {
+		case ch <- batch:
+			return true, nil
+		case <-state.Stopping():
+			return false, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	for {
+		q, err := d.query()
+		if err != nil {
+			return err
+		}
+
+		batch, err := d.poll(ctx, q, point)
+		if err != nil {
+			return err
+		}
+		if len(batch.muts) > 0 {
+			if ok, err := send(batch); err != nil || !ok {
+				return err
+			}
+			point = batch.cp
+		}
+
+		if d.deleteCheckInterval > 0 && time.Since(d.lastCheck) >= d.deleteCheckInterval {
+			deletes, err := d.checkDeletes(ctx, q)
+			if err != nil {
+				return err
+			}
+			if len(deletes) > 0 {
+				if ok, err := send(&pollBatch{muts: deletes}); err != nil || !ok {
+					return err
+				}
+			}
+		}
+
+		// Keep reading if the table has more rows.
+		if len(batch.muts) >= d.batchSize {
+			continue
+		}
+
+		select {
+		case <-time.After(d.pollInterval):
+		case <-state.Stopping():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Diagnostic implements [diag.Diagnostic].
+func (d *Dialect) Diagnostic(_ context.Context) any {
+	type Payload struct {
+		BatchSize           int
+		DeleteCheckInterval string
+		PollInterval        string
+		SourceTable         ident.Table
+		Target              ident.Table
+		UpdatedAt           ident.Ident
+	}
+	return &Payload{
+		BatchSize:           d.batchSize,
+		DeleteCheckInterval: d.deleteCheckInterval.String(),
+		PollInterval:        d.pollInterval.String(),
+		SourceTable:         d.sourceTable,
+		Target:              d.target,
+		UpdatedAt:           d.updatedAt,
+	}
+}
+
+// Process implements logical.Dialect.
+func (d *Dialect) Process(
+	ctx context.Context, ch <-chan logical.Message, events logical.Events,
+) error {
+	for msg := range ch {
+		// Each batch is self-contained, so there is no state to reset.
+		if logical.IsRollback(msg) {
+			continue
+		}
+
+		b, ok := msg.(*pollBatch)
+		if !ok {
+			panic(fmt.Sprintf("unimplemented type %T", msg))
+		}
+
+		batch, err := events.OnBegin(ctx)
+		if err != nil {
+			return err
+		}
+		if err := batch.OnData(ctx, d.sourceName, d.target, b.muts); err != nil {
+			_ = batch.OnRollback(ctx)
+			return err
+		}
+		select {
+		case err := <-batch.OnCommit(ctx):
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if b.cp != nil {
+			if err := events.SetConsistentPoint(ctx, b.cp); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ZeroStamp implements logical.Dialect.
+func (d *Dialect) ZeroStamp() stamp.Stamp {
+	return &consistentPoint{}
+}
+
+// checkDeletes reads the primary keys of the source table and returns
+// deletion mutations for any previously-read keys that are missing.
+func (d *Dialect) checkDeletes(ctx context.Context, q *pollQuery) ([]types.Mutation, error) {
+	start := time.Now()
+	rows, err := d.pool.QueryContext(ctx, q.keySet)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read keys from %s", d.sourceTable)
+	}
+	defer rows.Close()
+
+	indexes := make([]int, len(q.keys))
+	vals := make([]any, len(q.keys))
+	ptrs := make([]any, len(q.keys))
+	for idx := range vals {
+		indexes[idx] = idx
+		ptrs[idx] = &vals[idx]
+	}
+
+	current := make(map[string]struct{})
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		key, err := q.decodeKey(vals, indexes)
+		if err != nil {
+			return nil, err
+		}
+		current[string(key)] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var missing []string
+	for key := range d.known {
+		if _, found := current[key]; !found {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+
+	// The source has no record of when the row was deleted.
+	now := hlc.New(start.UnixNano(), 0)
+	ret := make([]types.Mutation, len(missing))
+	for idx, key := range missing {
+		ret[idx] = types.Mutation{Key: json.RawMessage(key), Time: now}
+		script.AddMeta("querylogical", d.target, &ret[idx])
+	}
+
+	d.known = current
+	d.lastCheck = start
+	deleteCount.WithLabelValues(metrics.TableValues(d.target)...).Add(float64(len(ret)))
+	log.Tracef("found %d deleted rows in %s", len(ret), d.sourceTable)
+	return ret, nil
+}
+
+// poll reads the rows that follow the consistent point.
+func (d *Dialect) poll(
+	ctx context.Context, q *pollQuery, cp *consistentPoint,
+) (*pollBatch, error) {
+	start := time.Now()
+	stmt := q.first
+	var args []any
+	if !cp.IsZero() {
+		var err error
+		args, err = q.nextArgs(cp)
+		if err != nil {
+			return nil, err
+		}
+		stmt = q.next
+	}
+
+	rows, err := d.pool.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not poll %s", d.sourceTable)
+	}
+	defer rows.Close()
+
+	vals := make([]any, len(q.columns))
+	ptrs := make([]any, len(q.columns))
+	for idx := range vals {
+		ptrs[idx] = &vals[idx]
+	}
+
+	ret := &pollBatch{cp: cp}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		mut, next, err := q.decode(vals)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not decode row from %s", d.sourceTable)
+		}
+		script.AddMeta("querylogical", d.target, &mut)
+		ret.muts = append(ret.muts, mut)
+		ret.cp = next
+		if d.deleteCheckInterval > 0 {
+			if d.known == nil {
+				d.known = make(map[string]struct{})
+			}
+			d.known[string(mut.Key)] = struct{}{}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	labels := metrics.TableValues(d.target)
+	pollDurations.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	pollRowCount.WithLabelValues(labels...).Add(float64(len(ret.muts)))
+	return ret, nil
+}
+
+// query returns the SQL to poll the source table, using its current
+// schema.
+func (d *Dialect) query() (*pollQuery, error) {
+	cols, ok := d.watcher.Get().Columns.Get(d.sourceTable)
+	if !ok {
+		return nil, errors.Errorf("source table %s not found", d.sourceTable)
+	}
+	return newPollQuery(d.pool.Product, d.sourceTable, cols, d.updatedAt, d.batchSize)
+}
+
+// QueryLogical is the top-level injection type.
+type QueryLogical struct {
+	Diagnostics *diag.Diagnostics
+	Factory     *logical.Factory
+	Loops       []*logical.Loop
+}
+
+var (
+	_ stdlogical.HasDiagnostics = (*QueryLogical)(nil)
+	_ stdlogical.HasHandlers    = (*QueryLogical)(nil)
+)
+
+// AddHandlers implements [stdlogical.HasHandlers] to allow the replica
+// to be paused or stopped at a specific point, and to provide the
+// admin API.
+func (l *QueryLogical) AddHandlers(auth types.Authenticator, mux *http.ServeMux) {
+	mux.Handle("/_/pause", logical.PauseHandler(auth, l.Factory))
+	mux.Handle("/_/stopAt", logical.StopAtHandler(auth, l.Factory))
+	mux.Handle("/_/admin/", logical.AdminHandler(auth, l.Factory))
+}
+
+// GetDiagnostics implements [stdlogical.HasDiagnostics].
+func (l *QueryLogical) GetDiagnostics() *diag.Diagnostics {
+	return l.Diagnostics
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+)
+
+// Backlog summarizes the unapplied mutations in a staging table.
+type Backlog struct {
+	Table     ident.Table `json:"table"`     // The staging table.
+	Unapplied int         `json:"unapplied"` // The number of unapplied mutations.
+	Oldest    time.Time   `json:"oldest"`    // The oldest unapplied mutation, if any.
+}
+
+// Staging tables are identified by having all the columns that are
+// declared in tableSchema.
+const (
+	backlogTablesTemplate = `
+SELECT table_name
+  FROM %[1]s.information_schema.columns
+ WHERE table_catalog = $1
+   AND table_schema = $2
+   AND column_name IN ('nanos', 'logical', 'key', 'mut', 'applied')
+ GROUP BY table_name
+HAVING count(*) = 5
+ ORDER BY table_name`
+	backlogCountTemplate = `
+SELECT count(*), COALESCE(min(nanos), 0) FROM %[1]s WHERE NOT applied`
+)
+
+// ScanBacklog reports the number of unapplied mutations in each of the
+// staging tables within the staging schema. It does not require any
+// knowledge of the target tables, so it may be used when cdc-sink is
+// not running.
+func ScanBacklog(
+	ctx context.Context, db types.StagingQuerier, stagingSchema ident.Schema,
+) ([]*Backlog, error) {
+	parts := stagingSchema.Idents(make([]ident.Ident, 0, 2))
+	if len(parts) != 2 {
+		return nil, errors.Errorf("expecting a schema with 2 parts, got %v", parts)
+	}
+
+	q := fmt.Sprintf(backlogTablesTemplate, parts[0])
+	rows, err := db.Query(ctx, q, parts[0].Raw(), parts[1].Raw())
+	if err != nil {
+		return nil, errors.Wrap(err, q)
+	}
+	var ret []*Backlog
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, errors.WithStack(err)
+		}
+		ret = append(ret, &Backlog{Table: ident.NewTable(stagingSchema, ident.New(name))})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, b := range ret {
+		q := fmt.Sprintf(backlogCountTemplate, b.Table)
+		var nanos int64
+		if err := db.QueryRow(ctx, q).Scan(&b.Unapplied, &nanos); err != nil {
+			return nil, errors.Wrap(err, q)
+		}
+		if nanos > 0 {
+			b.Oldest = time.Unix(0, nanos).UTC()
+		}
+	}
+	return ret, nil
+}
@@ -79,6 +79,9 @@ func (f *factory) Unstage(
 ) (*types.UnstageCursor, bool, error) {
 	// Duplicate the cursor so callers can choose to advance.
 	cursor = cursor.Copy()
+	if len(cursor.Targets) == 0 {
+		return cursor, false, nil
+	}
 
 	data := &templateData{
 		Cursor:        cursor,
@@ -27,6 +27,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/batches"
@@ -46,6 +47,7 @@ import (
 type apply struct {
 	cache   *types.TargetStatements
 	dlqs    types.DLQs
+	evolver *evolve.Evolver // May be nil.
 	product types.Product
 	target  ident.Table
 
@@ -93,6 +95,7 @@ func (f *factory) newApply(
 	a := &apply{
 		cache:   f.cache,
 		dlqs:    f.dlqs,
+		evolver: f.evolver,
 		product: product,
 		target:  target,
 
@@ -160,8 +163,18 @@ func (f *factory) newApply(
 	return a, err
 }
 
-// Apply applies the mutations to the target table.
+// Apply applies the mutations to the target table. Any error will be
+// reported as a [types.TableError].
 func (a *apply) Apply(ctx context.Context, tx types.TargetQuerier, muts []types.Mutation) error {
+	if err := a.applyMutations(ctx, tx, muts); err != nil {
+		return &types.TableError{Err: err, Table: a.target}
+	}
+	return nil
+}
+
+func (a *apply) applyMutations(
+	ctx context.Context, tx types.TargetQuerier, muts []types.Mutation,
+) error {
 	start := time.Now()
 	deletes, r := batches.Mutation()
 	defer r()
@@ -192,7 +205,25 @@ func (a *apply) Apply(ctx context.Context, tx types.TargetQuerier, muts []types.
 
 	// Accumulate mutations and flush incrementally.
 	for i := range muts {
-		if muts[i].IsDelete() {
+		keyless, err := a.isKeylessLocked(ctx, muts[i])
+		if err != nil {
+			return countError(err)
+		}
+		if keyless {
+			// Flush to preserve ordering.
+			if err := a.deleteLocked(ctx, tx, deletes); err != nil {
+				return countError(err)
+			}
+			deletes = deletes[:0]
+			if err := a.upsertLocked(ctx, tx, upserts, ""); err != nil {
+				return countError(err)
+			}
+			upserts = upserts[:0]
+			// Rows without identity are applied one at a time.
+			if err := a.keylessLocked(ctx, tx, muts[i]); err != nil {
+				return countError(err)
+			}
+		} else if muts[i].IsDelete() {
 			deletes = append(deletes, muts[i])
 			if len(deletes) == cap(deletes) {
 				if err := a.deleteLocked(ctx, tx, deletes); err != nil {
@@ -200,6 +231,21 @@ func (a *apply) Apply(ctx context.Context, tx types.TargetQuerier, muts []types.
 				}
 				deletes = deletes[:0]
 			}
+		} else if _, sparse := muts[i].Meta[types.SparseUpdate]; sparse {
+			// Flush to preserve ordering.
+			if err := a.deleteLocked(ctx, tx, deletes); err != nil {
+				return countError(err)
+			}
+			deletes = deletes[:0]
+			if err := a.upsertLocked(ctx, tx, upserts, ""); err != nil {
+				return countError(err)
+			}
+			upserts = upserts[:0]
+			// Sparse updates read the target row, so they are applied
+			// one at a time.
+			if err := a.sparseLocked(ctx, tx, muts[i]); err != nil {
+				return countError(err)
+			}
 		} else if custom, ok := muts[i].Meta[types.CustomUpsert]; ok {
 			// Flush
 			if err := a.upsertLocked(ctx, tx, upserts, ""); err != nil {
@@ -252,6 +298,15 @@ func (a *apply) deleteLocked(
 
 	allArgs := make([]any, 0, len(a.mu.templates.PKDelete)*len(muts))
 	for i, keyGroup := range keyGroups {
+		// A source may not know the key columns of a table, but it
+		// may be able to provide the contents of the deleted row.
+		if len(keyGroup) != len(a.mu.templates.PKDelete) && len(muts[i].Before) > 0 {
+			var err error
+			keyGroup, err = a.keyFromBeforeLocked(ctx, muts[i])
+			if err != nil {
+				return err
+			}
+		}
 		if len(keyGroup) != len(a.mu.templates.PKDelete) {
 			return errors.Errorf(
 				"schema drift detected in %s: "+
@@ -352,21 +407,9 @@ func (a *apply) upsertArgsLocked(bags []*merge.Bag) ([]any, error) {
 			// fixes to reified values. That is, if the target database
 			// requires special formatting or other data encapsulation,
 			// this is the place to do it.
-			value := entry.Value
-			if num, ok := value.(json.Number); ok {
-				// The JSON parser is configured to parse numbers as though
-				// they were strings.  We'll keep the string encoding so
-				// that the target database can tell us if the string value
-				// exceeds the precision or scale for the target column.
-				value = removeExponent(num).String()
-			} else if value != nil && entry.Column.Parse != nil {
-				// Target-driver specific fixups.
-				v, err := entry.Column.Parse(value)
-				if err != nil {
-					return errors.Wrapf(err, "could not parse %v as a %s",
-						value, entry.Column.Type)
-				}
-				value = v
+			value, err := targetValue(entry)
+			if err != nil {
+				return err
 			}
 
 			// Now that we know what value we're inserting, we need to
@@ -412,8 +455,12 @@ func (a *apply) upsertArgsLocked(bags []*merge.Bag) ([]any, error) {
 			// Report unmapped properties as an error if there's nowhere
 			// to store the data.
 			if err := merge.ValidateNoUnmappedColumns(rowData); err != nil {
-				return nil, errors.Wrapf(err,
+				err = errors.Wrapf(err,
 					"schema drift detected in %s at payload object offset %d", a.target, idx)
+				if a.evolver.Enabled(a.target) {
+					return nil, a.evolveLocked(bags[idx:], err)
+				}
+				return nil, err
 			}
 		} else {
 			extraJSONBytes, err := json.Marshal(&rowData.Unmapped)
@@ -439,6 +486,28 @@ func (a *apply) upsertArgsLocked(bags []*merge.Bag) ([]any, error) {
 	return allArgs, nil
 }
 
+// targetValue returns the value of the entry with any target-specific
+// fixups applied.
+func targetValue(entry *merge.Entry) (any, error) {
+	value := entry.Value
+	if num, ok := value.(json.Number); ok {
+		// The JSON parser is configured to parse numbers as though
+		// they were strings.  We'll keep the string encoding so
+		// that the target database can tell us if the string value
+		// exceeds the precision or scale for the target column.
+		value = removeExponent(num).String()
+	} else if value != nil && entry.Column.Parse != nil {
+		// Target-driver specific fixups.
+		v, err := entry.Column.Parse(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse %v as a %s",
+				value, entry.Column.Type)
+		}
+		value = v
+	}
+	return value, nil
+}
+
 // upsertBagsLocked contains the apply/merge functionality. The bags
 // argument provides the reified data to insert into the database. The
 // muts argument is only used if a merge behavior is required.
@@ -630,6 +699,26 @@ func (a *apply) upsertBagsLocked(
 	return a.upsertBagsLocked(ctx, db, applyUnconditional, nil, fixups, template)
 }
 
+// evolveLocked requests that the unmapped properties in the bags be
+// added to the target table. The drift error is always returned, so
+// that the caller will retry once the columns have been added. The
+// bags are not modified.
+func (a *apply) evolveLocked(bags []*merge.Bag, drift error) error {
+	unmapped := make([]*ident.Map[any], len(bags))
+	for idx, bag := range bags {
+		props := &ident.Map[any]{}
+		bag.Unmapped.CopyInto(props)
+		for _, ignored := range a.mu.templates.Ignore {
+			props.Delete(ignored)
+		}
+		unmapped[idx] = props
+	}
+	if err := a.evolver.Request(a.target, unmapped); err != nil {
+		return errors.Wrap(drift, err.Error())
+	}
+	return errors.Wrap(drift, "adding columns to target table; will retry")
+}
+
 // newBagLocked constructs a new property bag using cached metadata.
 func (a *apply) newBagLocked() *merge.Bag {
 	return merge.NewBag(a.mu.bagSpec)
@@ -36,6 +36,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/util/batches"
 	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/jsondiff"
 	"github.com/cockroachdb/cdc-sink/internal/util/merge"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pkg/errors"
@@ -2121,3 +2122,170 @@ func coerceToInt(r *require.Assertions, x any) int {
 		return 0
 	}
 }
+
+// TestKeylessBeforeImage verifies that rows in a table without a
+// primary key can be updated and deleted by their before-images.
+func TestKeylessBeforeImage(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	fixture, err := all.NewFixture(t)
+	r.NoError(err)
+	if fixture.TargetPool.Product == types.ProductOracle {
+		t.Skip("tables without a primary key are not supported for Oracle")
+	}
+	ctx := fixture.Context
+
+	tbl, err := fixture.CreateTargetTable(ctx,
+		"CREATE TABLE %s (k INT, v VARCHAR(256))")
+	r.NoError(err)
+
+	app, err := fixture.Appliers.Get(ctx, tbl.Name())
+	r.NoError(err)
+
+	// The source doesn't know about any synthetic key, so each
+	// mutation has an arbitrary key.
+	var nextKey int
+	mut := func(before, data string) types.Mutation {
+		nextKey++
+		ret := types.Mutation{Key: []byte(fmt.Sprintf(`["key-%d"]`, nextKey))}
+		if before != "" {
+			ret.Before = []byte(before)
+		}
+		if data != "" {
+			ret.Data = []byte(data)
+		}
+		return ret
+	}
+	count := func() int {
+		ct, err := tbl.RowCount(ctx)
+		r.NoError(err)
+		return ct
+	}
+
+	// Duplicate rows are allowed.
+	r.NoError(app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		mut("", `{"k":1,"v":"a"}`),
+		mut("", `{"k":1,"v":"a"}`),
+		mut("", `{"k":2,"v":"b"}`),
+	}))
+	a.Equal(3, count())
+
+	// Update only one of the duplicates, then delete it.
+	r.NoError(app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		mut(`{"k":1,"v":"a"}`, `{"k":1,"v":"z"}`),
+	}))
+	a.Equal(3, count())
+	r.NoError(app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		mut(`{"k":1,"v":"z"}`, ""),
+	}))
+	a.Equal(2, count())
+
+	// A before-image that doesn't match is a no-op.
+	r.NoError(app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		mut(`{"k":1,"v":"z"}`, ""),
+	}))
+	a.Equal(2, count())
+
+	// Mutations are applied in order within a batch.
+	r.NoError(app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		mut("", `{"k":3,"v":null}`),
+		mut(`{"k":3,"v":null}`, ""),
+		mut(`{"k":1,"v":"a"}`, ""),
+		mut(`{"k":2,"v":"b"}`, ""),
+	}))
+	a.Equal(0, count())
+}
+
+// Verify that columns absent from a sparse update retain their current
+// values and that partial JSON updates are applied to the current
+// document.
+func TestSparseUpdate(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	fixture, err := all.NewFixture(t)
+	r.NoError(err)
+	if fixture.TargetPool.Product == types.ProductOracle {
+		t.Skip("sparse updates are not supported for Oracle")
+	}
+	ctx := fixture.Context
+
+	jsonType, textFmt := "JSONB", "%s::TEXT"
+	switch fixture.TargetPool.Product {
+	case types.ProductMariaDB, types.ProductMySQL:
+		jsonType, textFmt = "JSON", "CAST(%s AS CHAR)"
+	}
+	tbl, err := fixture.CreateTargetTable(ctx, fmt.Sprintf(
+		"CREATE TABLE %%s (k INT PRIMARY KEY, v VARCHAR(256), n INT, j %s)", jsonType))
+	r.NoError(err)
+
+	app, err := fixture.Appliers.Get(ctx, tbl.Name())
+	r.NoError(err)
+
+	sparse := func(key int, data string, diffs map[string][]jsondiff.Diff) types.Mutation {
+		ret := types.Mutation{
+			Data: []byte(data),
+			Key:  []byte(fmt.Sprintf("[%d]", key)),
+			Meta: map[string]any{types.SparseUpdate: true},
+		}
+		if diffs != nil {
+			ret.Meta[types.JSONDiffs] = diffs
+		}
+		return ret
+	}
+	read := func(key int) (v, n, j *string) {
+		r.NoError(fixture.TargetPool.QueryRowContext(ctx, fmt.Sprintf(
+			"SELECT v, %s, %s FROM %s WHERE k = %d",
+			fmt.Sprintf(textFmt, "n"), fmt.Sprintf(textFmt, "j"), tbl.Name(), key),
+		).Scan(&v, &n, &j))
+		return
+	}
+
+	r.NoError(app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		{
+			Data: []byte(`{"k":1,"v":"a","n":10,"j":{"a":1,"b":[1,2]}}`),
+			Key:  []byte(`[1]`),
+		},
+	}))
+
+	// Unchanged columns keep their values.
+	r.NoError(app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		sparse(1, `{"k":1,"v":"b"}`, nil),
+	}))
+	v, n, j := read(1)
+	a.Equal("b", *v)
+	a.Equal("10", *n)
+	a.JSONEq(`{"a":1,"b":[1,2]}`, *j)
+
+	// Diffs are applied to the current document.
+	r.NoError(app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		sparse(1, `{"k":1}`, map[string][]jsondiff.Diff{
+			"j": {
+				{Op: jsondiff.Replace, Path: "$.a", Value: []byte(`2`)},
+				{Op: jsondiff.Insert, Path: "$.b[2]", Value: []byte(`3`)},
+			},
+		}),
+	}))
+	v, n, j = read(1)
+	a.Equal("b", *v)
+	a.Equal("10", *n)
+	a.JSONEq(`{"a":2,"b":[1,2,3]}`, *j)
+
+	// A missing row is inserted with unset columns.
+	r.NoError(app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		sparse(2, `{"k":2,"v":"c"}`, nil),
+	}))
+	v, n, j = read(2)
+	a.Equal("c", *v)
+	a.Nil(n)
+	a.Nil(j)
+
+	// There's nothing to apply a diff to.
+	err = app.Apply(ctx, fixture.TargetPool, []types.Mutation{
+		sparse(3, `{"k":3}`, map[string][]jsondiff.Diff{
+			"j": {{Op: jsondiff.Replace, Path: "$.a", Value: []byte(`2`)}},
+		}),
+	})
+	a.ErrorContains(err, "no current value")
+}
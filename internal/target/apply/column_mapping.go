@@ -27,6 +27,10 @@ import (
 	"github.com/pkg/errors"
 )
 
+// rowID is the name of the synthetic primary-key column that is
+// reported for tables without a declared primary key.
+var rowID = ident.New("rowid")
+
 // A columnMapping is used to resolve payload keys to database columns
 // that we intend to operate on. For instance, a CockroachDB changefeed
 // generally uses lower-case keys in the JSON payloads, while a target
@@ -43,6 +47,7 @@ type columnMapping struct {
 	Exprs                *ident.Map[string]           // Value-replacement expressions.
 	ExtrasColIdx         int                          // Position of the extras column, or -1 if unconfigured.
 	Ignore               ident.Idents                 // Named columns to ignore in the input.
+	Keyless              bool                         // The table has only a synthetic rowid key.
 	Merger               merge.Merger                 // Conflict-resolution callback.
 	Positions            *ident.Map[positionalColumn] // Map of idents to column info and position.
 	Product              types.Product                // Target database product.
@@ -177,6 +182,10 @@ func newColumnMapping(
 	}
 	ret.DeleteParameterCount = len(ret.PKDelete)
 	ret.UpsertParameterCount = currentParameterIndex
+	// A table without a declared primary key will be reported with a
+	// synthetic rowid column. Rows in such a table can only be
+	// identified by their contents.
+	ret.Keyless = len(ret.PKDelete) == 1 && ident.Equal(ret.PKDelete[0].Name, rowID)
 
 	// We also allow the user to force non-existent columns to be
 	// ignored (e.g. to drop a column).
@@ -20,6 +20,7 @@ import (
 	"context"
 	"sync"
 
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
@@ -32,6 +33,7 @@ type factory struct {
 	cache    *types.TargetStatements
 	configs  *applycfg.Configs
 	dlqs     types.DLQs
+	evolver  *evolve.Evolver
 	product  types.Product
 	stop     *stopper.Context
 	watchers types.Watchers
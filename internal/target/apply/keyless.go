@@ -0,0 +1,187 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/merge"
+	"github.com/cockroachdb/cdc-sink/internal/util/pjson"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// This file contains support for applying mutations to tables which
+// do not have a primary key. A source that is unaware of the synthetic
+// rowid column can instead provide the before-image of a row, which is
+// used to locate one matching row in the target.
+
+// isKeylessLocked returns true if the mutation should be applied by
+// matching the contents of rows, instead of by a key. This is the case
+// if the target table has no primary key and the row data does not
+// contain a value for the synthetic rowid column.
+func (a *apply) isKeylessLocked(ctx context.Context, mut types.Mutation) (bool, error) {
+	if !a.mu.templates.Keyless {
+		return false, nil
+	}
+	image := mut.Data
+	if mut.IsDelete() {
+		image = mut.Before
+	}
+	if len(image) == 0 {
+		return false, nil
+	}
+	bag, err := a.decodeBagLocked(ctx, image)
+	if err != nil {
+		return false, err
+	}
+	return !bag.Mapped.GetZero(rowID).Valid, nil
+}
+
+// keylessLocked applies a single mutation to a table without a primary
+// key. If the mutation has a before-image, at most one matching row
+// will be deleted or updated. Otherwise, a new row is inserted.
+func (a *apply) keylessLocked(ctx context.Context, db types.TargetQuerier, mut types.Mutation) error {
+	matchBefore := len(mut.Before) > 0
+	writeAfter := !mut.IsDelete()
+
+	var allArgs []any
+	if writeAfter {
+		bag, err := a.decodeBagLocked(ctx, mut.Data)
+		if err != nil {
+			return err
+		}
+		args, err := a.beforeArgsLocked(bag)
+		if err != nil {
+			return err
+		}
+		allArgs = append(allArgs, args...)
+	}
+	if matchBefore {
+		bag, err := a.decodeBagLocked(ctx, mut.Before)
+		if err != nil {
+			return err
+		}
+		args, err := a.beforeArgsLocked(bag)
+		if err != nil {
+			return err
+		}
+		allArgs = append(allArgs, args...)
+	}
+
+	stmt, err := a.cache.Prepare(ctx,
+		db,
+		fmt.Sprintf("before-%s-%d-%t-%t", a.target, a.mu.gen, matchBefore, writeAfter),
+		func() (string, error) {
+			return a.mu.templates.beforeExpr(matchBefore, writeAfter)
+		})
+	if err != nil {
+		return err
+	}
+
+	tag, err := stmt.ExecContext(ctx, allArgs...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	affected, err := tag.RowsAffected()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if writeAfter {
+		a.upserts.Add(float64(affected))
+	} else {
+		a.deletes.Add(float64(affected))
+	}
+	if matchBefore && affected == 0 {
+		log.WithFields(log.Fields{
+			"key":    string(mut.Key),
+			"target": a.target,
+		}).Debug("no row matched before-image")
+	}
+	return nil
+}
+
+// beforeArgsLocked returns the values in the bag for each of the
+// columns used to match or write a row in a keyless table.
+func (a *apply) beforeArgsLocked(bag *merge.Bag) ([]any, error) {
+	for _, ignored := range a.mu.templates.Ignore {
+		bag.Delete(ignored)
+	}
+	var extras any
+	if a.mu.templates.ExtrasColIdx == -1 {
+		if err := merge.ValidateNoUnmappedColumns(bag); err != nil {
+			return nil, errors.Wrapf(err, "schema drift detected in %s", a.target)
+		}
+	} else {
+		extraJSONBytes, err := json.Marshal(&bag.Unmapped)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not encode extras column value")
+		}
+		extras = string(extraJSONBytes)
+	}
+
+	cols := a.mu.templates.beforeColumns()
+	ret := make([]any, len(cols))
+	for idx, col := range cols {
+		if a.mu.templates.Positions.GetZero(col.Name).UpsertIndex == a.mu.templates.ExtrasColIdx {
+			ret[idx] = extras
+			continue
+		}
+		entry := bag.Mapped.GetZero(col.Name)
+		if entry == nil || !entry.Valid {
+			continue
+		}
+		value, err := targetValue(entry)
+		if err != nil {
+			return nil, err
+		}
+		ret[idx] = value
+	}
+	return ret, nil
+}
+
+// keyFromBeforeLocked extracts the values of the target's primary key
+// columns from the mutation's before-image.
+func (a *apply) keyFromBeforeLocked(ctx context.Context, mut types.Mutation) ([]any, error) {
+	bag, err := a.decodeBagLocked(ctx, mut.Before)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]any, len(a.mu.templates.PKDelete))
+	for idx, col := range a.mu.templates.PKDelete {
+		entry := bag.Mapped.GetZero(col.Name)
+		if entry == nil || !entry.Valid {
+			return nil, errors.Errorf(
+				"schema drift detected in %s: before-image is missing key column %s: %s@%s",
+				a.target, col.Name, string(mut.Key), mut.Time)
+		}
+		ret[idx] = entry.Value
+	}
+	return ret, nil
+}
+
+// decodeBagLocked decodes a single JSON object into a property bag.
+func (a *apply) decodeBagLocked(ctx context.Context, data []byte) (*merge.Bag, error) {
+	bags := []*merge.Bag{a.newBagLocked()}
+	if err := pjson.Decode(ctx, bags, func(int) []byte { return data }); err != nil {
+		return nil, err
+	}
+	return bags[0], nil
+}
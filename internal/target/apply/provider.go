@@ -17,6 +17,7 @@
 package apply
 
 import (
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
@@ -39,6 +40,7 @@ func ProvideFactory(
 	configs *applycfg.Configs,
 	diags *diag.Diagnostics,
 	dlqs types.DLQs,
+	evolver *evolve.Evolver,
 	target *types.TargetPool,
 	watchers types.Watchers,
 ) (types.Appliers, error) {
@@ -46,6 +48,7 @@ func ProvideFactory(
 		cache:    cache,
 		configs:  configs,
 		dlqs:     dlqs,
+		evolver:  evolver,
 		product:  target.Product,
 		stop:     ctx,
 		watchers: watchers,
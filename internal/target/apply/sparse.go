@@ -0,0 +1,160 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/jsondiff"
+	"github.com/cockroachdb/cdc-sink/internal/util/merge"
+	"github.com/cockroachdb/cdc-sink/internal/util/pjson"
+	"github.com/pkg/errors"
+)
+
+// This file contains support for sparse updates, where a source only
+// reports the columns of a row that have changed. The current contents
+// of the row are read from the target and used to fill in any columns
+// which are absent from the payload.
+
+// sparseLocked applies a single mutation that is marked with
+// types.SparseUpdate. If the target row does not exist, the absent
+// columns are treated as unset and will receive their default values.
+func (a *apply) sparseLocked(ctx context.Context, db types.TargetQuerier, mut types.Mutation) error {
+	bag, err := a.decodeBagLocked(ctx, mut.Data)
+	if err != nil {
+		return err
+	}
+	if err := merge.ValidatePK(bag); err != nil {
+		return errors.Wrapf(err, "schema drift detected in %s", a.target)
+	}
+
+	current, err := a.lookupLocked(ctx, db, mut)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		for idx, col := range a.mu.templates.Columns {
+			// Don't re-apply a user-defined expression to a value that
+			// has already been transformed by it.
+			if _, hasExpr := a.mu.templates.Exprs.Get(col.Name); hasExpr {
+				continue
+			}
+			if entry := bag.Mapped.GetZero(col.Name); entry != nil && !entry.Valid {
+				bag.Put(col.Name, current[idx])
+			}
+		}
+	}
+
+	if err := a.applyJSONDiffsLocked(bag, mut); err != nil {
+		return err
+	}
+
+	return a.upsertBagsLocked(ctx, db, applyUnconditional, nil, []*merge.Bag{bag}, "")
+}
+
+// lookupLocked returns the current values of the target row's columns,
+// in the order given by the templates, or nil if the row does not
+// exist.
+func (a *apply) lookupLocked(
+	ctx context.Context, db types.TargetQuerier, mut types.Mutation,
+) ([]any, error) {
+	keyGroups := make([][]any, 1)
+	if err := pjson.Decode(ctx, keyGroups, func(int) []byte { return mut.Key }); err != nil {
+		return nil, err
+	}
+	args := keyGroups[0]
+	if len(args) != len(a.mu.templates.PKDelete) {
+		return nil, errors.Errorf(
+			"schema drift detected in %s: "+
+				"inconsistent number of key columns: "+
+				"received %d expect %d: "+
+				"key %s@%s",
+			a.target,
+			len(args), len(a.mu.templates.PKDelete),
+			string(mut.Key), mut.Time)
+	}
+
+	stmt, err := a.cache.Prepare(ctx,
+		db,
+		fmt.Sprintf("lookup-%s-%d", a.target, a.mu.gen),
+		a.mu.templates.lookupExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errors.WithStack(rows.Err())
+	}
+	ret := make([]any, len(a.mu.templates.Columns))
+	scanPtrs := make([]any, len(ret))
+	for i := range ret {
+		scanPtrs[i] = &ret[i]
+	}
+	if err := rows.Scan(scanPtrs...); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ret, nil
+}
+
+// applyJSONDiffsLocked updates the JSON columns in the bag with any
+// partial updates provided by the mutation.
+func (a *apply) applyJSONDiffsLocked(bag *merge.Bag, mut types.Mutation) error {
+	found, ok := mut.Meta[types.JSONDiffs]
+	if !ok {
+		return nil
+	}
+	diffs, ok := found.(map[string][]jsondiff.Diff)
+	if !ok {
+		return errors.Errorf("invalid value for Meta[%s]", types.JSONDiffs)
+	}
+	for name, colDiffs := range diffs {
+		col := ident.New(name)
+		var doc []byte
+		switch t := bag.GetZero(col).(type) {
+		case nil:
+			return errors.Errorf(
+				"cannot apply partial JSON update to %s.%s: no current value: %s@%s",
+				a.target, col, string(mut.Key), mut.Time)
+		case []byte:
+			doc = t
+		case string:
+			doc = []byte(t)
+		default:
+			var err error
+			doc, err = json.Marshal(t)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		next, err := jsondiff.Apply(doc, colDiffs)
+		if err != nil {
+			return errors.Wrapf(err, "cannot apply partial JSON update to %s.%s", a.target, col)
+		}
+		bag.Put(col, string(next))
+	}
+	return nil
+}
@@ -165,14 +165,18 @@ type templates struct {
 	BulkDelete bool
 	BulkUpsert bool
 
+	before      *template.Template // May be nil if unsupported.
 	conditional *template.Template
 	delete      *template.Template
+	lookup      *template.Template // May be nil if unsupported.
 	upsert      *template.Template
 
 	tmpl *template.Template
 	// The variables below here are updated during evaluation.
-	ForDelete bool // True if we only iterate over PKs to delete
-	RowCount  int  // The number of rows to be applied.
+	ForDelete   bool // True if we only iterate over PKs to delete
+	MatchBefore bool // True if a row is located by its before-image.
+	RowCount    int  // The number of rows to be applied.
+	WriteAfter  bool // True if a new row image will be written.
 }
 
 // newTemplates constructs a new templates instance, performing some
@@ -183,14 +187,18 @@ func newTemplates(mapping *columnMapping) (*templates, error) {
 
 	switch mapping.Product {
 	case types.ProductCockroachDB:
+		ret.before = tmplCRDB.Lookup("before.tmpl")
 		ret.conditional = tmplCRDB.Lookup("conditional.tmpl")
 		ret.delete = tmplCRDB.Lookup("delete.tmpl")
+		ret.lookup = tmplCRDB.Lookup("lookup.tmpl")
 		ret.upsert = tmplCRDB.Lookup("upsert.tmpl")
 		ret.tmpl = tmplCRDB
 
 	case types.ProductMariaDB, types.ProductMySQL:
+		ret.before = tmplMy.Lookup("before.tmpl")
 		ret.conditional = tmplMy.Lookup("conditional.tmpl")
 		ret.delete = tmplMy.Lookup("delete.tmpl")
+		ret.lookup = tmplMy.Lookup("lookup.tmpl")
 		ret.upsert = tmplMy.Lookup("upsert.tmpl")
 		ret.tmpl = tmplMy
 
@@ -203,8 +211,10 @@ func newTemplates(mapping *columnMapping) (*templates, error) {
 		ret.conditional = ret.upsert
 		ret.tmpl = tmplOra
 	case types.ProductPostgreSQL:
+		ret.before = tmplPG.Lookup("before.tmpl")
 		ret.conditional = tmplPG.Lookup("conditional.tmpl")
 		ret.delete = tmplPG.Lookup("delete.tmpl")
+		ret.lookup = tmplPG.Lookup("lookup.tmpl")
 		ret.upsert = tmplPG.Lookup("upsert.tmpl")
 		ret.tmpl = tmplPG
 
@@ -267,20 +277,8 @@ func (t *templates) Vars() ([][]varPair, error) {
 				}
 			}
 
-			if pattern, ok := t.Exprs.Get(col.Name); ok {
-				var reference string
-				switch t.Product {
-				case types.ProductCockroachDB, types.ProductPostgreSQL:
-					reference = fmt.Sprintf("$%d", vp.Param)
-				case types.ProductMariaDB, types.ProductMySQL:
-					reference = "?"
-				case types.ProductOracle:
-					reference = fmt.Sprintf(":ref%d", vp.Param)
-				default:
-					return nil, errors.Errorf("unimplemented product %s", t.Product)
-				}
-
-				vp.Expr = strings.ReplaceAll(pattern, applycfg.SubstitutionToken, reference)
+			if err := t.substituteExpr(&vp); err != nil {
+				return nil, err
 			}
 
 			ret[row][colIdx] = vp
@@ -289,6 +287,101 @@ func (t *templates) Vars() ([][]varPair, error) {
 	return ret, nil
 }
 
+// AfterVars returns the substitution parameters for writing the data
+// columns of a single row. These parameters always come first.
+func (t *templates) AfterVars() ([]varPair, error) {
+	ret := make([]varPair, len(t.Data))
+	param := 0
+	for idx, col := range t.Data {
+		ret[idx] = varPair{Column: col}
+		// Columns with a fixed expression don't consume a parameter.
+		if t.Positions.GetZero(col.Name).UpsertIndex >= 0 {
+			param++
+			ret[idx].Param = param
+		}
+		if err := t.substituteExpr(&ret[idx]); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// BeforeVars returns the substitution parameters for matching a
+// single row by the values of its data columns. User-configured
+// expressions are not applied, since the before-image must match what
+// was previously written.
+func (t *templates) BeforeVars() []varPair {
+	cols := t.beforeColumns()
+	offset := 0
+	if t.WriteAfter {
+		offset = len(cols)
+	}
+	ret := make([]varPair, len(cols))
+	for idx, col := range cols {
+		ret[idx] = varPair{Column: col, Param: offset + idx + 1}
+	}
+	return ret
+}
+
+// beforeColumns returns the data columns which receive a value from
+// the incoming payload. These are the columns used to match a row by
+// its before-image.
+func (t *templates) beforeColumns() []types.ColData {
+	ret := make([]types.ColData, 0, len(t.Data))
+	for _, col := range t.Data {
+		if t.Positions.GetZero(col.Name).UpsertIndex >= 0 {
+			ret = append(ret, col)
+		}
+	}
+	return ret
+}
+
+// substituteExpr populates the varPair's Expr field if the user has
+// configured an expression for the column.
+func (t *templates) substituteExpr(vp *varPair) error {
+	pattern, ok := t.Exprs.Get(vp.Column.Name)
+	if !ok {
+		return nil
+	}
+	var reference string
+	switch t.Product {
+	case types.ProductCockroachDB, types.ProductPostgreSQL:
+		reference = fmt.Sprintf("$%d", vp.Param)
+	case types.ProductMariaDB, types.ProductMySQL:
+		reference = "?"
+	case types.ProductOracle:
+		reference = fmt.Sprintf(":ref%d", vp.Param)
+	default:
+		return errors.Errorf("unimplemented product %s", t.Product)
+	}
+
+	vp.Expr = strings.ReplaceAll(pattern, applycfg.SubstitutionToken, reference)
+	return nil
+}
+
+// beforeExpr generates a single-row statement for a keyless table. If
+// matchBefore is set, at most one row matching the before-image will be
+// deleted or, if writeAfter is also set, updated. Otherwise, a new row
+// will be inserted.
+func (t *templates) beforeExpr(matchBefore, writeAfter bool) (string, error) {
+	if t.before == nil {
+		return "", errors.Errorf("tables without a primary key are not supported for %s", t.Product)
+	}
+	if !matchBefore && !writeAfter {
+		return "", errors.New("nothing to do")
+	}
+
+	// Make a copy that we can tweak.
+	cpy := *t
+	cpy.MatchBefore = matchBefore
+	cpy.RowCount = 1
+	cpy.WriteAfter = writeAfter
+
+	var buf strings.Builder
+	err := t.before.Execute(&buf, &cpy)
+	return buf.String(), errors.WithStack(err)
+}
+
 func (t *templates) deleteExpr(rowCount int) (string, error) {
 	if t.BulkDelete {
 		rowCount = 1
@@ -304,6 +397,23 @@ func (t *templates) deleteExpr(rowCount int) (string, error) {
 	return buf.String(), errors.WithStack(err)
 }
 
+// lookupExpr generates a statement that reads the current contents of
+// a single row, given its primary key.
+func (t *templates) lookupExpr() (string, error) {
+	if t.lookup == nil {
+		return "", errors.Errorf("sparse updates are not supported for %s", t.Product)
+	}
+
+	// Make a copy that we can tweak.
+	cpy := *t
+	cpy.ForDelete = true
+	cpy.RowCount = 1
+
+	var buf strings.Builder
+	err := t.lookup.Execute(&buf, &cpy)
+	return buf.String(), errors.WithStack(err)
+}
+
 func (t *templates) customExpr(rowCount int, name string, mode applyMode) (string, error) {
 	if mode != applyUnconditional {
 		return "", errors.New("custom templates supported only with applyUnconditional")
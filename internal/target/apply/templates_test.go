@@ -453,6 +453,116 @@ func testQueryTemplates(t *testing.T, product types.Product) {
 	}
 }
 
+func TestKeylessTemplates(t *testing.T) {
+	for _, product := range []types.Product{
+		types.ProductCockroachDB,
+		types.ProductMySQL,
+		types.ProductPostgreSQL,
+	} {
+		t.Run(product.String(), func(t *testing.T) { testKeylessTemplates(t, product) })
+	}
+}
+
+func testKeylessTemplates(t *testing.T, product types.Product) {
+	t.Helper()
+	global := &templateGlobal{
+		cols: []types.ColData{
+			{
+				Name:    ident.New("rowid"),
+				Primary: true,
+				Type:    "INT8",
+			},
+			{
+				Name: ident.New("val0"),
+				Type: "STRING",
+			},
+			{
+				Name: ident.New("val1"),
+				Type: "STRING",
+			},
+			{
+				Ignored: true,
+				Name:    ident.New("ignored_val"),
+				Type:    "STRING",
+			},
+			{
+				Name: ident.New("geom"),
+				Type: "GEOMETRY",
+			},
+		},
+		product: product,
+		tableID: ident.NewTable(
+			ident.MustSchema(ident.New("database"), ident.New("schema")),
+			ident.New("table")),
+	}
+	switch product {
+	case types.ProductCockroachDB:
+		global.dir = "crdb"
+	case types.ProductMySQL:
+		global.dir = "my"
+		global.cols[4].Type = "geometry"
+		// JSON values must be cast to be compared.
+		global.cols = append(global.cols, types.ColData{
+			Name: ident.New("data"),
+			Type: "json",
+		})
+		global.tableID = ident.NewTable(
+			ident.MustSchema(ident.New("schema")),
+			ident.New("table"))
+	case types.ProductPostgreSQL:
+		global.dir = "pg"
+	default:
+		t.Fatalf("unimplemented: %s", product)
+	}
+
+	tcs := []*templateTestCase{
+		{
+			name: "keyless",
+		},
+		{
+			// The fixed expression doesn't consume a parameter and
+			// the column isn't used to match the before-image.
+			name: "keyless_expr",
+			cfg: &applycfg.Config{
+				Exprs: ident.MapOf[string](
+					ident.New("val0"), `'fixed'`,
+					ident.New("val1"), `$0||'foobar'`,
+				),
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+			cfg := applycfg.NewConfig()
+			if tc.cfg != nil {
+				cfg.Patch(tc.cfg)
+			}
+			mapping, err := newColumnMapping(cfg, global.cols, global.product, global.tableID)
+			r.NoError(err)
+			r.True(mapping.Keyless)
+			tmpls, err := newTemplates(mapping)
+			r.NoError(err)
+
+			for _, op := range []struct {
+				name                    string
+				matchBefore, writeAfter bool
+			}{
+				{"delete", true, false},
+				{"insert", false, true},
+				{"update", true, true},
+			} {
+				s, err := tmpls.beforeExpr(op.matchBefore, op.writeAfter)
+				r.NoError(err)
+				checkFile(t,
+					fmt.Sprintf("testdata/%s/%s.%s.sql", global.dir, tc.name, op.name),
+					s)
+			}
+		})
+	}
+}
+
 type templateGlobal struct {
 	cols    []types.ColData
 	dir     string
@@ -499,6 +609,18 @@ func checkTemplate(t *testing.T, global *templateGlobal, tc *templateTestCase) {
 			fmt.Sprintf("testdata/%s/%s.toasted.sql", global.dir, tc.name),
 			s)
 	})
+	t.Run("lookup", func(t *testing.T) {
+		r := require.New(t)
+
+		if tc.name != "base" || global.product == types.ProductOracle {
+			t.Skip("lookup only for base, not supported by oracle")
+		}
+		s, err := tmpls.lookupExpr()
+		r.NoError(err)
+		checkFile(t,
+			fmt.Sprintf("testdata/%s/%s.lookup.sql", global.dir, tc.name),
+			s)
+	})
 	t.Run("delete", func(t *testing.T) {
 		r := require.New(t)
 		s, err := tmpls.deleteExpr(2)
@@ -0,0 +1,4 @@
+SELECT "pk0","pk1","val0","val1","geom","geog","enum","has_default"
+FROM "database"."schema"."table"
+WHERE ("pk0","pk1","ignored_pk")IN(($1::STRING,$2::INT8,$3::STRING))
+FOR UPDATE
\ No newline at end of file
@@ -0,0 +1,3 @@
+DELETE FROM "database"."schema"."table"
+WHERE "val0" IS NOT DISTINCT FROM $1::STRING AND "val1" IS NOT DISTINCT FROM $2::STRING AND "geom" IS NOT DISTINCT FROM st_geomfromgeojson($3::JSONB)
+LIMIT 1
\ No newline at end of file
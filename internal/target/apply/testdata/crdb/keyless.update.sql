@@ -0,0 +1,4 @@
+UPDATE "database"."schema"."table"
+SET "val0" = $1::STRING, "val1" = $2::STRING, "geom" = st_geomfromgeojson($3::JSONB)
+WHERE "val0" IS NOT DISTINCT FROM $4::STRING AND "val1" IS NOT DISTINCT FROM $5::STRING AND "geom" IS NOT DISTINCT FROM st_geomfromgeojson($6::JSONB)
+LIMIT 1
\ No newline at end of file
@@ -0,0 +1,2 @@
+INSERT INTO "database"."schema"."table" ("val0","val1","geom")
+VALUES (('fixed')::STRING,($1||'foobar')::STRING,st_geomfromgeojson($2::JSONB))
\ No newline at end of file
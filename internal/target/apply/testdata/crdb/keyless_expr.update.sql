@@ -0,0 +1,4 @@
+UPDATE "database"."schema"."table"
+SET "val0" = ('fixed')::STRING, "val1" = ($1||'foobar')::STRING, "geom" = st_geomfromgeojson($2::JSONB)
+WHERE "val1" IS NOT DISTINCT FROM $3::STRING AND "geom" IS NOT DISTINCT FROM st_geomfromgeojson($4::JSONB)
+LIMIT 1
\ No newline at end of file
@@ -0,0 +1,4 @@
+SELECT "pk0","pk1","val0","val1","has_default"
+FROM "schema"."table"
+WHERE ("pk0","pk1")IN((?,?))
+FOR UPDATE
\ No newline at end of file
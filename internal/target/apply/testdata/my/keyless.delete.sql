@@ -0,0 +1,3 @@
+DELETE FROM "schema"."table"
+WHERE "val0" <=> ? AND "val1" <=> ? AND "geom" <=> st_geomfromgeojson(?) AND "data" <=> CAST(? AS JSON)
+LIMIT 1
\ No newline at end of file
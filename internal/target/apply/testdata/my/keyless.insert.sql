@@ -0,0 +1,2 @@
+INSERT INTO "schema"."table" ("val0","val1","geom","data")
+VALUES (?,?,st_geomfromgeojson(?),?)
\ No newline at end of file
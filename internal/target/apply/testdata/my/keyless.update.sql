@@ -0,0 +1,4 @@
+UPDATE "schema"."table"
+SET "val0" = ?, "val1" = ?, "geom" = st_geomfromgeojson(?), "data" = ?
+WHERE "val0" <=> ? AND "val1" <=> ? AND "geom" <=> st_geomfromgeojson(?) AND "data" <=> CAST(? AS JSON)
+LIMIT 1
\ No newline at end of file
@@ -0,0 +1,4 @@
+UPDATE "schema"."table"
+SET "val0" = ('fixed'), "val1" = (?||'foobar'), "geom" = st_geomfromgeojson(?), "data" = ?
+WHERE "val1" <=> ? AND "geom" <=> st_geomfromgeojson(?) AND "data" <=> CAST(? AS JSON)
+LIMIT 1
\ No newline at end of file
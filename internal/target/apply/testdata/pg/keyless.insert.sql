@@ -0,0 +1,2 @@
+INSERT INTO "database"."schema"."table" ("val0","val1","geom")
+VALUES ($1::STRING,$2::STRING,st_geomfromgeojson($3::JSONB))
\ No newline at end of file
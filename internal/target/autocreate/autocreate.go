@@ -0,0 +1,162 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package autocreate creates target tables from source metadata.
+package autocreate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/metrics"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// A Column describes a source column in a product-neutral way.
+type Column struct {
+	Kind    Kind
+	Name    ident.Ident
+	Primary bool
+}
+
+// Columns converts column data, such as that provided by a
+// [types.Watcher] attached to a source database, into Columns. Ignored
+// columns are omitted.
+func Columns(cols []types.ColData) []Column {
+	ret := make([]Column, 0, len(cols))
+	for _, col := range cols {
+		if col.Ignored {
+			continue
+		}
+		ret = append(ret, Column{
+			Kind:    KindOf(col.Type),
+			Name:    col.Name,
+			Primary: col.Primary,
+		})
+	}
+	return ret
+}
+
+// Creator creates target tables on demand.
+type Creator struct {
+	pool     *types.TargetPool
+	typeMap  *pgtype.Map // Only read from, so it may be shared.
+	watchers types.Watchers
+}
+
+// KindOfOID returns the Kind of a PostgreSQL type, or KindString if the
+// type is not known.
+func (c *Creator) KindOfOID(oid uint32) Kind {
+	if typ, ok := c.typeMap.TypeForOID(oid); ok {
+		return KindOf(typ.Name)
+	}
+	return KindString
+}
+
+// Ensure creates the table in the target database if it is not already
+// known to the target's schema watcher. The watcher will have been
+// refreshed by the time this method returns. Tables are created with
+// the columns and primary key given; no attempt is made to preserve the
+// length or precision of the source columns.
+//
+// A source table without a primary key is only created in CockroachDB,
+// which adds the synthetic rowid column that is used to apply
+// mutations to keyless tables. Such tables are skipped with a warning
+// in other targets and must be created by the user.
+func (c *Creator) Ensure(ctx context.Context, table ident.Table, cols []Column) error {
+	watcher, err := c.watchers.Get(table.Schema())
+	if err != nil {
+		return err
+	}
+	if _, ok := watcher.Get().Columns.Get(table); ok {
+		return nil
+	}
+	if !hasPrimary(cols) && c.pool.Product != types.ProductCockroachDB {
+		log.WithField("table", table).Warn(
+			"not creating target table, since the source table has no primary key")
+		return nil
+	}
+
+	stmt, err := createStatement(c.pool.Product, table, cols)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"stmt":  stmt,
+		"table": table,
+	}).Info("creating target table")
+	if _, err := c.pool.ExecContext(ctx, stmt); err != nil {
+		// Another instance may have created the table concurrently.
+		if refreshErr := watcher.Refresh(ctx, c.pool); refreshErr == nil {
+			if _, ok := watcher.Get().Columns.Get(table); ok {
+				return nil
+			}
+		}
+		return errors.Wrapf(err, "could not create table %s", table)
+	}
+	tablesCreated.WithLabelValues(metrics.TableValues(table)...).Inc()
+
+	return errors.Wrapf(watcher.Refresh(ctx, c.pool),
+		"could not refresh schema after creating %s", table)
+}
+
+// createStatement returns a CREATE TABLE statement for the target
+// product.
+func createStatement(product types.Product, table ident.Table, cols []Column) (string, error) {
+	var defs, pks []string
+	for _, col := range cols {
+		typ, err := SQLType(product, col.Kind, col.Primary)
+		if err != nil {
+			return "", errors.Wrapf(err, "column %s", col.Name)
+		}
+		def := fmt.Sprintf("%s %s", col.Name, typ)
+		if col.Primary {
+			def += " NOT NULL"
+			pks = append(pks, col.Name.String())
+		}
+		defs = append(defs, def)
+	}
+	// CockroachDB adds a rowid column to a table without a primary key.
+	if len(pks) > 0 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(pks, ", ")))
+	} else if product != types.ProductCockroachDB {
+		return "", errors.Errorf("cannot create %s: source table has no primary key", table)
+	}
+
+	// Oracle does not support IF NOT EXISTS until 23c.
+	ifNotExists := "IF NOT EXISTS "
+	if product == types.ProductOracle {
+		ifNotExists = ""
+	}
+	return fmt.Sprintf("CREATE TABLE %s%s (%s)",
+		ifNotExists, table, strings.Join(defs, ", ")), nil
+}
+
+// hasPrimary returns true if any of the columns is part of the primary
+// key.
+func hasPrimary(cols []Column) bool {
+	for _, col := range cols {
+		if col.Primary {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package autocreate_test
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsure(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	fixture, err := all.NewFixture(t)
+	r.NoError(err)
+	ctx := fixture.Context
+
+	creator := autocreate.ProvideCreator(fixture.TargetPool, fixture.Watchers)
+	tbl := ident.NewTable(fixture.TargetSchema.Schema(), ident.New("created"))
+	cols := []autocreate.Column{
+		{Kind: autocreate.KindInt, Name: ident.New("pk"), Primary: true},
+		{Kind: autocreate.KindString, Name: ident.New("val")},
+	}
+
+	r.NoError(creator.Ensure(ctx, tbl, cols))
+	found, ok := fixture.Watcher.Get().Columns.Get(tbl)
+	r.True(ok)
+	r.Len(found, 2)
+	a.True(found[0].Primary)
+	a.True(ident.Equal(ident.New("pk"), found[0].Name))
+	a.False(found[1].Primary)
+	a.True(ident.Equal(ident.New("val"), found[1].Name))
+
+	// A second call should be a no-op.
+	r.NoError(creator.Ensure(ctx, tbl, cols))
+}
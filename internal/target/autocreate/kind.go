@@ -0,0 +1,228 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package autocreate
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/pkg/errors"
+)
+
+// Kind is a product-neutral description of a column's type.
+type Kind int
+
+// Kinds which may be mapped onto target products.
+const (
+	KindUnknown Kind = iota
+	KindBool
+	KindBytes
+	KindDate
+	KindDecimal
+	KindFloat
+	KindInt
+	KindJSON
+	KindString
+	KindTime
+	KindTimestamp
+	KindTimestampTZ
+	KindUUID
+)
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type=Kind -trimprefix Kind
+
+// KindOf maps a CockroachDB or PostgreSQL type name to a Kind. The
+// name may be qualified or quoted, as is returned by a [types.Watcher],
+// or may be formatted as by PostgreSQL's format_type() function.
+// Types which are not otherwise recognized are mapped to KindString,
+// since the replication sources deliver those values as text.
+func KindOf(name string) Kind {
+	if idx := strings.Index(name, " COLLATE "); idx >= 0 {
+		name = name[:idx]
+	}
+	// Remove any length or precision, e.g. "timestamp(6) with time zone".
+	if open := strings.Index(name, "("); open >= 0 {
+		if close := strings.Index(name[open:], ")"); close >= 0 {
+			name = name[:open] + name[open+close+1:]
+		}
+	}
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	name = strings.ToLower(strings.Trim(strings.TrimSpace(name), `"`))
+
+	switch name {
+	case "bool", "boolean":
+		return KindBool
+	case "bytea", "bytes":
+		return KindBytes
+	case "date":
+		return KindDate
+	case "decimal", "numeric":
+		return KindDecimal
+	case "double precision", "float", "float4", "float8", "real":
+		return KindFloat
+	case "bigint", "int", "int2", "int4", "int8", "integer", "oid", "smallint":
+		return KindInt
+	case "json", "jsonb":
+		return KindJSON
+	case "time", "time with time zone", "time without time zone", "timetz":
+		return KindTime
+	case "timestamp", "timestamp without time zone":
+		return KindTimestamp
+	case "timestamp with time zone", "timestamptz":
+		return KindTimestampTZ
+	case "uuid":
+		return KindUUID
+	default:
+		return KindString
+	}
+}
+
+// SQLType returns the type to use in the target product. Primary key
+// columns may require a bounded type.
+func SQLType(product types.Product, kind Kind, primary bool) (string, error) {
+	switch product {
+	case types.ProductCockroachDB:
+		switch kind {
+		case KindBool:
+			return "BOOL", nil
+		case KindBytes:
+			return "BYTES", nil
+		case KindDate:
+			return "DATE", nil
+		case KindDecimal:
+			return "DECIMAL", nil
+		case KindFloat:
+			return "FLOAT8", nil
+		case KindInt:
+			return "INT8", nil
+		case KindJSON:
+			return "JSONB", nil
+		case KindString:
+			return "STRING", nil
+		case KindTime:
+			return "TIME", nil
+		case KindTimestamp:
+			return "TIMESTAMP", nil
+		case KindTimestampTZ:
+			return "TIMESTAMPTZ", nil
+		case KindUUID:
+			return "UUID", nil
+		}
+
+	case types.ProductMariaDB, types.ProductMySQL:
+		switch kind {
+		case KindBool:
+			return "BOOLEAN", nil
+		case KindBytes:
+			if primary {
+				return "VARBINARY(255)", nil
+			}
+			return "LONGBLOB", nil
+		case KindDate:
+			return "DATE", nil
+		case KindDecimal:
+			return "DECIMAL(65,30)", nil
+		case KindFloat:
+			return "DOUBLE", nil
+		case KindInt:
+			return "BIGINT", nil
+		case KindJSON:
+			return "JSON", nil
+		case KindString:
+			if primary {
+				return "VARCHAR(255)", nil
+			}
+			return "LONGTEXT", nil
+		case KindTime:
+			return "TIME(6)", nil
+		case KindTimestamp, KindTimestampTZ:
+			// MySQL's TIMESTAMP type has a limited range.
+			return "DATETIME(6)", nil
+		case KindUUID:
+			return "CHAR(36)", nil
+		}
+
+	case types.ProductOracle:
+		switch kind {
+		case KindBool:
+			return "NUMBER(1)", nil
+		case KindBytes:
+			if primary {
+				return "RAW(2000)", nil
+			}
+			return "BLOB", nil
+		case KindDate:
+			return "DATE", nil
+		case KindDecimal:
+			return "NUMBER", nil
+		case KindFloat:
+			return "BINARY_DOUBLE", nil
+		case KindInt:
+			return "NUMBER(19)", nil
+		case KindJSON:
+			return "CLOB", nil
+		case KindString:
+			if primary {
+				return "VARCHAR2(4000)", nil
+			}
+			return "CLOB", nil
+		case KindTime:
+			// Oracle has no time-of-day type.
+			return "VARCHAR2(32)", nil
+		case KindTimestamp:
+			return "TIMESTAMP", nil
+		case KindTimestampTZ:
+			return "TIMESTAMP WITH TIME ZONE", nil
+		case KindUUID:
+			return "VARCHAR2(36)", nil
+		}
+
+	case types.ProductPostgreSQL:
+		switch kind {
+		case KindBool:
+			return "BOOLEAN", nil
+		case KindBytes:
+			return "BYTEA", nil
+		case KindDate:
+			return "DATE", nil
+		case KindDecimal:
+			return "NUMERIC", nil
+		case KindFloat:
+			return "DOUBLE PRECISION", nil
+		case KindInt:
+			return "BIGINT", nil
+		case KindJSON:
+			return "JSONB", nil
+		case KindString:
+			return "TEXT", nil
+		case KindTime:
+			return "TIME", nil
+		case KindTimestamp:
+			return "TIMESTAMP", nil
+		case KindTimestampTZ:
+			return "TIMESTAMPTZ", nil
+		case KindUUID:
+			return "UUID", nil
+		}
+
+	default:
+		return "", errors.Errorf("unimplemented product: %s", product)
+	}
+	return "", errors.Errorf("unsupported kind %s for %s", kind, product)
+}
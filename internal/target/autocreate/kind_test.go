@@ -0,0 +1,106 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package autocreate
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKindOf(t *testing.T) {
+	tcs := []struct {
+		name     string
+		expected Kind
+	}{
+		{`"db"."pg_catalog"."int8"`, KindInt},
+		{`"db"."pg_catalog"."text" COLLATE en_US`, KindString},
+		{`"db"."pg_catalog"."jsonb"`, KindJSON},
+		{"bigint", KindInt},
+		{"boolean", KindBool},
+		{"bytea", KindBytes},
+		{"character varying(255)", KindString},
+		{"date", KindDate},
+		{"double precision", KindFloat},
+		{"integer[]", KindString},
+		{"numeric(10,2)", KindDecimal},
+		{"timestamp(6) with time zone", KindTimestampTZ},
+		{"timestamp without time zone", KindTimestamp},
+		{"time without time zone", KindTime},
+		{"uuid", KindUUID},
+		{"inet", KindString},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, KindOf(tc.name))
+		})
+	}
+}
+
+func TestCreateStatement(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	cols := []Column{
+		{Kind: KindString, Name: ident.New("pk0"), Primary: true},
+		{Kind: KindInt, Name: ident.New("pk1"), Primary: true},
+		{Kind: KindString, Name: ident.New("val")},
+		{Kind: KindTimestampTZ, Name: ident.New("ts")},
+	}
+
+	crdb := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+	stmt, err := createStatement(types.ProductCockroachDB, crdb, cols)
+	r.NoError(err)
+	a.Equal(`CREATE TABLE IF NOT EXISTS "db"."public"."tbl" (`+
+		`"pk0" STRING NOT NULL, "pk1" INT8 NOT NULL, "val" STRING, "ts" TIMESTAMPTZ, `+
+		`PRIMARY KEY ("pk0", "pk1"))`, stmt)
+
+	my := ident.NewTable(ident.MustSchema(ident.New("db")), ident.New("tbl"))
+	stmt, err = createStatement(types.ProductMySQL, my, cols)
+	r.NoError(err)
+	a.Equal(`CREATE TABLE IF NOT EXISTS "db"."tbl" (`+
+		`"pk0" VARCHAR(255) NOT NULL, "pk1" BIGINT NOT NULL, "val" LONGTEXT, "ts" DATETIME(6), `+
+		`PRIMARY KEY ("pk0", "pk1"))`, stmt)
+
+	stmt, err = createStatement(types.ProductOracle, my, cols)
+	r.NoError(err)
+	a.Equal(`CREATE TABLE "db"."tbl" (`+
+		`"pk0" VARCHAR2(4000) NOT NULL, "pk1" NUMBER(19) NOT NULL, "val" CLOB, "ts" TIMESTAMP WITH TIME ZONE, `+
+		`PRIMARY KEY ("pk0", "pk1"))`, stmt)
+
+	// Keyless tables rely on CockroachDB's synthetic rowid column.
+	stmt, err = createStatement(types.ProductCockroachDB, crdb, cols[2:])
+	r.NoError(err)
+	a.Equal(`CREATE TABLE IF NOT EXISTS "db"."public"."tbl" (`+
+		`"val" STRING, "ts" TIMESTAMPTZ)`, stmt)
+
+	_, err = createStatement(types.ProductPostgreSQL, crdb, cols[2:])
+	a.ErrorContains(err, "no primary key")
+}
+
+func TestKindOfOID(t *testing.T) {
+	a := assert.New(t)
+
+	c := ProvideCreator(nil, nil)
+	a.Equal(KindInt, c.KindOfOID(20))    // int8
+	a.Equal(KindJSON, c.KindOfOID(3802)) // jsonb
+	a.Equal(KindString, c.KindOfOID(0))  // unknown
+	a.Equal(KindUUID, c.KindOfOID(2950)) // uuid
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package evolve
+
+import (
+	"path"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// Config controls the schema-evolution behavior.
+type Config struct {
+	// Patterns of table.column names that may be added. If empty, any
+	// column may be added.
+	Allow []string
+	// Patterns of table.column names that must not be added.
+	Deny []string
+	// Target schemas in which columns may be added. Evolution is
+	// disabled if empty.
+	Schemas []string
+
+	// Set by Preflight.
+	parsed []ident.Schema
+}
+
+// Bind adds configuration flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	f.StringSliceVar(&c.Allow, "evolveAllow", nil,
+		"only add columns whose table.column name matches one of these glob patterns")
+	f.StringSliceVar(&c.Deny, "evolveDeny", nil,
+		"never add columns whose table.column name matches one of these glob patterns")
+	f.StringSliceVar(&c.Schemas, "evolveSchema", nil,
+		"add new source columns to tables in these target schemas, instead of reporting schema drift")
+}
+
+// Preflight validates the configuration.
+func (c *Config) Preflight() error {
+	for _, pattern := range append(append([]string(nil), c.Allow...), c.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid pattern %q", pattern)
+		}
+	}
+	c.parsed = c.parsed[:0]
+	for _, raw := range c.Schemas {
+		schema, err := ident.ParseSchema(raw)
+		if err != nil {
+			return err
+		}
+		c.parsed = append(c.parsed, schema)
+	}
+	return nil
+}
+
+// permitted returns true if the column may be added to the table.
+func (c *Config) permitted(table ident.Table, column ident.Ident) bool {
+	name := table.Table().Raw() + "." + column.Raw()
+	for _, pattern := range c.Deny {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(c.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range c.Allow {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
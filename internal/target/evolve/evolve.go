@@ -0,0 +1,280 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package evolve performs additive schema changes on the target when
+// the source adds columns to a table.
+package evolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/metrics"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// A Change records a schema change that was executed.
+type Change struct {
+	Column ident.Ident `json:"column"`
+	Error  string      `json:"error,omitempty"`
+	Stmt   string      `json:"stmt"`
+	Table  ident.Table `json:"table"`
+	Time   time.Time   `json:"time"`
+}
+
+// maxChanges limits the number of schema changes that are retained for
+// diagnostic purposes.
+const maxChanges = 128
+
+// Evolver adds columns to target tables. Schema changes are executed
+// in the background, since the caller will generally have an open
+// transaction on the table being altered. The caller is expected to
+// roll back and retry once the change has been made.
+type Evolver struct {
+	cfg      *Config
+	pool     *types.TargetPool
+	schemas  []ident.Schema
+	stop     *stopper.Context
+	watchers types.Watchers
+
+	mu struct {
+		sync.Mutex
+		changes []Change
+		hints   *ident.TableMap[*ident.Map[autocreate.Kind]]
+		pending *ident.TableMap[*ident.Map[struct{}]]
+	}
+}
+
+var _ diag.Diagnostic = (*Evolver)(nil)
+
+// Diagnostic implements [diag.Diagnostic]. It reports the schema
+// changes that have been executed.
+func (e *Evolver) Diagnostic(_ context.Context) any {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Change(nil), e.mu.changes...)
+}
+
+// Enabled returns true if columns may be added to the table.
+func (e *Evolver) Enabled(table ident.Table) bool {
+	if e == nil {
+		return false
+	}
+	for _, schema := range e.schemas {
+		if schema.Contains(table) {
+			return true
+		}
+	}
+	return false
+}
+
+// Hint records the kinds of the columns in a source table. These will
+// be preferred to inferring a column's type from its values.
+func (e *Evolver) Hint(table ident.Table, cols []autocreate.Column) {
+	if !e.Enabled(table) {
+		return
+	}
+	kinds := &ident.Map[autocreate.Kind]{}
+	for _, col := range cols {
+		kinds.Put(col.Name, col.Kind)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.mu.hints.Put(table, kinds)
+}
+
+// Request schedules the unmapped properties to be added as columns
+// to the table. An error will be returned if any of the columns may not
+// be added, in which case no changes will be made.
+func (e *Evolver) Request(table ident.Table, values []*ident.Map[any]) error {
+	kinds := &ident.Map[autocreate.Kind]{}
+	for _, row := range values {
+		if err := row.Range(func(col ident.Ident, value any) error {
+			if !e.cfg.permitted(table, col) {
+				return errors.Errorf("adding column %s to %s is not permitted", col, table)
+			}
+			kinds.Put(col, merge(kinds.GetZero(col), inferKind(value)))
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pending, ok := e.mu.pending.Get(table)
+	if !ok {
+		pending = &ident.Map[struct{}]{}
+		e.mu.pending.Put(table, pending)
+	}
+	hints := e.mu.hints.GetZero(table)
+
+	_ = kinds.Range(func(col ident.Ident, kind autocreate.Kind) error {
+		if _, dup := pending.Get(col); dup {
+			return nil
+		}
+		if hint, ok := hints.Get(col); ok {
+			kind = hint
+		} else if kind == autocreate.KindUnknown {
+			// Only null values have been seen.
+			kind = autocreate.KindString
+		}
+		pending.Put(col, struct{}{})
+		e.stop.Go(func() error {
+			e.addColumn(e.stop, table, col, kind)
+			return nil
+		})
+		return nil
+	})
+	return nil
+}
+
+// addColumn executes the schema change and refreshes the watcher.
+func (e *Evolver) addColumn(
+	ctx context.Context, table ident.Table, col ident.Ident, kind autocreate.Kind,
+) {
+	defer func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.mu.pending.GetZero(table).Delete(col)
+	}()
+
+	change := Change{Column: col, Table: table, Time: time.Now()}
+	err := func() error {
+		watcher, err := e.watchers.Get(table.Schema())
+		if err != nil {
+			return err
+		}
+		change.Stmt, err = addColumnStatement(e.pool.Product, table, col, kind)
+		if err != nil {
+			return err
+		}
+		if _, err := e.pool.ExecContext(ctx, change.Stmt); err != nil {
+			// The column may have been added by another instance.
+			if refreshErr := watcher.Refresh(ctx, e.pool); refreshErr == nil &&
+				hasColumn(watcher, table, col) {
+				return nil
+			}
+			return errors.WithStack(err)
+		}
+		columnsAdded.WithLabelValues(metrics.TableValues(table)...).Inc()
+		return watcher.Refresh(ctx, e.pool)
+	}()
+
+	fields := log.Fields{
+		"column": col,
+		"stmt":   change.Stmt,
+		"table":  table,
+	}
+	if err != nil {
+		change.Error = err.Error()
+		evolveErrors.WithLabelValues(metrics.TableValues(table)...).Inc()
+		log.WithError(err).WithFields(fields).Warn("could not add column to target table")
+	} else {
+		log.WithFields(fields).Info("added column to target table")
+	}
+	e.recordChange(change)
+}
+
+// recordChange retains the change for diagnostic purposes, discarding
+// the oldest changes once there are more than maxChanges.
+func (e *Evolver) recordChange(change Change) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.mu.changes = append(e.mu.changes, change)
+	if over := len(e.mu.changes) - maxChanges; over > 0 {
+		e.mu.changes = append([]Change(nil), e.mu.changes[over:]...)
+	}
+}
+
+// addColumnStatement returns an ALTER TABLE statement for the product.
+func addColumnStatement(
+	product types.Product, table ident.Table, col ident.Ident, kind autocreate.Kind,
+) (string, error) {
+	typ, err := autocreate.SQLType(product, kind, false)
+	if err != nil {
+		return "", err
+	}
+	switch product {
+	case types.ProductCockroachDB, types.ProductPostgreSQL:
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, col, typ), nil
+	case types.ProductMariaDB, types.ProductMySQL:
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, col, typ), nil
+	case types.ProductOracle:
+		return fmt.Sprintf("ALTER TABLE %s ADD (%s %s)", table, col, typ), nil
+	default:
+		return "", errors.Errorf("unimplemented product: %s", product)
+	}
+}
+
+// hasColumn returns true if the watcher knows of the column.
+func hasColumn(watcher types.Watcher, table ident.Table, col ident.Ident) bool {
+	cols, _ := watcher.Get().Columns.Get(table)
+	for _, found := range cols {
+		if ident.Equal(found.Name, col) {
+			return true
+		}
+	}
+	return false
+}
+
+// inferKind returns a Kind for a JSON value, decoded using
+// [json.Decoder.UseNumber].
+func inferKind(value any) autocreate.Kind {
+	switch t := value.(type) {
+	case nil:
+		return autocreate.KindUnknown
+	case bool:
+		return autocreate.KindBool
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return autocreate.KindInt
+		}
+		return autocreate.KindDecimal
+	case string:
+		return autocreate.KindString
+	default:
+		return autocreate.KindJSON
+	}
+}
+
+// merge reconciles the kinds inferred from multiple values. Integers
+// are widened to decimals and any other disagreement results in a
+// string.
+func merge(a, b autocreate.Kind) autocreate.Kind {
+	switch {
+	case a == b, b == autocreate.KindUnknown:
+		return a
+	case a == autocreate.KindUnknown:
+		return b
+	case a == autocreate.KindInt && b == autocreate.KindDecimal,
+		a == autocreate.KindDecimal && b == autocreate.KindInt:
+		return autocreate.KindDecimal
+	default:
+		return autocreate.KindString
+	}
+}
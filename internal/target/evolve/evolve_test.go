@@ -0,0 +1,107 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package evolve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermitted(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	cfg := &Config{
+		Allow:   []string{"orders.*", "users.email"},
+		Deny:    []string{"*.secret_*"},
+		Schemas: []string{"db.public"},
+	}
+	r.NoError(cfg.Preflight())
+
+	schema := ident.MustSchema(ident.New("db"), ident.Public)
+	orders := ident.NewTable(schema, ident.New("orders"))
+	users := ident.NewTable(schema, ident.New("users"))
+
+	a.True(cfg.permitted(orders, ident.New("total")))
+	a.False(cfg.permitted(orders, ident.New("secret_key")))
+	a.True(cfg.permitted(users, ident.New("email")))
+	a.False(cfg.permitted(users, ident.New("name")))
+
+	cfg.Allow = nil
+	a.True(cfg.permitted(users, ident.New("name")))
+	a.False(cfg.permitted(users, ident.New("secret_key")))
+
+	a.ErrorContains((&Config{Allow: []string{"["}}).Preflight(), "invalid pattern")
+}
+
+func TestInferKind(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(autocreate.KindUnknown, inferKind(nil))
+	a.Equal(autocreate.KindBool, inferKind(true))
+	a.Equal(autocreate.KindInt, inferKind(json.Number("42")))
+	a.Equal(autocreate.KindDecimal, inferKind(json.Number("4.2")))
+	a.Equal(autocreate.KindString, inferKind("hello"))
+	a.Equal(autocreate.KindJSON, inferKind(map[string]any{"a": 1}))
+
+	a.Equal(autocreate.KindInt, merge(autocreate.KindInt, autocreate.KindUnknown))
+	a.Equal(autocreate.KindInt, merge(autocreate.KindUnknown, autocreate.KindInt))
+	a.Equal(autocreate.KindDecimal, merge(autocreate.KindInt, autocreate.KindDecimal))
+	a.Equal(autocreate.KindString, merge(autocreate.KindBool, autocreate.KindInt))
+}
+
+func TestAddColumnStatement(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	col := ident.New("val")
+	crdb := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+	stmt, err := addColumnStatement(types.ProductCockroachDB, crdb, col, autocreate.KindInt)
+	r.NoError(err)
+	a.Equal(`ALTER TABLE "db"."public"."tbl" ADD COLUMN IF NOT EXISTS "val" INT8`, stmt)
+
+	my := ident.NewTable(ident.MustSchema(ident.New("db")), ident.New("tbl"))
+	stmt, err = addColumnStatement(types.ProductMySQL, my, col, autocreate.KindDecimal)
+	r.NoError(err)
+	a.Equal(`ALTER TABLE "db"."tbl" ADD COLUMN "val" DECIMAL(65,30)`, stmt)
+
+	stmt, err = addColumnStatement(types.ProductOracle, my, col, autocreate.KindJSON)
+	r.NoError(err)
+	a.Equal(`ALTER TABLE "db"."tbl" ADD ("val" CLOB)`, stmt)
+}
+
+func TestRecordChangeLimit(t *testing.T) {
+	a := assert.New(t)
+
+	e := &Evolver{}
+	for i := 0; i < maxChanges+10; i++ {
+		e.recordChange(Change{Column: ident.New(fmt.Sprintf("col_%d", i))})
+	}
+	changes := e.Diagnostic(context.Background()).([]Change)
+	if a.Len(changes, maxChanges) {
+		a.Equal(ident.New("col_10"), changes[0].Column)
+		a.Equal(ident.New(fmt.Sprintf("col_%d", maxChanges+9)), changes[maxChanges-1].Column)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package evolve
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/google/wire"
+)
+
+// Set is used by Wire.
+var Set = wire.NewSet(
+	ProvideEvolver,
+)
+
+// ProvideEvolver is called by Wire. It returns nil if no schemas have
+// been configured for evolution.
+func ProvideEvolver(
+	ctx *stopper.Context,
+	cfg *Config,
+	diags *diag.Diagnostics,
+	pool *types.TargetPool,
+	watchers types.Watchers,
+) (*Evolver, error) {
+	if err := cfg.Preflight(); err != nil {
+		return nil, err
+	}
+	if len(cfg.parsed) == 0 {
+		return nil, nil
+	}
+	schemas := make([]ident.Schema, len(cfg.parsed))
+	for idx, schema := range cfg.parsed {
+		var err error
+		schemas[idx], err = pool.Product.ExpandSchema(schema)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret := &Evolver{
+		cfg:      cfg,
+		pool:     pool,
+		schemas:  schemas,
+		stop:     ctx,
+		watchers: watchers,
+	}
+	ret.mu.hints = &ident.TableMap[*ident.Map[autocreate.Kind]]{}
+	ret.mu.pending = &ident.TableMap[*ident.Map[struct{}]]{}
+	if err := diags.Register("evolve", ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -20,7 +20,9 @@ package target
 
 import (
 	"github.com/cockroachdb/cdc-sink/internal/target/apply"
+	"github.com/cockroachdb/cdc-sink/internal/target/autocreate"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/evolve"
 	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/google/wire"
 )
@@ -29,6 +31,8 @@ import (
 // sub-packages.
 var Set = wire.NewSet(
 	apply.Set,
+	autocreate.Set,
 	dlq.Set,
+	evolve.Set,
 	schemawatch.Set,
 )
@@ -98,7 +98,8 @@ cols AS (
 		   END as data_type,
 		   column_type,
 		   column_default,
-		   extra IN ('STORED GENERATED', 'VIRTUAL GENERATED') AS ignored
+		   extra IN ('STORED GENERATED', 'VIRTUAL GENERATED') AS ignored,
+		   is_nullable = 'YES' AS nullable
 	FROM information_schema.columns
 ),
 ordered AS (
@@ -121,7 +122,8 @@ ordered AS (
 		    ELSE data_type
 		  END as data_type,
 		  column_default,
-		  ignored
+		  ignored,
+		  nullable
       FROM cols
 	       JOIN ordered USING (table_schema, table_name, column_name)
 		   LEFT JOIN pks USING (table_schema, table_name, column_name)
@@ -185,7 +187,8 @@ cols AS (
 			THEN column_default
 			ELSE quote (column_default)
 	     END as column_default,
-		 extra IN ('STORED GENERATED', 'VIRTUAL GENERATED') AS ignored
+		 extra IN ('STORED GENERATED', 'VIRTUAL GENERATED') AS ignored,
+		 is_nullable = 'YES' AS nullable
 	FROM information_schema.columns
 ),
 ordered AS (
@@ -200,7 +203,7 @@ ordered AS (
 			 LEFT JOIN pks USING (table_schema, table_name, column_name)
 	GROUP BY table_schema, table_name, column_name
    )
-SELECT column_name, pks.ordinal_position IS NOT NULL, data_type, column_default, ignored
+SELECT column_name, pks.ordinal_position IS NOT NULL, data_type, column_default, ignored, nullable
 FROM cols
 JOIN ordered USING (table_schema, table_name, column_name)
 LEFT JOIN pks USING (table_schema, table_name, column_name)
@@ -236,6 +239,7 @@ WITH atc AS (
     ELSE DATA_TYPE
   END DATA_TYPE,
   DATA_DEFAULT,
+  NULLABLE,
   VIRTUAL_COLUMN FROM ALL_TAB_COLS
 ),
      pk_cols  AS (SELECT OWNER, TABLE_NAME, CONSTRAINT_NAME FROM ALL_CONSTRAINTS WHERE CONSTRAINT_TYPE='P'),
@@ -244,7 +248,8 @@ SELECT COLUMN_NAME,
        COALESCE(IS_PK, 'f'),
        atc.DATA_TYPE,
        atc.DATA_DEFAULT,
-       CASE WHEN atc.VIRTUAL_COLUMN = 'YES' THEN 't' ELSE 'f' END
+       CASE WHEN atc.VIRTUAL_COLUMN = 'YES' THEN 't' ELSE 'f' END,
+       CASE WHEN atc.NULLABLE = 'Y' THEN 't' ELSE 'f' END
 FROM atc
 LEFT JOIN pk_cols  USING (OWNER, TABLE_NAME)
 LEFT JOIN acc USING (OWNER, TABLE_NAME, COLUMN_NAME, CONSTRAINT_NAME)
@@ -292,7 +297,8 @@ const sqlColumnsQueryPg = `
                        quote_ident(udt_catalog) || '.' || quote_ident(udt_schema) || '.' || quote_ident(udt_name) ||
                        CASE WHEN collation_name IS NOT NULL THEN ' COLLATE ' || collation_name ELSE '' END AS data_type,
                        column_default,
-                       is_generated NOT IN ('NEVER', 'NO') AS ignored
+                       is_generated NOT IN ('NEVER', 'NO') AS ignored,
+                       is_nullable = 'YES' AS nullable
                   FROM %[1]s.information_schema.columns
               ),
          ordered AS (
@@ -307,7 +313,7 @@ const sqlColumnsQueryPg = `
                            LEFT JOIN pks USING (table_catalog, table_schema, table_name, column_name)
                   GROUP BY table_catalog, table_schema, table_name, column_name
                  )
-  SELECT column_name, pks.ordinal_position IS NOT NULL, data_type, column_default, ignored
+  SELECT column_name, pks.ordinal_position IS NOT NULL, data_type, column_default, ignored, nullable
     FROM cols
     JOIN ordered USING (table_catalog, table_schema, table_name, column_name)
     LEFT JOIN pks USING (table_catalog, table_schema, table_name, column_name)
@@ -385,7 +391,8 @@ func getColumns(
 			var column types.ColData
 			var defaultExpr sql.NullString
 			var name string
-			if err := rows.Scan(&name, &column.Primary, &column.Type, &defaultExpr, &column.Ignored); err != nil {
+			if err := rows.Scan(&name, &column.Primary, &column.Type, &defaultExpr,
+				&column.Ignored, &column.Nullable); err != nil {
 				return err
 			}
 			column.Name = ident.New(name)
@@ -299,6 +299,21 @@ func TestGetColumns(t *testing.T) {
 				assert.Fail(t, "did not find b column")
 			},
 		},
+		// Check nullability.
+		{
+			products: []types.Product{types.ProductCockroachDB, types.ProductMariaDB,
+				types.ProductMySQL, types.ProductPostgreSQL, types.ProductOracle},
+			tableSchema: "a INT PRIMARY KEY, b INT NOT NULL, c INT",
+			primaryKeys: []string{"a"},
+			dataCols:    []string{"b", "c"},
+			check: func(t *testing.T, data []types.ColData) {
+				if assert.Len(t, data, 3) {
+					assert.False(t, data[0].Nullable)
+					assert.False(t, data[1].Nullable)
+					assert.True(t, data[2].Nullable)
+				}
+			},
+		},
 		// Checking MySQL default expressions.
 		{
 			products: []types.Product{types.ProductMySQL},
@@ -304,12 +304,61 @@ GROUP BY
 ORDER BY
   depth, table_name`
 
+// fkColumnsTemplatePg lists the columns of the foreign keys defined
+// within a schema, along with the name of the table that each foreign
+// key refers to and whether or not the constraint is DEFERRABLE. Only
+// references within the same schema are returned.
+const fkColumnsTemplatePg = `
+SELECT child.relname, att.attname, parent.relname, con.condeferrable
+FROM %[1]s.pg_catalog.pg_constraint AS con
+JOIN %[1]s.pg_catalog.pg_class AS child ON child.oid = con.conrelid
+JOIN %[1]s.pg_catalog.pg_class AS parent ON parent.oid = con.confrelid
+JOIN %[1]s.pg_catalog.pg_namespace AS ns ON ns.oid = child.relnamespace
+JOIN %[1]s.pg_catalog.pg_attribute AS att
+  ON att.attrelid = con.conrelid AND att.attnum = ANY (con.conkey)
+WHERE con.contype = 'f'
+  AND ns.nspname = $1
+  AND parent.relnamespace = child.relnamespace
+ORDER BY 1, 2`
+
+// fkColumnsTemplateMySQL is equivalent to fkColumnsTemplatePg. MySQL
+// has no deferrable constraints, but all foreign-key checks can be
+// disabled within a session, so every constraint is reported as being
+// deferrable.
+const fkColumnsTemplateMySQL = `
+SELECT table_name, column_name, referenced_table_name, 1
+FROM information_schema.key_column_usage
+WHERE table_schema = ?
+  AND referenced_table_schema = table_schema
+  AND referenced_table_name IS NOT NULL
+ORDER BY 1, 2`
+
+// fkColumnsTemplateOra is equivalent to fkColumnsTemplatePg.
+const fkColumnsTemplateOra = `
+SELECT c.TABLE_NAME, cc.COLUMN_NAME, p.TABLE_NAME,
+       CASE c.DEFERRABLE WHEN 'DEFERRABLE' THEN 1 ELSE 0 END
+FROM ALL_CONSTRAINTS c
+JOIN ALL_CONS_COLUMNS cc ON cc.OWNER = c.OWNER AND cc.CONSTRAINT_NAME = c.CONSTRAINT_NAME
+JOIN ALL_CONSTRAINTS p ON p.OWNER = c.R_OWNER AND p.CONSTRAINT_NAME = c.R_CONSTRAINT_NAME
+WHERE c.CONSTRAINT_TYPE = 'R'
+  AND c.OWNER = (:owner)
+  AND p.OWNER = c.OWNER
+ORDER BY 1, 2`
+
 // getDependencyOrder returns equivalency groups of tables defined
 // within the given database. The order of the slice will satisfy
-// the (acyclic) foreign-key dependency graph.
+// the foreign-key dependency graph.
+//
+// Tables that participate in a foreign-key cycle, or which refer to
+// such a table, have no well-defined ordering. These tables will be
+// placed into a final group and are also described by the returned
+// map, which will be nil if there are no cycles in the schema. The
+// map values are the columns that refer to tables in the final group.
+// The returned bool will be true if every foreign key between tables
+// in the final group is deferrable.
 func getDependencyOrder(
 	ctx context.Context, tx *types.TargetPool, db ident.Schema,
-) ([][]ident.Table, error) {
+) (_ [][]ident.Table, _ *ident.TableMap[[]ident.Ident], deferrable bool, _ error) {
 	var args []any
 	var stmt string
 	switch tx.Product {
@@ -317,7 +366,7 @@ func getDependencyOrder(
 		// Extract just the database name to refer to information_schema.
 		parts := db.Idents(make([]ident.Ident, 0, 2))
 		if len(parts) != 2 {
-			return nil, errors.Errorf("expecting two schema parts, had %d", len(parts))
+			return nil, nil, false, errors.Errorf("expecting two schema parts, had %d", len(parts))
 		}
 
 		// We are using a different template for CRDB
@@ -337,7 +386,7 @@ func getDependencyOrder(
 	case types.ProductMariaDB, types.ProductMySQL:
 		parts := db.Idents(make([]ident.Ident, 0, 1))
 		if len(parts) != 1 {
-			return nil, errors.Errorf("expecting one schema parts, had %d", len(parts))
+			return nil, nil, false, errors.Errorf("expecting one schema parts, had %d", len(parts))
 		}
 		stmt = depOrderTemplateMySQL
 		args = []any{parts[0].Raw()}
@@ -346,7 +395,7 @@ func getDependencyOrder(
 		stmt = depOrderTemplateOra
 		args = []any{sql.Named("owner", db.Raw())}
 	default:
-		return nil, errors.Errorf("getDependencyOrder unimplemented product: %s", tx.Product)
+		return nil, nil, false, errors.Errorf("getDependencyOrder unimplemented product: %s", tx.Product)
 	}
 
 	var cycles []ident.Table
@@ -386,9 +435,134 @@ func getDependencyOrder(
 		return nil
 	})
 
-	if len(cycles) > 0 {
-		return nil, errors.Errorf("cyclical FK references involving tables %s", cycles)
+	if err != nil || len(cycles) == 0 {
+		return depOrder, nil, false, err
+	}
+
+	refs, err := getForeignKeyColumns(ctx, tx, db)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	cyclic := &ident.TableMap[[]ident.Ident]{}
+	for _, tbl := range cycles {
+		cyclic.Put(tbl, nil)
+	}
+
+	// A table that refers to a table in a cycle cannot be written
+	// until the cycle has been, so move it into the final group.
+	for changed := true; changed; {
+		changed = false
+		for _, ref := range refs {
+			if _, found := cyclic.Get(ref.parent); !found {
+				continue
+			}
+			if _, found := cyclic.Get(ref.child); found {
+				continue
+			}
+			cyclic.Put(ref.child, nil)
+			cycles = append(cycles, ref.child)
+			changed = true
+		}
+	}
+	for idx, tables := range depOrder {
+		filtered := tables[:0]
+		for _, tbl := range tables {
+			if _, found := cyclic.Get(tbl); !found {
+				filtered = append(filtered, tbl)
+			}
+		}
+		depOrder[idx] = filtered
+	}
+
+	deferrable = true
+	for _, ref := range refs {
+		cols, found := cyclic.Get(ref.child)
+		if !found {
+			continue
+		}
+		if _, found := cyclic.Get(ref.parent); !found {
+			continue
+		}
+		deferrable = deferrable && ref.deferrable
+		// Multi-column constraints may be reported more than once.
+		if !containsIdent(cols, ref.column) {
+			cyclic.Put(ref.child, append(cols, ref.column))
+		}
+	}
+
+	return append(depOrder, cycles), cyclic, deferrable, nil
+}
+
+// containsIdent returns true if the ident is in the slice.
+func containsIdent(idents []ident.Ident, id ident.Ident) bool {
+	for _, elt := range idents {
+		if ident.Equal(elt, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// fkColumn describes one column of a foreign key.
+type fkColumn struct {
+	child      ident.Table
+	column     ident.Ident
+	deferrable bool
+	parent     ident.Table
+}
+
+// getForeignKeyColumns returns the columns of all foreign keys whose
+// tables are defined in the given database.
+func getForeignKeyColumns(
+	ctx context.Context, tx *types.TargetPool, db ident.Schema,
+) ([]fkColumn, error) {
+	var args []any
+	var stmt string
+	switch tx.Product {
+	case types.ProductCockroachDB, types.ProductPostgreSQL:
+		parts := db.Idents(make([]ident.Ident, 0, 2))
+		if len(parts) != 2 {
+			return nil, errors.Errorf("expecting two schema parts, had %d", len(parts))
+		}
+		stmt = fmt.Sprintf(fkColumnsTemplatePg, parts[0])
+		args = []any{parts[1].Raw()}
+
+	case types.ProductMariaDB, types.ProductMySQL:
+		stmt = fkColumnsTemplateMySQL
+		args = []any{db.Raw()}
+
+	case types.ProductOracle:
+		stmt = fkColumnsTemplateOra
+		args = []any{sql.Named("owner", db.Raw())}
+
+	default:
+		return nil, errors.Errorf("getForeignKeyColumns unimplemented product: %s", tx.Product)
 	}
 
-	return depOrder, err
+	var ret []fkColumn
+	err := retry.Retry(ctx, func(ctx context.Context) error {
+		rows, err := tx.QueryContext(ctx, stmt, args...)
+		if err != nil {
+			return errors.Wrap(err, stmt)
+		}
+		defer rows.Close()
+
+		ret = ret[:0]
+		for rows.Next() {
+			var child, column, parent string
+			var deferrable bool
+			if err := rows.Scan(&child, &column, &parent, &deferrable); err != nil {
+				return err
+			}
+			ret = append(ret, fkColumn{
+				child:      ident.NewTable(db, ident.New(child)),
+				column:     ident.New(column),
+				deferrable: deferrable,
+				parent:     ident.NewTable(db, ident.New(parent)),
+			})
+		}
+		return rows.Err()
+	})
+	return ret, err
 }
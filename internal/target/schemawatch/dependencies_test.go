@@ -143,7 +143,8 @@ func TestGetDependencyOrder(t *testing.T) {
 	a.Equal(len(tcs), tableCount)
 	a.True(expected.Equal(found, cmap.Comparator[int]()))
 
-	// Ensure that we fail in a useful manner if there is a reference cycle.
+	// Tables in a reference cycle should be placed into a final group,
+	// along with the columns that participate in the cycle.
 	switch pool.Product {
 	case types.ProductCockroachDB, types.ProductPostgreSQL:
 		_, err = pool.ExecContext(ctx, fmt.Sprintf(`
@@ -187,9 +188,30 @@ ALTER TABLE %[1]s.cycle_a ADD COLUMN ref int references %[1]s.cycle_b;
 	default:
 		r.FailNow("unsupported product")
 	}
-	err = fixture.Watcher.Refresh(ctx, pool)
-	a.ErrorContains(err, "cycle_a")
-	a.ErrorContains(err, "cycle_b")
+	r.NoError(fixture.Watcher.Refresh(ctx, pool))
+	snap = fixture.Watcher.Get()
+	r.NotEmpty(snap.Order)
+	r.NotNil(snap.Cycles)
+
+	// The constraints are NOT DEFERRABLE, but MySQL can disable its
+	// foreign-key checks instead.
+	switch pool.Product {
+	case types.ProductMariaDB, types.ProductMySQL:
+		a.True(snap.DeferrableCycles)
+	default:
+		a.False(snap.DeferrableCycles)
+	}
+
+	last := snap.Order[len(snap.Order)-1]
+	a.Len(last, 2)
+	for _, name := range []string{"cycle_a", "cycle_b"} {
+		tbl := ident.NewTable(fixture.TargetSchema.Schema(), ident.New(name))
+		a.Contains(last, tbl)
+		cols, ok := snap.Cycles.Get(tbl)
+		if a.True(ok, name) {
+			a.Equal([]ident.Ident{ident.New("ref")}, cols)
+		}
+	}
 }
 
 // TestNoDeferrableConstraints will act as a reminder if/when deferrable
@@ -0,0 +1,182 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package schemawatch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+)
+
+// diffSchema compares two snapshots of a schema and classifies each
+// change to the tables within it. The changes are returned in a stable
+// order.
+func diffSchema(before, after *types.SchemaData, now time.Time) []types.SchemaChange {
+	var ret []types.SchemaChange
+
+	_ = after.Columns.Range(func(tbl ident.Table, cols []types.ColData) error {
+		if prev, ok := before.Columns.Get(tbl); ok {
+			ret = append(ret, diffColumns(tbl, prev, cols, now)...)
+		} else {
+			ret = append(ret, types.SchemaChange{
+				Kind:   types.SchemaChangeCompatible,
+				Reason: "table created",
+				Table:  tbl,
+				Time:   now,
+			})
+		}
+		return nil
+	})
+	_ = before.Columns.Range(func(tbl ident.Table, _ []types.ColData) error {
+		if _, ok := after.Columns.Get(tbl); !ok {
+			ret = append(ret, types.SchemaChange{
+				Kind:   types.SchemaChangeBreaking,
+				Reason: "table dropped",
+				Table:  tbl,
+				Time:   now,
+			})
+		}
+		return nil
+	})
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if c := ident.Compare(ret[i].Table, ret[j].Table); c != 0 {
+			return c < 0
+		}
+		return ident.Compare(ret[i].Column, ret[j].Column) < 0
+	})
+	return ret
+}
+
+// diffColumns classifies the differences between two versions of a
+// table's columns.
+//
+// Changes to the primary key, dropped columns, and NOT NULL columns
+// that are added without a default value are breaking, since the
+// incoming mutations can no longer be applied as they were before.
+// Other changes to non-ignored columns require the apply statements to
+// be regenerated, which happens automatically when the watcher
+// broadcasts the new column data.
+func diffColumns(tbl ident.Table, before, after []types.ColData, now time.Time) []types.SchemaChange {
+	var ret []types.SchemaChange
+	add := func(kind types.SchemaChangeKind, col ident.Ident, reason string) {
+		ret = append(ret, types.SchemaChange{
+			Column: col,
+			Kind:   kind,
+			Reason: reason,
+			Table:  tbl,
+			Time:   now,
+		})
+	}
+
+	if prevPK, nextPK := primaryKey(before), primaryKey(after); prevPK != nextPK {
+		add(types.SchemaChangeBreaking, ident.Ident{},
+			fmt.Sprintf("primary key changed from (%s) to (%s)", prevPK, nextPK))
+	}
+
+	prev := &ident.Map[types.ColData]{}
+	for _, col := range before {
+		prev.Put(col.Name, col)
+	}
+	next := &ident.Map[types.ColData]{}
+	for _, col := range after {
+		next.Put(col.Name, col)
+	}
+
+	for _, col := range before {
+		if _, ok := next.Get(col.Name); ok || col.Primary {
+			continue
+		}
+		if col.Ignored {
+			add(types.SchemaChangeCompatible, col.Name, "ignored column dropped")
+		} else {
+			add(types.SchemaChangeBreaking, col.Name, "column dropped")
+		}
+	}
+
+	changed := false
+	for _, col := range after {
+		old, ok := prev.Get(col.Name)
+		switch {
+		case col.Primary && ok && old.Primary:
+			if col.Type != old.Type {
+				add(types.SchemaChangeRegenerate, col.Name,
+					fmt.Sprintf("type changed from %s to %s", old.Type, col.Type))
+				changed = true
+			}
+
+		case col.Primary || (ok && old.Primary):
+			// Reported as a primary key change above.
+
+		case !ok && col.Ignored:
+			add(types.SchemaChangeCompatible, col.Name, "ignored column added")
+
+		case !ok && !col.Nullable && col.DefaultExpr == "":
+			add(types.SchemaChangeBreaking, col.Name, "NOT NULL column added without a default")
+
+		case !ok:
+			add(types.SchemaChangeRegenerate, col.Name, "column added")
+			changed = true
+
+		case !col.Equal(old):
+			var reasons []string
+			if col.Type != old.Type {
+				reasons = append(reasons, fmt.Sprintf("type changed from %s to %s", old.Type, col.Type))
+			}
+			if col.Nullable != old.Nullable {
+				reasons = append(reasons, fmt.Sprintf("nullable changed to %t", col.Nullable))
+			}
+			if col.DefaultExpr != old.DefaultExpr {
+				reasons = append(reasons, fmt.Sprintf("default changed from %q to %q",
+					old.DefaultExpr, col.DefaultExpr))
+			}
+			if col.Ignored != old.Ignored {
+				reasons = append(reasons, fmt.Sprintf("ignored changed to %t", col.Ignored))
+			}
+			if len(reasons) > 0 {
+				add(types.SchemaChangeRegenerate, col.Name, strings.Join(reasons, "; "))
+				changed = true
+			}
+		}
+	}
+
+	// A change in column order alone affects the generated statements.
+	if !changed && len(ret) == 0 && !colSliceEqual(before, after) {
+		add(types.SchemaChangeRegenerate, ident.Ident{}, "columns reordered")
+	}
+	return ret
+}
+
+// primaryKey returns a string representation of the table's primary
+// key columns, in order.
+func primaryKey(cols []types.ColData) string {
+	var sb strings.Builder
+	for _, col := range cols {
+		if !col.Primary {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(col.Name.Canonical().Raw())
+	}
+	return sb.String()
+}
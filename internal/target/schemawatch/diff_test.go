@@ -0,0 +1,139 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package schemawatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffColumns(t *testing.T) {
+	tbl := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+	pk := types.ColData{Name: ident.New("pk"), Primary: true, Type: "INT8"}
+	val := types.ColData{Name: ident.New("val"), Nullable: true, Type: "STRING"}
+	base := []types.ColData{pk, val}
+
+	tcs := []struct {
+		name     string
+		after    []types.ColData
+		expected []types.SchemaChangeKind
+	}{
+		{"unchanged", base, nil},
+		{
+			"add nullable",
+			append(base, types.ColData{Name: ident.New("more"), Nullable: true, Type: "INT8"}),
+			[]types.SchemaChangeKind{types.SchemaChangeRegenerate},
+		},
+		{
+			"add defaulted",
+			append(base, types.ColData{Name: ident.New("more"), DefaultExpr: "0", Type: "INT8"}),
+			[]types.SchemaChangeKind{types.SchemaChangeRegenerate},
+		},
+		{
+			"add not null",
+			append(base, types.ColData{Name: ident.New("more"), Type: "INT8"}),
+			[]types.SchemaChangeKind{types.SchemaChangeBreaking},
+		},
+		{
+			"add ignored",
+			append(base, types.ColData{Name: ident.New("more"), Ignored: true, Type: "INT8"}),
+			[]types.SchemaChangeKind{types.SchemaChangeCompatible},
+		},
+		{
+			"drop column",
+			[]types.ColData{pk},
+			[]types.SchemaChangeKind{types.SchemaChangeBreaking},
+		},
+		{
+			"change type",
+			[]types.ColData{pk, {Name: ident.New("VAL"), Nullable: true, Type: "INT8"}},
+			[]types.SchemaChangeKind{types.SchemaChangeRegenerate},
+		},
+		{
+			"change primary key",
+			[]types.ColData{pk, {Name: ident.New("val"), Primary: true, Type: "STRING"}},
+			[]types.SchemaChangeKind{types.SchemaChangeBreaking},
+		},
+		{
+			"reorder",
+			[]types.ColData{val, pk},
+			[]types.SchemaChangeKind{types.SchemaChangeRegenerate},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := diffColumns(tbl, base, tc.after, time.Now())
+			var kinds []types.SchemaChangeKind
+			for _, change := range changes {
+				kinds = append(kinds, change.Kind)
+			}
+			assert.Equal(t, tc.expected, kinds, changes)
+		})
+	}
+}
+
+func TestDiffPause(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	schema := ident.MustSchema(ident.New("db"), ident.Public)
+	tbl := ident.NewTable(schema, ident.New("tbl"))
+	other := ident.NewTable(schema, ident.New("other"))
+	pk := types.ColData{Name: ident.New("pk"), Primary: true, Type: "INT8"}
+	val := types.ColData{Name: ident.New("val"), Nullable: true, Type: "STRING"}
+
+	snapshot := func(cols []types.ColData) *types.SchemaData {
+		ret := &types.SchemaData{Columns: &ident.TableMap[[]types.ColData]{}}
+		ret.Columns.Put(tbl, cols)
+		ret.Columns.Put(other, []types.ColData{pk})
+		return ret
+	}
+
+	w := &watcher{schema: schema}
+	w.mu.baseline = &ident.TableMap[[]types.ColData]{}
+	w.mu.data = snapshot([]types.ColData{pk, val})
+
+	// Dropping a column pauses only the affected table.
+	next := snapshot([]types.ColData{pk})
+	w.diffLocked(next, time.Now())
+	w.mu.data = next
+	r.Len(next.PausedBy(tbl), 1)
+	a.Equal("column dropped", next.PausedBy(tbl)[0].Reason)
+	a.Nil(next.PausedBy(other))
+	a.Nil(w.Snapshot(schema).PausedBy(other))
+	a.NotNil(w.Snapshot(schema).PausedBy(tbl))
+
+	// Adding an unrelated, nullable column doesn't resume the table.
+	next = snapshot([]types.ColData{pk, {Name: ident.New("x"), Nullable: true}})
+	w.diffLocked(next, time.Now())
+	w.mu.data = next
+	a.NotNil(next.PausedBy(tbl))
+
+	// Restoring the column resumes the table.
+	next = snapshot([]types.ColData{pk, val})
+	w.diffLocked(next, time.Now())
+	w.mu.data = next
+	a.Nil(next.Paused)
+	a.Equal(0, w.mu.baseline.Len())
+	a.NotEmpty(w.recentChanges())
+}
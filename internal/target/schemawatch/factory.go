@@ -56,6 +56,22 @@ func (f *factory) Diagnostic(_ context.Context) any {
 	return ret
 }
 
+// changesDiagnostic returns the most recent schema changes detected
+// in each schema.
+func (f *factory) changesDiagnostic(_ context.Context) any {
+	ret := make(map[string]any)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	_ = f.mu.data.Range(func(sch ident.Schema, w *watcher) error {
+		ret[sch.Raw()] = w.recentChanges()
+		return nil
+	})
+
+	return ret
+}
+
 // Get creates or returns a memoized watcher for the given database.
 func (f *factory) Get(db ident.Schema) (types.Watcher, error) {
 	if ret := f.getUnlocked(db); ret != nil {
@@ -0,0 +1,34 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package schemawatch
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/util/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	schemaChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "target_schema_changes_total",
+		Help: "the number of detected changes to target tables, by kind",
+	}, append(append([]string(nil), metrics.TableLabels...), "kind"))
+	schemaPaused = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "target_schema_paused",
+		Help: "set to 1 if a target table is paused due to a breaking schema change",
+	}, metrics.TableLabels)
+)
@@ -38,5 +38,8 @@ func ProvideFactory(
 	if err := d.Register("schema", w); err != nil {
 		return nil, err
 	}
+	if err := d.Register("schemaChanges", diag.DiagnosticFn(w.changesDiagnostic)); err != nil {
+		return nil, err
+	}
 	return w, nil
 }
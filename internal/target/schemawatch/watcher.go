@@ -28,6 +28,7 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/metrics"
 	"github.com/cockroachdb/cdc-sink/internal/util/retry"
 	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
 	"github.com/pkg/errors"
@@ -49,11 +50,18 @@ type watcher struct {
 
 	mu struct {
 		sync.RWMutex
-		data    *types.SchemaData
-		updated chan struct{} // Closed and replaced when data is updated.
+		// The columns of paused tables from before the breaking change.
+		baseline *ident.TableMap[[]types.ColData]
+		changes  []types.SchemaChange // The most recent changes.
+		data     *types.SchemaData
+		updated  chan struct{} // Closed and replaced when data is updated.
 	}
 }
 
+// maxChanges limits the number of schema changes that are retained for
+// diagnostic purposes.
+const maxChanges = 128
+
 var _ types.Watcher = (*watcher)(nil)
 
 // newWatcher constructs a new watcher to monitor the table schema in the
@@ -65,6 +73,7 @@ func newWatcher(ctx *stopper.Context, tx *types.TargetPool, schema ident.Schema)
 		delay:      *RefreshDelay,
 		schema:     schema,
 	}
+	w.mu.baseline = &ident.TableMap[[]types.ColData]{}
 	w.mu.updated = make(chan struct{})
 
 	// Initial data load to sanity-check and make ready.
@@ -110,6 +119,7 @@ func (w *watcher) Refresh(ctx context.Context, tx *types.TargetPool) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	w.diffLocked(data, time.Now())
 	w.mu.data = data
 
 	// Close and replace the channel to create a broadcast effect.
@@ -119,6 +129,86 @@ func (w *watcher) Refresh(ctx context.Context, tx *types.TargetPool) error {
 	return nil
 }
 
+// diffLocked compares the next schema data to the current data. The
+// changes are logged and counted, and tables are paused or resumed
+// based on whether or not they have breaking changes. A paused table
+// is resumed once its schema is again compatible with the schema that
+// it had before it was paused.
+func (w *watcher) diffLocked(next *types.SchemaData, now time.Time) {
+	prev := w.mu.data
+	paused := &ident.TableMap[[]types.SchemaChange]{}
+
+	// Re-evaluate tables that were already paused.
+	if prev.Paused != nil {
+		_ = prev.Paused.Range(func(tbl ident.Table, reasons []types.SchemaChange) error {
+			if cols, ok := next.Columns.Get(tbl); ok {
+				reasons = nil
+				for _, change := range diffColumns(tbl, w.mu.baseline.GetZero(tbl), cols, now) {
+					if change.Kind == types.SchemaChangeBreaking {
+						reasons = append(reasons, change)
+					}
+				}
+			}
+			if len(reasons) > 0 {
+				paused.Put(tbl, reasons)
+				return nil
+			}
+			w.mu.baseline.Delete(tbl)
+			schemaPaused.WithLabelValues(metrics.TableValues(tbl)...).Set(0)
+			log.WithField("table", tbl).Info("breaking schema changes resolved; resuming table")
+			return nil
+		})
+	}
+
+	for _, change := range diffSchema(prev, next, now) {
+		schemaChanges.WithLabelValues(
+			append(metrics.TableValues(change.Table), change.Kind.String())...).Inc()
+		w.mu.changes = append(w.mu.changes, change)
+
+		fields := log.Fields{
+			"column": change.Column,
+			"kind":   change.Kind,
+			"reason": change.Reason,
+			"table":  change.Table,
+		}
+		switch change.Kind {
+		case types.SchemaChangeCompatible:
+			log.WithFields(fields).Debug("detected compatible schema change")
+			continue
+		case types.SchemaChangeRegenerate:
+			log.WithFields(fields).Info("detected schema change; regenerating apply statements")
+			continue
+		}
+
+		// The baseline comparison above is authoritative for tables
+		// that were already paused.
+		if prev.PausedBy(change.Table) != nil {
+			continue
+		}
+		reasons := paused.GetZero(change.Table)
+		if reasons == nil {
+			w.mu.baseline.Put(change.Table, prev.Columns.GetZero(change.Table))
+			schemaPaused.WithLabelValues(metrics.TableValues(change.Table)...).Set(1)
+		}
+		paused.Put(change.Table, append(reasons, change))
+		log.WithFields(fields).Warn("detected breaking schema change; pausing table")
+	}
+
+	if over := len(w.mu.changes) - maxChanges; over > 0 {
+		w.mu.changes = append([]types.SchemaChange(nil), w.mu.changes[over:]...)
+	}
+	if paused.Len() > 0 {
+		next.Paused = paused
+	}
+}
+
+// recentChanges returns the most recently detected schema changes.
+func (w *watcher) recentChanges() []types.SchemaChange {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return append([]types.SchemaChange(nil), w.mu.changes...)
+}
+
 // Snapshot returns the known tables in the given user-defined schema.
 func (w *watcher) Snapshot(in ident.Schema) *types.SchemaData {
 	w.mu.RLock()
@@ -149,6 +239,28 @@ func (w *watcher) Snapshot(in ident.Schema) *types.SchemaData {
 			ret.Order = append(ret.Order, filtered)
 		}
 	}
+	if w.mu.data.Paused != nil {
+		ret.Paused = &ident.TableMap[[]types.SchemaChange]{}
+		_ = w.mu.data.Paused.Range(func(table ident.Table, changes []types.SchemaChange) error {
+			if in.Contains(table) {
+				ret.Paused.Put(table, changes)
+			}
+			return nil
+		})
+		if ret.Paused.Len() == 0 {
+			ret.Paused = nil
+		}
+	}
+	if w.mu.data.Cycles != nil {
+		ret.DeferrableCycles = w.mu.data.DeferrableCycles
+		ret.Cycles = &ident.TableMap[[]ident.Ident]{}
+		_ = w.mu.data.Cycles.Range(func(table ident.Table, cols []ident.Ident) error {
+			if in.Contains(table) {
+				ret.Cycles.Put(table, cols)
+			}
+			return nil
+		})
+	}
 	return ret
 }
 
@@ -304,7 +416,7 @@ func (w *watcher) getTables(ctx context.Context, tx *types.TargetPool) (*types.S
 
 		// Empty if there were no tables.
 		if !sch.Empty() {
-			ret.Order, err = getDependencyOrder(ctx, tx, sch)
+			ret.Order, ret.Cycles, ret.DeferrableCycles, err = getDependencyOrder(ctx, tx, sch)
 		}
 		return err
 	})
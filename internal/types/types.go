@@ -25,6 +25,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
@@ -47,6 +48,18 @@ type Appliers interface {
 	Get(ctx context.Context, target ident.Table) (Applier, error)
 }
 
+// A TableError associates an error with the target table to which
+// mutations were being applied. It does not alter the error's message.
+type TableError struct {
+	Err   error
+	Table ident.Table
+}
+
+func (e *TableError) Error() string { return e.Err.Error() }
+
+// Unwrap allows the underlying error to be inspected.
+func (e *TableError) Unwrap() error { return e.Err }
+
 // An Authenticator determines if an operation on some schema should be
 // allowed to proceed.
 type Authenticator interface {
@@ -133,6 +146,18 @@ type Memo interface {
 // Meta[CustomUpsert] = "custom.template.name"
 const CustomUpsert = "upsert.custom"
 
+// SparseUpdate marks a mutation whose Data contains only those columns
+// that were changed by the source. Any column that is absent from the
+// payload will retain its current value in the target row.
+// Meta[SparseUpdate] = true
+const SparseUpdate = "upsert.sparse"
+
+// JSONDiffs provides partial updates to JSON columns, which will be
+// applied to the current value of the column in the target row. It is
+// used together with SparseUpdate.
+// Meta[JSONDiffs] = map[string][]jsondiff.Diff{"column": ...}
+const JSONDiffs = "upsert.jsonDiffs"
+
 // A Mutation describes a row to upsert into the target database.  That
 // is, it is a collection of column values to apply to a row in some
 // table.
@@ -238,6 +263,8 @@ type ColData struct {
 	DefaultExpr string
 	Ignored     bool
 	Name        ident.Ident
+	// Nullable is set if the column permits NULL values.
+	Nullable bool
 	// A Parse function may be supplied to allow a datatype
 	// to be converted into a type more readily
 	// used by a target database driver.
@@ -253,6 +280,7 @@ func (d ColData) Equal(o ColData) bool {
 	return d.DefaultExpr == o.DefaultExpr &&
 		d.Ignored == o.Ignored &&
 		ident.Equal(d.Name, o.Name) &&
+		d.Nullable == o.Nullable &&
 		// Parse is excluded, since functions are not comparable.
 		d.Primary == o.Primary &&
 		d.Type == o.Type
@@ -262,15 +290,39 @@ func (d ColData) Equal(o ColData) bool {
 type SchemaData struct {
 	Columns *ident.TableMap[[]ColData]
 
+	// Cycles is non-nil if the schema contains foreign-key cycles. It
+	// contains the tables in the final group of Order, which have no
+	// well-defined ordering, and maps them to the columns that refer
+	// to other tables in that group.
+	Cycles *ident.TableMap[[]ident.Ident]
+
+	// DeferrableCycles is true if every foreign key between the tables
+	// described by Cycles can have its checks deferred until the end
+	// of a transaction.
+	DeferrableCycles bool
+
 	// Order is a two-level slice that represents equivalency-groups
 	// with respect to table foreign-key ordering. That is, if all
 	// updates for tables in Order[N] are applied, then updates in
-	// Order[N+1] can then be applied.
+	// Order[N+1] can then be applied. If the schema contains
+	// foreign-key cycles, the final group will contain the tables
+	// described by Cycles.
 	//
 	// The need for this data can be revisited if CRDB adds support
 	// for deferrable foreign-key constraints:
 	// https://github.com/cockroachdb/cockroach/issues/31632
 	Order [][]ident.Table
+
+	// Paused is non-nil if breaking changes have been detected in the
+	// target schema. It maps the affected tables to the changes that
+	// caused them to be paused.
+	//
+	// Holding back a paused table requires that its mutations can be
+	// kept until the table is resumed, so only the cdc resolver, which
+	// stages all mutations, acts upon this. Logical replication
+	// sources and cdc's immediate mode report breaking changes, but
+	// continue to apply mutations to the target.
+	Paused *ident.TableMap[[]SchemaChange]
 }
 
 // OriginalName returns the name of the table as it is defined in the
@@ -280,6 +332,54 @@ func (s *SchemaData) OriginalName(tbl ident.Table) (ident.Table, bool) {
 	return ret, ok
 }
 
+// PausedBy returns the breaking changes which have paused the table,
+// or nil if the table is not paused.
+func (s *SchemaData) PausedBy(tbl ident.Table) []SchemaChange {
+	if s.Paused == nil {
+		return nil
+	}
+	return s.Paused.GetZero(tbl)
+}
+
+// SchemaChangeKind classifies a change in a target table's schema by
+// its effect on the mutations that are being applied to it.
+type SchemaChangeKind int
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type=SchemaChangeKind -trimprefix SchemaChange
+
+// These are the kinds of schema changes that are detected.
+const (
+	// The change does not affect how mutations are applied.
+	SchemaChangeCompatible SchemaChangeKind = iota
+	// The change requires the apply statements to be regenerated.
+	SchemaChangeRegenerate
+	// The change prevents mutations from being applied correctly.
+	SchemaChangeBreaking
+)
+
+// MarshalText allows the kind to be rendered in diagnostic output.
+func (k SchemaChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// A SchemaChange describes a difference between two successive
+// snapshots of a target table's schema.
+type SchemaChange struct {
+	Column ident.Ident      `json:"column,omitempty"`
+	Kind   SchemaChangeKind `json:"kind"`
+	Reason string           `json:"reason"`
+	Table  ident.Table      `json:"table"`
+	Time   time.Time        `json:"time"`
+}
+
+// String is for debugging use only.
+func (c SchemaChange) String() string {
+	if c.Column.Empty() {
+		return fmt.Sprintf("%s: %s (%s)", c.Table, c.Reason, c.Kind)
+	}
+	return fmt.Sprintf("%s.%s: %s (%s)", c.Table, c.Column, c.Reason, c.Kind)
+}
+
 // Product is an enum type to make it easy to switch on the underlying
 // database.
 type Product int
@@ -426,8 +526,9 @@ type Watcher interface {
 	// The returned struct must not be modified.
 	Get() *SchemaData
 	// Refresh will force the Watcher to immediately query the database
-	// for updated schema information. This is intended for testing and
-	// does not need to be called in the general case.
+	// for updated schema information. This is intended for testing, or
+	// after executing DDL, and does not need to be called in the
+	// general case.
 	Refresh(context.Context, *TargetPool) error
 	// Watch returns a channel that emits updated column data for the
 	// given table.  The channel will be closed if there
@@ -77,6 +77,21 @@ func (c *Configs) Get(tbl ident.Table) *notify.Var[*Config] {
 	return ret
 }
 
+// Reset restores the default configuration of every table within the
+// schema. Existing handles remain valid and will be notified.
+func (c *Configs) Reset(sch ident.Schema) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// No error returned from callback.
+	_ = c.mu.data.Range(func(tbl ident.Table, v *notify.Var[*Config]) error {
+		if sch.Contains(tbl) {
+			v.Set(NewConfig())
+		}
+		return nil
+	})
+}
+
 // Set updates the active configuration for the given table.
 func (c *Configs) Set(tbl ident.Table, cfg *Config) error {
 	// Treat nil as zero so we don't break the contract in Get().
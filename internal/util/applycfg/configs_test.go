@@ -62,4 +62,22 @@ func TestConfigs(t *testing.T) {
 	r.NoError(cfgs.Set(tbl, nil))
 	zero, _ = handle.Get()
 	r.True(zero.IsZero())
+
+	// Resetting a schema only affects the tables within it.
+	other := ident.NewTable(ident.MustSchema(ident.New("other")), ident.New("table"))
+	withExtras := NewConfig()
+	withExtras.Extras = ident.New("extras")
+	r.NoError(cfgs.Set(tbl, withExtras))
+	r.NoError(cfgs.Set(other, withExtras.Copy()))
+	_, changed = handle.Get()
+	cfgs.Reset(tbl.Schema())
+	select {
+	case <-changed:
+	default:
+		r.Fail("should have seen channel closed")
+	}
+	zero, _ = handle.Get()
+	r.True(zero.IsZero())
+	kept, _ := cfgs.Get(other).Get()
+	r.False(kept.IsZero())
 }
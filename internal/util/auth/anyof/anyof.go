@@ -0,0 +1,79 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package anyof contains a types.Authenticator which allows a request
+// if any of its delegates allow the request.
+package anyof
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	log "github.com/sirupsen/logrus"
+)
+
+type authenticator struct {
+	delegates []types.Authenticator
+}
+
+// New returns an Authenticator which allows a request if any of the
+// delegates allow it. If there is exactly one delegate, it will be
+// returned.
+func New(delegates ...types.Authenticator) types.Authenticator {
+	if len(delegates) == 1 {
+		return delegates[0]
+	}
+	return &authenticator{delegates}
+}
+
+var _ types.Authenticator = (*authenticator)(nil)
+
+// Check returns true if any delegate returns true. An error from one
+// delegate will not prevent another from allowing the request. If no
+// delegate allows the request, the first error is returned.
+func (a *authenticator) Check(
+	ctx context.Context, schema ident.Schema, token string,
+) (bool, error) {
+	var firstErr error
+	for _, delegate := range a.delegates {
+		ok, err := delegate.Check(ctx, schema, token)
+		if err != nil {
+			log.WithError(err).Debug("authenticator returned an error")
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, firstErr
+}
+
+// Diagnostic implements diag.Diagnostic.
+func (a *authenticator) Diagnostic(ctx context.Context) any {
+	ret := make(map[string]any, len(a.delegates))
+	for idx, delegate := range a.delegates {
+		if d, ok := delegate.(diag.Diagnostic); ok {
+			ret[fmt.Sprintf("%d", idx)] = d.Diagnostic(ctx)
+		}
+	}
+	return ret
+}
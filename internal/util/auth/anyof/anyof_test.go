@@ -0,0 +1,64 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package anyof
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/reject"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/trust"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+)
+
+type failing struct{}
+
+func (failing) Check(context.Context, ident.Schema, string) (bool, error) {
+	return false, errors.New("failed")
+}
+
+func TestAnyOf(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	sch := ident.MustSchema(ident.New("db"), ident.Public)
+
+	single := reject.New()
+	a.Same(single, New(single))
+
+	tcs := []struct {
+		delegates []types.Authenticator
+		expect    bool
+		err       bool
+	}{
+		{delegates: []types.Authenticator{reject.New(), reject.New()}},
+		{delegates: []types.Authenticator{reject.New(), trust.New()}, expect: true},
+		{delegates: []types.Authenticator{failing{}, trust.New()}, expect: true},
+		{delegates: []types.Authenticator{failing{}, reject.New()}, err: true},
+	}
+	for idx, tc := range tcs {
+		ok, err := New(tc.delegates...).Check(ctx, sch, "")
+		a.Equal(tc.expect, ok, idx)
+		if tc.err {
+			a.Error(err, idx)
+		} else {
+			a.NoError(err, idx)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hmac
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// Config contains the user-visible configuration for validating
+// HMAC-signed requests.
+type Config struct {
+	// If true, signed requests will be accepted.
+	Enabled bool
+	// How often to reload the secrets from the staging database.
+	RefreshDelay time.Duration
+	// The maximum difference between the request's timestamp and the
+	// current time. Signatures seen within the window are tracked by
+	// each process, not shared between processes.
+	ReplayWindow time.Duration
+	// The name of the header that contains the signature.
+	SignatureHeader string
+	// The name of the header that contains the unix timestamp.
+	TimestampHeader string
+}
+
+// Bind registers flags.
+func (c *Config) Bind(flags *pflag.FlagSet) {
+	flags.BoolVar(&c.Enabled, "hmacEnabled", false,
+		"accept requests whose bodies are signed with a per-schema shared secret")
+	flags.DurationVar(&c.RefreshDelay, "hmacRefresh", time.Minute,
+		"how often to scan for updated HMAC secrets; set to zero to disable")
+	flags.DurationVar(&c.ReplayWindow, "hmacReplayWindow", 5*time.Minute,
+		"reject signed requests whose timestamp differs from the current time by more than this amount; "+
+			"replayed signatures are only detected within a single cdc-sink process")
+	flags.StringVar(&c.SignatureHeader, "hmacSignatureHeader", "X-Cdc-Sink-Signature",
+		"the HTTP header that contains the hex-encoded HMAC-SHA256 signature")
+	flags.StringVar(&c.TimestampHeader, "hmacTimestampHeader", "X-Cdc-Sink-Timestamp",
+		"the HTTP header that contains the unix timestamp of a signed request")
+}
+
+// Preflight validates the configuration.
+func (c *Config) Preflight() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ReplayWindow <= 0 {
+		return errors.New("hmacReplayWindow must be positive")
+	}
+	if c.SignatureHeader == "" {
+		return errors.New("hmacSignatureHeader must be set")
+	}
+	if c.TimestampHeader == "" {
+		return errors.New("hmacTimestampHeader must be set")
+	}
+	return nil
+}
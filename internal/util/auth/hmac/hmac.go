@@ -0,0 +1,271 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hmac contains a types.Authenticator which validates requests
+// whose bodies have been signed with a shared secret. This is intended
+// for webhook emitters that cannot present a bearer JWT.
+//
+// The signature is the hex-encoded HMAC-SHA256 of the request's unix
+// timestamp, method, escaped path, and body, separated by newlines:
+//
+//	HEX(HMAC-SHA256(secret, timestamp + "\n" + method + "\n" + path + "\n" + body))
+//
+// The signature and timestamp are sent in configurable headers. The
+// signature may be prefixed with "sha256=". Requests whose timestamp
+// falls outside of the replay window are rejected, as is any signature
+// which has already been seen within the window. The seen signatures
+// are kept in memory, so a request could be replayed against another
+// cdc-sink process that shares the same secrets.
+//
+// The secrets are stored in the staging database and are periodically
+// refreshed:
+//
+//	INSERT INTO _cdc_sink.hmac_secrets (schema_name, secret)
+//	VALUES ('my_db.public', 'a-long-random-string');
+//
+// A schema may have several active secrets, to allow for rotation.
+package hmac
+
+import (
+	"container/heap"
+	"context"
+	cryptohmac "crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/httpauth"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// SecretsTable is the name of the table that contains the shared
+// secrets used to validate signed requests.
+var SecretsTable = ident.New("hmac_secrets")
+
+type authenticator struct {
+	cfg *Config
+	now func() time.Time // Injection point for testing.
+
+	mu struct {
+		sync.RWMutex
+		// Secrets, keyed by the canonical schema name.
+		secrets map[string][][]byte
+	}
+
+	// Signatures which have been accepted within the replay window.
+	seenMu struct {
+		sync.Mutex
+		order seenHeap // Ordered by timestamp, for pruning.
+		seen  map[string]time.Time
+	}
+
+	sql struct {
+		selectSecrets string
+	}
+}
+
+var _ types.Authenticator = (*authenticator)(nil)
+
+// Check implements types.Authenticator. The bearer token is ignored;
+// the signature, timestamp, and body are retrieved from the context.
+func (a *authenticator) Check(ctx context.Context, schema ident.Schema, _ string) (bool, error) {
+	header := httpauth.Header(ctx)
+	if header == nil {
+		return false, nil
+	}
+	sigHeader := header.Get(a.cfg.SignatureHeader)
+	tsHeader := header.Get(a.cfg.TimestampHeader)
+	if sigHeader == "" || tsHeader == "" {
+		return false, nil
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(sigHeader, "sha256="))
+	if err != nil {
+		hmacRejected.WithLabelValues("malformed").Inc()
+		return false, nil
+	}
+	unix, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		hmacRejected.WithLabelValues("malformed").Inc()
+		return false, nil
+	}
+	now := a.now()
+	ts := time.Unix(unix, 0)
+	if skew := now.Sub(ts); skew > a.cfg.ReplayWindow || skew < -a.cfg.ReplayWindow {
+		log.WithFields(log.Fields{
+			"schema":    schema,
+			"timestamp": ts,
+		}).Debug("signed request outside of replay window")
+		hmacRejected.WithLabelValues("expired").Inc()
+		return false, nil
+	}
+
+	a.mu.RLock()
+	secrets := a.mu.secrets[schemaKey(schema)]
+	a.mu.RUnlock()
+	if len(secrets) == 0 {
+		return false, nil
+	}
+
+	body, err := httpauth.Body(ctx)
+	if err != nil {
+		return false, err
+	}
+	method, path := httpauth.Request(ctx)
+
+	for _, secret := range secrets {
+		if !cryptohmac.Equal(sig, Sign(secret, tsHeader, method, path, body)) {
+			continue
+		}
+		if !a.markSeen(string(sig), ts, now) {
+			log.WithField("schema", schema).Debug("replayed signed request")
+			hmacRejected.WithLabelValues("replayed").Inc()
+			return false, nil
+		}
+		log.WithField("schema", schema).Debug("successful authorization")
+		return true, nil
+	}
+	hmacRejected.WithLabelValues("signature").Inc()
+	return false, nil
+}
+
+// Diagnostic implements [diag.Diagnostic].
+func (a *authenticator) Diagnostic(context.Context) any {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	schemas := make(map[string]int, len(a.mu.secrets))
+	for sch, secrets := range a.mu.secrets {
+		schemas[sch] = len(secrets)
+	}
+	return map[string]any{"hmac": true, "schemas": schemas}
+}
+
+// markSeen records an accepted signature. It returns false if the
+// signature has already been seen within the replay window.
+func (a *authenticator) markSeen(sig string, ts, now time.Time) bool {
+	a.seenMu.Lock()
+	defer a.seenMu.Unlock()
+	if a.seenMu.seen == nil {
+		a.seenMu.seen = make(map[string]time.Time)
+	}
+	// Prune entries that would be rejected by the timestamp check.
+	for len(a.seenMu.order) > 0 && now.Sub(a.seenMu.order[0].ts) > a.cfg.ReplayWindow {
+		expired := heap.Pop(&a.seenMu.order).(seenSig)
+		delete(a.seenMu.seen, expired.sig)
+	}
+	if _, dup := a.seenMu.seen[sig]; dup {
+		return false
+	}
+	a.seenMu.seen[sig] = ts
+	heap.Push(&a.seenMu.order, seenSig{sig: sig, ts: ts})
+	return true
+}
+
+// seenSig is an accepted signature and the timestamp of its request.
+type seenSig struct {
+	sig string
+	ts  time.Time
+}
+
+// seenHeap implements [heap.Interface] to order accepted signatures by
+// their timestamps, which may arrive out of order.
+type seenHeap []seenSig
+
+var _ heap.Interface = (*seenHeap)(nil)
+
+func (h seenHeap) Len() int           { return len(h) }
+func (h seenHeap) Less(i, j int) bool { return h[i].ts.Before(h[j].ts) }
+func (h seenHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *seenHeap) Push(x any) { *h = append(*h, x.(seenSig)) }
+
+func (h *seenHeap) Pop() any {
+	old := *h
+	ret := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return ret
+}
+
+// Sign computes the signature of a request. The path must be escaped,
+// as it would appear in the request line. This is exported for use by
+// tests and by clients written in Go.
+func Sign(secret []byte, timestamp, method, path string, body []byte) []byte {
+	mac := cryptohmac.New(sha256.New, secret)
+	for _, part := range []string{timestamp, method, path} {
+		_, _ = mac.Write([]byte(part))
+		_, _ = mac.Write([]byte("\n"))
+	}
+	_, _ = mac.Write(body)
+	return mac.Sum(nil)
+}
+
+const (
+	ensureSecretsTemplate = `
+CREATE TABLE IF NOT EXISTS %s (
+	id UUID NOT NULL PRIMARY KEY DEFAULT gen_random_uuid(),
+	schema_name STRING NOT NULL,
+	secret STRING NOT NULL,
+	active BOOL NOT NULL DEFAULT true
+)`
+	selectSecretsTemplate = `SELECT id, schema_name, secret FROM %s WHERE active`
+)
+
+// refresh loads the active secrets from the database.
+func (a *authenticator) refresh(ctx context.Context, tx types.StagingQuerier) error {
+	next := make(map[string][][]byte)
+
+	rows, err := tx.Query(ctx, a.sql.selectSecrets)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, schemaName, secret string
+		if err := rows.Scan(&id, &schemaName, &secret); err != nil {
+			return errors.WithStack(err)
+		}
+		sch, err := ident.ParseSchema(schemaName)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse schema name in %s", id)
+		}
+		if secret == "" {
+			return errors.Errorf("empty secret in %s", id)
+		}
+		key := schemaKey(sch)
+		next[key] = append(next[key], []byte(secret))
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mu.secrets = next
+	log.Trace("refreshed HMAC data")
+	hmacRefreshedAt.SetToCurrentTime()
+	return nil
+}
+
+// schemaKey returns a case-insensitive map key for the schema.
+func schemaKey(sch ident.Schema) string {
+	return strings.ToLower(sch.Canonical().Raw())
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hmac
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/util/httpauth"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMAC(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1_700_000_000, 0)
+	cfg := &Config{
+		Enabled:         true,
+		ReplayWindow:    time.Minute,
+		SignatureHeader: "X-Signature",
+		TimestampHeader: "X-Timestamp",
+	}
+	a.NoError(cfg.Preflight())
+
+	auth := &authenticator{cfg: cfg, now: func() time.Time { return now }}
+	auth.mu.secrets = map[string][][]byte{
+		"db.public": {[]byte("old-secret"), []byte("new-secret")},
+	}
+
+	target := ident.MustSchema(ident.New("DB"), ident.Public)
+	other := ident.MustSchema(ident.New("other"), ident.Public)
+	body := []byte(`{"payload":[]}`)
+
+	const method, path = http.MethodPost, "/db/public"
+	request := func(secret string, ts time.Time, body []byte, prefix string) context.Context {
+		tsString := strconv.FormatInt(ts.Unix(), 10)
+		sig := hex.EncodeToString(Sign([]byte(secret), tsString, method, path, body))
+		header := http.Header{}
+		header.Set(cfg.SignatureHeader, prefix+sig)
+		header.Set(cfg.TimestampHeader, tsString)
+		return httpauth.WithSignedRequest(context.Background(), method, path, header, body)
+	}
+	check := func(ctx context.Context, schema ident.Schema) bool {
+		ok, err := auth.Check(ctx, schema, "")
+		a.NoError(err)
+		return ok
+	}
+
+	a.False(check(context.Background(), target), "no request")
+	a.False(check(httpauth.WithSignedRequest(
+		context.Background(), method, path, http.Header{}, body), target),
+		"no headers")
+
+	a.True(check(request("new-secret", now, body, ""), target))
+	a.True(check(request("old-secret", now.Add(-time.Second), body, "sha256="), target))
+	a.False(check(request("new-secret", now.Add(-2*time.Second), body, ""), other),
+		"wrong schema")
+	a.False(check(request("bad-secret", now, body, ""), target), "bad secret")
+
+	// The signature must cover the method, path, and body.
+	ctx := request("new-secret", now.Add(-3*time.Second), body, "")
+	a.False(check(httpauth.WithSignedRequest(
+		ctx, method, path, httpauth.Header(ctx), []byte(`{}`)), target), "modified body")
+	a.False(check(httpauth.WithSignedRequest(
+		ctx, http.MethodPut, path, httpauth.Header(ctx), body), target), "modified method")
+	a.False(check(httpauth.WithSignedRequest(
+		ctx, method, "/other/public", httpauth.Header(ctx), body), target), "modified path")
+
+	// The timestamp must fall within the replay window.
+	a.False(check(request("new-secret", now.Add(-2*time.Minute), body, ""), target), "too old")
+	a.False(check(request("new-secret", now.Add(2*time.Minute), body, ""), target), "too new")
+
+	// A valid request cannot be submitted twice.
+	replay := request("new-secret", now.Add(-4*time.Second), body, "")
+	a.True(check(replay, target))
+	a.False(check(replay, target), "replayed")
+
+	// Seen signatures are pruned once they fall out of the window.
+	now = now.Add(2 * time.Minute)
+	a.True(check(request("new-secret", now, body, ""), target))
+	a.Len(auth.seenMu.seen, 1)
+	a.Len(auth.seenMu.order, 1)
+}
+
+// Verify that signatures are pruned in timestamp order, even if the
+// requests arrive out of order.
+func TestMarkSeen(t *testing.T) {
+	a := assert.New(t)
+
+	auth := &authenticator{cfg: &Config{ReplayWindow: time.Minute}}
+	now := time.Unix(1_700_000_000, 0)
+
+	a.True(auth.markSeen("b", now.Add(-10*time.Second), now))
+	a.True(auth.markSeen("a", now.Add(-50*time.Second), now))
+	a.True(auth.markSeen("c", now.Add(30*time.Second), now))
+	a.False(auth.markSeen("a", now.Add(-50*time.Second), now))
+	a.Len(auth.seenMu.seen, 3)
+
+	// Only a has fallen out of the window.
+	now = now.Add(20 * time.Second)
+	a.True(auth.markSeen("d", now, now))
+	a.Len(auth.seenMu.seen, 3)
+	a.NotContains(auth.seenMu.seen, "a")
+	a.True(auth.markSeen("a", now.Add(-50*time.Second), now), "pruned")
+
+	// Every previous entry has expired.
+	now = now.Add(100 * time.Second)
+	a.True(auth.markSeen("c", now, now))
+	a.False(auth.markSeen("c", now, now))
+	a.Len(auth.seenMu.seen, 1)
+	a.Len(auth.seenMu.order, 1)
+}
+
+// Verify that secrets are loaded from the staging database.
+func TestHMACProvider(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	fixture, err := all.NewFixture(t)
+	r.NoError(err)
+
+	ctx := fixture.Context
+	pool := fixture.StagingPool
+	stagingDB := fixture.StagingDB
+
+	cfg := &Config{
+		Enabled:         true,
+		ReplayWindow:    time.Minute,
+		SignatureHeader: "X-Signature",
+		TimestampHeader: "X-Timestamp",
+	}
+	auth, err := ProvideAuth(ctx, cfg, pool, stagingDB)
+	r.NoError(err)
+	impl := auth.(*authenticator)
+	a.Empty(impl.mu.secrets)
+
+	_, err = pool.Exec(ctx, fmt.Sprintf(
+		"INSERT INTO %s (schema_name, secret) VALUES ($1, $2), ($1, $3), ($4, $5)",
+		ident.NewTable(stagingDB.Schema(), SecretsTable)),
+		"db.public", "secret", "rotated", "other.public", "other")
+	r.NoError(err)
+	r.NoError(impl.refresh(ctx, pool))
+
+	a.Len(impl.mu.secrets["db.public"], 2)
+	a.Len(impl.mu.secrets["other.public"], 1)
+}
+
+func TestHMACConfigPreflight(t *testing.T) {
+	a := assert.New(t)
+	a.NoError((&Config{}).Preflight())
+	a.Error((&Config{Enabled: true}).Preflight())
+	a.Error((&Config{Enabled: true, ReplayWindow: time.Minute, SignatureHeader: "X-Sig"}).Preflight())
+	a.NoError((&Config{
+		Enabled:         true,
+		ReplayWindow:    time.Minute,
+		SignatureHeader: "X-Sig",
+		TimestampHeader: "X-Ts",
+	}).Preflight())
+}
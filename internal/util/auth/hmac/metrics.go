@@ -0,0 +1,33 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hmac
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	hmacRefreshedAt = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hmac_last_refresh_time",
+		Help: "the unix timestamp at which the HMAC secrets were refreshed",
+	})
+	hmacRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hmac_rejected_count",
+		Help: "the number of signed requests that were rejected",
+	}, []string{"reason"})
+)
@@ -0,0 +1,76 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hmac
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ProvideAuth constructs an HMAC-based authenticator. This provider
+// will also start a background goroutine to look for configuration
+// changes in the database.
+func ProvideAuth(
+	ctx *stopper.Context, cfg *Config, db types.StagingQuerier, stagingDB ident.StagingSchema,
+) (types.Authenticator, error) {
+	secretsTable := ident.NewTable(stagingDB.Schema(), SecretsTable)
+
+	if _, err := db.Exec(ctx, fmt.Sprintf(ensureSecretsTemplate, secretsTable)); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	impl := &authenticator{cfg: cfg, now: time.Now}
+	impl.sql.selectSecrets = fmt.Sprintf(selectSecretsTemplate, secretsTable)
+
+	if err := impl.refresh(ctx, db); err != nil {
+		return nil, err
+	}
+
+	// Start a refresh loop that will also listen for HUP signals.
+	if cfg.RefreshDelay > 0 {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGHUP)
+		ctx.Go(func() error {
+			defer close(ch)
+			defer signal.Stop(ch)
+
+			for {
+				select {
+				case <-ctx.Stopping():
+					return nil
+				case <-ch:
+					log.Debug("reloading HMAC data due to SIGHUP")
+				case <-time.After(cfg.RefreshDelay):
+				}
+				if err := impl.refresh(ctx, db); err != nil {
+					log.WithError(err).Warn("could not refresh HMAC data")
+				}
+			}
+		})
+	}
+
+	return impl, nil
+}
@@ -17,8 +17,13 @@
 package jwt
 
 import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
 )
 
 // ClaimData is the custom data that we want to embed in a JWT token.
@@ -48,3 +53,74 @@ func (c Claims) Valid() error {
 	}
 	return c.RegisteredClaims.Valid()
 }
+
+// schemaClaims extends Claims to read additional schema names from a
+// user-configured claim, for use with external identity providers.
+type schemaClaims struct {
+	Claims
+	name string // The name of the additional claim, may be empty.
+}
+
+// UnmarshalJSON decodes the standard claims and then appends any
+// schemas found in the named claim.
+func (c *schemaClaims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.Claims); err != nil {
+		return errors.WithStack(err)
+	}
+	if c.name == "" {
+		return nil
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return errors.WithStack(err)
+	}
+	raw, ok := all[c.name]
+	if !ok {
+		return nil
+	}
+	schemas, err := parseSchemaClaim(raw)
+	if err != nil {
+		return errors.Wrapf(err, "claim %q", c.name)
+	}
+	c.Ext.Schemas = append(c.Ext.Schemas, schemas...)
+	return nil
+}
+
+// parseSchemaClaim accepts a string of space- or comma-separated schema
+// names, or an array whose elements are either schema names or arrays
+// of name parts.
+func parseSchemaClaim(raw json.RawMessage) ([]ident.Schema, error) {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		var ret []ident.Schema
+		for _, name := range strings.FieldsFunc(str, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		}) {
+			sch, err := ident.ParseSchema(name)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, sch)
+		}
+		return ret, nil
+	}
+
+	var elts []json.RawMessage
+	if err := json.Unmarshal(raw, &elts); err != nil {
+		return nil, errors.New("expecting a string or an array")
+	}
+	ret := make([]ident.Schema, 0, len(elts))
+	for _, elt := range elts {
+		var sch ident.Schema
+		if err := json.Unmarshal(elt, &str); err == nil {
+			sch, err = ident.ParseSchema(str)
+			if err != nil {
+				return nil, err
+			}
+		} else if err := json.Unmarshal(elt, &sch); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret = append(ret, sch)
+	}
+	return ret, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package jwt
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// Config contains the user-visible configuration for validating JWT
+// tokens against keys provided by an external identity provider.
+type Config struct {
+	// If set, tokens must contain this value in their "aud" claim.
+	Audience string
+	// If set, tokens must contain this value in their "iss" claim.
+	Issuer string
+	// A path to a local JSON Web Key Set, for air-gapped deployments.
+	JWKSFile string
+	// A URL from which to retrieve a JSON Web Key Set.
+	JWKSURL string
+	// If true, the JWKS URL will be discovered from the issuer's
+	// OpenID Connect configuration document.
+	OIDCDiscovery bool
+	// The name of an additional claim which holds schema names.
+	SchemasClaim string
+}
+
+// Bind registers flags.
+func (c *Config) Bind(flags *pflag.FlagSet) {
+	flags.StringVar(&c.Audience, "jwtAudience", "",
+		"if set, JWT tokens must contain the value in their aud claim")
+	flags.StringVar(&c.Issuer, "jwtIssuer", "",
+		"if set, JWT tokens must contain the value in their iss claim")
+	flags.StringVar(&c.JWKSFile, "jwtJWKSFile", "",
+		"a path to a JSON Web Key Set file containing token signing keys")
+	flags.StringVar(&c.JWKSURL, "jwtJWKSURL", "",
+		"a URL from which to load a JSON Web Key Set containing token signing keys")
+	flags.BoolVar(&c.OIDCDiscovery, "jwtOIDCDiscovery", false,
+		"discover the JWKS URL from the OpenID Connect configuration of the jwtIssuer")
+	flags.StringVar(&c.SchemasClaim, "jwtSchemasClaim", "",
+		"the name of an additional claim that contains a list of schema names, "+
+			"e.g. [\"db.public\"] or \"db.public other_db.public\"")
+}
+
+// Preflight validates the configuration. A local JWKS file must be
+// readable, since, unlike a remote key set, it is not expected to
+// become available later.
+func (c *Config) Preflight() error {
+	if c.JWKSFile != "" {
+		if _, err := loadJWKSFile(c.JWKSFile); err != nil {
+			return errors.Wrap(err, "jwtJWKSFile")
+		}
+	}
+	if c.JWKSURL != "" {
+		if err := checkHTTPURL(c.JWKSURL); err != nil {
+			return errors.Wrap(err, "jwtJWKSURL")
+		}
+	}
+	if c.OIDCDiscovery {
+		if c.Issuer == "" {
+			return errors.New("jwtOIDCDiscovery requires jwtIssuer to be set")
+		}
+		if c.JWKSURL != "" {
+			return errors.New("jwtOIDCDiscovery and jwtJWKSURL are mutually exclusive")
+		}
+		if err := checkHTTPURL(c.Issuer); err != nil {
+			return errors.Wrap(err, "jwtIssuer")
+		}
+	}
+	return nil
+}
+
+// usesJWKS returns true if any external key source is configured.
+func (c *Config) usesJWKS() bool {
+	return c.JWKSFile != "" || c.JWKSURL != "" || c.OIDCDiscovery
+}
+
+func checkHTTPURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return nil
+	default:
+		return errors.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// minJWKSFetchInterval limits how often a token with an unknown key id
+// can cause the JWKS to be fetched.
+const minJWKSFetchInterval = 30 * time.Second
+
+var jwksClient = &http.Client{Timeout: 30 * time.Second}
+
+// A jwk is a single JSON Web Key. Only the fields necessary to
+// reconstruct RSA and EC public keys are decoded.
+//
+// https://www.rfc-editor.org/rfc/rfc7517
+type jwk struct {
+	Crv string `json:"crv"`
+	E   string `json:"e"`
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	Use string `json:"use"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// A keyEntry associates a public key with its optional key id.
+type keyEntry struct {
+	ID  string
+	Key crypto.PublicKey
+}
+
+// parseJWKS decodes the signing keys in a JSON Web Key Set. Keys of
+// unsupported types or that are not intended for signatures are
+// skipped.
+func parseJWKS(data []byte) ([]keyEntry, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrap(err, "could not decode JWKS")
+	}
+
+	ret := make([]keyEntry, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, errors.Wrapf(err, "key %q", k.Kid)
+		}
+		if key == nil {
+			log.Debugf("ignoring JWKS key %q of type %q", k.Kid, k.Kty)
+			continue
+		}
+		ret = append(ret, keyEntry{ID: k.Kid, Key: key})
+	}
+	return ret, nil
+}
+
+// publicKey returns the public key, or nil if the key type is not
+// supported.
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing key parameter")
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+// fetch retrieves the body of an HTTP GET request.
+func fetch(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp, err := jwksClient.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("could not fetch %s: %s", u, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, errors.Wrap(err, u)
+}
+
+// discoverJWKS retrieves the jwks_uri from the issuer's OpenID Connect
+// configuration document.
+//
+// https://openid.net/specs/openid-connect-discovery-1_0.html
+func discoverJWKS(ctx context.Context, issuer string) (string, error) {
+	data, err := fetch(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return "", err
+	}
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", errors.Wrap(err, "could not decode OpenID configuration")
+	}
+	if doc.JWKSURI == "" {
+		return "", errors.New("OpenID configuration does not contain a jwks_uri")
+	}
+	return doc.JWKSURI, checkHTTPURL(doc.JWKSURI)
+}
+
+// loadJWKSFile reads the signing keys from a local JSON Web Key Set.
+func loadJWKSFile(path string) ([]keyEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	keys, err := parseJWKS(data)
+	return keys, errors.Wrap(err, path)
+}
+
+// refreshJWKS reloads the configured JSON Web Key Sets. If any of the
+// configured sources cannot be loaded, an error is returned and the
+// previous keys are retained.
+func (a *authenticator) refreshJWKS(ctx context.Context) error {
+	if !a.cfg.usesJWKS() {
+		return nil
+	}
+
+	a.jwksMu.Lock()
+	defer a.jwksMu.Unlock()
+	return a.refreshJWKSLocked(ctx)
+}
+
+// refreshJWKSLocked implements refreshJWKS. The caller must hold
+// jwksMu.
+func (a *authenticator) refreshJWKSLocked(ctx context.Context) error {
+	a.jwksMu.lastFetch = time.Now()
+
+	var next []keyEntry
+	if a.cfg.JWKSFile != "" {
+		keys, err := loadJWKSFile(a.cfg.JWKSFile)
+		if err != nil {
+			return err
+		}
+		next = append(next, keys...)
+	}
+
+	jwksURL := a.cfg.JWKSURL
+	if a.cfg.OIDCDiscovery {
+		var err error
+		jwksURL, err = discoverJWKS(ctx, a.cfg.Issuer)
+		if err != nil {
+			return err
+		}
+	}
+	if jwksURL != "" {
+		data, err := fetch(ctx, jwksURL)
+		if err != nil {
+			return err
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return errors.Wrap(err, jwksURL)
+		}
+		next = append(next, keys...)
+	}
+
+	a.mu.Lock()
+	a.mu.jwks = next
+	a.mu.Unlock()
+	log.Tracef("refreshed %d JWKS keys", len(next))
+	jwksRefreshedAt.SetToCurrentTime()
+	return nil
+}
+
+// retryJWKS is called if the JSON Web Key Sets could not be loaded at
+// startup. It retries, with an increasing delay, until the keys have
+// been loaded or the context is stopped.
+func (a *authenticator) retryJWKS(ctx *stopper.Context, delay time.Duration) {
+	for {
+		select {
+		case <-ctx.Stopping():
+			return
+		case <-time.After(delay):
+		}
+		err := a.refreshJWKS(ctx)
+		if err == nil {
+			log.Info("loaded JWKS")
+			return
+		}
+		log.WithError(err).Warn("could not load JWKS; will retry")
+		if delay *= 2; delay > minJWKSFetchInterval {
+			delay = minJWKSFetchInterval
+		}
+	}
+}
+
+// maybeRefreshJWKS is called when a token refers to an unknown key id,
+// which may indicate that the identity provider has rotated its keys.
+// It returns true if the keys were reloaded. The lock is held for the
+// duration of the fetch, so that concurrent requests with the same
+// unknown key id cause only one fetch.
+func (a *authenticator) maybeRefreshJWKS(ctx context.Context) bool {
+	if a.cfg.JWKSURL == "" && !a.cfg.OIDCDiscovery {
+		return false
+	}
+	waitStart := time.Now()
+	a.jwksMu.Lock()
+	defer a.jwksMu.Unlock()
+	// Another request refreshed the keys while this one was waiting.
+	if a.jwksMu.lastFetch.After(waitStart) {
+		return true
+	}
+	if time.Since(a.jwksMu.lastFetch) < minJWKSFetchInterval {
+		return false
+	}
+	if err := a.refreshJWKSLocked(ctx); err != nil {
+		log.WithError(err).Warn("could not refresh JWKS")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,320 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ecJWK returns the JWK representation of the key's public half.
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	enc := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.FillBytes(make([]byte, 32)))
+	}
+	return map[string]string{
+		"crv": "P-256",
+		"kid": kid,
+		"kty": "EC",
+		"use": "sig",
+		"x":   enc(key.X),
+		"y":   enc(key.Y),
+	}
+}
+
+// signWithKid signs the claims and sets the key id in the header.
+func signWithKid(t *testing.T, kid string, key *ecdsa.PrivateKey, claims jwt.Claims) string {
+	tkn := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	if kid != "" {
+		tkn.Header["kid"] = kid
+	}
+	ret, err := tkn.SignedString(key)
+	require.NoError(t, err)
+	return ret
+}
+
+func TestParseJWKS(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	r.NoError(err)
+
+	data, err := json.Marshal(map[string]any{
+		"keys": []map[string]string{
+			ecJWK("ec", ecKey),
+			{
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+				"kid": "rsa",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			},
+			{"kid": "enc", "kty": "RSA", "use": "enc"},
+			{"kid": "oct", "kty": "oct"},
+		},
+	})
+	r.NoError(err)
+
+	keys, err := parseJWKS(data)
+	r.NoError(err)
+	if a.Len(keys, 2) {
+		a.Equal("ec", keys[0].ID)
+		a.True(ecKey.PublicKey.Equal(keys[0].Key))
+		a.Equal("rsa", keys[1].ID)
+		a.True(rsaKey.PublicKey.Equal(keys[1].Key))
+	}
+
+	_, err = parseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}]}`))
+	a.ErrorContains(err, "not on curve")
+}
+
+func TestJWKS(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	ctx := context.Background()
+
+	key1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	key2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+
+	// The served key set can be swapped to simulate rotation.
+	var served atomic.Value
+	served.Store(map[string]any{"keys": []any{ecJWK("k1", key1)}})
+
+	var svr *httptest.Server
+	svr = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"jwks_uri": svr.URL + "/keys"})
+		case "/keys":
+			_ = json.NewEncoder(w).Encode(served.Load())
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer svr.Close()
+
+	target := ident.MustSchema(ident.New("db"), ident.Public)
+	other := ident.MustSchema(ident.New("other"), ident.Public)
+	claims := func(iss, aud string) *Claims {
+		cl, err := NewClaim([]ident.Schema{target})
+		r.NoError(err)
+		cl.Issuer = iss
+		cl.Audience = jwt.ClaimStrings{aud}
+		cl.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+		return &cl
+	}
+
+	cfg := &Config{
+		Audience:      "cdc-sink",
+		Issuer:        svr.URL,
+		OIDCDiscovery: true,
+	}
+	r.NoError(cfg.Preflight())
+	auth := &authenticator{cfg: cfg}
+	r.NoError(auth.refreshJWKS(ctx))
+
+	check := func(token string, schema ident.Schema) bool {
+		ok, err := auth.Check(ctx, schema, token)
+		r.NoError(err)
+		return ok
+	}
+
+	good := signWithKid(t, "k1", key1, claims(svr.URL, "cdc-sink"))
+	a.True(check(good, target))
+	a.False(check(good, other))
+
+	// Issuer and audience must match.
+	a.False(check(signWithKid(t, "k1", key1, claims("https://elsewhere", "cdc-sink")), target))
+	a.False(check(signWithKid(t, "k1", key1, claims(svr.URL, "other")), target))
+
+	// A token signed by an unknown key must not validate, even if the
+	// key id matches.
+	a.False(check(signWithKid(t, "k1", key2, claims(svr.URL, "cdc-sink")), target))
+
+	// Rotate the keys. A token with a new key id causes a refresh, but
+	// only once the minimum interval has elapsed.
+	served.Store(map[string]any{"keys": []any{ecJWK("k2", key2)}})
+	rotated := signWithKid(t, "k2", key2, claims(svr.URL, "cdc-sink"))
+	a.False(check(rotated, target))
+	auth.jwksMu.lastFetch = time.Time{}
+	a.True(check(rotated, target))
+	a.False(check(good, target))
+
+	// Tokens without a key id are checked against all keys.
+	a.True(check(signWithKid(t, "", key2, claims(svr.URL, "cdc-sink")), target))
+}
+
+// Verify that keys which cannot be loaded at startup are retried in
+// the background.
+func TestRetryJWKS(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+
+	// The server is unavailable for the first few requests.
+	var requests atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if requests.Add(1) <= 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []any{ecJWK("k1", key)}})
+	}))
+	defer svr.Close()
+
+	auth := &authenticator{cfg: &Config{JWKSURL: svr.URL + "/keys"}}
+	r.Error(auth.refreshJWKS(context.Background()))
+
+	ctx := stopper.WithContext(context.Background())
+	defer ctx.Stop(0)
+	auth.retryJWKS(ctx, time.Millisecond)
+	a.Equal(int32(4), requests.Load())
+	auth.mu.RLock()
+	a.Len(auth.mu.jwks, 1)
+	auth.mu.RUnlock()
+
+	// Retries end when the context is stopped.
+	requests.Store(0)
+	stopped := stopper.WithContext(context.Background())
+	stopped.Stop(0)
+	auth.retryJWKS(stopped, time.Hour)
+	a.Zero(requests.Load())
+}
+
+// Verify that concurrent requests with an unknown key id cause only
+// one fetch.
+func TestMaybeRefreshJWKSOnce(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+
+	var requests atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []any{ecJWK("k1", key)}})
+	}))
+	defer svr.Close()
+
+	auth := &authenticator{cfg: &Config{JWKSURL: svr.URL + "/keys"}}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			auth.maybeRefreshJWKS(context.Background())
+		}()
+	}
+	wg.Wait()
+	a.Equal(int32(1), requests.Load())
+}
+
+func TestJWKSFileAndSchemasClaim(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	ctx := context.Background()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	data, err := json.Marshal(map[string]any{"keys": []any{ecJWK("local", key)}})
+	r.NoError(err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	r.NoError(os.WriteFile(path, data, 0600))
+
+	auth := &authenticator{cfg: &Config{JWKSFile: path, SchemasClaim: "scope"}}
+	r.NoError(auth.refreshJWKS(ctx))
+
+	target := ident.MustSchema(ident.New("db"), ident.Public)
+	other := ident.MustSchema(ident.New("other"), ident.Public)
+
+	tcs := []struct {
+		scope  any
+		expect bool
+	}{
+		{scope: "openid db.public", expect: true},
+		{scope: "db.public,other.public", expect: true},
+		{scope: []string{"db.public"}, expect: true},
+		{scope: [][]string{{"db", "*"}}, expect: true},
+		{scope: "other.public", expect: false},
+		{scope: nil, expect: false}, // No schemas at all is invalid.
+	}
+	for _, tc := range tcs {
+		claims := jwt.MapClaims{"jti": "some-id"}
+		if tc.scope != nil {
+			claims["scope"] = tc.scope
+		}
+		token := signWithKid(t, "local", key, claims)
+		ok, err := auth.Check(ctx, target, token)
+		a.NoError(err)
+		a.Equalf(tc.expect, ok, "%v", tc.scope)
+	}
+
+	// The custom claim is additive to the standard claim.
+	cl, err := NewClaim([]ident.Schema{other})
+	r.NoError(err)
+	token := signWithKid(t, "local", key, &cl)
+	ok, err := auth.Check(ctx, other, token)
+	a.NoError(err)
+	a.True(ok)
+}
+
+func TestJWTConfigPreflight(t *testing.T) {
+	a := assert.New(t)
+	a.NoError((&Config{}).Preflight())
+	a.NoError((&Config{JWKSURL: "https://idp/keys"}).Preflight())
+	a.Error((&Config{JWKSURL: "ftp://idp/keys"}).Preflight())
+	a.Error((&Config{OIDCDiscovery: true}).Preflight())
+	a.Error((&Config{Issuer: "https://idp", JWKSURL: "https://idp/keys", OIDCDiscovery: true}).Preflight())
+	a.NoError((&Config{Issuer: "https://idp", OIDCDiscovery: true}).Preflight())
+
+	// A local key set must be readable.
+	dir := t.TempDir()
+	a.Error((&Config{JWKSFile: filepath.Join(dir, "missing.json")}).Preflight())
+	bad := filepath.Join(dir, "bad.json")
+	a.NoError(os.WriteFile(bad, []byte("not json"), 0600))
+	a.Error((&Config{JWKSFile: bad}).Preflight())
+	good := filepath.Join(dir, "good.json")
+	a.NoError(os.WriteFile(good, []byte(`{"keys":[]}`), 0600))
+	a.NoError((&Config{JWKSFile: good}).Preflight())
+}
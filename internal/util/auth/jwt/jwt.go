@@ -20,6 +20,11 @@
 // We require that incoming tokens have
 // been signed with either RSA or EC keys. The public keys used for
 // validation are stored in the database and are periodically refreshed.
+// Keys may also be loaded from a JSON Web Key Set (JWKS) that is
+// published by an OpenID Connect provider or stored in a local file.
+// Tokens that carry a "kid" header are validated against the JWKS key
+// with the same id, and the JWKS will be reloaded if the key id is
+// unknown, to support key rotation.
 //
 // Incoming JWT tokens are required to have the well-known "jti" token
 // identifier field set. This is checked against a list of revoked token
@@ -89,8 +94,17 @@ var (
 )
 
 type authenticator struct {
+	cfg *Config
+
+	// Serializes JWKS refreshes.
+	jwksMu struct {
+		sync.Mutex
+		lastFetch time.Time
+	}
+
 	mu struct {
 		sync.RWMutex
+		jwks       []keyEntry
 		publicKeys []crypto.PublicKey
 		revoked    map[string]struct{}
 	}
@@ -105,14 +119,76 @@ var _ types.Authenticator = (*authenticator)(nil)
 
 // Check implements types.Authenticator.
 func (a *authenticator) Check(
-	_ context.Context, schema ident.Schema, token string,
+	ctx context.Context, schema ident.Schema, token string,
 ) (ok bool, _ error) {
+	claims, ok := a.verify(ctx, token)
+	if !ok {
+		return false, nil
+	}
+
+	a.mu.RLock()
+	_, revoked := a.mu.revoked[claims.ID]
+	a.mu.RUnlock()
+	if revoked {
+		log.WithFields(log.Fields{
+			"id":     claims.ID,
+			"schema": schema,
+		}).Debug("saw revoked token")
+		return false, nil
+	}
+	for _, allowed := range claims.Ext.Schemas {
+		if matches(allowed, schema) {
+			log.WithFields(log.Fields{
+				"id":     claims.ID,
+				"schema": schema,
+			}).Debug("successful authorization")
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// candidates returns the public keys which may have been used to sign
+// a token with the given key id. A JWKS key with a matching id is
+// preferred. The boolean return value will be false if the key id
+// should have matched a JWKS key, but did not.
+func (a *authenticator) candidates(kid string) ([]crypto.PublicKey, bool) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	for _, key := range a.mu.publicKeys {
-		var claims Claims
+
+	if kid != "" {
+		for _, entry := range a.mu.jwks {
+			if entry.ID == kid {
+				return []crypto.PublicKey{entry.Key}, true
+			}
+		}
+	}
+
+	ret := make([]crypto.PublicKey, 0, len(a.mu.publicKeys)+len(a.mu.jwks))
+	ret = append(ret, a.mu.publicKeys...)
+	for _, entry := range a.mu.jwks {
+		if kid == "" || entry.ID == "" {
+			ret = append(ret, entry.Key)
+		}
+	}
+	return ret, kid == "" || !a.cfg.usesJWKS()
+}
+
+// verify returns the claims in the token if it has been signed by a
+// known key and satisfies the configured issuer and audience.
+func (a *authenticator) verify(ctx context.Context, token string) (*Claims, bool) {
+	kid := tokenKeyID(token)
+	keys, known := a.candidates(kid)
+	// The identity provider may have rotated its keys.
+	if !known && a.maybeRefreshJWKS(ctx) {
+		keys, _ = a.candidates(kid)
+	}
+
+	for _, key := range keys {
+		claims := &schemaClaims{name: a.cfg.SchemasClaim}
 		_, err := jwt.ParseWithClaims(token,
-			&claims,
+			claims,
 			func(unvalidated *jwt.Token) (any, error) {
 				return key, nil
 			},
@@ -122,30 +198,33 @@ func (a *authenticator) Check(
 			log.WithError(errors.WithStack(err)).Trace("invalid token")
 			continue
 		}
-		if _, revoked := a.mu.revoked[claims.ID]; revoked {
-			log.WithFields(log.Fields{
-				"id":     claims.ID,
-				"schema": schema,
-			}).Debug("saw revoked token")
-			return false, nil
+		if a.cfg.Issuer != "" && !claims.VerifyIssuer(a.cfg.Issuer, true) {
+			log.WithField("iss", claims.Issuer).Debug("unexpected token issuer")
+			return nil, false
 		}
-		for _, allowed := range claims.Ext.Schemas {
-			if matches(allowed, schema) {
-				log.WithFields(log.Fields{
-					"id":     claims.ID,
-					"schema": schema,
-				}).Debug("successful authorization")
-				return true, nil
-			}
+		if a.cfg.Audience != "" && !claims.VerifyAudience(a.cfg.Audience, true) {
+			log.WithField("aud", claims.Audience).Debug("unexpected token audience")
+			return nil, false
 		}
+		return &claims.Claims, true
 	}
+	return nil, false
+}
 
-	return false, nil
+// tokenKeyID returns the "kid" header of the token, if any.
+func tokenKeyID(token string) string {
+	tkn, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+	kid, _ := tkn.Header["kid"].(string)
+	return kid
 }
 
 // Diagnostic implements [diag.Diagnostic].
 func (a *authenticator) Diagnostic(context.Context) any {
 	type payload struct {
+		JWKSKeys   int
 		JWT        bool
 		PublicKeys int
 		Revoked    map[string]bool
@@ -157,6 +236,7 @@ func (a *authenticator) Diagnostic(context.Context) any {
 
 	a.mu.RLock()
 	defer a.mu.RUnlock()
+	p.JWKSKeys = len(a.mu.jwks)
 	p.PublicKeys = len(a.mu.publicKeys)
 	for id := range a.mu.revoked {
 		p.Revoked[id] = true
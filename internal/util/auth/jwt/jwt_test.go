@@ -38,7 +38,7 @@ func TestJWT(t *testing.T) {
 	pool := fixture.StagingPool
 	stagingDB := fixture.StagingDB
 
-	auth, err := ProvideAuth(ctx, pool, stagingDB)
+	auth, err := ProvideAuth(ctx, &Config{}, pool, stagingDB)
 	if !a.NoError(err) {
 		return
 	}
@@ -26,4 +26,8 @@ var (
 		Name: "jwt_last_refresh_time",
 		Help: "the unix timestamp at which the JWT caches were refreshed",
 	})
+	jwksRefreshedAt = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "jwt_jwks_last_refresh_time",
+		Help: "the unix timestamp at which the JSON Web Key Sets were refreshed",
+	})
 )
@@ -38,7 +38,7 @@ var Set = wire.NewSet(ProvideAuth)
 // This provider will also start a background goroutine to look for
 // configuration changes in the database.
 func ProvideAuth(
-	ctx *stopper.Context, db types.StagingQuerier, stagingDB ident.StagingSchema,
+	ctx *stopper.Context, cfg *Config, db types.StagingQuerier, stagingDB ident.StagingSchema,
 ) (auth types.Authenticator, err error) {
 	keyTable := ident.NewTable(stagingDB.Schema(), PublicKeysTable)
 	revokedTable := ident.NewTable(stagingDB.Schema(), RevokedIdsTable)
@@ -53,7 +53,7 @@ func ProvideAuth(
 		return
 	}
 
-	impl := &authenticator{}
+	impl := &authenticator{cfg: cfg}
 	impl.sql.selectKeys = fmt.Sprintf(selectKeysTemplate, keyTable)
 	impl.sql.selectRevoked = fmt.Sprintf(selectRevokedTemplate, revokedTable)
 
@@ -61,6 +61,21 @@ func ProvideAuth(
 	if err = impl.refresh(ctx, db); err != nil {
 		return
 	}
+	// The identity provider may not be reachable yet, so the keys will
+	// be loaded in the background. Tokens signed by those keys will be
+	// rejected until then. A local file is not retried, since it has
+	// already been checked by Preflight.
+	if jwksErr := impl.refreshJWKS(ctx); jwksErr != nil {
+		if cfg.JWKSURL == "" && !cfg.OIDCDiscovery {
+			err = jwksErr
+			return
+		}
+		log.WithError(jwksErr).Warn("could not load JWKS; will retry")
+		ctx.Go(func() error {
+			impl.retryJWKS(ctx, time.Second)
+			return nil
+		})
+	}
 
 	// Start a refresh loop that will also listen for HUP signals.
 	if *RefreshDelay > 0 {
@@ -81,6 +96,9 @@ func ProvideAuth(
 				if err := impl.refresh(ctx, db); err != nil {
 					log.WithError(err).Warn("could not refresh JWT data")
 				}
+				if err := impl.refreshJWKS(ctx); err != nil {
+					log.WithError(err).Warn("could not refresh JWKS")
+				}
 			}
 		})
 	}
@@ -0,0 +1,246 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package mtls contains a types.Authenticator which authorizes
+// requests based on the TLS client certificate presented by the
+// caller.
+//
+// The client certificate chain must be valid with respect to a
+// user-provided CA bundle. The leaf certificate is then compared to a
+// list of rules, loaded from a JSON file, which map certificate
+// subjects or subject alternative names (SANs) to the schemas that the
+// caller may operate on:
+//
+//	{
+//	  "rules": [
+//	    {
+//	      "commonName": "changefeed-*",
+//	      "schemas": [ "my_db.public" ]
+//	    },
+//	    {
+//	      "san": "spiffe://example.com/ns/prod/*",
+//	      "schemas": [ "*.public" ]
+//	    }
+//	  ]
+//	}
+//
+// All patterns in a rule must match the certificate. Patterns may use
+// "*" to match any sequence of characters and "?" to match a single
+// character. Schema patterns are case-insensitive.
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/httpauth"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// A Rule maps client certificates onto allowed schemas.
+type Rule struct {
+	// A pattern to match against the subject's common name.
+	CommonName string `json:"commonName,omitempty"`
+	// A pattern to match against the full subject, e.g. "CN=foo,O=Bar".
+	Subject string `json:"subject,omitempty"`
+	// A pattern to match against any DNS, email, IP, or URI SAN.
+	SAN string `json:"san,omitempty"`
+	// Patterns to match against the requested schema.
+	Schemas []string `json:"schemas"`
+}
+
+// Rules is the top-level structure of the rules file.
+type Rules struct {
+	Rules []Rule `json:"rules"`
+}
+
+// compiledRule holds the compiled forms of a Rule's patterns.
+type compiledRule struct {
+	commonName *regexp.Regexp // May be nil.
+	subject    *regexp.Regexp // May be nil.
+	san        *regexp.Regexp // May be nil.
+	schemas    []*regexp.Regexp
+}
+
+type authenticator struct {
+	roots *x509.CertPool
+	rules []*compiledRule
+}
+
+var _ types.Authenticator = (*authenticator)(nil)
+
+// New constructs an Authenticator which will validate client
+// certificates against the PEM-encoded CA bundle and apply the rules
+// contained in the JSON file.
+func New(caFile, rulesFile string) (types.Authenticator, error) {
+	caBytes, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caBytes) {
+		return nil, errors.Errorf("no certificates found in %s", caFile)
+	}
+
+	rulesBytes, err := os.ReadFile(rulesFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var rules Rules
+	if err := json.Unmarshal(rulesBytes, &rules); err != nil {
+		return nil, errors.Wrap(err, rulesFile)
+	}
+
+	compiled, err := compile(rules)
+	if err != nil {
+		return nil, errors.Wrap(err, rulesFile)
+	}
+	return &authenticator{roots: roots, rules: compiled}, nil
+}
+
+// Check implements types.Authenticator. The bearer token is ignored;
+// the client certificates are retrieved from the context.
+func (a *authenticator) Check(ctx context.Context, schema ident.Schema, _ string) (bool, error) {
+	certs := httpauth.PeerCertificates(ctx)
+	if len(certs) == 0 {
+		return false, nil
+	}
+
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		Roots:         a.roots,
+	}); err != nil {
+		log.WithError(err).WithField("subject", leaf.Subject.String()).
+			Debug("invalid client certificate")
+		return false, nil
+	}
+
+	requested := strings.ToLower(schema.Canonical().Raw())
+	for _, rule := range a.rules {
+		if rule.matchesCert(leaf) && rule.matchesSchema(requested) {
+			log.WithFields(log.Fields{
+				"schema":  schema,
+				"subject": leaf.Subject.String(),
+			}).Debug("successful authorization")
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Diagnostic implements diag.Diagnostic.
+func (a *authenticator) Diagnostic(context.Context) any {
+	return map[string]any{"mtls": true, "rules": len(a.rules)}
+}
+
+// compile validates the rules and converts their patterns.
+func compile(rules Rules) ([]*compiledRule, error) {
+	ret := make([]*compiledRule, len(rules.Rules))
+	for idx, rule := range rules.Rules {
+		if rule.CommonName == "" && rule.Subject == "" && rule.SAN == "" {
+			return nil, errors.Errorf("rule %d: at least one certificate pattern is required", idx)
+		}
+		if len(rule.Schemas) == 0 {
+			return nil, errors.Errorf("rule %d: no schemas defined", idx)
+		}
+		c := &compiledRule{
+			commonName: compileGlob(rule.CommonName),
+			subject:    compileGlob(rule.Subject),
+			san:        compileGlob(rule.SAN),
+			schemas:    make([]*regexp.Regexp, len(rule.Schemas)),
+		}
+		for i, sch := range rule.Schemas {
+			c.schemas[i] = compileGlob(strings.ToLower(sch))
+		}
+		ret[idx] = c
+	}
+	return ret, nil
+}
+
+// compileGlob converts a pattern containing * and ? wildcards into an
+// anchored regular expression. An empty pattern returns nil.
+func compileGlob(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// matchesCert returns true if all patterns in the rule match.
+func (r *compiledRule) matchesCert(cert *x509.Certificate) bool {
+	if r.commonName != nil && !r.commonName.MatchString(cert.Subject.CommonName) {
+		return false
+	}
+	if r.subject != nil && !r.subject.MatchString(cert.Subject.String()) {
+		return false
+	}
+	if r.san != nil && !r.matchesSAN(cert) {
+		return false
+	}
+	return true
+}
+
+// matchesSAN returns true if any SAN in the certificate matches.
+func (r *compiledRule) matchesSAN(cert *x509.Certificate) bool {
+	for _, name := range cert.DNSNames {
+		if r.san.MatchString(name) {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if r.san.MatchString(email) {
+			return true
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if r.san.MatchString(ip.String()) {
+			return true
+		}
+	}
+	for _, u := range cert.URIs {
+		if r.san.MatchString(u.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSchema returns true if the lower-cased schema name matches
+// any of the rule's schema patterns.
+func (r *compiledRule) matchesSchema(requested string) bool {
+	for _, sch := range r.schemas {
+		if sch.MatchString(requested) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/httpauth"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T) *testCA {
+	r := require.New(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	tmpl := &x509.Certificate{
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		NotAfter:              time.Now().Add(time.Hour),
+		NotBefore:             time.Now().Add(-time.Hour),
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	r.NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	r.NoError(err)
+	return &testCA{cert, key}
+}
+
+// issue creates a client certificate signed by the CA.
+func (ca *testCA) issue(t *testing.T, tmpl *x509.Certificate) *x509.Certificate {
+	r := require.New(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.SerialNumber = big.NewInt(2)
+	if tmpl.ExtKeyUsage == nil {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	r.NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	r.NoError(err)
+	return cert
+}
+
+func TestMTLS(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	dir := t.TempDir()
+
+	ca := newCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	r.NoError(os.WriteFile(caFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600))
+
+	rulesFile := filepath.Join(dir, "rules.json")
+	rules, err := json.Marshal(&Rules{Rules: []Rule{
+		{CommonName: "changefeed-*", Schemas: []string{"DB.Public"}},
+		{SAN: "spiffe://example.com/ns/prod/*", Schemas: []string{"*.public"}},
+		{CommonName: "both", Subject: "*O=Example*", Schemas: []string{"other.public"}},
+	}})
+	r.NoError(err)
+	r.NoError(os.WriteFile(rulesFile, rules, 0600))
+
+	auth, err := New(caFile, rulesFile)
+	r.NoError(err)
+
+	target := ident.MustSchema(ident.New("db"), ident.Public)
+	other := ident.MustSchema(ident.New("other"), ident.Public)
+	spiffe, err := url.Parse("spiffe://example.com/ns/prod/sa/feed")
+	r.NoError(err)
+
+	tcs := []struct {
+		name   string
+		certs  []*x509.Certificate
+		schema ident.Schema
+		expect bool
+	}{
+		{name: "no cert", schema: target},
+		{
+			name:   "common name",
+			certs:  []*x509.Certificate{ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "changefeed-1"}})},
+			schema: target,
+			expect: true,
+		},
+		{
+			name:   "common name wrong schema",
+			certs:  []*x509.Certificate{ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "changefeed-1"}})},
+			schema: other,
+		},
+		{
+			name:   "uri san",
+			certs:  []*x509.Certificate{ca.issue(t, &x509.Certificate{URIs: []*url.URL{spiffe}})},
+			schema: other,
+			expect: true,
+		},
+		{
+			name: "all patterns must match",
+			certs: []*x509.Certificate{ca.issue(t, &x509.Certificate{
+				Subject: pkix.Name{CommonName: "both"}})},
+			schema: other,
+		},
+		{
+			name: "subject and common name",
+			certs: []*x509.Certificate{ca.issue(t, &x509.Certificate{
+				Subject: pkix.Name{CommonName: "both", Organization: []string{"Example"}}})},
+			schema: other,
+			expect: true,
+		},
+		{
+			name: "wrong key usage",
+			certs: []*x509.Certificate{ca.issue(t, &x509.Certificate{
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+				Subject:     pkix.Name{CommonName: "changefeed-1"}})},
+			schema: target,
+		},
+		{
+			name:   "untrusted issuer",
+			certs:  []*x509.Certificate{newCA(t).issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "changefeed-1"}})},
+			schema: target,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.certs != nil {
+				ctx = httpauth.WithPeerCertificates(ctx, tc.certs)
+			}
+			ok, err := auth.Check(ctx, tc.schema, "")
+			a.NoError(err)
+			a.Equal(tc.expect, ok)
+		})
+	}
+}
+
+func TestCompileRules(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := compile(Rules{Rules: []Rule{{Schemas: []string{"*"}}}})
+	a.ErrorContains(err, "certificate pattern")
+
+	_, err = compile(Rules{Rules: []Rule{{CommonName: "foo"}}})
+	a.ErrorContains(err, "no schemas")
+
+	glob := compileGlob("a?c.*")
+	a.True(glob.MatchString("abc.public"))
+	a.False(glob.MatchString("abbc.public"))
+	a.False(glob.MatchString("xabc.public"))
+	a.Nil(compileGlob(""))
+}
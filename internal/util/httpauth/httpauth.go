@@ -14,15 +14,140 @@
 //
 // SPDX-License-Identifier: Apache-2.0
 
-// Package httpauth contains a common function for extracting
+// Package httpauth contains common functions for extracting
 // credentials from an HTTP request.
 package httpauth
 
 import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"io"
 	"net/http"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
+// maxBodySize limits the number of bytes that will be buffered by
+// [Body].
+const maxBodySize = 64 << 20
+
+type requestKey struct{}
+
+// requestInfo holds the request that was associated with a context by
+// [Handler]. The fields may be overridden for testing.
+type requestInfo struct {
+	body    []byte
+	certs   []*x509.Certificate
+	header  http.Header
+	method  string
+	path    string
+	read    bool
+	request *http.Request
+}
+
+func infoFrom(ctx context.Context) *requestInfo {
+	ret, _ := ctx.Value(requestKey{}).(*requestInfo)
+	return ret
+}
+
+// Body returns the body of the request that was associated with the
+// context by [Handler]. The body is buffered, so that it may still be
+// consumed by the downstream handler. This function returns nil if no
+// request is associated with the context.
+func Body(ctx context.Context) ([]byte, error) {
+	info := infoFrom(ctx)
+	if info == nil {
+		return nil, nil
+	}
+	if info.read || info.request == nil || info.request.Body == nil {
+		return info.body, nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(info.request.Body, maxBodySize+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(buf) > maxBodySize {
+		return nil, errors.Errorf("request body exceeds %d bytes", maxBodySize)
+	}
+	_ = info.request.Body.Close()
+	info.request.Body = io.NopCloser(bytes.NewReader(buf))
+	info.body = buf
+	info.read = true
+	return buf, nil
+}
+
+// Handler returns a handler that makes the request's TLS client
+// certificates, headers, and body available via the request's context.
+// This allows an Authenticator to inspect the request without changing
+// its signature.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info := &requestInfo{
+			header: req.Header,
+			method: req.Method,
+			path:   req.URL.EscapedPath(),
+		}
+		if req.TLS != nil {
+			info.certs = req.TLS.PeerCertificates
+		}
+		req = req.WithContext(context.WithValue(req.Context(), requestKey{}, info))
+		info.request = req
+		h.ServeHTTP(w, req)
+	})
+}
+
+// Header returns the headers of the request that was associated with
+// the context by [Handler], or nil if there is no such request.
+func Header(ctx context.Context) http.Header {
+	if info := infoFrom(ctx); info != nil {
+		return info.header
+	}
+	return nil
+}
+
+// PeerCertificates returns the TLS client certificates of the request
+// that was associated with the context by [Handler]. The first element
+// is the leaf certificate. This function returns nil if the client did
+// not present a certificate.
+func PeerCertificates(ctx context.Context) []*x509.Certificate {
+	if info := infoFrom(ctx); info != nil {
+		return info.certs
+	}
+	return nil
+}
+
+// WithPeerCertificates returns a context that carries the TLS client
+// certificates. This is intended for testing.
+func WithPeerCertificates(ctx context.Context, certs []*x509.Certificate) context.Context {
+	return context.WithValue(ctx, requestKey{}, &requestInfo{certs: certs})
+}
+
+// Request returns the method and escaped path of the request that was
+// associated with the context by [Handler]. Empty strings will be
+// returned if there is no request.
+func Request(ctx context.Context) (method, path string) {
+	if info := infoFrom(ctx); info != nil {
+		return info.method, info.path
+	}
+	return "", ""
+}
+
+// WithSignedRequest returns a context that carries the method, path,
+// headers, and body of a request. This is intended for testing.
+func WithSignedRequest(
+	ctx context.Context, method, path string, header http.Header, body []byte,
+) context.Context {
+	return context.WithValue(ctx, requestKey{}, &requestInfo{
+		body:   body,
+		header: header,
+		method: method,
+		path:   path,
+		read:   true,
+	})
+}
+
 // Token returns the bearer authorization header or the access_token
 // HTTP parameter associated with the request. If an authorization query
 // parameter is used, the request will be updated to delete that
@@ -0,0 +1,56 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package httpauth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerBody(t *testing.T) {
+	a := assert.New(t)
+
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		a.Equal("value", Header(ctx).Get("X-Test"))
+		a.Nil(PeerCertificates(ctx))
+		method, path := Request(ctx)
+		a.Equal(http.MethodPost, method)
+		a.Equal("/db/my%20schema", path)
+
+		// The body can be read repeatedly by authenticators.
+		for i := 0; i < 2; i++ {
+			buf, err := Body(ctx)
+			a.NoError(err)
+			a.Equal("hello world", string(buf))
+		}
+
+		// The body is still available to the handler.
+		buf, err := io.ReadAll(req.Body)
+		a.NoError(err)
+		a.Equal("hello world", string(buf))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/db/my%20schema", strings.NewReader("hello world"))
+	req.Header.Set("X-Test", "value")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
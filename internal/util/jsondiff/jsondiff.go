@@ -0,0 +1,295 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package jsondiff applies the partial updates to JSON documents that
+// MySQL writes to its binlog when binlog_row_value_options=PARTIAL_JSON
+// is set.
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Op is the kind of change described by a Diff. The values match those
+// used by MySQL's binary encoding.
+type Op byte
+
+// The operations that may be applied to a document.
+const (
+	// Replace changes the value at an existing path.
+	Replace Op = iota
+	// Insert adds an object member or inserts an array element.
+	Insert
+	// Remove deletes an object member or an array element.
+	Remove
+)
+
+func (o Op) String() string {
+	switch o {
+	case Replace:
+		return "replace"
+	case Insert:
+		return "insert"
+	case Remove:
+		return "remove"
+	default:
+		return fmt.Sprintf("Op(%d)", byte(o))
+	}
+}
+
+// A Diff describes a single change to a JSON document.
+type Diff struct {
+	Op    Op
+	Path  string          // A MySQL JSON path expression, e.g. $.foo[1]
+	Value json.RawMessage // Unused for Remove.
+}
+
+// Apply returns a copy of the document with the diffs applied in order.
+func Apply(doc []byte, diffs []Diff) ([]byte, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	for _, diff := range diffs {
+		legs, err := parsePath(diff.Path)
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if diff.Op != Remove {
+			value, err = decode(diff.Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		root, err = apply(root, legs, diff.Op, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not %s %s", diff.Op, diff.Path)
+		}
+	}
+	ret, err := json.Marshal(root)
+	return ret, errors.WithStack(err)
+}
+
+// apply recurses into the node and returns its replacement.
+func apply(node any, legs []leg, op Op, value any) (any, error) {
+	if len(legs) == 0 {
+		if op != Replace {
+			return nil, errors.New("the document root can only be replaced")
+		}
+		return value, nil
+	}
+	l, rest := legs[0], legs[1:]
+
+	if l.member {
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("member %q of a non-object", l.key)
+		}
+		child, found := obj[l.key]
+		if len(rest) > 0 {
+			if !found {
+				return nil, errors.Errorf("member %q not found", l.key)
+			}
+			next, err := apply(child, rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			obj[l.key] = next
+			return obj, nil
+		}
+		switch op {
+		case Replace:
+			if !found {
+				return nil, errors.Errorf("member %q not found", l.key)
+			}
+			obj[l.key] = value
+		case Insert:
+			obj[l.key] = value
+		case Remove:
+			delete(obj, l.key)
+		}
+		return obj, nil
+	}
+
+	arr, ok := node.([]any)
+	if !ok {
+		return nil, errors.Errorf("index %s of a non-array", l)
+	}
+	idx := l.index
+	if l.last {
+		idx = len(arr) - 1 - l.index
+	}
+	if len(rest) > 0 || op != Insert {
+		if idx < 0 || idx >= len(arr) {
+			return nil, errors.Errorf("index %s out of range", l)
+		}
+	}
+	if len(rest) > 0 {
+		next, err := apply(arr[idx], rest, op, value)
+		if err != nil {
+			return nil, err
+		}
+		arr[idx] = next
+		return arr, nil
+	}
+	switch op {
+	case Replace:
+		arr[idx] = value
+	case Insert:
+		// Inserting past the end of an array appends to it.
+		if idx < 0 {
+			idx = 0
+		} else if idx > len(arr) {
+			idx = len(arr)
+		}
+		arr = append(arr, nil)
+		copy(arr[idx+1:], arr[idx:])
+		arr[idx] = value
+	case Remove:
+		arr = append(arr[:idx], arr[idx+1:]...)
+	}
+	return arr, nil
+}
+
+// decode parses a JSON value, preserving the exact representation of
+// numbers.
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var ret any
+	if err := dec.Decode(&ret); err != nil {
+		return nil, errors.Wrap(err, "could not decode JSON value")
+	}
+	return ret, nil
+}
+
+// A leg is a single step in a path expression.
+type leg struct {
+	index  int    // An array index.
+	key    string // An object member name.
+	last   bool   // If true, index is relative to the end of the array.
+	member bool   // If true, key is valid, otherwise index is.
+}
+
+func (l leg) String() string {
+	switch {
+	case l.member:
+		return strconv.Quote(l.key)
+	case l.last && l.index == 0:
+		return "[last]"
+	case l.last:
+		return fmt.Sprintf("[last-%d]", l.index)
+	default:
+		return fmt.Sprintf("[%d]", l.index)
+	}
+}
+
+// parsePath splits a path expression into its legs. Wildcards and
+// ranges are not supported, since they never appear in a binlog diff.
+func parsePath(path string) ([]leg, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.Errorf("path %q must begin with $", path)
+	}
+	var ret []leg
+	rest := strings.TrimSpace(path[1:])
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = strings.TrimSpace(rest[1:])
+			if strings.HasPrefix(rest, `"`) {
+				end := closingQuote(rest)
+				if end < 0 {
+					return nil, errors.Errorf("unterminated member name in path %q", path)
+				}
+				key, err := strconv.Unquote(rest[:end+1])
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid member name in path %q", path)
+				}
+				ret = append(ret, leg{key: key, member: true})
+				rest = rest[end+1:]
+			} else {
+				end := strings.IndexAny(rest, ".[ ")
+				if end < 0 {
+					end = len(rest)
+				}
+				if end == 0 {
+					return nil, errors.Errorf("empty member name in path %q", path)
+				}
+				ret = append(ret, leg{key: rest[:end], member: true})
+				rest = rest[end:]
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, errors.Errorf("unterminated array index in path %q", path)
+			}
+			l, err := parseIndex(strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid array index in path %q", path)
+			}
+			ret = append(ret, l)
+			rest = rest[end+1:]
+		default:
+			return nil, errors.Errorf("unexpected %q in path %q", rest[0], path)
+		}
+		rest = strings.TrimSpace(rest)
+	}
+	return ret, nil
+}
+
+// parseIndex parses the contents of an array index: N, last, or last-N.
+func parseIndex(s string) (leg, error) {
+	if s == "last" {
+		return leg{last: true}, nil
+	}
+	if offset, ok := strings.CutPrefix(s, "last"); ok {
+		offset = strings.TrimSpace(offset)
+		if !strings.HasPrefix(offset, "-") {
+			return leg{}, errors.Errorf("unexpected %q", s)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(offset[1:]))
+		if err != nil || n < 0 {
+			return leg{}, errors.Errorf("unexpected %q", s)
+		}
+		return leg{index: n, last: true}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return leg{}, errors.Errorf("unexpected %q", s)
+	}
+	return leg{index: n}, nil
+}
+
+// closingQuote returns the index of the double-quote that terminates
+// the string which begins at s[0], or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package jsondiff
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	tcs := []struct {
+		doc      string
+		diffs    []Diff
+		expected string
+		err      string
+	}{
+		{
+			doc:      `{"a":1,"b":[1,2,3]}`,
+			diffs:    []Diff{{Op: Replace, Path: "$.a", Value: json.RawMessage(`"x"`)}},
+			expected: `{"a":"x","b":[1,2,3]}`,
+		},
+		{
+			doc: `{"a":1,"b":[1,2,3]}`,
+			diffs: []Diff{
+				{Op: Replace, Path: "$.b[1]", Value: json.RawMessage(`{"c":true}`)},
+				{Op: Insert, Path: "$.b[1].d", Value: json.RawMessage(`null`)},
+			},
+			expected: `{"a":1,"b":[1,{"c":true,"d":null},3]}`,
+		},
+		{
+			doc: `{"a":1,"b":[1,2,3]}`,
+			diffs: []Diff{
+				{Op: Insert, Path: "$.b[0]", Value: json.RawMessage(`0`)},
+				{Op: Insert, Path: "$.b[10]", Value: json.RawMessage(`4`)},
+				{Op: Remove, Path: "$.a"},
+			},
+			expected: `{"b":[0,1,2,3,4]}`,
+		},
+		{
+			doc:      `{"a b":[1,2,3]}`,
+			diffs:    []Diff{{Op: Remove, Path: `$."a b"[last]`}},
+			expected: `{"a b":[1,2]}`,
+		},
+		{
+			doc:      `[1,2,3]`,
+			diffs:    []Diff{{Op: Replace, Path: `$[last-2]`, Value: json.RawMessage(`1.50`)}},
+			expected: `[1.50,2,3]`,
+		},
+		{
+			doc:   `{"a":1}`,
+			diffs: []Diff{{Op: Replace, Path: "$.b", Value: json.RawMessage(`1`)}},
+			err:   `could not replace $.b: member "b" not found`,
+		},
+		{
+			doc:   `{"a":1}`,
+			diffs: []Diff{{Op: Remove, Path: "$.a[0]"}},
+			err:   `could not remove $.a[0]: index [0] of a non-array`,
+		},
+		{
+			doc:   `{"a":1}`,
+			diffs: []Diff{{Op: Remove, Path: "a"}},
+			err:   `path "a" must begin with $`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.expected+tc.err, func(t *testing.T) {
+			a := assert.New(t)
+			out, err := Apply([]byte(tc.doc), tc.diffs)
+			if tc.err != "" {
+				a.EqualError(err, tc.err)
+				return
+			}
+			if a.NoError(err) {
+				a.JSONEq(tc.expected, string(out))
+			}
+		})
+	}
+}
@@ -53,6 +53,21 @@ type HasDiagnostics interface {
 	GetDiagnostics() *diag.Diagnostics
 }
 
+// HasHandlers allows the object to register additional endpoints,
+// such as administrative controls.
+type HasHandlers interface {
+	AddHandlers(auth types.Authenticator, mux *http.ServeMux)
+}
+
+// HasStopPoint allows the object to report that replication has
+// stopped at a point requested by an operator, which will cause the
+// command to exit.
+type HasStopPoint interface {
+	// StopPoint returns the requested stop point, if any, and a
+	// channel that is closed once replication has stopped there.
+	StopPoint() (requested string, reached <-chan struct{})
+}
+
 // HasServeMux allows the object to provide a [http.ServeMux] to bind
 // the endpoints to, if the [MetricsAddrFlag] is not set.
 type HasServeMux interface {
@@ -116,23 +131,49 @@ func New(t *Template) *cobra.Command {
 				diags = diag.New(stopper.From(cmd.Context()))
 			}
 
+			var extra []HasHandlers
+			if x, ok := started.(HasHandlers); ok {
+				extra = append(extra, x)
+			}
+
 			// Start metrics on a separate port or bind to an existing mux.
 			if metricsAddr != "" {
-				cancelServer, err := MetricsServer(auth, metricsAddr, diags)
+				cancelServer, err := MetricsServer(auth, metricsAddr, diags, extra...)
 				if err != nil {
 					return err
 				}
 				defer cancelServer()
 			} else if x, ok := started.(HasServeMux); ok {
-				AddHandlers(auth, x.GetServeMux(), diags)
+				AddHandlers(auth, x.GetServeMux(), diags, extra...)
 			}
 
 			if t.testCallback != nil {
 				t.testCallback()
 			}
-			// Wait for shutdown. The main function uses log.Exit()
-			// to call the above handler.
-			<-cmd.Context().Done()
+			// Wait for shutdown or for the stop point to be reached.
+			// The main function uses log.Exit() to call the above
+			// handler.
+			stopPoint, hasStopPoint := started.(HasStopPoint)
+			var reached <-chan struct{}
+			if hasStopPoint {
+				_, reached = stopPoint.StopPoint()
+			}
+			select {
+			case <-cmd.Context().Done():
+				// Report a failure if the requested stop point was not
+				// reached before the process was shut down.
+				if hasStopPoint {
+					if requested, _ := stopPoint.StopPoint(); requested != "" {
+						select {
+						case <-reached:
+						default:
+							return errors.Errorf("shut down before reaching stop point %s", requested)
+						}
+					}
+				}
+			case <-reached:
+				log.Info("replication has reached the requested stop point")
+			}
 			return nil
 		},
 	}
@@ -144,8 +185,11 @@ func New(t *Template) *cobra.Command {
 	return cmd
 }
 
-// AddHandlers populates the ServeMux with diagnostic endpoints.
-func AddHandlers(auth types.Authenticator, mux *http.ServeMux, diags *diag.Diagnostics) {
+// AddHandlers populates the ServeMux with diagnostic endpoints and any
+// endpoints provided by the extra handlers.
+func AddHandlers(
+	auth types.Authenticator, mux *http.ServeMux, diags *diag.Diagnostics, extra ...HasHandlers,
+) {
 	// The pprof handlers attach themselves to the system-default mux.
 	// The index page also assumes that the handlers are reachable from
 	// this specific prefix. It seems unlikely that this would collide
@@ -160,15 +204,18 @@ func AddHandlers(auth types.Authenticator, mux *http.ServeMux, diags *diag.Diagn
 				EnableOpenMetrics: true,
 				ErrorLog:          log.StandardLogger().WithField("promhttp", "true"),
 			})))
+	for _, x := range extra {
+		x.AddHandlers(auth, mux)
+	}
 	mux.Handle("/_/", http.NotFoundHandler()) // Reserve all under /_/
 }
 
 // MetricsServer starts a trivial HTTP server which runs until canceled.
 func MetricsServer(
-	auth types.Authenticator, bindAddr string, diags *diag.Diagnostics,
+	auth types.Authenticator, bindAddr string, diags *diag.Diagnostics, extra ...HasHandlers,
 ) (func(), error) {
 	mux := &http.ServeMux{}
-	AddHandlers(auth, mux, diags)
+	AddHandlers(auth, mux, diags, extra...)
 	mux.HandleFunc("/_/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
@@ -18,7 +18,10 @@ package stdserver
 
 import (
 	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/anyof"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/hmac"
 	"github.com/cockroachdb/cdc-sink/internal/util/auth/jwt"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/mtls"
 	"github.com/cockroachdb/cdc-sink/internal/util/auth/trust"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
@@ -27,7 +30,9 @@ import (
 )
 
 // Authenticator constructs a JWT-based authenticator,
-// or a no-op authenticator if Config.DisableAuth has been set.
+// or a no-op authenticator if Config.DisableAuth has been set. If
+// client certificates or signed requests are enabled, a request will be
+// allowed if any of its credentials are acceptable.
 func Authenticator(
 	ctx *stopper.Context,
 	diags *diag.Diagnostics,
@@ -41,7 +46,26 @@ func Authenticator(
 		log.Info("authentication disabled, any caller may write to the target database")
 		auth = trust.New()
 	} else {
-		auth, err = jwt.ProvideAuth(ctx, pool, stagingDB)
+		auth, err = jwt.ProvideAuth(ctx, &config.JWT, pool, stagingDB)
+		if err != nil {
+			return nil, err
+		}
+		delegates := []types.Authenticator{auth}
+		if config.TLSClientRules != "" {
+			certAuth, err := mtls.New(config.TLSClientCA, config.TLSClientRules)
+			if err != nil {
+				return nil, err
+			}
+			delegates = append(delegates, certAuth)
+		}
+		if config.HMAC.Enabled {
+			hmacAuth, err := hmac.ProvideAuth(ctx, &config.HMAC, pool, stagingDB)
+			if err != nil {
+				return nil, err
+			}
+			delegates = append(delegates, hmacAuth)
+		}
+		auth = anyof.New(delegates...)
 	}
 	if d, ok := auth.(diag.Diagnostic); ok {
 		if err := diags.Register("auth", d); err != nil {
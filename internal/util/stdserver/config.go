@@ -17,6 +17,8 @@
 package stdserver
 
 import (
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/hmac"
+	"github.com/cockroachdb/cdc-sink/internal/util/auth/jwt"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 )
@@ -26,7 +28,11 @@ type Config struct {
 	BindAddr           string
 	DisableAuth        bool
 	GenerateSelfSigned bool
+	HMAC               hmac.Config
+	JWT                jwt.Config
 	TLSCertFile        string
+	TLSClientCA        string
+	TLSClientRules     string
 	TLSPrivateKey      string
 }
 
@@ -42,6 +48,8 @@ func (c *Config) Bind(flags *pflag.FlagSet) {
 		"disableAuthentication",
 		false,
 		"disable authentication of incoming cdc-sink requests; not recommended for production.")
+	c.HMAC.Bind(flags)
+	c.JWT.Bind(flags)
 	flags.BoolVar(
 		&c.GenerateSelfSigned,
 		"tlsSelfSigned",
@@ -52,6 +60,16 @@ func (c *Config) Bind(flags *pflag.FlagSet) {
 		"tlsCertificate",
 		"",
 		"a path to a PEM-encoded TLS certificate chain")
+	flags.StringVar(
+		&c.TLSClientCA,
+		"tlsClientCA",
+		"",
+		"a path to a PEM-encoded CA bundle used to verify TLS client certificates")
+	flags.StringVar(
+		&c.TLSClientRules,
+		"tlsClientRules",
+		"",
+		"a path to a JSON file that maps TLS client certificates to allowed schemas")
 	flags.StringVar(
 		&c.TLSPrivateKey,
 		"tlsPrivateKey",
@@ -70,5 +88,14 @@ func (c *Config) Preflight() error {
 	if c.GenerateSelfSigned && c.TLSCertFile != "" {
 		return errors.New("self-signed certificate requested, but also specified a TLS certificate")
 	}
-	return nil
+	if (c.TLSClientCA == "") != (c.TLSClientRules == "") {
+		return errors.New("either both of tlsClientCA and tlsClientRules must be set, or none")
+	}
+	if c.TLSClientCA != "" && c.TLSCertFile == "" && !c.GenerateSelfSigned {
+		return errors.New("tlsClientCA requires TLS to be enabled")
+	}
+	if err := c.HMAC.Preflight(); err != nil {
+		return err
+	}
+	return c.JWT.Preflight()
 }
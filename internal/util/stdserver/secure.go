@@ -25,6 +25,7 @@ import (
 	"crypto/x509/pkix"
 	"math/big"
 	"net"
+	"os"
 	"time"
 
 	"github.com/pkg/errors"
@@ -32,8 +33,31 @@ import (
 
 // TLSConfig loads the certificate and key
 // from disk, to generate a self-signed localhost certificate, or to
-// return nil if TLS has been disabled.
+// return nil if TLS has been disabled. If a client CA bundle has been
+// configured, clients will be asked to present a certificate.
 func TLSConfig(config *Config) (*tls.Config, error) {
+	ret, err := serverTLSConfig(config)
+	if err != nil || ret == nil || config.TLSClientCA == "" {
+		return ret, err
+	}
+
+	caBytes, err := os.ReadFile(config.TLSClientCA)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBytes) {
+		return nil, errors.Errorf("no certificates found in %s", config.TLSClientCA)
+	}
+	// Client certificates are optional, since a bearer token may be
+	// used instead. The Authenticator will reject requests that lack
+	// any valid credential.
+	ret.ClientAuth = tls.VerifyClientCertIfGiven
+	ret.ClientCAs = pool
+	return ret, nil
+}
+
+func serverTLSConfig(config *Config) (*tls.Config, error) {
 	if config.TLSCertFile != "" && config.TLSPrivateKey != "" {
 		cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSPrivateKey)
 		if err != nil {
@@ -25,6 +25,7 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/httpauth"
 	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
 	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
 	"github.com/pkg/errors"
@@ -75,7 +76,7 @@ func New(
 	tlsConfig *tls.Config,
 ) *Server {
 	srv := &http.Server{
-		Handler:   h2c.NewHandler(mux, &http2.Server{}),
+		Handler:   h2c.NewHandler(httpauth.Handler(mux), &http2.Server{}),
 		TLSConfig: tlsConfig,
 	}
 
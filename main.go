@@ -27,15 +27,19 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/cockroachdb/cdc-sink/internal/cmd/checkpoint"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/dumphelp"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/dumptemplates"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/fslogical"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/licenses"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/mkjwt"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/multilogical"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/mylogical"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/pglogical"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/preflight"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/querylogical"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/start"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/status"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/version"
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/util/logfmt"
@@ -104,16 +108,20 @@ func main() {
 	f.CountVarP(&verbosity, "verbose", "v", "increase logging verbosity to debug; repeat for trace")
 
 	root.AddCommand(
+		checkpoint.Command(),
 		dumphelp.Command(),
 		dumptemplates.Command(),
 		fslogical.Command(),
 		licenses.Command(),
 		mkjwt.Command(),
+		multilogical.Command(),
 		mylogical.Command(),
 		pglogical.Command(),
 		preflight.Command(),
+		querylogical.Command(),
 		script.HelpCommand(),
 		start.Command(),
+		status.Command(),
 		version.Command(),
 	)
 